@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,11 +23,18 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/vknow360/otaship/backend/internal/auth"
+	"github.com/vknow360/otaship/backend/internal/cache"
 	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/gc"
 	"github.com/vknow360/otaship/backend/internal/handlers"
 	"github.com/vknow360/otaship/backend/internal/logger"
 	mid "github.com/vknow360/otaship/backend/internal/middleware"
+	"github.com/vknow360/otaship/backend/internal/migrator"
+	"github.com/vknow360/otaship/backend/internal/projectstorage"
+	"github.com/vknow360/otaship/backend/internal/rollout"
 	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/webhook"
 )
 
 var (
@@ -69,14 +77,14 @@ func main() {
 	defer db.Close()
 	queries := database.New(db)
 
-	providers := make(map[string]storage.Provider)
+	serverProviders := make(map[string]storage.Provider)
 
 	s3, err := storage.NewS3Provider()
 	if err != nil {
 		slog.Error("Failed to connect to S3", slog.String("error", err.Error()))
 	} else {
 		slog.Info("Connected to S3")
-		providers["s3"] = s3
+		serverProviders["s3"] = s3
 	}
 
 	cld, err := storage.NewCloudinaryProvider()
@@ -84,14 +92,26 @@ func main() {
 		slog.Error("Failed to connect to Cloudinary", slog.String("error", err.Error()))
 	} else {
 		slog.Info("Connected to Cloudinary")
-		providers["cloudinary"] = cld
+		serverProviders["cloudinary"] = cld
 	}
 
-	if len(providers) == 0 {
+	local, err := storage.NewLocalProvider()
+	if err != nil {
+		slog.Error("Failed to set up local storage", slog.String("error", err.Error()))
+	} else {
+		slog.Info("Using local storage")
+		serverProviders["local"] = local
+	}
+
+	if len(serverProviders) == 0 {
 		panic("No storage provider configured")
 	}
 
-	setDefaultProvider(queries, providers)
+	setDefaultProvider(queries, serverProviders)
+	providers := storage.NewRegistry(serverProviders)
+	projectStorage := setupProjectStorage(queries, providers)
+	handlers.SetSigningKeyStore(projectStorage)
+	sealSigningKeys(queries, projectStorage)
 
 	r := chi.NewRouter()
 	r.Use(logger.Middleware)
@@ -104,15 +124,17 @@ func main() {
 	}
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{
 			"Origin", "Content-Type", "Authorization", "X-API-Key",
 			"expo-platform", "expo-runtime-version", "expo-channel-name",
 			"expo-protocol-version", "expo-expect-signature",
 			"expo-current-update-id", "expo-embedded-update-id",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata",
 		},
 		ExposedHeaders: []string{
 			"expo-protocol-version", "expo-sfv-version", "expo-signature",
+			"Location", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Expires",
 		},
 		MaxAge: 300,
 	}))
@@ -150,11 +172,36 @@ func main() {
 		w.Write([]byte(html))
 	})
 
-	r.Mount("/api", apiRouter(queries))
-	r.Mount("/api/project", projectRouter(db, queries, providers))
-	r.Mount("/api/admin", adminRouter(db, queries, providers))
+	// With ASSET_URL_SECRET set this route only serves signed URLs.
+	if local != nil {
+		r.Get("/assets/*", handlers.ServeLocalAsset(local))
+	}
+
+	setupManifestCache(db)
+
+	r.Mount("/api", apiRouter(queries, bundlePatcher(queries, providers)))
+	r.Mount("/api/telemetry", telemetryRouter(db, queries))
+	if signer := assetURLSigner(port); signer != nil {
+		handlers.SetAssetURLSigner(signer)
+		r.Mount("/api/assets", assetRouter(queries, providers))
+	}
+	gcGracePeriod := envDuration("STORAGE_GC_GRACE_PERIOD", gc.DefaultGracePeriod)
+	if gcGracePeriod <= handlers.DirectUploadTTL {
+		slog.Warn("STORAGE_GC_GRACE_PERIOD should be longer than an hour, or direct uploads may lose blobs before they are finalized")
+	}
+	collector := gc.NewCollector(db, queries, providers, gcGracePeriod)
+
+	r.Mount("/api/auth", authRouter(queries))
+	uploads := uploadConfig()
+	r.Mount("/api/project", projectRouter(db, queries, providers, uploads))
+	r.Mount("/api/admin", adminRouter(db, queries, providers, projectStorage, collector))
 
 	startAggregationJob(db)
+	startGCJob(collector)
+	startRolloutScheduler(rollout.NewScheduler(db, queries, handlers.InvalidateManifestCache))
+	startWebhookDispatcher(webhook.NewDispatcher(queries))
+	startStorageMigrations(migrator.New(db, queries, providers, handlers.InvalidateManifestCache))
+	startUploadCleanup(queries, uploads.Dir)
 
 	// Start server
 	srv := &http.Server{
@@ -180,7 +227,7 @@ func main() {
 	srv.Shutdown(ctx)
 }
 
-func apiRouter(queries *database.Queries) http.Handler {
+func apiRouter(queries *database.Queries, patcher *handlers.BundlePatcher) http.Handler {
 	r := chi.NewRouter()
 
 	limiter := httprate.NewRateLimiter(10, time.Minute, httprate.WithKeyFuncs(httprate.KeyByIP), httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
@@ -189,61 +236,214 @@ func apiRouter(queries *database.Queries) http.Handler {
 		json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
 	}))
 	r.Use(limiter.Handler)
-	r.Get("/manifest/{project_id}", handlers.CheckForUpdates(queries))
+	r.Get("/manifest/{project_id}", handlers.CheckForUpdates(queries, os.Getenv("DEVICE_ID_HEADER"), patcher))
 	r.Get("/validate-key", handlers.ValidateAPIKey(queries))
 
 	return r
 }
 
-func projectRouter(db *pgxpool.Pool, queries *database.Queries, providers map[string]storage.Provider) http.Handler {
+// telemetryRouter takes launch reports from apps. It gets its own limit
+// because many devices can share one IP.
+func telemetryRouter(db *pgxpool.Pool, queries *database.Queries) http.Handler {
+	r := chi.NewRouter()
+	r.Use(httprate.LimitByIP(120, time.Minute))
+	r.Post("/{project_id}", handlers.RecordTelemetry(db, queries, os.Getenv("DEVICE_ID_HEADER")))
+	return r
+}
+
+func assetRouter(queries *database.Queries, providers *storage.Registry) http.Handler {
+	r := chi.NewRouter()
+	// Devices fetch every asset of an update at once, often from behind
+	// the same NAT.
+	r.Use(httprate.LimitByIP(600, time.Minute))
+	r.Get("/{project_id}/{hash}", handlers.ProxyAsset(queries, providers))
+	return r
+}
+
+func projectRouter(db *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, uploads handlers.UploadConfig) http.Handler {
 	r := chi.NewRouter()
 	r.Use(httprate.LimitByIP(30, time.Minute))
 	r.Use(mid.ProjectKeyOnly(queries))
+	r.Use(mid.Audit(queries))
 	r.Get("/me", handlers.GetMe(queries))
 
-	r.Post("/{project_id}/updates/{update_id}/upload", handlers.UploadAsset(db, queries, providers))
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireScope(auth.ScopeRead))
+		r.Get("/updates", handlers.ListProjectUpdates(queries))
+		r.Get("/updates/{update_id}/manifest", handlers.GetUpdateManifest(queries))
+		r.Get("/updates/{update_id}/rollout", handlers.GetRollout(queries))
+		r.Get("/updates/{update_id}/telemetry", handlers.GetUpdateTelemetry(queries))
+		r.Get("/branches", handlers.ListBranches(queries))
+		r.Get("/channels", handlers.ListChannels(queries))
+		r.Get("/auto-rollback", handlers.GetAutoRollbackPolicy(queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireScope(auth.ScopePublish))
+		r.Post("/{project_id}/updates/{update_id}/upload", handlers.UploadAsset(db, queries, providers, uploads))
+		r.Route("/{project_id}/updates/{update_id}/uploads", func(r chi.Router) {
+			r.Post("/", handlers.CreateUploadSession(queries, uploads))
+			r.Head("/{upload_id}", handlers.GetUploadSession(queries))
+			r.Patch("/{upload_id}", handlers.PatchUploadSession(queries, uploads))
+			r.Delete("/{upload_id}", handlers.DeleteUploadSession(queries, uploads))
+			r.Post("/{upload_id}/finalize", handlers.FinalizeUploadSession(db, queries, providers, uploads))
+		})
+		r.Route("/{project_id}/updates/{update_id}/direct-uploads", func(r chi.Router) {
+			r.Post("/", handlers.CreateDirectUpload(db, queries, providers, uploads))
+			r.Put("/{direct_upload_id}/blobs/{hash}", handlers.PutDirectUploadBlob(queries, providers, uploads))
+			r.Post("/{direct_upload_id}/finalize", handlers.FinalizeDirectUpload(db, queries, providers))
+		})
+		r.Post("/updates", handlers.CreateUpdate(db, queries))
+		r.Post("/updates/{update_id}/promote", handlers.PromoteUpdate(db, queries))
+		r.Post("/updates/{update_id}/signature", handlers.SubmitUpdateSignature(db, queries))
+		r.Put("/updates/{update_id}/rollout/schedule", handlers.SetRolloutSchedule(db, queries))
+		r.Post("/updates/{update_id}/rollout/pause", handlers.PauseRollout(db, queries))
+		r.Post("/updates/{update_id}/rollout/resume", handlers.ResumeRollout(db, queries))
+		r.Post("/branches", handlers.CreateBranch(queries))
+		r.Post("/channels", handlers.CreateChannel(queries))
+		r.Patch("/channels/{channel_name}", handlers.UpdateChannel(queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireScope(auth.ScopeRollback))
+		r.Post("/updates/{update_id}/rollback", handlers.CreateRollback(db, queries))
+		r.Post("/{project_id}/rollback-to-embedded", handlers.CreateRollbackToEmbedded(db, queries))
+		r.Put("/auto-rollback", handlers.SetAutoRollbackPolicy(queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireScope(auth.ScopeDelete))
+		r.Delete("/updates/{update_id}", handlers.DeleteProjectUpdate(db, queries))
+		r.Delete("/branches/{branch_name}", handlers.DeleteBranch(queries))
+		r.Delete("/channels/{channel_name}", handlers.DeleteChannel(queries))
+	})
+	return r
+}
 
-	r.Post("/updates", handlers.CreateUpdate(db, queries))
-	r.Get("/updates", handlers.ListProjectUpdates(queries))
-	r.Delete("/updates/{update_id}", handlers.DeleteProjectUpdate(queries, providers))
-	r.Post("/updates/{update_id}/rollback", handlers.CreateRollback(db, queries))
-	r.Post("/{project_id}/rollback-to-embedded", handlers.CreateRollbackToEmbedded(db, queries))
+// authRouter issues and ends user sessions for the admin API.
+func authRouter(queries *database.Queries) http.Handler {
+	r := chi.NewRouter()
+	r.Use(httprate.LimitByIP(10, time.Minute))
+	r.Post("/login", handlers.Login(queries, envDuration("SESSION_TTL", 30*24*time.Hour)))
+	r.Post("/logout", handlers.Logout(queries))
 	return r
 }
 
-func adminRouter(db *pgxpool.Pool, queries *database.Queries, providers map[string]storage.Provider) http.Handler {
+// adminRouter serves the dashboard and user API. The root admin token may
+// do everything; users need a role on the organization or project a route
+// targets, see mid.RequireRole.
+func adminRouter(db *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, projectStorage *projectstorage.Store, collector *gc.Collector) http.Handler {
 	r := chi.NewRouter()
 	r.Use(httprate.LimitByIP(100, time.Minute))
-	r.Use(mid.AdminOnly(accessToken))
+	r.Use(mid.AdminAuth(accessToken, queries))
+	r.Use(mid.Audit(queries))
 
 	r.Get("/verify", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`{"status": "ok"}`))
 	})
+	r.Get("/me", handlers.GetCurrentUser(queries))
 
+	// Listing and creating filter by the caller's memberships themselves.
 	r.Get("/projects", handlers.GetProjects(queries))
 	r.Post("/projects", handlers.CreateProject(queries))
-	r.Get("/projects/{project_id}", handlers.GetProjectByID(queries))
-	r.Patch("/projects/{project_id}", handlers.UpdateProject(queries))
-	r.Delete("/projects/{project_id}", handlers.DeleteProject(queries))
-	r.Get("/projects/{project_id}/stats", handlers.GetProjectStats(queries))
-	r.Post("/projects/{project_id}/keys", handlers.CreateAPIKey(queries))
-	r.Get("/projects/{project_id}/keys", handlers.ListAPIKeys(queries))
-	r.Delete("/projects/{project_id}/keys/{key_id}", handlers.DeleteAPIKey(queries))
-
-	r.Get("/updates", handlers.ListUpdates(queries))
-	r.Get("/updates/{update_id}", handlers.GetUpdate(queries))
-	r.Get("/updates/{update_id}/assets", handlers.ListUpdateAssets(queries))
-	r.Patch("/updates/{update_id}/rollout", handlers.UpdateRolloutPercentage(queries))
-	r.Delete("/updates/{update_id}", handlers.DeleteUpdate(queries, providers))
-	r.Post("/updates/{update_id}/rollback", handlers.CreateRollback(db, queries))
-	r.Post("/projects/{project_id}/rollback-to-embedded", handlers.CreateRollbackToEmbedded(db, queries))
-
-	r.Get("/settings", handlers.GetSettings(queries, providers))
-	r.Put("/settings", handlers.UpdateSetting(queries))
-	r.Get("/settings/storage/usage", handlers.GetStorageUsage(providers))
-	r.Get("/settings/{key}", handlers.GetSetting(queries))
-
-	r.Get("/stats", handlers.GetGlobalStats(queries))
+	r.Get("/organizations", handlers.ListOrganizations(queries))
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireRole(queries, auth.RoleViewer))
+
+		r.Get("/organizations/{org_id}/members", handlers.ListOrganizationMembers(queries))
+
+		r.Get("/projects/{project_id}", handlers.GetProjectByID(queries))
+		r.Get("/projects/{project_id}/stats", handlers.GetProjectStats(queries))
+		r.Get("/projects/{project_id}/members", handlers.ListProjectMembers(queries))
+		r.Get("/projects/{project_id}/certificates", handlers.ListSigningCertificates(queries))
+		r.Get("/projects/{project_id}/branches", handlers.ListBranches(queries))
+		r.Get("/projects/{project_id}/channels", handlers.ListChannels(queries))
+		r.Get("/projects/{project_id}/auto-rollback", handlers.GetAutoRollbackPolicy(queries))
+
+		r.Get("/updates", handlers.ListUpdates(queries))
+		r.Get("/updates/{update_id}", handlers.GetUpdate(queries))
+		r.Get("/updates/{update_id}/assets", handlers.ListUpdateAssets(queries))
+		r.Get("/updates/{update_id}/rollout", handlers.GetRollout(queries))
+		r.Get("/updates/{update_id}/telemetry", handlers.GetUpdateTelemetry(queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireRole(queries, auth.RolePublisher))
+
+		r.Post("/projects/{project_id}/branches", handlers.CreateBranch(queries))
+		r.Delete("/projects/{project_id}/branches/{branch_name}", handlers.DeleteBranch(queries))
+		r.Post("/projects/{project_id}/channels", handlers.CreateChannel(queries))
+		r.Patch("/projects/{project_id}/channels/{channel_name}", handlers.UpdateChannel(queries))
+		r.Delete("/projects/{project_id}/channels/{channel_name}", handlers.DeleteChannel(queries))
+		r.Post("/projects/{project_id}/rollback-to-embedded", handlers.CreateRollbackToEmbedded(db, queries))
+
+		r.Patch("/updates/{update_id}/rollout", handlers.UpdateRolloutPercentage(db, queries))
+		r.Put("/updates/{update_id}/rollout/schedule", handlers.SetRolloutSchedule(db, queries))
+		r.Post("/updates/{update_id}/rollout/pause", handlers.PauseRollout(db, queries))
+		r.Post("/updates/{update_id}/rollout/resume", handlers.ResumeRollout(db, queries))
+		r.Delete("/updates/{update_id}", handlers.DeleteUpdate(db, queries))
+		r.Post("/updates/{update_id}/rollback", handlers.CreateRollback(db, queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireRole(queries, auth.RoleAdmin))
+
+		r.Post("/organizations/{org_id}/members", handlers.SetOrganizationMember(db, queries))
+		r.Delete("/organizations/{org_id}/members/{user_id}", handlers.RemoveOrganizationMember(queries))
+
+		r.Patch("/projects/{project_id}", handlers.UpdateProject(queries))
+		r.Post("/projects/{project_id}/members", handlers.SetProjectMember(db, queries))
+		r.Delete("/projects/{project_id}/members/{user_id}", handlers.RemoveProjectMember(queries))
+		r.Post("/projects/{project_id}/keys", handlers.CreateAPIKey(queries))
+		r.Get("/projects/{project_id}/keys", handlers.ListAPIKeys(queries))
+		r.Delete("/projects/{project_id}/keys/{key_id}", handlers.DeleteAPIKey(queries))
+		r.Get("/projects/{project_id}/signing-keys", handlers.ListSigningKeys(queries))
+		r.Post("/projects/{project_id}/signing-keys", handlers.CreateSigningKey(db, queries))
+		r.Post("/projects/{project_id}/signing-keys/rotate", handlers.RotateSigningKey(db, queries))
+		r.Post("/projects/{project_id}/signing-keys/{key_id}/retire", handlers.RetireSigningKey(queries))
+		r.Post("/projects/{project_id}/certificates", handlers.CreateSigningCertificate(queries))
+		r.Delete("/projects/{project_id}/certificates/{key_id}", handlers.DeleteSigningCertificate(queries))
+		r.Put("/projects/{project_id}/auto-rollback", handlers.SetAutoRollbackPolicy(queries))
+		r.Get("/projects/{project_id}/storage", handlers.GetProjectStorage(queries, projectStorage))
+		r.Put("/projects/{project_id}/storage", handlers.SetProjectStorage(db, queries, providers, projectStorage))
+		r.Delete("/projects/{project_id}/storage", handlers.DeleteProjectStorage(queries))
+		r.Post("/projects/{project_id}/storage/check", handlers.CheckProjectStorage(queries, providers, projectStorage))
+		r.Post("/projects/{project_id}/webhooks", handlers.CreateWebhook(queries))
+		r.Get("/projects/{project_id}/webhooks", handlers.ListWebhooks(queries))
+		r.Delete("/projects/{project_id}/webhooks/{webhook_id}", handlers.DeleteWebhook(queries))
+		r.Get("/projects/{project_id}/webhooks/{webhook_id}/deliveries", handlers.ListWebhookDeliveries(queries))
+
+		// Users must pass ?project_id=; the root token may list everything.
+		r.Get("/audit", handlers.ListAuditEvents(queries))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RequireRole(queries, auth.RoleOwner))
+
+		r.Delete("/projects/{project_id}", handlers.DeleteProject(db, queries))
+	})
+
+	// Server-wide settings stay with the root token.
+	r.Group(func(r chi.Router) {
+		r.Use(mid.RootOnly)
+
+		r.Post("/organizations", handlers.CreateOrganization(queries))
+
+		r.Get("/settings", handlers.GetSettings(queries, providers))
+		r.Put("/settings", handlers.UpdateSetting(queries))
+		r.Get("/settings/storage/usage", handlers.GetStorageUsage(providers))
+		r.Get("/settings/{key}", handlers.GetSetting(queries))
+
+		r.Post("/storage/gc", handlers.RunStorageGC(collector))
+		r.Get("/storage/migrations", handlers.ListStorageMigrations(queries))
+		r.Post("/storage/migrations", handlers.CreateStorageMigration(queries, providers))
+		r.Get("/storage/migrations/{migration_id}", handlers.GetStorageMigration(queries))
+		r.Post("/storage/migrations/{migration_id}/pause", handlers.PauseStorageMigration(queries))
+		r.Post("/storage/migrations/{migration_id}/resume", handlers.ResumeStorageMigration(queries))
+
+		r.Get("/stats", handlers.GetGlobalStats(queries))
+	})
 
 	return r
 }
@@ -310,6 +510,279 @@ func prune(pool *pgxpool.Pool) {
 	}
 }
 
+func startGCJob(collector *gc.Collector) {
+	interval := envDuration("STORAGE_GC_INTERVAL", 24*time.Hour)
+	scanOrphans := os.Getenv("STORAGE_GC_SCAN_ORPHANS") == "true"
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			report, err := collector.Run(ctx, gc.Options{ScanOrphans: scanOrphans})
+			cancel()
+			if err != nil {
+				slog.Error("Storage garbage collection failed", slog.Any("error", err))
+				continue
+			}
+			slog.Info("Storage garbage collection complete",
+				slog.Int("deleted", len(report.Deleted)),
+				slog.Int("released", report.Released),
+				slog.Int("errors", len(report.Errors)),
+			)
+		}
+	}()
+}
+
+func startRolloutScheduler(scheduler *rollout.Scheduler) {
+	interval := envDuration("ROLLOUT_SCHEDULER_INTERVAL", time.Minute)
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			advanced, err := scheduler.Run(ctx)
+			cancel()
+			if err != nil {
+				slog.Error("Rollout scheduler failed", slog.Any("error", err))
+				continue
+			}
+			if advanced > 0 {
+				slog.Info("Rollout scheduler advanced updates", slog.Int("advanced", advanced))
+			}
+		}
+	}()
+}
+
+func startWebhookDispatcher(dispatcher *webhook.Dispatcher) {
+	interval := envDuration("WEBHOOK_DISPATCH_INTERVAL", 10*time.Second)
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			delivered, err := dispatcher.Run(ctx)
+			cancel()
+			if err != nil {
+				slog.Error("Webhook dispatcher failed", slog.Any("error", err))
+				continue
+			}
+			if delivered > 0 {
+				slog.Debug("Webhooks delivered", slog.Int("delivered", delivered))
+			}
+		}
+	}()
+}
+
+// startStorageMigrations works on running storage migrations. A run keeps
+// going for as long as there is work, so ticks during it are dropped.
+func startStorageMigrations(m *migrator.Migrator) {
+	ticker := time.NewTicker(30 * time.Second)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			copied, err := m.Run(ctx)
+			cancel()
+			if err != nil {
+				slog.Error("Storage migration failed", slog.Any("error", err))
+				continue
+			}
+			if copied > 0 {
+				slog.Info("Storage objects migrated", slog.Int("copied", copied))
+			}
+		}
+	}()
+}
+
+func startUploadCleanup(queries *database.Queries, dir string) {
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			removed, err := handlers.CleanupUploadSessions(ctx, queries, dir)
+			if err != nil {
+				slog.Error("Upload session cleanup failed", slog.Any("error", err))
+			} else if removed > 0 {
+				slog.Info("Expired upload sessions removed", slog.Int("removed", removed))
+			}
+			expired, err := queries.DeleteExpiredDirectUploads(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+			cancel()
+			if err != nil {
+				slog.Error("Direct upload cleanup failed", slog.Any("error", err))
+			} else if expired > 0 {
+				slog.Info("Expired direct uploads removed", slog.Int64("removed", expired))
+			}
+		}
+	}()
+}
+
+// uploadConfig reads where bundles are staged and the default upload limit.
+func uploadConfig() handlers.UploadConfig {
+	cfg := handlers.UploadConfig{Dir: os.Getenv("UPLOAD_DIR"), MaxSize: 50 << 20}
+	if cfg.Dir == "" {
+		cfg.Dir = "./uploads"
+	}
+	if value := os.Getenv("MAX_UPLOAD_SIZE_MB"); value != "" {
+		mb, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || mb <= 0 {
+			slog.Warn("Invalid MAX_UPLOAD_SIZE_MB, using default", slog.String("value", value))
+		} else {
+			cfg.MaxSize = mb << 20
+		}
+	}
+	return cfg
+}
+
+// bundlePatcher returns the patcher for launch asset diffs, or nil when
+// BUNDLE_PATCH_MAX_SIZE_MB is 0.
+func bundlePatcher(queries *database.Queries, providers *storage.Registry) *handlers.BundlePatcher {
+	maxSize := int64(32 << 20)
+	if value := os.Getenv("BUNDLE_PATCH_MAX_SIZE_MB"); value != "" {
+		mb, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || mb < 0 {
+			slog.Warn("Invalid BUNDLE_PATCH_MAX_SIZE_MB, using default", slog.String("value", value))
+		} else {
+			maxSize = mb << 20
+		}
+	}
+	if maxSize == 0 {
+		return nil
+	}
+	return handlers.NewBundlePatcher(queries, providers, maxSize)
+}
+
+// assetURLSigner returns the signer for asset proxy URLs, or nil when
+// ASSET_URL_SECRET is unset and manifests keep the storage providers' URLs.
+func assetURLSigner(port string) *handlers.AssetURLSigner {
+	secret := os.Getenv("ASSET_URL_SECRET")
+	if secret == "" {
+		return nil
+	}
+	if len(secret) < 32 {
+		slog.Warn("ASSET_URL_SECRET should be at least 32 characters long")
+	}
+	baseURL := os.Getenv("PUBLIC_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("LOCAL_STORAGE_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+	ttl := envDuration("ASSET_URL_TTL", time.Hour)
+	if ttl < 20*time.Minute {
+		slog.Warn("ASSET_URL_TTL should be well over the 10 minute manifest cache lifetime, or devices may get expired asset URLs")
+	}
+	// Manifests signed at publish time are served unchanged, so their URLs
+	// last until the update has to be republished.
+	signedTTL := envDuration("ASSET_URL_SIGNED_TTL", 90*24*time.Hour)
+	slog.Info("Serving assets through the signed asset proxy", slog.String("base_url", baseURL))
+	return handlers.NewAssetURLSigner(baseURL, []byte(secret), ttl, signedTTL)
+}
+
+// setupManifestCache shares manifest cache invalidations between instances.
+// The updates table notifies every change on a Postgres channel, which is
+// enough on its own; REDIS_URL moves explicit invalidations to Redis, and
+// with MANIFEST_CACHE=redis the cache itself lives there, so nothing needs
+// to be broadcast.
+func setupManifestCache(db *pgxpool.Pool) {
+	redisURL := os.Getenv("REDIS_URL")
+	mode := os.Getenv("MANIFEST_CACHE")
+	if mode != "" && mode != "memory" && mode != "redis" {
+		slog.Error("Invalid MANIFEST_CACHE, expected memory or redis", slog.String("value", mode))
+		os.Exit(1)
+	}
+	if mode == "redis" && redisURL == "" {
+		slog.Error("MANIFEST_CACHE=redis requires REDIS_URL")
+		os.Exit(1)
+	}
+
+	var bus cache.Bus = cache.NewPostgres(db, cache.UpdatesChannel)
+	if redisURL != "" {
+		redis, err := cache.NewRedis(redisURL)
+		if err != nil {
+			slog.Error("Invalid REDIS_URL", slog.Any("error", err))
+			os.Exit(1)
+		}
+		if mode == "redis" {
+			handlers.SetManifestCache(redis, nil)
+			slog.Info("Manifest cache stored in Redis")
+			return
+		}
+		redisBus := redis.Channel(handlers.ManifestInvalidationChannel)
+		go redisBus.Subscribe(context.Background(), handlers.HandleManifestInvalidation)
+		handlers.SetManifestCache(cache.NewMemory(), redisBus)
+		slog.Info("Manifest cache invalidations shared through Redis")
+	} else {
+		handlers.SetManifestCache(cache.NewMemory(), bus)
+	}
+
+	go bus.Subscribe(context.Background(), handlers.HandleManifestInvalidation)
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid duration, using default", slog.String("key", key), slog.String("value", value))
+		return fallback
+	}
+	return d
+}
+
+// setupProjectStorage lets projects keep blobs in their own buckets when
+// STORAGE_CREDENTIALS_KEY is set to seal their credentials with.
+func setupProjectStorage(queries *database.Queries, providers *storage.Registry) *projectstorage.Store {
+	value := os.Getenv("STORAGE_CREDENTIALS_KEY")
+	if value == "" {
+		slog.Info("Project storage disabled: STORAGE_CREDENTIALS_KEY is not set")
+		return nil
+	}
+	key, err := projectstorage.ParseKey(value)
+	if err != nil {
+		panic(err.Error())
+	}
+	store, err := projectstorage.NewStore(queries, key)
+	if err != nil {
+		panic("Failed to set up project storage: " + err.Error())
+	}
+	providers.SetLoader(store.Load)
+	return store
+}
+
+// sealSigningKeys seals signing keys that earlier versions stored in the
+// clear. Without STORAGE_CREDENTIALS_KEY they keep signing, unsealed.
+func sealSigningKeys(queries *database.Queries, store *projectstorage.Store) {
+	ctx := context.Background()
+
+	keys, err := queries.ListUnsealedSigningKeys(ctx)
+	if err != nil {
+		slog.Error("Failed to list signing keys", slog.String("error", err.Error()))
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if store == nil {
+		slog.Warn("Signing keys are stored in the clear; set STORAGE_CREDENTIALS_KEY to seal them", slog.Int("keys", len(keys)))
+		return
+	}
+	for _, key := range keys {
+		sealed, err := store.SealSigningKey(key.ProjectID, key.PrivateKey)
+		if err == nil {
+			err = queries.UpdateSigningKeyPrivateKey(ctx, database.UpdateSigningKeyPrivateKeyParams{
+				PrivateKey: sealed,
+				ID:         key.ID,
+			})
+		}
+		if err != nil {
+			panic("Failed to seal signing keys: " + err.Error())
+		}
+	}
+	slog.Info("Sealed signing keys", slog.Int("keys", len(keys)))
+}
+
 func setDefaultProvider(queries *database.Queries, providers map[string]storage.Provider) {
 	ctx := context.Background()
 
@@ -320,6 +793,8 @@ func setDefaultProvider(queries *database.Queries, providers map[string]storage.
 			defaultProvider = "cloudinary"
 		} else if providers["s3"] != nil {
 			defaultProvider = "s3"
+		} else if providers["local"] != nil {
+			defaultProvider = "local"
 		}
 		queries.UpdateSetting(ctx, database.UpdateSettingParams{
 			Key:   "storage_provider",
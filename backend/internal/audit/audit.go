@@ -0,0 +1,59 @@
+// Package audit carries what a mutating admin or project request changed,
+// so the audit middleware can record it once the handler has run. Handlers
+// add what only they know: the project an update belongs to and the values
+// before and after the change.
+package audit
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Event is filled in while a request is handled. The middleware adds the
+// actor, the action and the route's URL parameters as targets.
+type Event struct {
+	ProjectID pgtype.UUID
+	Targets   map[string]string
+	Before    any
+	After     any
+}
+
+type eventKey struct{}
+
+// NewContext returns a context carrying a new, empty Event.
+func NewContext(ctx context.Context) (context.Context, *Event) {
+	e := &Event{Targets: map[string]string{}}
+	return context.WithValue(ctx, eventKey{}, e), e
+}
+
+func from(ctx context.Context) *Event {
+	e, _ := ctx.Value(eventKey{}).(*Event)
+	return e
+}
+
+// SetProject records the project a request acted on, for routes that do not
+// name it in the URL. It does nothing outside an audited request, as do the
+// other setters.
+func SetProject(ctx context.Context, id pgtype.UUID) {
+	if e := from(ctx); e != nil {
+		e.ProjectID = id
+	}
+}
+
+// SetTarget records an ID the request acted on that is not a URL parameter,
+// such as the ID of a created update.
+func SetTarget(ctx context.Context, name, id string) {
+	if e := from(ctx); e != nil {
+		e.Targets[name] = id
+	}
+}
+
+// SetChange records the state before and after the request. Either may be
+// nil, for something created or deleted. Secrets must not be passed.
+func SetChange(ctx context.Context, before, after any) {
+	if e := from(ctx); e != nil {
+		e.Before = before
+		e.After = after
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scopes an API key can hold on the project routes.
+const (
+	// ScopeRead lists and reads updates, rollouts, telemetry, branches and
+	// channels.
+	ScopeRead = "read"
+	// ScopePublish creates and uploads updates, promotes them, changes
+	// rollouts and manages branches and channels.
+	ScopePublish = "publish"
+	// ScopeRollback rolls back updates and sets the auto-rollback policy.
+	ScopeRollback = "rollback"
+	// ScopeDelete deletes updates, branches and channels.
+	ScopeDelete = "delete"
+)
+
+// AllScopes lists every scope, in the order they are shown.
+var AllScopes = []string{ScopeRead, ScopePublish, ScopeRollback, ScopeDelete}
+
+// ParseScopes checks scopes and returns them deduplicated in AllScopes
+// order. No scopes at all means every scope.
+func ParseScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return slices.Clone(AllScopes), nil
+	}
+	for _, s := range scopes {
+		if !slices.Contains(AllScopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	var res []string
+	for _, s := range AllScopes {
+		if slices.Contains(scopes, s) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+// APIKey is what a project API key may do, as stored with the key.
+type APIKey struct {
+	ID     pgtype.UUID
+	Name   string
+	Scopes []string
+	// Channels lists the channels and branches the key may act on. An
+	// empty list allows all of them.
+	Channels []string
+}
+
+// HasScope reports whether the key holds scope.
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsChannel reports whether the key may act on the named channel or
+// branch. The empty name stands for the whole project, which only keys
+// without a channel allowlist may change.
+func (k APIKey) AllowsChannel(name string) bool {
+	if len(k.Channels) == 0 {
+		return true
+	}
+	return name != "" && slices.Contains(k.Channels, name)
+}
+
+type apiKeyKey struct{}
+
+// WithAPIKey returns a context carrying the API key a project request was
+// authenticated with.
+func WithAPIKey(ctx context.Context, key APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// APIKeyFrom returns the API key stored by WithAPIKey. ok is false on
+// routes that are not authenticated with an API key.
+func APIKeyFrom(ctx context.Context) (key APIKey, ok bool) {
+	key, ok = ctx.Value(apiKeyKey{}).(APIKey)
+	return key, ok
+}
@@ -0,0 +1,200 @@
+// Package auth holds the roles users hold on organizations and projects,
+// password hashing and session tokens for the admin API, and the scopes of
+// project API keys.
+package auth
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+)
+
+// Role is what a user may do on a project, either through the project's
+// organization or granted on the project directly. Each role includes the
+// ones below it.
+type Role string
+
+const (
+	// RoleViewer reads projects, updates, stats and settings of a project.
+	RoleViewer Role = "viewer"
+	// RolePublisher also manages updates, rollouts, rollbacks, branches
+	// and channels.
+	RolePublisher Role = "publisher"
+	// RoleAdmin also manages the project, its API keys, signing keys and
+	// members.
+	RoleAdmin Role = "admin"
+	// RoleOwner also deletes the project and grants the owner role.
+	RoleOwner Role = "owner"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer:    1,
+	RolePublisher: 2,
+	RoleAdmin:     3,
+	RoleOwner:     4,
+}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, bool) {
+	role := Role(s)
+	_, ok := roleRanks[role]
+	return role, ok
+}
+
+// Allows reports whether r includes required. The zero Role allows nothing.
+func (r Role) Allows(required Role) bool {
+	rank, ok := roleRanks[r]
+	return ok && rank >= roleRanks[required]
+}
+
+// Highest returns the strongest of roles, ignoring unknown ones. It returns
+// the zero Role when there are none.
+func Highest(roles ...string) Role {
+	var best Role
+	for _, s := range roles {
+		if role, ok := ParseRole(s); ok && roleRanks[role] > roleRanks[best] {
+			best = role
+		}
+	}
+	return best
+}
+
+// Principal is who an admin request acts as: the holder of the root admin
+// token, or a logged-in user.
+type Principal struct {
+	Root   bool
+	UserID pgtype.UUID
+	Email  string
+	Name   string
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal the auth middleware stored in ctx.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 600000
+	passwordKeyLength  = 32
+	// MinPasswordLength is the shortest password HashPassword accepts.
+	MinPasswordLength = 10
+)
+
+var ErrPasswordTooShort = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+
+// HashPassword returns a salted PBKDF2-SHA256 hash of password, encoded
+// with its parameters so they can be raised later.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeyLength)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// sessionTokenPrefix marks user session tokens so they are told apart from
+// the root admin token without a database lookup.
+const sessionTokenPrefix = "ots_"
+
+// NewSessionToken returns a random bearer token for a user session.
+func NewSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return sessionTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// IsSessionToken reports whether token has the form of a session token.
+func IsSessionToken(token string) bool {
+	return strings.HasPrefix(token, sessionTokenPrefix)
+}
+
+// ProjectRole returns the strongest role a user holds on a project, through
+// its organization or directly, or the zero Role if none.
+func ProjectRole(ctx context.Context, queries *database.Queries, projectID, userID pgtype.UUID) (Role, error) {
+	roles, err := queries.GetProjectRoles(ctx, database.GetProjectRolesParams{
+		UserID:    userID,
+		ProjectID: projectID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return Highest(roles.OrganizationRole, roles.ProjectRole), nil
+}
+
+// OrganizationRole returns the role a user holds in an organization, or the
+// zero Role if none.
+func OrganizationRole(ctx context.Context, queries *database.Queries, organizationID, userID pgtype.UUID) (Role, error) {
+	role, err := queries.GetOrganizationRole(ctx, database.GetOrganizationRoleParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return Highest(role), nil
+}
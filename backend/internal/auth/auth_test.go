@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RolePublisher, RoleAdmin, false},
+		{RoleViewer, RolePublisher, false},
+		{RolePublisher, RoleViewer, true},
+		{"", RoleViewer, false},
+		{"superuser", RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestHighest(t *testing.T) {
+	if got := Highest("viewer", "admin", ""); got != RoleAdmin {
+		t.Errorf("Highest() = %q, want admin", got)
+	}
+	if got := Highest("", "bogus"); got != "" {
+		t.Errorf("Highest() = %q, want no role", got)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	if _, err := HashPassword("short"); err != ErrPasswordTooShort {
+		t.Errorf("HashPassword() error = %v, want ErrPasswordTooShort", err)
+	}
+
+	hash, err := HashPassword("correct horse battery")
+	if err != nil {
+		t.Fatalf("HashPassword() unexpected error: %v", err)
+	}
+	if !CheckPassword(hash, "correct horse battery") {
+		t.Error("CheckPassword() rejected the right password")
+	}
+	if CheckPassword(hash, "correct horse battery!") {
+		t.Error("CheckPassword() accepted a wrong password")
+	}
+	if CheckPassword("not-a-hash", "correct horse battery") {
+		t.Error("CheckPassword() accepted a malformed hash")
+	}
+
+	other, _ := HashPassword("correct horse battery")
+	if other == hash {
+		t.Error("HashPassword() should salt each hash")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	got, err := ParseScopes([]string{"delete", "read", "read"})
+	if err != nil || !slices.Equal(got, []string{ScopeRead, ScopeDelete}) {
+		t.Errorf("ParseScopes() = %v, %v, want [read delete]", got, err)
+	}
+	if got, _ := ParseScopes(nil); !slices.Equal(got, AllScopes) {
+		t.Errorf("ParseScopes(nil) = %v, want every scope", got)
+	}
+	if _, err := ParseScopes([]string{"admin"}); err == nil {
+		t.Error("ParseScopes() accepted an unknown scope")
+	}
+}
+
+func TestAPIKeyAllowsChannel(t *testing.T) {
+	tests := []struct {
+		channels []string
+		name     string
+		want     bool
+	}{
+		{nil, "production", true},
+		{nil, "", true},
+		{[]string{"staging"}, "staging", true},
+		{[]string{"staging"}, "production", false},
+		{[]string{"staging"}, "", false},
+	}
+	for _, tt := range tests {
+		key := APIKey{Channels: tt.channels}
+		if got := key.AllowsChannel(tt.name); got != tt.want {
+			t.Errorf("APIKey{Channels: %v}.AllowsChannel(%q) = %v, want %v", tt.channels, tt.name, got, tt.want)
+		}
+	}
+}
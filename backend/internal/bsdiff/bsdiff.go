@@ -0,0 +1,369 @@
+// Package bsdiff builds and applies binary patches in the format of bsdiff
+// 4.3 by Colin Percival, as laid out by Matthew Endsley's library: a
+// 16-byte "ENDSLEY/BSDIFF43" header, the size of the new file, then a
+// stream of control records, each followed by its diff and extra bytes. The
+// stream is not compressed here; callers choose the compression.
+package bsdiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const magic = "ENDSLEY/BSDIFF43"
+
+// MaxSize is the largest file Diff accepts; the suffix array uses int32.
+const MaxSize = math.MaxInt32 - 1
+
+var ErrCorrupt = errors.New("corrupt patch")
+
+// Diff writes a patch that turns old into new.
+func Diff(old, new []byte, w io.Writer) error {
+	if len(old) > MaxSize || len(new) > MaxSize {
+		return fmt.Errorf("bsdiff: input larger than %d bytes", MaxSize)
+	}
+
+	bw := &errWriter{w: w}
+	bw.write([]byte(magic))
+	bw.writeInt(int64(len(new)))
+
+	I := suffixArray(old)
+	oldSize, newSize := len(old), len(new)
+
+	var scan, pos, length int
+	var lastScan, lastPos, lastOffset int
+	for scan < newSize {
+		oldScore := 0
+		scan += length
+		for scsc := scan; scan < newSize; scan++ {
+			pos, length = search(I, old, new[scan:], 0, oldSize)
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && old[scsc+lastOffset] == new[scsc] {
+					oldScore++
+				}
+			}
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+			if scan+lastOffset < oldSize && old[scan+lastOffset] == new[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != newSize {
+			continue
+		}
+
+		// Extend the previous match forwards and this one backwards, as
+		// long as at least half of the bytes agree.
+		s, sf, lenf := 0, 0, 0
+		for i := 0; lastScan+i < scan && lastPos+i < oldSize; {
+			if old[lastPos+i] == new[lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf, lenf = s, i
+			}
+		}
+
+		lenb := 0
+		if scan < newSize {
+			s, sb := 0, 0
+			for i := 1; scan >= lastScan+i && pos >= i; i++ {
+				if old[pos-i] == new[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb, lenb = s, i
+				}
+			}
+		}
+
+		if lastScan+lenf > scan-lenb {
+			overlap := (lastScan + lenf) - (scan - lenb)
+			s, ss, lens := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if new[lastScan+lenf-overlap+i] == old[lastPos+lenf-overlap+i] {
+					s++
+				}
+				if new[scan-lenb+i] == old[pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss, lens = s, i+1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		extra := (scan - lenb) - (lastScan + lenf)
+		bw.writeInt(int64(lenf))
+		bw.writeInt(int64(extra))
+		bw.writeInt(int64((pos - lenb) - (lastPos + lenf)))
+
+		diff := make([]byte, lenf)
+		for i := range diff {
+			diff[i] = new[lastScan+i] - old[lastPos+i]
+		}
+		bw.write(diff)
+		bw.write(new[lastScan+lenf : lastScan+lenf+extra])
+
+		lastScan = scan - lenb
+		lastPos = pos - lenb
+		lastOffset = pos - scan
+	}
+	return bw.err
+}
+
+// Patch applies a patch made by Diff to old and returns the new file.
+func Patch(old []byte, patch io.Reader) ([]byte, error) {
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(patch, header); err != nil {
+		return nil, ErrCorrupt
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrCorrupt
+	}
+	newSize := readInt(header[len(magic):])
+	if newSize < 0 || newSize > MaxSize {
+		return nil, ErrCorrupt
+	}
+
+	new := make([]byte, newSize)
+	ctrl := make([]byte, 24)
+	var oldPos, newPos int64
+	for newPos < newSize {
+		if _, err := io.ReadFull(patch, ctrl); err != nil {
+			return nil, ErrCorrupt
+		}
+		diffLen, extraLen, seek := readInt(ctrl), readInt(ctrl[8:]), readInt(ctrl[16:])
+		// Compare each length with what is left, since their sum can
+		// overflow.
+		if diffLen < 0 || extraLen < 0 || diffLen > newSize-newPos || extraLen > newSize-newPos-diffLen {
+			return nil, ErrCorrupt
+		}
+
+		if _, err := io.ReadFull(patch, new[newPos:newPos+diffLen]); err != nil {
+			return nil, ErrCorrupt
+		}
+		for i := int64(0); i < diffLen; i++ {
+			if p := oldPos + i; p >= 0 && p < int64(len(old)) {
+				new[newPos+i] += old[p]
+			}
+		}
+		newPos += diffLen
+		oldPos += diffLen
+
+		if _, err := io.ReadFull(patch, new[newPos:newPos+extraLen]); err != nil {
+			return nil, ErrCorrupt
+		}
+		newPos += extraLen
+		oldPos += seek
+	}
+	return new, nil
+}
+
+// search finds the longest prefix of new that occurs in old, using the
+// suffix array I between st and en.
+func search(I []int32, old, new []byte, st, en int) (pos, n int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		suffix := old[I[x]:]
+		if bytes.Compare(suffix[:min(len(suffix), len(new))], new[:min(len(suffix), len(new))]) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+	x := matchLen(old[I[st]:], new)
+	y := matchLen(old[I[en]:], new)
+	if x > y {
+		return int(I[st]), x
+	}
+	return int(I[en]), y
+}
+
+func matchLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// suffixArray sorts the suffixes of old with the Larsson-Sadakane qsufsort
+// that bsdiff uses. The result has len(old)+1 entries; the first is the
+// empty suffix.
+func suffixArray(old []byte) []int32 {
+	n := len(old)
+	I := make([]int32, n+1)
+	V := make([]int32, n+1)
+
+	var buckets [256]int32
+	for _, c := range old {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, c := range old {
+		buckets[c]++
+		I[buckets[c]] = int32(i)
+	}
+	I[0] = int32(n)
+	for i, c := range old {
+		V[i] = buckets[c]
+	}
+	V[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			I[buckets[i]] = -1
+		}
+	}
+	I[0] = -1
+
+	for h := 1; I[0] != -int32(n+1); h += h {
+		length := 0
+		i := 0
+		for i < n+1 {
+			if I[i] < 0 {
+				length -= int(I[i])
+				i -= int(I[i])
+				continue
+			}
+			if length != 0 {
+				I[i-length] = -int32(length)
+			}
+			length = int(V[I[i]]) + 1 - i
+			split(I, V, i, length, h)
+			i += length
+			length = 0
+		}
+		if length != 0 {
+			I[i-length] = -int32(length)
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		I[V[i]] = int32(i)
+	}
+	return I
+}
+
+func split(I, V []int32, start, length, h int) {
+	if length < 16 {
+		for k := start; k < start+length; {
+			j := 1
+			x := V[int(I[k])+h]
+			for i := 1; k+i < start+length; i++ {
+				if v := V[int(I[k+i])+h]; v < x {
+					x = v
+					j = 0
+				}
+				if V[int(I[k+i])+h] == x {
+					I[k+j], I[k+i] = I[k+i], I[k+j]
+					j++
+				}
+			}
+			for i := 0; i < j; i++ {
+				V[I[k+i]] = int32(k + j - 1)
+			}
+			if j == 1 {
+				I[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := V[int(I[start+length/2])+h]
+	jj, kk := 0, 0
+	for i := start; i < start+length; i++ {
+		if v := V[int(I[i])+h]; v < x {
+			jj++
+		} else if v == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, 0, 0
+	for i < jj {
+		if v := V[int(I[i])+h]; v < x {
+			i++
+		} else if v == x {
+			I[i], I[jj+j] = I[jj+j], I[i]
+			j++
+		} else {
+			I[i], I[kk+k] = I[kk+k], I[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if V[int(I[jj+j])+h] == x {
+			j++
+		} else {
+			I[jj+j], I[kk+k] = I[kk+k], I[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		split(I, V, start, jj-start, h)
+	}
+	for i := 0; i < kk-jj; i++ {
+		V[I[jj+i]] = int32(kk - 1)
+	}
+	if jj == kk-1 {
+		I[jj] = -1
+	}
+	if start+length > kk {
+		split(I, V, kk, start+length-kk, h)
+	}
+}
+
+// writeInt and readInt use bsdiff's sign-magnitude little-endian integers.
+func (w *errWriter) writeInt(x int64) {
+	var buf [8]byte
+	u := uint64(x)
+	if x < 0 {
+		u = uint64(-x) | 1<<63
+	}
+	binary.LittleEndian.PutUint64(buf[:], u)
+	w.write(buf[:])
+}
+
+func readInt(buf []byte) int64 {
+	u := binary.LittleEndian.Uint64(buf)
+	x := int64(u &^ (1 << 63))
+	if u&(1<<63) != 0 {
+		x = -x
+	}
+	return x
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) write(p []byte) {
+	if w.err == nil && len(p) > 0 {
+		_, w.err = w.w.Write(p)
+	}
+}
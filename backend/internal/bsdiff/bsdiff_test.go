@@ -0,0 +1,86 @@
+package bsdiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"testing"
+)
+
+func TestDiffPatchRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+
+	base := random(64 << 10)
+	edited := append([]byte(nil), base...)
+	for i := 0; i < 50; i++ {
+		edited[rng.Intn(len(edited))] ^= 0xff
+	}
+	edited = append(edited[:1000], append(random(300), edited[1000:]...)...)
+	edited = append(edited[:40000], edited[42000:]...)
+
+	tests := []struct {
+		name     string
+		old, new []byte
+	}{
+		{"empty old", nil, []byte("hello")},
+		{"empty new", []byte("hello"), nil},
+		{"identical", base, base},
+		{"edited", base, edited},
+		{"unrelated", random(5000), random(7000)},
+		{"repetitive", bytes.Repeat([]byte("ab"), 3000), bytes.Repeat([]byte("abc"), 2000)},
+	}
+	for _, tt := range tests {
+		var patch bytes.Buffer
+		if err := Diff(tt.old, tt.new, &patch); err != nil {
+			t.Fatalf("%s: Diff failed: %v", tt.name, err)
+		}
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(patch.Bytes())
+		zw.Close()
+
+		got, err := Patch(tt.old, &patch)
+		if err != nil {
+			t.Fatalf("%s: Patch failed: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.new) {
+			t.Errorf("%s: patched output differs from new", tt.name)
+		}
+		// The diff bytes of a small edit are almost all zero.
+		if tt.name == "edited" && compressed.Len() > len(tt.new)/20 {
+			t.Errorf("%s: compressed patch is %d bytes for a %d byte file", tt.name, compressed.Len(), len(tt.new))
+		}
+	}
+}
+
+func TestPatchRejectsCorruptInput(t *testing.T) {
+	var patch bytes.Buffer
+	if err := Diff([]byte("old contents"), []byte("new contents!"), &patch); err != nil {
+		t.Fatal(err)
+	}
+	valid := patch.Bytes()
+
+	// Lengths whose sum wraps around to a negative number.
+	var overflow bytes.Buffer
+	overflow.WriteString(magic)
+	bw := &errWriter{w: &overflow}
+	for _, x := range []int64{13, 1 << 62, 1 << 62, 0} {
+		bw.writeInt(x)
+	}
+
+	for _, corrupt := range [][]byte{
+		nil,
+		[]byte("NOT/A/BSDIFF/PATCH"),
+		valid[:len(valid)-3],
+		overflow.Bytes(),
+	} {
+		if _, err := Patch([]byte("old contents"), bytes.NewReader(corrupt)); err != ErrCorrupt {
+			t.Errorf("Patch(%q) error = %v, want ErrCorrupt", corrupt, err)
+		}
+	}
+}
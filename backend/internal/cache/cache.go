@@ -0,0 +1,105 @@
+// Package cache holds the manifest cache shared by the request handlers and
+// the bus that carries cache invalidations between server instances.
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a byte cache with per-entry expiry. Implementations are safe for
+// concurrent use.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add stores an empty entry if key is absent and reports whether it did.
+	Add(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// DeletePrefix removes every entry whose key starts with prefix. An
+	// empty prefix clears the cache.
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Bus broadcasts invalidation messages to every server instance.
+type Bus interface {
+	Publish(ctx context.Context, message string) error
+	// Subscribe calls fn for every message until ctx is done. Messages
+	// published while the subscription is down are lost, so fn is also
+	// called with an empty message each time the subscription (re)starts;
+	// subscribers should then drop everything they cached.
+	Subscribe(ctx context.Context, fn func(message string)) error
+}
+
+const memorySweepInterval = time.Minute
+
+// Memory is a Cache local to the process.
+type Memory struct {
+	mu        sync.RWMutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweepLocked(now)
+	m.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *Memory) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.sweepLocked(now)
+	if entry, ok := m.entries[key]; ok && !now.After(entry.expires) {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: []byte{}, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (m *Memory) DeletePrefix(ctx context.Context, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
+
+// sweepLocked drops expired entries, at most once per memorySweepInterval.
+func (m *Memory) sweepLocked(now time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process server speaking enough RESP for Redis: GET, SET
+// with EX, PX and NX, DEL, SCAN with a prefix MATCH, PUBLISH and SUBSCRIBE.
+// Other commands fail, so clients stay on RESP2 after HELLO is refused.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	values      map[string]string
+	expires     map[string]time.Time
+	subscribers map[string][]*fakeRedisConn
+}
+
+type fakeRedisConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *fakeRedisConn) write(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	io.WriteString(c.Conn, s)
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	f := &fakeRedis{
+		listener:    listener,
+		values:      make(map[string]string),
+		expires:     make(map[string]time.Time),
+		subscribers: make(map[string][]*fakeRedisConn),
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(&fakeRedisConn{Conn: conn})
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) URL() string {
+	return "redis://" + f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn *fakeRedisConn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readFakeCommand(reader)
+		if err != nil {
+			return
+		}
+		conn.write(f.exec(conn, args))
+	}
+}
+
+func readFakeCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) exec(conn *fakeRedisConn, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		value, ok := f.getLocked(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "SET":
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				seconds, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(seconds) * time.Second
+				i++
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			case "NX":
+				nx = true
+			}
+		}
+		if _, exists := f.getLocked(args[1]); nx && exists {
+			return "$-1\r\n"
+		}
+		f.values[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if ttl > 0 {
+			f.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := f.values[key]; ok {
+				deleted++
+			}
+			delete(f.values, key)
+			delete(f.expires, key)
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		prefix := strings.TrimSuffix(unescapeFakePattern(args[3]), "*")
+		var keys []string
+		for key := range f.values {
+			if _, ok := f.getLocked(key); ok && strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		reply := "*2\r\n" + bulk("0") + fmt.Sprintf("*%d\r\n", len(keys))
+		for _, key := range keys {
+			reply += bulk(key)
+		}
+		return reply
+	case "PUBLISH":
+		subscribers := f.subscribers[args[1]]
+		for _, sub := range subscribers {
+			go sub.write("*3\r\n" + bulk("message") + bulk(args[1]) + bulk(args[2]))
+		}
+		return fmt.Sprintf(":%d\r\n", len(subscribers))
+	case "SUBSCRIBE":
+		f.subscribers[args[1]] = append(f.subscribers[args[1]], conn)
+		return "*3\r\n" + bulk("subscribe") + bulk(args[1]) + ":1\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func (f *fakeRedis) getLocked(key string) (string, bool) {
+	value, ok := f.values[key]
+	if !ok {
+		return "", false
+	}
+	if expires, ok := f.expires[key]; ok && time.Now().After(expires) {
+		delete(f.values, key)
+		delete(f.expires, key)
+		return "", false
+	}
+	return value, true
+}
+
+// dropConnections closes every subscriber connection, as a Redis restart
+// would.
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for channel, subs := range f.subscribers {
+		for _, sub := range subs {
+			sub.Close()
+		}
+		delete(f.subscribers, channel)
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func unescapeFakePattern(pattern string) string {
+	return strings.NewReplacer(`\*`, "*", `\?`, "?", `\[`, "[", `\]`, "]", `\\`, `\`).Replace(pattern)
+}
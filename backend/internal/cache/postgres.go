@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UpdatesChannel is the Postgres channel the updates table trigger notifies
+// with a project ID whenever one of the project's updates changes.
+const UpdatesChannel = "otaship_updates"
+
+// Postgres is a Bus on a Postgres LISTEN/NOTIFY channel, for deployments
+// that run nothing besides the database.
+type Postgres struct {
+	pool    *pgxpool.Pool
+	channel string
+}
+
+func NewPostgres(pool *pgxpool.Pool, channel string) *Postgres {
+	return &Postgres{pool: pool, channel: channel}
+}
+
+func (p *Postgres) Publish(ctx context.Context, message string) error {
+	_, err := p.pool.Exec(ctx, "SELECT pg_notify($1, $2)", p.channel, message)
+	return err
+}
+
+func (p *Postgres) Subscribe(ctx context.Context, fn func(message string)) error {
+	for {
+		err := p.listen(ctx, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Warn("Postgres listener lost, reconnecting",
+			slog.String("channel", p.channel),
+			slog.Any("error", err),
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (p *Postgres) listen(ctx context.Context, fn func(message string)) error {
+	pooled, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection stays in LISTEN mode, so it must not go back to the
+	// pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.channel}.Sanitize()); err != nil {
+		return err
+	}
+	fn("")
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix   = "otaship:"
+	redisIdleConns   = 8
+	redisDialTimeout = 5 * time.Second
+	redisTimeout     = 5 * time.Second
+	redisScanCount   = 100
+)
+
+// Redis is a Cache stored in Redis, shared by every instance that points at
+// the same server. It also provides pub/sub channels through Channel.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis parses a redis:// or rediss:// URL such as
+// redis://:password@localhost:6379/0. No connection is made until first use.
+func NewRedis(rawURL string) (*Redis, error) {
+	// go-redis falls back to localhost for a URL without a host, which
+	// would hide a misconfigured REDIS_URL.
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() == "" {
+		return nil, errors.New("redis URL has no host")
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	opts.DialTimeout = redisDialTimeout
+	opts.ReadTimeout = redisTimeout
+	opts.WriteTimeout = redisTimeout
+	opts.ContextTimeoutEnabled = true
+	opts.MaxIdleConns = redisIdleConns
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, redisKeyPrefix+key, value, redisTTL(ttl)).Err()
+}
+
+func (r *Redis) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, redisKeyPrefix+key, "", redisTTL(ttl)).Result()
+}
+
+func (r *Redis) DeletePrefix(ctx context.Context, prefix string) error {
+	pattern := redisKeyPrefix + escapeRedisPattern(prefix) + "*"
+	iter := r.client.Scan(ctx, 0, pattern, redisScanCount).Iterator()
+	keys := make([]string, 0, redisScanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == redisScanCount {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.client.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+// Channel returns a Bus on the named pub/sub channel.
+func (r *Redis) Channel(name string) Bus {
+	return &redisChannel{client: r.client, name: redisKeyPrefix + name}
+}
+
+type redisChannel struct {
+	client *redis.Client
+	name   string
+}
+
+func (c *redisChannel) Publish(ctx context.Context, message string) error {
+	return c.client.Publish(ctx, c.name, message).Err()
+}
+
+// Subscribe relies on go-redis to reconnect and subscribe again after a
+// lost connection. Each confirmed subscription calls fn with an empty
+// message, since messages published in between are gone.
+func (c *redisChannel) Subscribe(ctx context.Context, fn func(message string)) error {
+	pubsub := c.client.Subscribe(ctx, c.name)
+	defer pubsub.Close()
+	stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+	defer stop()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			slog.Warn("Redis subscription lost, reconnecting",
+				slog.String("channel", c.name),
+				slog.Any("error", err),
+			)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				fn("")
+			}
+		case *redis.Message:
+			fn(msg.Payload)
+		}
+	}
+}
+
+// redisTTL keeps sub-millisecond TTLs from turning into no expiry at all.
+func redisTTL(ttl time.Duration) time.Duration {
+	if ttl < time.Millisecond {
+		return time.Millisecond
+	}
+	return ttl
+}
+
+func escapeRedisPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
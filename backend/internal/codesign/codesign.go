@@ -0,0 +1,361 @@
+// Package codesign signs and verifies Expo Updates manifests. The algorithm
+// names match the alg parameter of the expo-expect-signature and
+// expo-signature headers.
+package codesign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// AlgRSAPKCS1SHA256 is the only algorithm expo-updates clients support
+	// today and the default when a request does not name one.
+	AlgRSAPKCS1SHA256  = "rsa-v1_5-sha256"
+	AlgECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgEd25519         = "ed25519"
+)
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	ErrInvalidKey     = errors.New("invalid private key")
+	ErrBadSignature   = errors.New("signature verification failed")
+)
+
+// Signer signs manifest bodies with one private key.
+type Signer interface {
+	Alg() string
+	Public() crypto.PublicKey
+	Sign(data []byte) ([]byte, error)
+}
+
+// SupportedAlgs lists every algorithm NewSigner and Verify understand.
+func SupportedAlgs() []string {
+	return []string{AlgRSAPKCS1SHA256, AlgECDSAP256SHA256, AlgEd25519}
+}
+
+// IsSupported reports whether alg is one of SupportedAlgs.
+func IsSupported(alg string) bool {
+	for _, a := range SupportedAlgs() {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSigner parses a PEM private key (PKCS#8, PKCS#1 or SEC 1) and returns a
+// signer for its algorithm.
+func NewSigner(privateKeyPEM string) (Signer, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			// Some tools label PKCS#1 keys as "PRIVATE KEY".
+			if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+				key, err = rsaKey, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return rsaSigner{k}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: only P-256 ECDSA keys are supported", ErrUnsupportedAlg)
+		}
+		return ecdsaSigner{k}, nil
+	case ed25519.PrivateKey:
+		return ed25519Signer{k}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlg, key)
+	}
+}
+
+type rsaSigner struct{ key *rsa.PrivateKey }
+
+func (s rsaSigner) Alg() string { return AlgRSAPKCS1SHA256 }
+
+func (s rsaSigner) Public() crypto.PublicKey { return &s.key.PublicKey }
+
+func (s rsaSigner) Sign(data []byte) ([]byte, error) {
+	hashed := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hashed[:])
+}
+
+type ecdsaSigner struct{ key *ecdsa.PrivateKey }
+
+func (s ecdsaSigner) Alg() string { return AlgECDSAP256SHA256 }
+
+func (s ecdsaSigner) Public() crypto.PublicKey { return &s.key.PublicKey }
+
+// Sign returns an ASN.1 DER encoded signature.
+func (s ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	hashed := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, s.key, hashed[:])
+}
+
+type ed25519Signer struct{ key ed25519.PrivateKey }
+
+func (s ed25519Signer) Alg() string { return AlgEd25519 }
+
+func (s ed25519Signer) Public() crypto.PublicKey { return s.key.Public() }
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, data), nil
+}
+
+// Verify checks a raw signature over data with the given public key.
+func Verify(alg string, publicKey crypto.PublicKey, data, signature []byte) error {
+	hashed := sha256.Sum256(data)
+
+	switch alg {
+	case AlgRSAPKCS1SHA256, "":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s needs an RSA public key", ErrUnsupportedAlg, AlgRSAPKCS1SHA256)
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+			return ErrBadSignature
+		}
+	case AlgECDSAP256SHA256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s needs an ECDSA public key", ErrUnsupportedAlg, alg)
+		}
+		if !ecdsa.VerifyASN1(key, hashed[:], signature) {
+			return ErrBadSignature
+		}
+	case AlgEd25519:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s needs an Ed25519 public key", ErrUnsupportedAlg, alg)
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return ErrBadSignature
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+	return nil
+}
+
+// VerifyHeader checks an expo-signature header value against the part body
+// it was sent with, using the public key in a PEM certificate.
+func VerifyHeader(certificatePEM string, header string, data []byte) error {
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil {
+		return errors.New("invalid certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	params := ParseHeader(header)
+	if params["sig"] == "" {
+		return errors.New("expo-signature has no sig parameter")
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["sig"])
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	return Verify(params["alg"], cert.PublicKey, data, signature)
+}
+
+// ParseHeader reads the parameters of a structured-field dictionary such as
+// expo-expect-signature or expo-signature. Bare members map to "".
+func ParseHeader(header string) map[string]string {
+	params := make(map[string]string)
+	for _, member := range strings.Split(header, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(member, "=")
+		params[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return params
+}
+
+// FormatHeader builds the expo-signature value for a signature.
+func FormatHeader(signature []byte, keyID, alg string) string {
+	return fmt.Sprintf(`sig="%s", keyid="%s", alg="%s"`,
+		base64.StdEncoding.EncodeToString(signature), keyID, alg)
+}
+
+// GenerateKey creates a key pair for alg together with a self-signed code
+// signing certificate that can be embedded in an app's
+// updates.codeSigningCertificate.
+func GenerateKey(alg, commonName string, validFor time.Duration) (privateKeyPEM, certificatePEM string, err error) {
+	var (
+		key       crypto.Signer
+		publicKey crypto.PublicKey
+	)
+	switch alg {
+	case AlgRSAPKCS1SHA256, "":
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", "", err
+		}
+		key, publicKey = rsaKey, &rsaKey.PublicKey
+	case AlgECDSAP256SHA256:
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		key, publicKey = ecKey, &ecKey.PublicKey
+	case AlgEd25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		key, publicKey = priv, pub
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKeyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}))
+	certificatePEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}))
+	return privateKeyPEM, certificatePEM, nil
+}
+
+// ParseCertificateChain decodes a PEM chain, leaf first. When intermediates or
+// a root follow the leaf, the leaf must chain up to the last certificate. The
+// leaf must be usable for code signing and currently valid.
+func ParseCertificateChain(chainPEM string) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+
+	leaf := chain[0]
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return nil, errors.New("certificate is expired or not yet valid")
+	}
+	if len(leaf.ExtKeyUsage) > 0 && !hasCodeSigningUsage(leaf) {
+		return nil, errors.New("certificate is not valid for code signing")
+	}
+
+	if len(chain) > 1 {
+		roots := x509.NewCertPool()
+		roots.AddCert(chain[len(chain)-1])
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1 : len(chain)-1] {
+			intermediates.AddCert(cert)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("certificate chain does not verify: %w", err)
+		}
+	}
+	return chain, nil
+}
+
+// MatchesCertificate reports whether cert holds the public key of signer, so
+// that signatures it makes verify against the certificate.
+func MatchesCertificate(signer Signer, cert *x509.Certificate) bool {
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && publicKey.Equal(cert.PublicKey)
+}
+
+func hasCodeSigningUsage(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageCodeSigning || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// AlgForPublicKey returns the algorithm a public key signs with.
+func AlgForPublicKey(publicKey crypto.PublicKey) (string, error) {
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		return AlgRSAPKCS1SHA256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: only P-256 ECDSA keys are supported", ErrUnsupportedAlg)
+		}
+		return AlgECDSAP256SHA256, nil
+	case ed25519.PublicKey:
+		return AlgEd25519, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnsupportedAlg, publicKey)
+	}
+}
@@ -0,0 +1,178 @@
+package codesign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenerateKey(t *testing.T) {
+	privateKeyPEM, certificatePEM, err := GenerateKey(AlgRSAPKCS1SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+
+	signer, err := NewSigner(privateKeyPEM)
+	if err != nil {
+		t.Fatalf("Generated key is not usable: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(certificatePEM))
+	if block == nil {
+		t.Fatalf("Certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if cert.Subject.CommonName != "my-app" {
+		t.Errorf("Expected common name my-app, got %s", cert.Subject.CommonName)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageCodeSigning {
+		t.Errorf("Expected code signing extended key usage, got %v", cert.ExtKeyUsage)
+	}
+
+	manifest := []byte(`{"id":"123"}`)
+	sig, err := signer.Sign(manifest)
+	if err != nil {
+		t.Fatalf("Sign() unexpected error: %v", err)
+	}
+	params := ParseHeader(FormatHeader(sig, "main", signer.Alg()))
+	raw, _ := base64.StdEncoding.DecodeString(params["sig"])
+	hashed := sha256.Sum256(manifest)
+	if err := rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, hashed[:], raw); err != nil {
+		t.Errorf("Signature does not verify against the certificate: %v", err)
+	}
+}
+
+func TestSignAndVerifyHeader(t *testing.T) {
+	data := []byte(`{"id":"123","runtimeVersion":"1.0.0"}`)
+
+	for _, alg := range SupportedAlgs() {
+		t.Run(alg, func(t *testing.T) {
+			privateKeyPEM, certificatePEM, err := GenerateKey(alg, "my-app", time.Hour)
+			if err != nil {
+				t.Fatalf("GenerateKey() unexpected error: %v", err)
+			}
+
+			signer, err := NewSigner(privateKeyPEM)
+			if err != nil {
+				t.Fatalf("NewSigner() unexpected error: %v", err)
+			}
+			if signer.Alg() != alg {
+				t.Errorf("Expected alg %s, got %s", alg, signer.Alg())
+			}
+
+			sig, err := signer.Sign(data)
+			if err != nil {
+				t.Fatalf("Sign() unexpected error: %v", err)
+			}
+			header := FormatHeader(sig, "main", signer.Alg())
+
+			if err := VerifyHeader(certificatePEM, header, data); err != nil {
+				t.Errorf("VerifyHeader() unexpected error: %v", err)
+			}
+			if err := VerifyHeader(certificatePEM, header, []byte(`{"id":"456"}`)); !errors.Is(err, ErrBadSignature) {
+				t.Errorf("Expected ErrBadSignature for tampered data, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsAlgorithmMismatch(t *testing.T) {
+	privateKeyPEM, _, err := GenerateKey(AlgEd25519, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	_, rsaCert, err := GenerateKey(AlgRSAPKCS1SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+
+	signer, _ := NewSigner(privateKeyPEM)
+	sig, _ := signer.Sign([]byte("data"))
+
+	err = VerifyHeader(rsaCert, FormatHeader(sig, "main", AlgEd25519), []byte("data"))
+	if !errors.Is(err, ErrUnsupportedAlg) {
+		t.Errorf("Expected ErrUnsupportedAlg, got %v", err)
+	}
+}
+
+func TestNewSignerPKCS1Key(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate test RSA key: %v", err)
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	})
+
+	signer, err := NewSigner(string(privateKeyPEM))
+	if err != nil {
+		t.Fatalf("NewSigner() unexpected error: %v", err)
+	}
+	manifest := []byte(`{"id": "123", "version": "1.0.0"}`)
+	signature, err := signer.Sign(manifest)
+	if err != nil {
+		t.Fatalf("Sign() unexpected error: %v", err)
+	}
+	if err := Verify(signer.Alg(), &privateKey.PublicKey, manifest, signature); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+}
+
+func TestNewSignerInvalidKey(t *testing.T) {
+	if _, err := NewSigner("invalid-key-data"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		keyID  string
+		alg    string
+	}{
+		{`sig, keyid="main", alg="rsa-v1_5-sha256"`, "main", "rsa-v1_5-sha256"},
+		{`sig`, "", ""},
+		{`sig, keyid="2024-rotation"`, "2024-rotation", ""},
+		{`alg="rsa-v1_5-sha256",keyid=root`, "root", "rsa-v1_5-sha256"},
+		{`alg="ed25519",keyid=root`, "root", "ed25519"},
+	}
+
+	for _, tt := range tests {
+		got := ParseHeader(tt.header)
+		if got["keyid"] != tt.keyID || got["alg"] != tt.alg {
+			t.Errorf("ParseHeader(%q) = %v, want keyid %q alg %q", tt.header, got, tt.keyID, tt.alg)
+		}
+	}
+}
+
+func TestMatchesCertificate(t *testing.T) {
+	privateKeyPEM, certificatePEM, err := GenerateKey(AlgECDSAP256SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	_, otherPEM, err := GenerateKey(AlgECDSAP256SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	signer, _ := NewSigner(privateKeyPEM)
+
+	own, _ := ParseCertificateChain(certificatePEM)
+	other, _ := ParseCertificateChain(otherPEM)
+	if !MatchesCertificate(signer, own[0]) {
+		t.Error("Expected the key's own certificate to match")
+	}
+	if MatchesCertificate(signer, other[0]) {
+		t.Error("Expected another key's certificate not to match")
+	}
+}
@@ -12,16 +12,19 @@ import (
 )
 
 const createAPIKey = `-- name: CreateAPIKey :one
-INSERT INTO api_keys (project_id, name, key_hash, key_suffix)
-VALUES ($1, $2, $3, $4)
-RETURNING id, project_id, name, key_hash, key_suffix, created_at, last_used_at
+INSERT INTO api_keys (project_id, name, key_hash, key_suffix, scopes, channels, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, project_id, name, key_hash, key_suffix, created_at, last_used_at, scopes, channels, expires_at
 `
 
 type CreateAPIKeyParams struct {
-	ProjectID pgtype.UUID `json:"project_id"`
-	Name      string      `json:"name"`
-	KeyHash   string      `json:"key_hash"`
-	KeySuffix string      `json:"key_suffix"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Name      string             `json:"name"`
+	KeyHash   string             `json:"key_hash"`
+	KeySuffix string             `json:"key_suffix"`
+	Scopes    []string           `json:"scopes"`
+	Channels  []string           `json:"channels"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
 }
 
 func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
@@ -30,6 +33,9 @@ func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (Api
 		arg.Name,
 		arg.KeyHash,
 		arg.KeySuffix,
+		arg.Scopes,
+		arg.Channels,
+		arg.ExpiresAt,
 	)
 	var i ApiKey
 	err := row.Scan(
@@ -40,6 +46,9 @@ func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (Api
 		&i.KeySuffix,
 		&i.CreatedAt,
 		&i.LastUsedAt,
+		&i.Scopes,
+		&i.Channels,
+		&i.ExpiresAt,
 	)
 	return i, err
 }
@@ -60,26 +69,38 @@ func (q *Queries) DeleteAPIKey(ctx context.Context, arg DeleteAPIKeyParams) erro
 }
 
 const getAPIKeyBySuffix = `-- name: GetAPIKeyBySuffix :one
-SELECT id, project_id, key_hash 
+SELECT id, project_id, name, key_hash, scopes, channels, expires_at
 FROM api_keys 
 WHERE key_suffix = $1
 `
 
 type GetAPIKeyBySuffixRow struct {
-	ID        pgtype.UUID `json:"id"`
-	ProjectID pgtype.UUID `json:"project_id"`
-	KeyHash   string      `json:"key_hash"`
+	ID        pgtype.UUID        `json:"id"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Name      string             `json:"name"`
+	KeyHash   string             `json:"key_hash"`
+	Scopes    []string           `json:"scopes"`
+	Channels  []string           `json:"channels"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
 }
 
 func (q *Queries) GetAPIKeyBySuffix(ctx context.Context, keySuffix string) (GetAPIKeyBySuffixRow, error) {
 	row := q.db.QueryRow(ctx, getAPIKeyBySuffix, keySuffix)
 	var i GetAPIKeyBySuffixRow
-	err := row.Scan(&i.ID, &i.ProjectID, &i.KeyHash)
+	err := row.Scan(
+		&i.ID,
+		&i.ProjectID,
+		&i.Name,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.Channels,
+		&i.ExpiresAt,
+	)
 	return i, err
 }
 
 const listAPIKeys = `-- name: ListAPIKeys :many
-SELECT id, name, key_suffix, created_at, last_used_at 
+SELECT id, name, key_suffix, scopes, channels, expires_at, created_at, last_used_at
 FROM api_keys 
 WHERE project_id = $1 
 ORDER BY created_at DESC
@@ -89,6 +110,9 @@ type ListAPIKeysRow struct {
 	ID         pgtype.UUID        `json:"id"`
 	Name       string             `json:"name"`
 	KeySuffix  string             `json:"key_suffix"`
+	Scopes     []string           `json:"scopes"`
+	Channels   []string           `json:"channels"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
 	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
 }
@@ -106,6 +130,9 @@ func (q *Queries) ListAPIKeys(ctx context.Context, projectID pgtype.UUID) ([]Lis
 			&i.ID,
 			&i.Name,
 			&i.KeySuffix,
+			&i.Scopes,
+			&i.Channels,
+			&i.ExpiresAt,
 			&i.CreatedAt,
 			&i.LastUsedAt,
 		); err != nil {
@@ -88,6 +88,38 @@ func (q *Queries) DeleteAssetByUpdateID(ctx context.Context, updateID pgtype.UUI
 	return err
 }
 
+const getAssetByProjectAndHash = `-- name: GetAssetByProjectAndHash :one
+SELECT a.id, a.update_id, a.file_name, a.mime_type, a.key, a.url, a.hash, a.storage_provider, a.size FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = $1
+AND a.hash = $2
+AND a.storage_provider = $3
+LIMIT 1
+`
+
+type GetAssetByProjectAndHashParams struct {
+	ProjectID       pgtype.UUID `json:"project_id"`
+	Hash            string      `json:"hash"`
+	StorageProvider string      `json:"storage_provider"`
+}
+
+func (q *Queries) GetAssetByProjectAndHash(ctx context.Context, arg GetAssetByProjectAndHashParams) (Asset, error) {
+	row := q.db.QueryRow(ctx, getAssetByProjectAndHash, arg.ProjectID, arg.Hash, arg.StorageProvider)
+	var i Asset
+	err := row.Scan(
+		&i.ID,
+		&i.UpdateID,
+		&i.FileName,
+		&i.MimeType,
+		&i.Key,
+		&i.Url,
+		&i.Hash,
+		&i.StorageProvider,
+		&i.Size,
+	)
+	return i, err
+}
+
 const getAssetsByUpdateID = `-- name: GetAssetsByUpdateID :many
 SELECT id, update_id, file_name, mime_type, key, url, hash, storage_provider, size FROM assets 
 WHERE update_id = $1
@@ -123,3 +155,37 @@ func (q *Queries) GetAssetsByUpdateID(ctx context.Context, updateID pgtype.UUID)
 	}
 	return items, nil
 }
+
+const getProjectAssetByHash = `-- name: GetProjectAssetByHash :one
+SELECT a.id, a.update_id, a.file_name, a.mime_type, a.key, a.url, a.hash, a.storage_provider, a.size FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = $1
+AND a.hash = $2
+ORDER BY a.storage_provider = $3 DESC
+LIMIT 1
+`
+
+type GetProjectAssetByHashParams struct {
+	ProjectID         pgtype.UUID `json:"project_id"`
+	Hash              string      `json:"hash"`
+	PreferredProvider string      `json:"preferred_provider"`
+}
+
+// GetProjectAssetByHash finds a stored blob of a project by content hash,
+// preferring a copy on the given provider.
+func (q *Queries) GetProjectAssetByHash(ctx context.Context, arg GetProjectAssetByHashParams) (Asset, error) {
+	row := q.db.QueryRow(ctx, getProjectAssetByHash, arg.ProjectID, arg.Hash, arg.PreferredProvider)
+	var i Asset
+	err := row.Scan(
+		&i.ID,
+		&i.UpdateID,
+		&i.FileName,
+		&i.MimeType,
+		&i.Key,
+		&i.Url,
+		&i.Hash,
+		&i.StorageProvider,
+		&i.Size,
+	)
+	return i, err
+}
@@ -17,17 +17,19 @@ INSERT INTO download_events (
     project_id,
     device_hash,
     platform,
-    channel
-) VALUES ($1, $2, $3, $4, $5)
-RETURNING id, update_id, project_id, timestamp, device_hash, platform, channel
+    channel,
+    device_id_source
+) VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, update_id, project_id, timestamp, device_hash, platform, channel, device_id_source
 `
 
 type CreateDownloadEventParams struct {
-	UpdateID   pgtype.UUID `json:"update_id"`
-	ProjectID  pgtype.UUID `json:"project_id"`
-	DeviceHash string      `json:"device_hash"`
-	Platform   string      `json:"platform"`
-	Channel    string      `json:"channel"`
+	UpdateID       pgtype.UUID `json:"update_id"`
+	ProjectID      pgtype.UUID `json:"project_id"`
+	DeviceHash     string      `json:"device_hash"`
+	Platform       string      `json:"platform"`
+	Channel        string      `json:"channel"`
+	DeviceIDSource string      `json:"device_id_source"`
 }
 
 func (q *Queries) CreateDownloadEvent(ctx context.Context, arg CreateDownloadEventParams) (DownloadEvent, error) {
@@ -37,6 +39,7 @@ func (q *Queries) CreateDownloadEvent(ctx context.Context, arg CreateDownloadEve
 		arg.DeviceHash,
 		arg.Platform,
 		arg.Channel,
+		arg.DeviceIDSource,
 	)
 	var i DownloadEvent
 	err := row.Scan(
@@ -47,6 +50,7 @@ func (q *Queries) CreateDownloadEvent(ctx context.Context, arg CreateDownloadEve
 		&i.DeviceHash,
 		&i.Platform,
 		&i.Channel,
+		&i.DeviceIDSource,
 	)
 	return i, err
 }
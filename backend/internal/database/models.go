@@ -16,6 +16,9 @@ type ApiKey struct {
 	KeySuffix  string             `json:"key_suffix"`
 	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	Scopes     []string           `json:"scopes"`
+	Channels   []string           `json:"channels"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
 }
 
 type Asset struct {
@@ -30,14 +33,101 @@ type Asset struct {
 	Size            int64       `json:"size"`
 }
 
+type AssetVariant struct {
+	StorageProvider string `json:"storage_provider"`
+	Key             string `json:"key"`
+	Encoding        string `json:"encoding"`
+	VariantKey      string `json:"variant_key"`
+	Url             string `json:"url"`
+	Size            int64  `json:"size"`
+}
+
+type AuditEvent struct {
+	ID        int64              `json:"id"`
+	ActorType string             `json:"actor_type"`
+	ActorID   string             `json:"actor_id"`
+	ActorName string             `json:"actor_name"`
+	Action    string             `json:"action"`
+	Path      string             `json:"path"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Targets   []byte             `json:"targets"`
+	Before    []byte             `json:"before"`
+	After     []byte             `json:"after"`
+	Status    int32              `json:"status"`
+	RequestID string             `json:"request_id"`
+	Ip        string             `json:"ip"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type AutoRollbackPolicy struct {
+	ProjectID    pgtype.UUID        `json:"project_id"`
+	Enabled      bool               `json:"enabled"`
+	MaxErrorRate float64            `json:"max_error_rate"`
+	MinLaunches  int32              `json:"min_launches"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Branch struct {
+	ID        pgtype.UUID        `json:"id"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Name      string             `json:"name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type BundlePatch struct {
+	ProjectID       pgtype.UUID        `json:"project_id"`
+	BaseHash        string             `json:"base_hash"`
+	TargetHash      string             `json:"target_hash"`
+	Status          string             `json:"status"`
+	StorageProvider string             `json:"storage_provider"`
+	Key             string             `json:"key"`
+	Url             string             `json:"url"`
+	Hash            string             `json:"hash"`
+	Size            int64              `json:"size"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+}
+
+type Channel struct {
+	ID                pgtype.UUID        `json:"id"`
+	ProjectID         pgtype.UUID        `json:"project_id"`
+	Name              string             `json:"name"`
+	BranchID          pgtype.UUID        `json:"branch_id"`
+	RolloutBranchID   pgtype.UUID        `json:"rollout_branch_id"`
+	RolloutPercentage int32              `json:"rollout_percentage"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+}
+
+type DirectUpload struct {
+	ID              pgtype.UUID        `json:"id"`
+	UpdateID        pgtype.UUID        `json:"update_id"`
+	Platform        string             `json:"platform"`
+	Activate        bool               `json:"activate"`
+	StorageProvider string             `json:"storage_provider"`
+	ExpoConfig      []byte             `json:"expo_config"`
+	Assets          []byte             `json:"assets"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt       pgtype.Timestamptz `json:"expires_at"`
+}
+
+type DirectUploadBlob struct {
+	DirectUploadID pgtype.UUID `json:"direct_upload_id"`
+	Hash           string      `json:"hash"`
+	Key            string      `json:"key"`
+	MimeType       string      `json:"mime_type"`
+	Size           int64       `json:"size"`
+	Url            string      `json:"url"`
+}
+
 type DownloadEvent struct {
-	ID         int64              `json:"id"`
-	UpdateID   pgtype.UUID        `json:"update_id"`
-	ProjectID  pgtype.UUID        `json:"project_id"`
-	Timestamp  pgtype.Timestamptz `json:"timestamp"`
-	DeviceHash string             `json:"device_hash"`
-	Platform   string             `json:"platform"`
-	Channel    string             `json:"channel"`
+	ID             int64              `json:"id"`
+	UpdateID       pgtype.UUID        `json:"update_id"`
+	ProjectID      pgtype.UUID        `json:"project_id"`
+	Timestamp      pgtype.Timestamptz `json:"timestamp"`
+	DeviceHash     string             `json:"device_hash"`
+	Platform       string             `json:"platform"`
+	Channel        string             `json:"channel"`
+	DeviceIDSource string             `json:"device_id_source"`
 }
 
 type DownloadStat struct {
@@ -50,12 +140,69 @@ type DownloadStat struct {
 	DownloadCount int32       `json:"download_count"`
 }
 
+type Organization struct {
+	ID        pgtype.UUID        `json:"id"`
+	Slug      string             `json:"slug"`
+	Name      string             `json:"name"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID pgtype.UUID        `json:"organization_id"`
+	UserID         pgtype.UUID        `json:"user_id"`
+	Role           string             `json:"role"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
 type Project struct {
-	ID          pgtype.UUID        `json:"id"`
-	Slug        string             `json:"slug"`
-	Name        string             `json:"name"`
-	Description string             `json:"description"`
-	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	ID             pgtype.UUID        `json:"id"`
+	Slug           string             `json:"slug"`
+	Name           string             `json:"name"`
+	Description    string             `json:"description"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	OrganizationID pgtype.UUID        `json:"organization_id"`
+	MaxUploadSize  pgtype.Int8        `json:"max_upload_size"`
+}
+
+type ProjectMember struct {
+	ProjectID pgtype.UUID        `json:"project_id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	Role      string             `json:"role"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type ProjectStorage struct {
+	ID        pgtype.UUID        `json:"id"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Provider  string             `json:"provider"`
+	Bucket    string             `json:"bucket"`
+	Prefix    string             `json:"prefix"`
+	Config    []byte             `json:"config"`
+	Active    bool               `json:"active"`
+	CheckedAt pgtype.Timestamptz `json:"checked_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RolloutEvent struct {
+	ID             int64              `json:"id"`
+	UpdateID       pgtype.UUID        `json:"update_id"`
+	Action         string             `json:"action"`
+	FromPercentage int32              `json:"from_percentage"`
+	ToPercentage   int32              `json:"to_percentage"`
+	Actor          string             `json:"actor"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
+type RolloutSchedule struct {
+	UpdateID            pgtype.UUID        `json:"update_id"`
+	Steps               []int32            `json:"steps"`
+	StepIntervalSeconds int64              `json:"step_interval_seconds"`
+	CurrentStep         int32              `json:"current_step"`
+	Status              string             `json:"status"`
+	NextStepAt          pgtype.Timestamptz `json:"next_step_at"`
+	CreatedAt           pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt           pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Setting struct {
@@ -64,6 +211,52 @@ type Setting struct {
 	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
+type SigningCertificate struct {
+	ID          pgtype.UUID        `json:"id"`
+	ProjectID   pgtype.UUID        `json:"project_id"`
+	KeyID       string             `json:"key_id"`
+	Certificate string             `json:"certificate"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type SigningKey struct {
+	ID          pgtype.UUID        `json:"id"`
+	ProjectID   pgtype.UUID        `json:"project_id"`
+	KeyID       string             `json:"key_id"`
+	Algorithm   string             `json:"algorithm"`
+	PrivateKey  string             `json:"private_key"`
+	Certificate string             `json:"certificate"`
+	IsPrimary   bool               `json:"is_primary"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	RetiredAt   pgtype.Timestamptz `json:"retired_at"`
+}
+
+type StorageGcQueue struct {
+	Key             string             `json:"key"`
+	StorageProvider string             `json:"storage_provider"`
+	MimeType        string             `json:"mime_type"`
+	QueuedAt        pgtype.Timestamptz `json:"queued_at"`
+}
+
+type StorageMigration struct {
+	ID             pgtype.UUID        `json:"id"`
+	ProjectID      pgtype.UUID        `json:"project_id"`
+	SourceProvider string             `json:"source_provider"`
+	TargetProvider string             `json:"target_provider"`
+	DeleteSource   bool               `json:"delete_source"`
+	Status         string             `json:"status"`
+	LastKey        string             `json:"last_key"`
+	TotalObjects   int64              `json:"total_objects"`
+	CopiedObjects  int64              `json:"copied_objects"`
+	CopiedBytes    int64              `json:"copied_bytes"`
+	FailedObjects  int64              `json:"failed_objects"`
+	LastError      string             `json:"last_error"`
+	LeaseUntil     pgtype.Timestamptz `json:"lease_until"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+	CompletedAt    pgtype.Timestamptz `json:"completed_at"`
+}
+
 type Update struct {
 	ID                pgtype.UUID        `json:"id"`
 	ProjectID         pgtype.UUID        `json:"project_id"`
@@ -76,4 +269,67 @@ type Update struct {
 	Message           pgtype.Text        `json:"message"`
 	ExpoConfig        []byte             `json:"expo_config"`
 	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	SignedManifest    []byte             `json:"signed_manifest"`
+	ManifestSignature pgtype.Text        `json:"manifest_signature"`
+}
+
+type UpdateTelemetry struct {
+	UpdateID       pgtype.UUID        `json:"update_id"`
+	ProjectID      pgtype.UUID        `json:"project_id"`
+	Launches       int64              `json:"launches"`
+	JsErrors       int64              `json:"js_errors"`
+	Crashes        int64              `json:"crashes"`
+	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+	RolledBackAt   pgtype.Timestamptz `json:"rolled_back_at"`
+	RollbackReason pgtype.Text        `json:"rollback_reason"`
+}
+
+type UploadSession struct {
+	ID           pgtype.UUID        `json:"id"`
+	UpdateID     pgtype.UUID        `json:"update_id"`
+	Platform     string             `json:"platform"`
+	Activate     bool               `json:"activate"`
+	UploadLength int64              `json:"upload_length"`
+	UploadOffset int64              `json:"upload_offset"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+}
+
+type User struct {
+	ID           pgtype.UUID        `json:"id"`
+	Email        string             `json:"email"`
+	Name         string             `json:"name"`
+	PasswordHash string             `json:"password_hash"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type UserSession struct {
+	ID        pgtype.UUID        `json:"id"`
+	UserID    pgtype.UUID        `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Webhook struct {
+	ID        pgtype.UUID        `json:"id"`
+	ProjectID pgtype.UUID        `json:"project_id"`
+	Url       string             `json:"url"`
+	Secret    string             `json:"secret"`
+	Events    []string           `json:"events"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64              `json:"id"`
+	WebhookID      pgtype.UUID        `json:"webhook_id"`
+	Event          string             `json:"event"`
+	Payload        []byte             `json:"payload"`
+	Status         string             `json:"status"`
+	Attempts       int32              `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	ResponseStatus pgtype.Int4        `json:"response_status"`
+	LastError      string             `json:"last_error"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
 }
@@ -12,19 +12,25 @@ import (
 )
 
 const createProject = `-- name: CreateProject :one
-INSERT INTO projects (slug, name, description) 
-VALUES ($1, $2, $3) 
-RETURNING id, slug, name, description, created_at
+INSERT INTO projects (slug, name, description, organization_id) 
+VALUES ($1, $2, $3, $4) 
+RETURNING id, slug, name, description, created_at, organization_id, max_upload_size
 `
 
 type CreateProjectParams struct {
-	Slug        string `json:"slug"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Slug           string      `json:"slug"`
+	Name           string      `json:"name"`
+	Description    string      `json:"description"`
+	OrganizationID pgtype.UUID `json:"organization_id"`
 }
 
 func (q *Queries) CreateProject(ctx context.Context, arg CreateProjectParams) (Project, error) {
-	row := q.db.QueryRow(ctx, createProject, arg.Slug, arg.Name, arg.Description)
+	row := q.db.QueryRow(ctx, createProject,
+		arg.Slug,
+		arg.Name,
+		arg.Description,
+		arg.OrganizationID,
+	)
 	var i Project
 	err := row.Scan(
 		&i.ID,
@@ -32,12 +38,14 @@ func (q *Queries) CreateProject(ctx context.Context, arg CreateProjectParams) (P
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
 	)
 	return i, err
 }
 
 const deleteProject = `-- name: DeleteProject :one
-DELETE FROM projects WHERE id = $1 RETURNING id, slug, name, description, created_at
+DELETE FROM projects WHERE id = $1 RETURNING id, slug, name, description, created_at, organization_id, max_upload_size
 `
 
 func (q *Queries) DeleteProject(ctx context.Context, id pgtype.UUID) (Project, error) {
@@ -49,12 +57,14 @@ func (q *Queries) DeleteProject(ctx context.Context, id pgtype.UUID) (Project, e
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
 	)
 	return i, err
 }
 
 const getProjectByID = `-- name: GetProjectByID :one
-SELECT id, slug, name, description, created_at FROM projects WHERE id = $1
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects WHERE id = $1
 `
 
 func (q *Queries) GetProjectByID(ctx context.Context, id pgtype.UUID) (Project, error) {
@@ -66,12 +76,14 @@ func (q *Queries) GetProjectByID(ctx context.Context, id pgtype.UUID) (Project,
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
 	)
 	return i, err
 }
 
 const getProjectBySlug = `-- name: GetProjectBySlug :one
-SELECT id, slug, name, description, created_at FROM projects WHERE slug = $1
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects WHERE slug = $1
 `
 
 func (q *Queries) GetProjectBySlug(ctx context.Context, slug string) (Project, error) {
@@ -83,12 +95,87 @@ func (q *Queries) GetProjectBySlug(ctx context.Context, slug string) (Project, e
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
 	)
 	return i, err
 }
 
+const getProjectRoles = `-- name: GetProjectRoles :one
+SELECT
+    COALESCE(om.role, '')::text AS organization_role,
+    COALESCE(pm.role, '')::text AS project_role
+FROM projects p
+LEFT JOIN organization_members om
+    ON om.organization_id = p.organization_id AND om.user_id = $1
+LEFT JOIN project_members pm
+    ON pm.project_id = p.id AND pm.user_id = $1
+WHERE p.id = $2
+`
+
+type GetProjectRolesParams struct {
+	UserID    pgtype.UUID `json:"user_id"`
+	ProjectID pgtype.UUID `json:"project_id"`
+}
+
+type GetProjectRolesRow struct {
+	OrganizationRole string `json:"organization_role"`
+	ProjectRole      string `json:"project_role"`
+}
+
+// The roles a user holds on a project through its organization and
+// directly. Either is empty when the user has none.
+func (q *Queries) GetProjectRoles(ctx context.Context, arg GetProjectRolesParams) (GetProjectRolesRow, error) {
+	row := q.db.QueryRow(ctx, getProjectRoles, arg.UserID, arg.ProjectID)
+	var i GetProjectRolesRow
+	err := row.Scan(&i.OrganizationRole, &i.ProjectRole)
+	return i, err
+}
+
+const listProjectMembers = `-- name: ListProjectMembers :many
+SELECT u.id AS user_id, u.email, u.name, pm.role, pm.created_at
+FROM project_members pm
+JOIN users u ON u.id = pm.user_id
+WHERE pm.project_id = $1
+ORDER BY u.email
+`
+
+type ListProjectMembersRow struct {
+	UserID    pgtype.UUID        `json:"user_id"`
+	Email     string             `json:"email"`
+	Name      string             `json:"name"`
+	Role      string             `json:"role"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+func (q *Queries) ListProjectMembers(ctx context.Context, projectID pgtype.UUID) ([]ListProjectMembersRow, error) {
+	rows, err := q.db.Query(ctx, listProjectMembers, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProjectMembersRow
+	for rows.Next() {
+		var i ListProjectMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.Name,
+			&i.Role,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listProjects = `-- name: ListProjects :many
-SELECT id, slug, name, description, created_at FROM projects ORDER BY created_at DESC
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects ORDER BY created_at DESC
 `
 
 func (q *Queries) ListProjects(ctx context.Context) ([]Project, error) {
@@ -106,6 +193,44 @@ func (q *Queries) ListProjects(ctx context.Context) ([]Project, error) {
 			&i.Name,
 			&i.Description,
 			&i.CreatedAt,
+			&i.OrganizationID,
+			&i.MaxUploadSize,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProjectsForUser = `-- name: ListProjectsForUser :many
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects
+WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE organization_members.user_id = $1)
+   OR id IN (SELECT project_id FROM project_members WHERE project_members.user_id = $1)
+ORDER BY created_at DESC
+`
+
+// Projects a user can see, through an organization or a direct grant.
+func (q *Queries) ListProjectsForUser(ctx context.Context, userID pgtype.UUID) ([]Project, error) {
+	rows, err := q.db.Query(ctx, listProjectsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Project
+	for rows.Next() {
+		var i Project
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Name,
+			&i.Description,
+			&i.CreatedAt,
+			&i.OrganizationID,
+			&i.MaxUploadSize,
 		); err != nil {
 			return nil, err
 		}
@@ -117,11 +242,57 @@ func (q *Queries) ListProjects(ctx context.Context) ([]Project, error) {
 	return items, nil
 }
 
+const removeProjectMember = `-- name: RemoveProjectMember :execrows
+DELETE FROM project_members
+WHERE project_id = $1 AND user_id = $2
+`
+
+type RemoveProjectMemberParams struct {
+	ProjectID pgtype.UUID `json:"project_id"`
+	UserID    pgtype.UUID `json:"user_id"`
+}
+
+func (q *Queries) RemoveProjectMember(ctx context.Context, arg RemoveProjectMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeProjectMember, arg.ProjectID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setProjectMaxUploadSize = `-- name: SetProjectMaxUploadSize :one
+UPDATE projects
+SET max_upload_size = $1
+WHERE id = $2
+RETURNING id, slug, name, description, created_at, organization_id, max_upload_size
+`
+
+type SetProjectMaxUploadSizeParams struct {
+	MaxUploadSize pgtype.Int8 `json:"max_upload_size"`
+	ID            pgtype.UUID `json:"id"`
+}
+
+// NULL falls back to the server's MAX_UPLOAD_SIZE_MB.
+func (q *Queries) SetProjectMaxUploadSize(ctx context.Context, arg SetProjectMaxUploadSizeParams) (Project, error) {
+	row := q.db.QueryRow(ctx, setProjectMaxUploadSize, arg.MaxUploadSize, arg.ID)
+	var i Project
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Name,
+		&i.Description,
+		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
+	)
+	return i, err
+}
+
 const updateProject = `-- name: UpdateProject :one
 UPDATE projects 
 SET name = $2, description = $3 
 WHERE id = $1 
-RETURNING id, slug, name, description, created_at
+RETURNING id, slug, name, description, created_at, organization_id, max_upload_size
 `
 
 type UpdateProjectParams struct {
@@ -139,6 +310,25 @@ func (q *Queries) UpdateProject(ctx context.Context, arg UpdateProjectParams) (P
 		&i.Name,
 		&i.Description,
 		&i.CreatedAt,
+		&i.OrganizationID,
+		&i.MaxUploadSize,
 	)
 	return i, err
 }
+
+const upsertProjectMember = `-- name: UpsertProjectMember :exec
+INSERT INTO project_members (project_id, user_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+`
+
+type UpsertProjectMemberParams struct {
+	ProjectID pgtype.UUID `json:"project_id"`
+	UserID    pgtype.UUID `json:"user_id"`
+	Role      string      `json:"role"`
+}
+
+func (q *Queries) UpsertProjectMember(ctx context.Context, arg UpsertProjectMemberParams) error {
+	_, err := q.db.Exec(ctx, upsertProjectMember, arg.ProjectID, arg.UserID, arg.Role)
+	return err
+}
@@ -34,7 +34,7 @@ INSERT INTO updates (
     message
 ) 
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at
+RETURNING id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at, signed_manifest, manifest_signature
 `
 
 type CreateUpdateParams struct {
@@ -72,6 +72,8 @@ func (q *Queries) CreateUpdate(ctx context.Context, arg CreateUpdateParams) (Upd
 		&i.Message,
 		&i.ExpoConfig,
 		&i.CreatedAt,
+		&i.SignedManifest,
+		&i.ManifestSignature,
 	)
 	return i, err
 }
@@ -163,7 +165,7 @@ func (q *Queries) GetDownloadCountsByUpdateIDs(ctx context.Context, dollar_1 []p
 }
 
 const getLatestActiveUpdate = `-- name: GetLatestActiveUpdate :one
-SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at FROM updates 
+SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at, signed_manifest, manifest_signature FROM updates 
 WHERE is_active = true 
 AND project_id = $1
 AND platform = $2
@@ -200,12 +202,14 @@ func (q *Queries) GetLatestActiveUpdate(ctx context.Context, arg GetLatestActive
 		&i.Message,
 		&i.ExpoConfig,
 		&i.CreatedAt,
+		&i.SignedManifest,
+		&i.ManifestSignature,
 	)
 	return i, err
 }
 
 const getUpdateByID = `-- name: GetUpdateByID :one
-SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at FROM updates WHERE id = $1
+SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at, signed_manifest, manifest_signature FROM updates WHERE id = $1
 `
 
 func (q *Queries) GetUpdateByID(ctx context.Context, id pgtype.UUID) (Update, error) {
@@ -223,6 +227,8 @@ func (q *Queries) GetUpdateByID(ctx context.Context, id pgtype.UUID) (Update, er
 		&i.Message,
 		&i.ExpoConfig,
 		&i.CreatedAt,
+		&i.SignedManifest,
+		&i.ManifestSignature,
 	)
 	return i, err
 }
@@ -251,7 +257,7 @@ func (q *Queries) GetUpdatesCountByProject(ctx context.Context, projectID pgtype
 }
 
 const listUpdatesByProject = `-- name: ListUpdatesByProject :many
-SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at FROM updates 
+SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at, signed_manifest, manifest_signature FROM updates 
 WHERE project_id = $1
 ORDER BY created_at DESC 
 LIMIT $3 OFFSET $2
@@ -284,6 +290,8 @@ func (q *Queries) ListUpdatesByProject(ctx context.Context, arg ListUpdatesByPro
 			&i.Message,
 			&i.ExpoConfig,
 			&i.CreatedAt,
+			&i.SignedManifest,
+			&i.ManifestSignature,
 		); err != nil {
 			return nil, err
 		}
@@ -296,7 +304,7 @@ func (q *Queries) ListUpdatesByProject(ctx context.Context, arg ListUpdatesByPro
 }
 
 const listUpdatesPaginated = `-- name: ListUpdatesPaginated :many
-SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at FROM updates 
+SELECT id, project_id, runtime_version, channel, rollout_percentage, platform, is_active, is_rollback, message, expo_config, created_at, signed_manifest, manifest_signature FROM updates 
 ORDER BY created_at DESC 
 LIMIT $2 OFFSET $1
 `
@@ -327,6 +335,8 @@ func (q *Queries) ListUpdatesPaginated(ctx context.Context, arg ListUpdatesPagin
 			&i.Message,
 			&i.ExpoConfig,
 			&i.CreatedAt,
+			&i.SignedManifest,
+			&i.ManifestSignature,
 		); err != nil {
 			return nil, err
 		}
@@ -338,6 +348,23 @@ func (q *Queries) ListUpdatesPaginated(ctx context.Context, arg ListUpdatesPagin
 	return items, nil
 }
 
+const setUpdateManifestSignature = `-- name: SetUpdateManifestSignature :exec
+UPDATE updates
+SET signed_manifest = $1, manifest_signature = $2
+WHERE id = $3
+`
+
+type SetUpdateManifestSignatureParams struct {
+	SignedManifest    []byte      `json:"signed_manifest"`
+	ManifestSignature pgtype.Text `json:"manifest_signature"`
+	ID                pgtype.UUID `json:"id"`
+}
+
+func (q *Queries) SetUpdateManifestSignature(ctx context.Context, arg SetUpdateManifestSignatureParams) error {
+	_, err := q.db.Exec(ctx, setUpdateManifestSignature, arg.SignedManifest, arg.ManifestSignature, arg.ID)
+	return err
+}
+
 const updateExpoConfig = `-- name: UpdateExpoConfig :exec
 UPDATE updates
 SET expo_config = $1
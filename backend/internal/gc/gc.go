@@ -0,0 +1,361 @@
+package gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+)
+
+// DefaultGracePeriod keeps freshly orphaned objects around long enough for
+// in-flight uploads that already resolved them to commit their asset rows.
+const DefaultGracePeriod = 6 * time.Hour
+
+const batchSize = 500
+
+type Collector struct {
+	pool      *pgxpool.Pool
+	queries   *database.Queries
+	providers *storage.Registry
+	grace     time.Duration
+}
+
+type Options struct {
+	DryRun      bool `json:"dry_run"`
+	ScanOrphans bool `json:"scan_orphans"`
+}
+
+type Object struct {
+	Key      string `json:"key"`
+	Provider string `json:"provider"`
+	Size     int64  `json:"size,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+type Report struct {
+	DryRun      bool      `json:"dry_run"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	// Deleted lists objects that were removed, or would be on a dry run.
+	Deleted []Object `json:"deleted"`
+	// Released counts queue entries dropped because the object is
+	// referenced again (for example by a rollback created after the delete).
+	Released  int      `json:"released"`
+	Unscanned []string `json:"unscanned_providers,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+func NewCollector(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, grace time.Duration) *Collector {
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+	return &Collector{pool: pool, queries: queries, providers: providers, grace: grace}
+}
+
+// Run deletes queued objects that no asset row references anymore, patches
+// between launch assets that are gone and, when requested, objects under a
+// project prefix that were never recorded.
+func (c *Collector) Run(ctx context.Context, opts Options) (*Report, error) {
+	report := &Report{
+		DryRun:    opts.DryRun,
+		StartedAt: time.Now().UTC(),
+		Deleted:   []Object{},
+	}
+
+	if err := c.collectQueued(ctx, opts, report); err != nil {
+		return nil, err
+	}
+	if err := c.collectPatches(ctx, opts, report); err != nil {
+		return nil, err
+	}
+
+	if opts.ScanOrphans {
+		if err := c.collectOrphans(ctx, opts, report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.CompletedAt = time.Now().UTC()
+	return report, nil
+}
+
+func (c *Collector) collectQueued(ctx context.Context, opts Options, report *Report) error {
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(-c.grace), Valid: true}
+
+	candidates, err := c.queries.ListGCCandidates(ctx, database.ListGCCandidatesParams{
+		QueuedAt: cutoff,
+		Limit:    batchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list gc candidates: %w", err)
+	}
+
+	for _, candidate := range candidates {
+		entry := database.DeleteGCQueueEntryParams{
+			Key:             candidate.Key,
+			StorageProvider: candidate.StorageProvider,
+		}
+
+		if candidate.ReferenceCount > 0 {
+			report.Released++
+			if !opts.DryRun {
+				if err := c.queries.DeleteGCQueueEntry(ctx, entry); err != nil {
+					report.Errors = append(report.Errors, err.Error())
+				}
+			}
+			continue
+		}
+
+		object := Object{Key: candidate.Key, Provider: candidate.StorageProvider, Reason: "unreferenced"}
+		variants, err := c.queries.ListAssetVariants(ctx, database.ListAssetVariantsParams{
+			StorageProvider: candidate.StorageProvider,
+			Key:             candidate.Key,
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		if opts.DryRun {
+			report.Deleted = append(report.Deleted, object)
+			for _, variant := range variants {
+				report.Deleted = append(report.Deleted, Object{Key: variant.VariantKey, Provider: variant.StorageProvider, Size: variant.Size, Reason: "variant of unreferenced"})
+			}
+			continue
+		}
+
+		provider, err := c.providers.Get(ctx, candidate.StorageProvider)
+		if errors.Is(err, storage.ErrProviderRemoved) {
+			// The project's own storage went with the project; its
+			// objects are the owner's to clean up.
+			if err := c.queries.DeleteGCQueueEntry(ctx, entry); err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			}
+			continue
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("storage provider %q is not available for %s: %v", candidate.StorageProvider, candidate.Key, err))
+			continue
+		}
+
+		if err := c.deleteQueued(ctx, provider, candidate, object, cutoff, variants, report); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	return nil
+}
+
+// deleteQueued deletes an unreferenced object and its variants while it
+// holds the object's queue entry. Uploads re-queue a key before writing it,
+// so either the entry is fresh again and the object is kept, or the upload
+// waits until the delete is done.
+func (c *Collector) deleteQueued(ctx context.Context, provider storage.Provider, candidate database.ListGCCandidatesRow, object Object, cutoff pgtype.Timestamptz, variants []database.AssetVariant, report *Report) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start gc transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	qtx := c.queries.WithTx(tx)
+
+	entry := database.DeleteGCQueueEntryParams{
+		Key:             candidate.Key,
+		StorageProvider: candidate.StorageProvider,
+	}
+	_, err = qtx.LockGCQueueEntry(ctx, database.LockGCQueueEntryParams{
+		Key:             candidate.Key,
+		StorageProvider: candidate.StorageProvider,
+		QueuedAt:        cutoff,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The object is being, or has just been, uploaded again.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Re-check right before deleting so a rollback that cloned the
+	// asset after the candidate list was read keeps its object.
+	count, err := qtx.CountAssetReferences(ctx, database.CountAssetReferencesParams{
+		Key:             candidate.Key,
+		StorageProvider: candidate.StorageProvider,
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		report.Released++
+		if err := qtx.DeleteGCQueueEntry(ctx, entry); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+
+	if err := provider.Delete(ctx, candidate.Key, candidate.MimeType); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete storage object",
+			slog.String("key", candidate.Key),
+			slog.String("provider", candidate.StorageProvider),
+			slog.Any("error", err),
+		)
+		return fmt.Errorf("failed to delete %s: %v", candidate.Key, err)
+	}
+	report.Deleted = append(report.Deleted, object)
+
+	// Compressed variants go with the object. The queue entry is kept
+	// until they are gone, so a failed delete is retried.
+	if !c.deleteVariants(ctx, qtx, provider, variants, report) {
+		return nil
+	}
+	if err := qtx.DeleteGCQueueEntry(ctx, entry); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// deleteVariants removes the compressed variants of a deleted object and
+// their rows, reporting whether all of them are gone.
+func (c *Collector) deleteVariants(ctx context.Context, queries *database.Queries, provider storage.Provider, variants []database.AssetVariant, report *Report) bool {
+	if len(variants) == 0 {
+		return true
+	}
+	for _, variant := range variants {
+		if err := provider.Delete(ctx, variant.VariantKey, ""); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("failed to delete %s: %v", variant.VariantKey, err))
+			return false
+		}
+		report.Deleted = append(report.Deleted, Object{Key: variant.VariantKey, Provider: variant.StorageProvider, Size: variant.Size, Reason: "variant of unreferenced"})
+	}
+	err := queries.DeleteAssetVariants(ctx, database.DeleteAssetVariantsParams{
+		StorageProvider: variants[0].StorageProvider,
+		Key:             variants[0].Key,
+	})
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return false
+	}
+	return true
+}
+
+// collectPatches removes bundle patches from or to launch assets that no
+// update uses anymore, along with their rows.
+func (c *Collector) collectPatches(ctx context.Context, opts Options, report *Report) error {
+	patches, err := c.queries.ListObsoleteBundlePatches(ctx, database.ListObsoleteBundlePatchesParams{
+		CreatedBefore: pgtype.Timestamptz{Time: time.Now().Add(-c.grace), Valid: true},
+		Limit:         batchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list obsolete patches: %w", err)
+	}
+
+	for _, patch := range patches {
+		object := Object{Key: patch.Key, Provider: patch.StorageProvider, Size: patch.Size, Reason: "obsolete patch"}
+		if opts.DryRun {
+			if patch.Key != "" {
+				report.Deleted = append(report.Deleted, object)
+			}
+			continue
+		}
+
+		if patch.Key != "" {
+			provider, err := c.providers.Get(ctx, patch.StorageProvider)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("storage provider %q is not available for %s: %v", patch.StorageProvider, patch.Key, err))
+				continue
+			}
+			if err := provider.Delete(ctx, patch.Key, "application/octet-stream"); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to delete %s: %v", patch.Key, err))
+				continue
+			}
+			report.Deleted = append(report.Deleted, object)
+		}
+
+		err := c.queries.DeleteBundlePatch(ctx, database.DeleteBundlePatchParams{
+			ProjectID:  patch.ProjectID,
+			BaseHash:   patch.BaseHash,
+			TargetHash: patch.TargetHash,
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	return nil
+}
+
+var errStopListing = errors.New("stop listing")
+
+func (c *Collector) collectOrphans(ctx context.Context, opts Options, report *Report) error {
+	projects, err := c.queries.ListProjects(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	cutoff := time.Now().Add(-c.grace)
+
+	// Buckets projects bring themselves are not scanned; they may hold
+	// objects the owner put there.
+	for name, provider := range c.providers.Server() {
+		lister, ok := provider.(storage.Lister)
+		if !ok {
+			report.Unscanned = append(report.Unscanned, name)
+			continue
+		}
+
+		keys, err := c.queries.ListReferencedAssetKeys(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to list referenced keys: %w", err)
+		}
+		referenced := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			referenced[key] = true
+		}
+
+		// Only look under project prefixes so a shared bucket never loses
+		// objects that OTAship did not write.
+		for _, project := range projects {
+			var orphans []storage.ObjectInfo
+			err := lister.List(ctx, project.Slug+"/", func(info storage.ObjectInfo) error {
+				if referenced[info.Key] || info.LastModified.After(cutoff) {
+					return nil
+				}
+				orphans = append(orphans, info)
+				if len(orphans) >= batchSize {
+					return errStopListing
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, errStopListing) {
+				report.Errors = append(report.Errors, fmt.Sprintf("failed to list %s objects for %s: %v", name, project.Slug, err))
+				continue
+			}
+
+			for _, info := range orphans {
+				object := Object{Key: info.Key, Provider: name, Size: info.Size, Reason: "orphaned"}
+				if opts.DryRun {
+					report.Deleted = append(report.Deleted, object)
+					continue
+				}
+
+				count, err := c.queries.CountAssetReferences(ctx, database.CountAssetReferencesParams{
+					Key:             info.Key,
+					StorageProvider: name,
+				})
+				if err != nil || count > 0 {
+					continue
+				}
+
+				if err := provider.Delete(ctx, info.Key, ""); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("failed to delete %s: %v", info.Key, err))
+					continue
+				}
+				report.Deleted = append(report.Deleted, object)
+			}
+		}
+	}
+	return nil
+}
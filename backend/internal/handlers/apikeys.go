@@ -4,32 +4,53 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/auth"
 	"github.com/vknow360/otaship/backend/internal/database"
 	"github.com/vknow360/otaship/backend/internal/utils"
 )
 
 type CreateAPIKeyRequest struct {
 	Name string `json:"name"`
+	// Scopes defaults to every scope.
+	Scopes []string `json:"scopes"`
+	// Channels limits the key to these channels and branches. Empty allows
+	// all of them.
+	Channels []string `json:"channels"`
+	// ExpiresAt is in Unix milliseconds. Zero means the key never expires.
+	ExpiresAt int64 `json:"expires_at"`
 }
 
 type CreateAPIKeyResponse struct {
-	APIKey    string `json:"api_key"`
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	KeySuffix string `json:"key_suffix"`
-	CreatedAt int64  `json:"created_at"`
-	LastUsed  int64  `json:"last_used"`
+	APIKey    string   `json:"api_key"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	KeySuffix string   `json:"key_suffix"`
+	Scopes    []string `json:"scopes"`
+	Channels  []string `json:"channels"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+	LastUsed  int64    `json:"last_used"`
 }
 
 type ListAPIKeysResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	KeySuffix string `json:"key_suffix"`
-	CreatedAt int64  `json:"created_at"`
-	LastUsed  int64  `json:"last_used"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	KeySuffix string   `json:"key_suffix"`
+	Scopes    []string `json:"scopes"`
+	Channels  []string `json:"channels"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+	Expired   bool     `json:"expired"`
+	CreatedAt int64    `json:"created_at"`
+	LastUsed  int64    `json:"last_used"`
 }
 
 func toListAPIKeysResponse(k database.ListAPIKeysRow) ListAPIKeysResponse {
@@ -38,13 +59,67 @@ func toListAPIKeysResponse(k database.ListAPIKeysRow) ListAPIKeysResponse {
 	if k.LastUsedAt.Valid {
 		lastUsed = k.LastUsedAt.Time.UnixMilli()
 	}
-	return ListAPIKeysResponse{
+	res := ListAPIKeysResponse{
 		ID:        k.ID.String(),
 		Name:      k.Name,
 		KeySuffix: k.KeySuffix,
+		Scopes:    k.Scopes,
+		Channels:  k.Channels,
 		CreatedAt: k.CreatedAt.Time.UnixMilli(),
 		LastUsed:  lastUsed,
 	}
+	if res.Channels == nil {
+		res.Channels = []string{}
+	}
+	if k.ExpiresAt.Valid {
+		res.ExpiresAt = k.ExpiresAt.Time.UnixMilli()
+		res.Expired = !k.ExpiresAt.Time.After(time.Now())
+	}
+	return res
+}
+
+// parseKeyChannels validates a channel allowlist and drops duplicates.
+func parseKeyChannels(channels []string) ([]string, error) {
+	res := []string{}
+	for _, c := range channels {
+		if !channelNameRegex.MatchString(c) {
+			return nil, fmt.Errorf("invalid channel name %q", c)
+		}
+		if !slices.Contains(res, c) {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+// keyAllowsChannel checks that the API key a project request was made with
+// may act on the named channel or branch, writing the error response when it
+// may not. The empty name stands for the whole project. Requests without an
+// API key, such as admin requests, always pass.
+func keyAllowsChannel(w http.ResponseWriter, r *http.Request, name string) bool {
+	key, ok := auth.APIKeyFrom(r.Context())
+	if !ok || key.AllowsChannel(name) {
+		return true
+	}
+	if name == "" {
+		jsonError(w, "API key is limited to channels "+strings.Join(key.Channels, ", "), http.StatusForbidden)
+	} else {
+		jsonError(w, "API key is not allowed to use "+name, http.StatusForbidden)
+	}
+	return false
+}
+
+// keyAllowsTargets checks every channel and branch name a publish names, and
+// the branch they resolve to, against the API key's channel allowlist. A
+// name that is allowed must not lead to one that is not. Empty names are
+// skipped.
+func keyAllowsTargets(w http.ResponseWriter, r *http.Request, names ...string) bool {
+	for _, name := range names {
+		if name != "" && !keyAllowsChannel(w, r, name) {
+			return false
+		}
+	}
+	return true
 }
 
 func CreateAPIKey(queries *database.Queries) http.HandlerFunc {
@@ -65,6 +140,25 @@ func CreateAPIKey(queries *database.Queries) http.HandlerFunc {
 			jsonError(w, "Name is required", http.StatusBadRequest)
 			return
 		}
+		scopes, err := auth.ParseScopes(req.Scopes)
+		if err != nil {
+			jsonError(w, "Invalid scopes: "+err.Error()+"; use read, publish, rollback or delete", http.StatusBadRequest)
+			return
+		}
+		channels, err := parseKeyChannels(req.Channels)
+		if err != nil {
+			jsonError(w, "Invalid channels: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var expiresAt pgtype.Timestamptz
+		if req.ExpiresAt != 0 {
+			expiresAt = pgtype.Timestamptz{Time: time.UnixMilli(req.ExpiresAt), Valid: true}
+			if !expiresAt.Time.After(time.Now()) {
+				jsonError(w, "expires_at must be in the future", http.StatusBadRequest)
+				return
+			}
+		}
+
 		apiKey := utils.GenerateAPIKey()
 		keySuffix := apiKey[len(apiKey)-16:]
 
@@ -77,12 +171,23 @@ func CreateAPIKey(queries *database.Queries) http.HandlerFunc {
 			Name:      req.Name,
 			KeyHash:   string(hashedKey),
 			KeySuffix: keySuffix,
+			Scopes:    scopes,
+			Channels:  channels,
+			ExpiresAt: expiresAt,
 		})
 		if err != nil {
 			jsonError(w, "Failed to create API key", http.StatusInternalServerError)
 			return
 		}
 
+		audit.SetTarget(r.Context(), "key_id", key.ID.String())
+		audit.SetChange(r.Context(), nil, map[string]any{
+			"name":       key.Name,
+			"scopes":     key.Scopes,
+			"channels":   channels,
+			"expires_at": req.ExpiresAt,
+		})
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(CreateAPIKeyResponse{
@@ -90,6 +195,9 @@ func CreateAPIKey(queries *database.Queries) http.HandlerFunc {
 			ID:        key.ID.String(),
 			Name:      key.Name,
 			KeySuffix: key.KeySuffix,
+			Scopes:    key.Scopes,
+			Channels:  channels,
+			ExpiresAt: req.ExpiresAt,
 			CreatedAt: key.CreatedAt.Time.UnixMilli(),
 			LastUsed:  key.LastUsedAt.Time.UnixMilli(),
 		})
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+// assetRedirectTTL is how long the storage URLs the proxy redirects to
+// stay valid. Devices follow the redirect straight away.
+const assetRedirectTTL = 15 * time.Minute
+
+// localAssetScope takes the place of the project ID in signatures of local
+// storage URLs, so they cannot be used as proxy URLs or the other way round.
+const localAssetScope = "local"
+
+var (
+	errAssetURLInvalid = errors.New("invalid asset URL signature")
+	errAssetURLExpired = errors.New("asset URL has expired")
+)
+
+// AssetURLSigner builds the asset proxy URLs manifests point devices at.
+// URLs carry an HMAC of the project, hash and expiry, so the proxy only
+// serves what the server handed out and storage can stay private.
+type AssetURLSigner struct {
+	baseURL   string
+	secret    []byte
+	ttl       time.Duration
+	signedTTL time.Duration
+}
+
+// NewAssetURLSigner returns a signer for URLs under baseURL that stay valid
+// for ttl after the manifest holding them is built. URLs in manifests signed
+// at publish time stay valid for signedTTL after the update was created.
+func NewAssetURLSigner(baseURL string, secret []byte, ttl, signedTTL time.Duration) *AssetURLSigner {
+	return &AssetURLSigner{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		secret:    secret,
+		ttl:       ttl,
+		signedTTL: signedTTL,
+	}
+}
+
+// assetURLs is nil unless manifests go through the asset proxy.
+var assetURLs *AssetURLSigner
+
+// SetAssetURLSigner makes manifests point at the asset proxy instead of the
+// storage provider's URLs. Call it before serving requests.
+func SetAssetURLSigner(signer *AssetURLSigner) {
+	assetURLs = signer
+}
+
+// URL returns the signed proxy URL of an asset, valid until expires.
+func (s *AssetURLSigner) URL(projectID, hash string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", s.signature(projectID, hash, expires.Unix()))
+	return s.baseURL + "/api/assets/" + projectID + "/" + hash + "?" + query.Encode()
+}
+
+// localURL signs rawURL, the local storage URL of key. While the asset
+// proxy is on, the /assets route only serves URLs signed this way.
+func (s *AssetURLSigner) localURL(rawURL, key string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("sig", s.signature(localAssetScope, key, expires.Unix()))
+	return rawURL + "?" + query.Encode()
+}
+
+func (s *AssetURLSigner) signature(projectID, hash string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(projectID + "/" + hash + "/" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature and expiry of a proxy URL's query. URLs
+// without an expiry are refused.
+func (s *AssetURLSigner) verify(projectID, hash string, query url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || expires <= 0 {
+		return errAssetURLInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(query.Get("sig"))
+	if err != nil {
+		return errAssetURLInvalid
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(s.signature(projectID, hash, expires))
+	if !hmac.Equal(sig, expected) {
+		return errAssetURLInvalid
+	}
+	if now.Unix() > expires {
+		return errAssetURLExpired
+	}
+	return nil
+}
+
+// manifestURLExpiry is when asset URLs in a newly built manifest expire.
+// Manifests are cached for manifestCacheTTL, so devices get URLs that are
+// valid for at least the difference.
+func manifestURLExpiry() time.Time {
+	if assetURLs == nil {
+		return time.Time{}
+	}
+	return time.Now().Add(assetURLs.ttl)
+}
+
+// signedManifestURLExpiry is when asset URLs in an update's publish-time
+// signed manifest expire. It only depends on the update, so the manifest
+// the CLI signs and the one the signature is checked against are the same
+// bytes. Once it passes, the update has to be republished and signed again.
+func signedManifestURLExpiry(update database.Update) time.Time {
+	if assetURLs == nil {
+		return time.Time{}
+	}
+	return update.CreatedAt.Time.Add(assetURLs.signedTTL)
+}
+
+// signedURLsExpireAt returns when the asset URLs in an update's publish-time
+// signed manifest stop working, or the zero time if they do not expire.
+func signedURLsExpireAt(update database.Update) time.Time {
+	if update.SignedManifest == nil || update.IsRollback {
+		return time.Time{}
+	}
+	return signedManifestURLExpiry(update)
+}
+
+// proxiedAsset is what the proxy caches about a blob.
+type proxiedAsset struct {
+	StorageProvider string `json:"storage_provider"`
+	Key             string `json:"key"`
+	Url             string `json:"url"`
+	// Variants maps encodings to the keys of compressed variants.
+	Variants map[string]string `json:"variants"`
+}
+
+func proxiedAssetCacheKey(projectID, hash string) string {
+	return projectID + ":asset:" + hash
+}
+
+// ProxyAsset serves /api/assets/{project_id}/{hash} for signed URLs. Blobs
+// on providers that presign downloads are redirected to; local blobs are
+// streamed, and the rest redirect to the URL stored with the asset.
+func ProxyAsset(queries *database.Queries, providers *storage.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "project_id")
+		hash := chi.URLParam(r, "hash")
+
+		projectID, err := utils.ParseUUID(id)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		if err := assetURLs.verify(id, hash, r.URL.Query(), time.Now()); err != nil {
+			jsonError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		asset, err := lookupProxiedAsset(r.Context(), queries, providers, projectID, hash)
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to look up asset", slog.String("hash", hash), slog.Any("error", err))
+			jsonError(w, "Failed to fetch asset", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		key := asset.Key
+		for _, encoding := range acceptedEncodings(r.Header.Get("Accept-Encoding")) {
+			if variantKey, ok := asset.Variants[encoding.Name]; ok {
+				key = variantKey
+				break
+			}
+		}
+
+		provider, err := providers.Get(r.Context(), asset.StorageProvider)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load storage provider", slog.String("provider", asset.StorageProvider), slog.Any("error", err))
+			jsonError(w, "Failed to fetch asset", http.StatusInternalServerError)
+			return
+		}
+
+		switch provider := provider.(type) {
+		case storage.DownloadPresigner:
+			target, err := provider.PresignDownload(r.Context(), key, assetRedirectTTL)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to presign asset download", slog.String("key", key), slog.Any("error", err))
+				jsonError(w, "Failed to fetch asset", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			http.Redirect(w, r, target, http.StatusFound)
+
+		case *storage.LocalProvider:
+			object, err := provider.Open(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+					jsonError(w, "Asset not found", http.StatusNotFound)
+					return
+				}
+				slog.ErrorContext(r.Context(), "Failed to open local asset", slog.String("key", key), slog.Any("error", err))
+				jsonError(w, "Failed to read asset", http.StatusInternalServerError)
+				return
+			}
+			defer object.Close()
+			serveLocalObject(w, r, object)
+
+		default:
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			http.Redirect(w, r, asset.Url, http.StatusFound)
+		}
+	}
+}
+
+// lookupProxiedAsset finds a blob by hash, preferring the copy on the
+// provider new updates are published to.
+func lookupProxiedAsset(ctx context.Context, queries *database.Queries, providers *storage.Registry, projectID pgtype.UUID, hash string) (*proxiedAsset, error) {
+	cacheKey := proxiedAssetCacheKey(projectID.String(), hash)
+	if data, ok, err := manifestCache.Get(ctx, cacheKey); err == nil && ok {
+		var asset proxiedAsset
+		if json.Unmarshal(data, &asset) == nil {
+			return &asset, nil
+		}
+	}
+
+	var preferred string
+	if provider, err := publishProvider(ctx, queries, providers, projectID); err == nil && provider != nil {
+		preferred = provider.Name()
+	}
+	row, err := queries.GetProjectAssetByHash(ctx, database.GetProjectAssetByHashParams{
+		ProjectID:         projectID,
+		Hash:              hash,
+		PreferredProvider: preferred,
+	})
+	if err != nil {
+		return nil, err
+	}
+	variants, err := queries.ListAssetVariants(ctx, database.ListAssetVariantsParams{
+		StorageProvider: row.StorageProvider,
+		Key:             row.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asset := &proxiedAsset{
+		StorageProvider: row.StorageProvider,
+		Key:             row.Key,
+		Url:             row.Url,
+		Variants:        make(map[string]string, len(variants)),
+	}
+	for _, variant := range variants {
+		asset.Variants[variant.Encoding] = variant.VariantKey
+	}
+
+	encoded, err := json.Marshal(asset)
+	if err == nil {
+		err = manifestCache.Set(ctx, cacheKey, encoded, manifestCacheTTL)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Manifest cache write failed", slog.Any("error", err))
+	}
+	return asset, nil
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssetURLSigner(t *testing.T) {
+	signer := NewAssetURLSigner("https://ota.example.com/", []byte("secret"), time.Hour, 24*time.Hour)
+	now := time.Unix(1700000000, 0)
+	project, hash := "0b8f8a4e-3f3e-4a43-9d4a-6f1f0c4c2d11", "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg"
+
+	query := func(rawURL string) url.Values {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Query()
+	}
+
+	expiring := signer.URL(project, hash, now.Add(time.Hour))
+	if want := "https://ota.example.com/api/assets/" + project + "/" + hash + "?"; !strings.HasPrefix(expiring, want) {
+		t.Fatalf("URL = %q, want prefix %q", expiring, want)
+	}
+	tampered := query(expiring)
+	tampered.Set("expires", "9999999999")
+	// URLs signed without an expiry used to be accepted forever.
+	noExpiry := url.Values{"sig": {signer.signature(project, hash, 0)}}
+
+	tests := []struct {
+		name  string
+		hash  string
+		query url.Values
+		now   time.Time
+		want  error
+	}{
+		{"valid", hash, query(expiring), now, nil},
+		{"expired", hash, query(expiring), now.Add(2 * time.Hour), errAssetURLExpired},
+		{"other asset", "other", query(expiring), now, errAssetURLInvalid},
+		{"extended expiry", hash, tampered, now, errAssetURLInvalid},
+		{"unsigned", hash, url.Values{}, now, errAssetURLInvalid},
+		{"no expiry", hash, noExpiry, now, errAssetURLInvalid},
+	}
+	for _, tt := range tests {
+		if err := signer.verify(project, tt.hash, tt.query, tt.now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: verify() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	other := NewAssetURLSigner("https://ota.example.com", []byte("other"), time.Hour, 24*time.Hour)
+	if err := other.verify(project, hash, query(expiring), now); !errors.Is(err, errAssetURLInvalid) {
+		t.Errorf("verify() with another secret = %v, want %v", err, errAssetURLInvalid)
+	}
+}
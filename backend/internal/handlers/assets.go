@@ -2,12 +2,12 @@ package handlers
 
 import (
 	"archive/zip"
-	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -16,10 +16,14 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/projectstorage"
 	"github.com/vknow360/otaship/backend/internal/storage"
 	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
 )
 
 type ExpoMetadata struct {
@@ -46,80 +50,56 @@ type UploadedAsset struct {
 	Hash        string
 	ContentType string
 	Size        int64
+	// Reused is set when the blob already existed in storage and only a new
+	// asset row pointing at it is needed.
+	Reused bool
 }
 
-func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers map[string]storage.Provider) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		updateIdStr := chi.URLParam(r, "update_id")
-		projectIdStr := chi.URLParam(r, "project_id")
-		if updateIdStr == "" {
-			jsonError(w, "Update ID is required", http.StatusBadRequest)
-			return
-		}
-		if projectIdStr == "" {
-			jsonError(w, "Project ID is required", http.StatusBadRequest)
-			return
-		}
-		updateId, err := utils.ParseUUID(updateIdStr)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "Invalid update ID", slog.String("update_id", updateIdStr), slog.Any("error", err))
-			jsonError(w, "Invalid update ID", http.StatusBadRequest)
-			return
-		}
-		projectId, err := utils.ParseUUID(projectIdStr)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "Invalid project ID", slog.String("project_id", projectIdStr), slog.Any("error", err))
-			jsonError(w, "Invalid project ID", http.StatusBadRequest)
-			return
-		}
-
-		if projectId != utils.GetProjectId(r.Context()) {
-			slog.WarnContext(r.Context(), "Project ID does not match the authenticated user", slog.String("project_id", projectIdStr))
-			jsonError(w, "Project ID does not match the authenticated user", http.StatusForbidden)
-			return
-		}
-
-		project, err := queries.GetProjectByID(r.Context(), projectId)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "Project not found", slog.String("project_id", projectIdStr))
-			jsonError(w, "Project not found", http.StatusNotFound)
-			return
-		}
-
-		tx, err := pool.Begin(r.Context())
-		if err != nil {
-			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
-			return
-		}
+// UploadConfig configures bundle uploads.
+type UploadConfig struct {
+	// Dir holds bundles while they are processed and the chunks of
+	// resumable uploads.
+	Dir string
+	// MaxSize is the largest bundle in bytes accepted from projects that do
+	// not set their own limit.
+	MaxSize int64
+}
 
-		defer tx.Rollback(r.Context())
+// maxSize returns the largest bundle project may upload.
+func (c UploadConfig) maxSize(project database.Project) int64 {
+	if project.MaxUploadSize.Valid {
+		return project.MaxUploadSize.Int64
+	}
+	return c.MaxSize
+}
 
-		qtx := queries.WithTx(tx)
+func uploadTooLarge(w http.ResponseWriter, limit int64) {
+	jsonError(w, fmt.Sprintf("Bundle exceeds the upload limit of %d bytes", limit), http.StatusRequestEntityTooLarge)
+}
 
-		update, err := qtx.GetUpdateByID(r.Context(), updateId)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "Update not found",
-				slog.String("update_id", updateIdStr),
-				slog.Any("error", err),
-			)
-			jsonError(w, "Update not found", http.StatusNotFound)
-			return
-		}
+// multipartMemory is how much of a multipart upload is kept in memory
+// before the rest spills to temporary files.
+const multipartMemory = 32 << 20
 
-		if update.ProjectID != project.ID {
-			slog.WarnContext(r.Context(), "Update does not belong to project",
-				slog.String("update_id", update.ID.String()),
-				slog.String("project_id", project.ID.String()),
-			)
-			jsonError(w, "Update does not belong to the specified project", http.StatusBadRequest)
+// UploadAsset takes a whole bundle in one multipart request. Large bundles
+// on unreliable networks should use the resumable upload endpoints instead.
+func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project, update, ok := uploadTarget(w, r, queries)
+		if !ok {
 			return
 		}
 
-		const maxUploadSize = 50 << 20
+		maxUploadSize := uploads.maxSize(project)
 		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 
-		if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-			jsonError(w, "File too large", http.StatusBadRequest)
+		if err := r.ParseMultipartForm(multipartMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				uploadTooLarge(w, maxUploadSize)
+				return
+			}
+			jsonError(w, "Invalid multipart form", http.StatusBadRequest)
 			return
 		}
 
@@ -133,6 +113,10 @@ func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers map[st
 			return
 		}
 
+		// Publishing with activate=false leaves the update inactive until a
+		// publish-time signature is submitted for it.
+		activate := r.FormValue("activate") != "false"
+
 		file, header, err := r.FormFile("bundle")
 		if err != nil {
 			jsonError(w, "Bundle file is required", http.StatusBadRequest)
@@ -151,8 +135,8 @@ func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers map[st
 			return
 		}
 
-		os.MkdirAll("./uploads", 0755)
-		tempFile, err := os.CreateTemp("./uploads/", "otaship-upload-*.zip")
+		os.MkdirAll(uploads.Dir, 0755)
+		tempFile, err := os.CreateTemp(uploads.Dir, "otaship-upload-*.zip")
 		if err != nil {
 			slog.ErrorContext(r.Context(), "Failed to create temp file", slog.Any("error", err))
 			jsonError(w, "Failed to create temp file", http.StatusInternalServerError)
@@ -167,118 +151,277 @@ func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers map[st
 			return
 		}
 
-		zipReader, err := zip.NewReader(tempFile, bytesWritten)
-		if err != nil {
-			jsonError(w, "Failed to read zip file", http.StatusBadRequest)
-			return
-		}
+		publishBundle(w, r, pool, queries, providers, project, update, tempFile, bytesWritten, platform, activate)
+	}
+}
 
-		metadata, expoConfig, err := parseZipMetadata(r.Context(), zipReader)
-		if err != nil {
-			slog.ErrorContext(r.Context(), "Failed to parse metadata", slog.Any("error", err))
-			jsonError(w, "Failed to parse metadata", http.StatusBadRequest)
-			return
-		}
+// uploadTarget loads the project and update an upload request is for and
+// checks that the caller may upload to them, writing the error response
+// when it may not.
+func uploadTarget(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.Project, database.Update, bool) {
+	updateIdStr := chi.URLParam(r, "update_id")
+	projectIdStr := chi.URLParam(r, "project_id")
+	if updateIdStr == "" {
+		jsonError(w, "Update ID is required", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, false
+	}
+	if projectIdStr == "" {
+		jsonError(w, "Project ID is required", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, false
+	}
+	updateId, err := utils.ParseUUID(updateIdStr)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Invalid update ID", slog.String("update_id", updateIdStr), slog.Any("error", err))
+		jsonError(w, "Invalid update ID", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, false
+	}
+	projectId, err := utils.ParseUUID(projectIdStr)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Invalid project ID", slog.String("project_id", projectIdStr), slog.Any("error", err))
+		jsonError(w, "Invalid project ID", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, false
+	}
+
+	if projectId != utils.GetProjectId(r.Context()) {
+		slog.WarnContext(r.Context(), "Project ID does not match the authenticated user", slog.String("project_id", projectIdStr))
+		jsonError(w, "Project ID does not match the authenticated user", http.StatusForbidden)
+		return database.Project{}, database.Update{}, false
+	}
+
+	project, err := queries.GetProjectByID(r.Context(), projectId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Project not found", slog.String("project_id", projectIdStr))
+		jsonError(w, "Project not found", http.StatusNotFound)
+		return database.Project{}, database.Update{}, false
+	}
+
+	update, err := queries.GetUpdateByID(r.Context(), updateId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Update not found",
+			slog.String("update_id", updateIdStr),
+			slog.Any("error", err),
+		)
+		jsonError(w, "Update not found", http.StatusNotFound)
+		return database.Project{}, database.Update{}, false
+	}
+
+	if update.ProjectID != project.ID {
+		slog.WarnContext(r.Context(), "Update does not belong to project",
+			slog.String("update_id", update.ID.String()),
+			slog.String("project_id", project.ID.String()),
+		)
+		jsonError(w, "Update does not belong to the specified project", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, false
+	}
+	if !keyAllowsChannel(w, r, update.Channel) {
+		return database.Project{}, database.Update{}, false
+	}
+	return project, update, true
+}
+
+// publishBundle stores the assets of an exported bundle for update and,
+// with activate set, makes it the active update on its branch. It writes
+// the response and reports whether the bundle was published.
+func publishBundle(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, project database.Project, update database.Update, bundle io.ReaderAt, size int64, platform string, activate bool) bool {
+	zipReader, err := zip.NewReader(bundle, size)
+	if err != nil {
+		jsonError(w, "Failed to read zip file", http.StatusBadRequest)
+		return false
+	}
 
-		// Store expoConfig on the update
-		if expoConfig != nil {
-			err = qtx.UpdateExpoConfig(r.Context(), database.UpdateExpoConfigParams{
-				ExpoConfig: expoConfig,
-				ID:         update.ID,
+	metadata, expoConfig, err := parseZipMetadata(r.Context(), zipReader)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to parse metadata", slog.Any("error", err))
+		jsonError(w, "Failed to parse metadata", http.StatusBadRequest)
+		return false
+	}
+
+	tx, err := pool.Begin(r.Context())
+	if err != nil {
+		jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+		return false
+	}
+
+	defer tx.Rollback(r.Context())
+
+	qtx := queries.WithTx(tx)
+
+	platformMetadata, exists := metadata.FileMetadata[platform]
+	if !exists {
+		slog.ErrorContext(r.Context(), "Platform metadata not found", slog.String("platform", platform))
+		jsonError(w, "Platform metadata not found in bundle", http.StatusBadRequest)
+		return false
+	}
+
+	replaceUpdateAssets(r.Context(), qtx, update, expoConfig)
+
+	filesToUpload := make(map[string]bool)
+	normalizedBundle := normalizeAssetPath(platformMetadata.Bundle)
+	filesToUpload[normalizedBundle] = true
+
+	for _, asset := range platformMetadata.Assets {
+		normalized := normalizeAssetPath(asset.Path)
+		filesToUpload[normalized] = true
+	}
+
+	storage, err := publishProvider(r.Context(), qtx, providers, project.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load project storage", slog.Any("error", err))
+		jsonError(w, "Project storage is unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+
+	var uploadedAssets []UploadedAsset
+	var newAssets []UploadedAsset
+	blobs := make(map[string]UploadedAsset)
+
+	// Blobs written by this request are handed to the garbage collector
+	// rather than deleted outright: a concurrent publish of the same
+	// content may already point at them.
+	cleanupAssets := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, asset := range newAssets {
+			err := queries.QueueStorageObjectForGC(ctx, database.QueueStorageObjectForGCParams{
+				Key:             asset.StorageKey,
+				StorageProvider: storage.Name(),
+				MimeType:        asset.ContentType,
 			})
 			if err != nil {
-				slog.WarnContext(r.Context(), "Failed to store expo config", slog.Any("error", err))
+				slog.ErrorContext(ctx, "Failed to queue asset for cleanup", slog.Any("error", err))
 			}
 		}
+	}
 
-		platformMetadata, exists := metadata.FileMetadata[platform]
-		if !exists {
-			slog.ErrorContext(r.Context(), "Platform metadata not found", slog.String("platform", platform))
-			jsonError(w, "Platform metadata not found in bundle", http.StatusBadRequest)
-			return
-		}
+	failUpload := func(message string) {
+		cleanupAssets()
 
-		err = qtx.DeleteAssetByUpdateID(r.Context(), update.ID)
+		// also delete update from db
+		err := qtx.DeleteUpdate(r.Context(), update.ID)
 		if err != nil {
-			slog.WarnContext(r.Context(), "Failed to delete old assets", slog.Any("error", err))
-		}
-
-		filesToUpload := make(map[string]bool)
-		normalizedBundle := normalizeAssetPath(platformMetadata.Bundle)
-		filesToUpload[normalizedBundle] = true
-
-		for _, asset := range platformMetadata.Assets {
-			normalized := normalizeAssetPath(asset.Path)
-			filesToUpload[normalized] = true
+			slog.ErrorContext(r.Context(), "Failed to delete update", slog.Any("error", err))
 		}
+		jsonError(w, message, http.StatusInternalServerError)
+	}
 
-		providerName, err := qtx.GetSetting(r.Context(), "storage_provider")
-		storage, ok := providers[providerName.Value]
-		if !ok {
-			slog.WarnContext(r.Context(), "Storage provider not found", slog.String("provider", providerName.Value))
-			for _, provider := range providers {
-				storage = provider
-				break
-			}
+	for _, zipFile := range zipReader.File {
+		normalizedZipName := normalizeAssetPath(zipFile.Name)
+		if !filesToUpload[normalizedZipName] {
+			slog.DebugContext(r.Context(), "Skipping asset",
+				slog.String("original", zipFile.Name),
+				slog.String("normalized", normalizedZipName),
+			)
+			continue
 		}
 
-		var uploadedAssets []UploadedAsset
-
-		deleteAsset := func(ctx context.Context, asset UploadedAsset) {
-			if err := storage.Delete(ctx, asset.StorageKey, asset.ContentType); err != nil {
-				slog.ErrorContext(ctx, "Failed to delete asset", slog.Any("error", err))
-			}
+		asset, err := hashZipAsset(r.Context(), platformMetadata, zipFile, normalizedZipName)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to read asset",
+				slog.String("asset", normalizedZipName),
+				slog.Any("error", err),
+			)
+			failUpload("Failed to read asset")
+			return false
 		}
 
-		cleanupAssets := func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-			for _, asset := range uploadedAssets {
-				go deleteAsset(ctx, asset)
-			}
+		if blob, ok := blobs[asset.Hash]; ok {
+			asset.StorageKey = blob.StorageKey
+			asset.StorageURL = blob.StorageURL
+			asset.Reused = true
+			uploadedAssets = append(uploadedAssets, asset)
+			continue
 		}
 
-		for _, zipFile := range zipReader.File {
-			normalizedZipName := normalizeAssetPath(zipFile.Name)
-			if !filesToUpload[normalizedZipName] {
-				slog.DebugContext(r.Context(), "Skipping asset",
-					slog.String("original", zipFile.Name),
-					slog.String("normalized", normalizedZipName),
-				)
-				continue
-			}
-			asset, err := uploadZipAssets(r.Context(), storage, platformMetadata, zipFile, project.Slug, update.ID.String(), platform, normalizedZipName)
-			if err != nil {
-				slog.ErrorContext(r.Context(), "Failed to upload asset",
-					slog.String("asset", normalizedZipName),
-					slog.Any("error", err),
-				)
-				// should cleanup already uploaded assets
-				cleanupAssets()
-
-				// also delete update from db
-				err = qtx.DeleteUpdate(r.Context(), update.ID)
-				if err != nil {
-					slog.ErrorContext(r.Context(), "Failed to delete update", slog.Any("error", err))
-				}
-				jsonError(w, "Failed to upload asset", http.StatusInternalServerError)
-				return
-			}
+		existing, err := qtx.GetAssetByProjectAndHash(r.Context(), database.GetAssetByProjectAndHashParams{
+			ProjectID:       project.ID,
+			Hash:            asset.Hash,
+			StorageProvider: storage.Name(),
+		})
+		if err == nil {
+			asset.StorageKey = existing.Key
+			asset.StorageURL = existing.Url
+			asset.Reused = true
+			blobs[asset.Hash] = asset
 			uploadedAssets = append(uploadedAssets, asset)
-			slog.InfoContext(r.Context(), "Uploaded asset",
+			slog.DebugContext(r.Context(), "Reusing stored asset",
 				slog.String("asset", normalizedZipName),
 				slog.String("key", asset.StorageKey),
 			)
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.WarnContext(r.Context(), "Failed to look up existing asset, uploading again",
+				slog.String("asset", normalizedZipName),
+				slog.Any("error", err),
+			)
 		}
 
-		err = saveAssetRecords(r.Context(), qtx, uploadedAssets, update, storage.Name())
+		// Keys follow content, so the object may be queued for deletion
+		// from an earlier update. Re-queueing it restarts the grace period
+		// and keeps the entry locked until this transaction ends, which
+		// garbage collection waits for.
+		err = qtx.QueueStorageObjectForGC(r.Context(), database.QueueStorageObjectForGCParams{
+			Key:             buildStorageKey(project.Slug, asset.Hash),
+			StorageProvider: storage.Name(),
+			MimeType:        asset.ContentType,
+		})
 		if err != nil {
-			cleanupAssets()
-			slog.ErrorContext(r.Context(), "Failed to save asset records", slog.Any("error", err))
-			jsonError(w, "Failed to save asset records", http.StatusInternalServerError)
-			return
+			slog.ErrorContext(r.Context(), "Failed to queue asset for garbage collection", slog.Any("error", err))
+			failUpload("Failed to upload asset")
+			return false
 		}
 
+		asset, err = uploadZipAssets(r.Context(), storage, zipFile, asset, project.Slug)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to upload asset",
+				slog.String("asset", normalizedZipName),
+				slog.Any("error", err),
+			)
+			failUpload("Failed to upload asset")
+			return false
+		}
+		blobs[asset.Hash] = asset
+		newAssets = append(newAssets, asset)
+		uploadedAssets = append(uploadedAssets, asset)
+		slog.InfoContext(r.Context(), "Uploaded asset",
+			slog.String("asset", normalizedZipName),
+			slog.String("key", asset.StorageKey),
+		)
+	}
+
+	if !commitBundle(w, r, tx, qtx, project, update, platform, activate, storage.Name(), uploadedAssets, newAssets, cleanupAssets) {
+		return false
+	}
+	go storeAssetVariants(queries, storage, newAssets)
+	return true
+}
+
+// commitBundle records the assets of a published bundle, activates the
+// update when asked and commits tx. It writes the response; cleanup runs
+// when anything fails, to release blobs stored for the bundle.
+func commitBundle(w http.ResponseWriter, r *http.Request, tx pgx.Tx, qtx *database.Queries, project database.Project, update database.Update, platform string, activate bool, providerName string, uploadedAssets, newAssets []UploadedAsset, cleanup func()) bool {
+	err := saveAssetRecords(r.Context(), qtx, uploadedAssets, update, providerName)
+	if err != nil {
+		cleanup()
+		slog.ErrorContext(r.Context(), "Failed to save asset records", slog.Any("error", err))
+		jsonError(w, "Failed to save asset records", http.StatusInternalServerError)
+		return false
+	}
+
+	// New assets change the manifest, so any stored signature is stale.
+	if update.SignedManifest != nil {
+		err = qtx.SetUpdateManifestSignature(r.Context(), database.SetUpdateManifestSignatureParams{
+			ID: update.ID,
+		})
+		if err != nil {
+			cleanup()
+			slog.ErrorContext(r.Context(), "Failed to clear manifest signature", slog.Any("error", err))
+			jsonError(w, "Failed to clear manifest signature", http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	if activate {
 		err = qtx.DeactivateUpdates(r.Context(), database.DeactivateUpdatesParams{
 			ProjectID:      update.ProjectID,
 			Channel:        update.Channel,
@@ -286,40 +429,103 @@ func UploadAsset(pool *pgxpool.Pool, queries *database.Queries, providers map[st
 			RuntimeVersion: update.RuntimeVersion,
 		})
 		if err != nil {
-			cleanupAssets()
+			cleanup()
 			slog.WarnContext(r.Context(), "Failed to deactivate updates", slog.Any("error", err))
 			jsonError(w, "Failed to deactivate updates", http.StatusInternalServerError)
-			return
+			return false
 		}
 
 		err = qtx.ActivateUpdate(r.Context(), update.ID)
 		if err != nil {
-			cleanupAssets()
+			cleanup()
 			slog.ErrorContext(r.Context(), "Failed to activate update", slog.Any("error", err))
 			jsonError(w, "Failed to activate update", http.StatusInternalServerError)
-			return
+			return false
 		}
 
-		err = tx.Commit(r.Context())
+		activated := update
+		activated.IsActive = true
+		err = webhook.Enqueue(r.Context(), qtx, webhook.EventUpdateActivated, activated, nil)
 		if err != nil {
-			cleanupAssets()
-			slog.ErrorContext(r.Context(), "Failed to commit transaction", slog.Any("error", err))
-			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
-			return
+			cleanup()
+			slog.ErrorContext(r.Context(), "Failed to queue webhooks", slog.Any("error", err))
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return false
 		}
+	}
+
+	err = tx.Commit(r.Context())
+	if err != nil {
+		cleanup()
+		slog.ErrorContext(r.Context(), "Failed to commit transaction", slog.Any("error", err))
+		jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return false
+	}
 
-		go InvalidateManifestCache(projectIdStr)
+	go InvalidateManifestCache(project.ID.String())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"message":        "Assets uploaded successfully",
+		"project":        project.Name,
+		"update":         update.ID.String(),
+		"platform":       platform,
+		"uploadedAssets": len(uploadedAssets),
+		"newAssets":      len(newAssets),
+		"reusedAssets":   len(uploadedAssets) - len(newAssets),
+		"activated":      activate,
+	})
+	return true
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success":        true,
-			"message":        "Assets uploaded successfully",
-			"project":        project.Name,
-			"update":         update.ID.String(),
-			"platform":       platform,
-			"uploadedAssets": len(uploadedAssets),
+// publishProvider returns the storage provider new blobs of a project are
+// stored with: the project's own storage when it has one, else the one the
+// storage_provider setting names. It fails rather than fall back when the
+// project's storage cannot be loaded, so its blobs never land elsewhere.
+func publishProvider(ctx context.Context, queries *database.Queries, providers *storage.Registry, projectID pgtype.UUID) (storage.Provider, error) {
+	own, err := queries.GetActiveProjectStorage(ctx, projectID)
+	if err == nil {
+		return providers.Get(ctx, projectstorage.ProviderName(own.ID))
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	providerName, _ := queries.GetSetting(ctx, "storage_provider")
+	provider, ok := providers.Server()[providerName.Value]
+	if !ok {
+		slog.WarnContext(ctx, "Storage provider not found", slog.String("provider", providerName.Value))
+		for _, p := range providers.Server() {
+			provider = p
+			break
+		}
+	}
+	return provider, nil
+}
+
+// replaceUpdateAssets stores the expo config of a new bundle for update and
+// drops its previous assets, queueing their blobs for garbage collection.
+func replaceUpdateAssets(ctx context.Context, qtx *database.Queries, update database.Update, expoConfig json.RawMessage) {
+	if expoConfig != nil {
+		err := qtx.UpdateExpoConfig(ctx, database.UpdateExpoConfigParams{
+			ExpoConfig: expoConfig,
+			ID:         update.ID,
 		})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to store expo config", slog.Any("error", err))
+		}
+	}
+
+	err := qtx.QueueUpdateAssetsForGC(ctx, update.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to queue old assets for cleanup", slog.Any("error", err))
+	}
+
+	err = qtx.DeleteAssetByUpdateID(ctx, update.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to delete old assets", slog.Any("error", err))
 	}
 }
 
@@ -371,7 +577,9 @@ func saveAssetRecords(ctx context.Context, queries *database.Queries, uploadedAs
 	return nil
 }
 
-func uploadZipAssets(ctx context.Context, storage storage.Provider, platformMetadata PlatformFileMetadata, zipFile *zip.File, projectSlug, updateID, platform, normalizedZipName string) (UploadedAsset, error) {
+// hashZipAsset reads a zip entry once to compute its SHA-256 and content type
+// so the upload can be skipped when the project already stores the same bytes.
+func hashZipAsset(ctx context.Context, platformMetadata PlatformFileMetadata, zipFile *zip.File, normalizedZipName string) (UploadedAsset, error) {
 	slog.DebugContext(ctx, "Processing zip file", slog.String("name", zipFile.Name))
 
 	fileReader, err := zipFile.Open()
@@ -400,24 +608,35 @@ func uploadZipAssets(ctx context.Context, storage storage.Provider, platformMeta
 		}
 	}
 
-	fullStream := io.MultiReader(bytes.NewReader(headerBytes), fileReader)
 	hasher := sha256.New()
+	hasher.Write(headerBytes)
+	if _, err := io.Copy(hasher, fileReader); err != nil {
+		return UploadedAsset{}, errors.New("failed to read file data from zip " + zipFile.Name)
+	}
 
-	teeStream := io.TeeReader(fullStream, hasher)
+	return UploadedAsset{
+		FileName:    normalizedZipName,
+		Hash:        base64.RawURLEncoding.EncodeToString(hasher.Sum(nil)),
+		ContentType: contentType,
+		Size:        int64(zipFile.UncompressedSize64),
+	}, nil
+}
 
-	storageKey := buildStorageKey(
-		projectSlug,
-		updateID,
-		platform,
-		normalizedZipName,
-	)
+func uploadZipAssets(ctx context.Context, storage storage.Provider, zipFile *zip.File, asset UploadedAsset, projectSlug string) (UploadedAsset, error) {
+	fileReader, err := zipFile.Open()
+	if err != nil {
+		return UploadedAsset{}, errors.New("failed to read file from zip " + zipFile.Name)
+	}
+	defer fileReader.Close()
+
+	storageKey := buildStorageKey(projectSlug, asset.Hash)
 
 	storageURL, err := storage.Upload(
 		ctx,
 		storageKey,
-		teeStream,
-		contentType,
-		int64(zipFile.UncompressedSize64),
+		fileReader,
+		asset.ContentType,
+		asset.Size,
 	)
 
 	if err != nil {
@@ -429,17 +648,9 @@ func uploadZipAssets(ctx context.Context, storage storage.Provider, platformMeta
 		return UploadedAsset{}, errors.New("Failed to upload file to storage: " + zipFile.Name)
 	}
 
-	hashBytes := hasher.Sum(nil)
-	hash := base64.RawURLEncoding.EncodeToString(hashBytes)
-
-	return UploadedAsset{
-		FileName:    normalizedZipName,
-		StorageKey:  storageKey,
-		StorageURL:  storageURL,
-		Hash:        hash,
-		ContentType: contentType,
-		Size:        int64(zipFile.UncompressedSize64),
-	}, nil
+	asset.StorageKey = storageKey
+	asset.StorageURL = storageURL
+	return asset, nil
 }
 
 func parseZipMetadata(ctx context.Context, zipReader *zip.Reader) (ExpoMetadata, json.RawMessage, error) {
@@ -506,8 +717,10 @@ func inferBundleContentType(fileName string) string {
 	return "application/octet-stream"
 }
 
-func buildStorageKey(projectSlug, updateID, platform, fileName string) string {
-	return projectSlug + "/" + updateID + "/" + platform + "/" + fileName
+// buildStorageKey addresses blobs by content so identical files are stored
+// once per project no matter how many updates reference them.
+func buildStorageKey(projectSlug, hash string) string {
+	return projectSlug + "/assets/" + hash
 }
 
 func normalizeAssetPath(path string) string {
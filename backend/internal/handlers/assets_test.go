@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestHashZipAsset(t *testing.T) {
+	bundle := []byte("console.log('hello')")
+	font := bytes.Repeat([]byte{0x00, 0x01, 0x00, 0x00}, 200)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"_expo/static/js/ios/index-abc.js": bundle,
+		"assets/font1":                     font,
+		"assets/font2":                     font,
+	} {
+		f, _ := zw.Create(name)
+		f.Write(data)
+	}
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+
+	metadata := PlatformFileMetadata{
+		Bundle: "_expo/static/js/ios/index-abc.js",
+		Assets: []AssetMetadata{
+			{Path: "assets/font1", Ext: "ttf", ContentType: "font/ttf"},
+			{Path: "assets/font2", Ext: "ttf", ContentType: "font/ttf"},
+		},
+	}
+
+	hashes := make(map[string]UploadedAsset)
+	for _, f := range zr.File {
+		asset, err := hashZipAsset(context.Background(), metadata, f, normalizeAssetPath(f.Name))
+		if err != nil {
+			t.Fatalf("hashZipAsset(%s) failed: %v", f.Name, err)
+		}
+		hashes[f.Name] = asset
+	}
+
+	bundleSum := sha256.Sum256(bundle)
+	if got := hashes["_expo/static/js/ios/index-abc.js"]; got.Hash != base64.RawURLEncoding.EncodeToString(bundleSum[:]) {
+		t.Errorf("Unexpected bundle hash %s", got.Hash)
+	} else if got.ContentType != "application/javascript" {
+		t.Errorf("Expected bundle content type application/javascript, got %s", got.ContentType)
+	}
+
+	if hashes["assets/font1"].Hash != hashes["assets/font2"].Hash {
+		t.Errorf("Identical files should share a hash")
+	}
+	if hashes["assets/font1"].ContentType != "font/ttf" {
+		t.Errorf("Expected metadata content type to win, got %s", hashes["assets/font1"].ContentType)
+	}
+
+	key := buildStorageKey("my-app", hashes["assets/font1"].Hash)
+	if key != "my-app/assets/"+hashes["assets/font1"].Hash {
+		t.Errorf("Unexpected storage key %s", key)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type AuditEventResponse struct {
+	ID        int64           `json:"id"`
+	ActorType string          `json:"actor_type"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	ActorName string          `json:"actor_name"`
+	Action    string          `json:"action"`
+	Path      string          `json:"path"`
+	ProjectID string          `json:"project_id,omitempty"`
+	Targets   json.RawMessage `json:"targets"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Status    int32           `json:"status"`
+	RequestID string          `json:"request_id,omitempty"`
+	IP        string          `json:"ip"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+func toAuditEventResponse(e database.AuditEvent) AuditEventResponse {
+	res := AuditEventResponse{
+		ID:        e.ID,
+		ActorType: e.ActorType,
+		ActorID:   e.ActorID,
+		ActorName: e.ActorName,
+		Action:    e.Action,
+		Path:      e.Path,
+		Targets:   e.Targets,
+		Before:    e.Before,
+		After:     e.After,
+		Status:    e.Status,
+		RequestID: e.RequestID,
+		IP:        e.Ip,
+		CreatedAt: e.CreatedAt.Time.UnixMilli(),
+	}
+	if e.ProjectID.Valid {
+		res.ProjectID = e.ProjectID.String()
+	}
+	return res
+}
+
+// ListAuditEvents returns audit events, newest first. Every filter is
+// optional: project_id, actor (a user email, API key name or either's ID),
+// action (a substring such as "rollback"), target (any ID the request acted
+// on), and since and until in Unix milliseconds.
+func ListAuditEvents(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var filter database.CountAuditEventsParams
+		if id := query.Get("project_id"); id != "" {
+			projectId, err := utils.ParseUUID(id)
+			if err != nil {
+				jsonError(w, "Invalid project ID", http.StatusBadRequest)
+				return
+			}
+			filter.ProjectID = projectId
+		}
+		filter.Actor = optionalText(query.Get("actor"))
+		filter.Action = optionalText(query.Get("action"))
+		filter.Target = optionalText(query.Get("target"))
+
+		var ok bool
+		if filter.Since, ok = parseMillisParam(w, query.Get("since"), "since"); !ok {
+			return
+		}
+		if filter.Until, ok = parseMillisParam(w, query.Get("until"), "until"); !ok {
+			return
+		}
+
+		limit := int32(defaultAuditLimit)
+		if l, err := utils.ParseInt32(query.Get("limit")); err == nil && l > 0 {
+			limit = min(l, maxAuditLimit)
+		}
+		offset := int32(0)
+		if o, err := utils.ParseInt32(query.Get("offset")); err == nil && o > 0 {
+			offset = o
+		}
+
+		events, err := queries.ListAuditEvents(r.Context(), database.ListAuditEventsParams{
+			ProjectID: filter.ProjectID,
+			Actor:     filter.Actor,
+			Action:    filter.Action,
+			Target:    filter.Target,
+			Since:     filter.Since,
+			Until:     filter.Until,
+			Limit:     limit,
+			Offset:    offset,
+		})
+		if err != nil {
+			jsonError(w, "Failed to fetch audit events", http.StatusInternalServerError)
+			return
+		}
+		total, err := queries.CountAuditEvents(r.Context(), filter)
+		if err != nil {
+			jsonError(w, "Failed to count audit events", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]AuditEventResponse, len(events))
+		for i, e := range events {
+			res[i] = toAuditEventResponse(e)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"events": res,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
+
+func optionalText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+func parseMillisParam(w http.ResponseWriter, value, name string) (pgtype.Timestamptz, bool) {
+	if value == "" {
+		return pgtype.Timestamptz{}, true
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		jsonError(w, name+" must be Unix milliseconds", http.StatusBadRequest)
+		return pgtype.Timestamptz{}, false
+	}
+	return pgtype.Timestamptz{Time: time.UnixMilli(ms), Valid: true}, true
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/auth"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type UserResponse struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type LoginResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt int64        `json:"expires_at"`
+	User      UserResponse `json:"user"`
+}
+
+type CurrentUserResponse struct {
+	Root          bool                   `json:"root"`
+	User          *UserResponse          `json:"user,omitempty"`
+	Organizations []OrganizationResponse `json:"organizations"`
+}
+
+// dummyPasswordHash is checked against when the email is unknown, so that
+// a login takes as long whether or not the account exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("otaship-dummy-password")
+	return hash
+})
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Login exchanges a user's email and password for a bearer token accepted
+// by the admin API.
+func Login(queries *database.Queries, sessionTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := queries.GetUserByEmail(r.Context(), normalizeEmail(req.Email))
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Failed to log in", http.StatusInternalServerError)
+				return
+			}
+			auth.CheckPassword(dummyPasswordHash(), req.Password)
+			jsonError(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		if !auth.CheckPassword(user.PasswordHash, req.Password) {
+			jsonError(w, "Invalid email or password", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := auth.NewSessionToken()
+		if err != nil {
+			jsonError(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		session, err := queries.CreateUserSession(r.Context(), database.CreateUserSessionParams{
+			UserID:    user.ID,
+			TokenHash: utils.CalculateSHA256([]byte(token)),
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(sessionTTL), Valid: true},
+		})
+		if err != nil {
+			jsonError(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+
+		if err := queries.DeleteExpiredUserSessions(r.Context(), user.ID); err != nil {
+			slog.WarnContext(r.Context(), "Failed to delete expired sessions", slog.Any("error", err))
+		}
+
+		slog.InfoContext(r.Context(), "User logged in", slog.String("user_id", user.ID.String()))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LoginResponse{
+			Token:     token,
+			ExpiresAt: session.ExpiresAt.Time.UnixMilli(),
+			User: UserResponse{
+				ID:    user.ID.String(),
+				Email: user.Email,
+				Name:  user.Name,
+			},
+		})
+	}
+}
+
+// Logout ends the session of the bearer token it is called with.
+func Logout(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !auth.IsSessionToken(token) {
+			jsonError(w, "A session token is required", http.StatusUnauthorized)
+			return
+		}
+		if err := queries.DeleteUserSession(r.Context(), utils.CalculateSHA256([]byte(token))); err != nil {
+			jsonError(w, "Failed to log out", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetCurrentUser describes who the admin request is authenticated as, with
+// the organizations a user belongs to.
+func GetCurrentUser(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.PrincipalFrom(r.Context())
+		res := CurrentUserResponse{Root: p.Root, Organizations: []OrganizationResponse{}}
+
+		if !p.Root {
+			res.User = &UserResponse{ID: p.UserID.String(), Email: p.Email, Name: p.Name}
+			orgs, err := queries.ListUserOrganizations(r.Context(), p.UserID)
+			if err != nil {
+				jsonError(w, "Failed to fetch organizations", http.StatusInternalServerError)
+				return
+			}
+			for _, o := range orgs {
+				res.Organizations = append(res.Organizations, OrganizationResponse{
+					ID:        o.ID.String(),
+					Slug:      o.Slug,
+					Name:      o.Name,
+					Role:      o.Role,
+					CreatedAt: o.CreatedAt.Time.UnixMilli(),
+				})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+type CreateBranchRequest struct {
+	Name string `json:"name"`
+}
+
+type BranchResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	UpdateCount int64  `json:"update_count"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// routeProjectID returns the project a branch or channel request acts on:
+// the API key's project on project routes, the {project_id} URL parameter on
+// admin routes.
+func routeProjectID(r *http.Request) (pgtype.UUID, error) {
+	if projectId := utils.GetProjectId(r.Context()); projectId.Valid {
+		return projectId, nil
+	}
+	return utils.ParseUUID(chi.URLParam(r, "project_id"))
+}
+
+func ListBranches(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		branches, err := queries.ListBranches(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch branches", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]BranchResponse, len(branches))
+		for i, b := range branches {
+			res[i] = BranchResponse{
+				ID:          b.ID.String(),
+				Name:        b.Name,
+				UpdateCount: b.UpdateCount,
+				CreatedAt:   b.CreatedAt.Time.UnixMilli(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func CreateBranch(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req CreateBranchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !channelNameRegex.MatchString(req.Name) {
+			jsonError(w, "Invalid branch name. Must start with a letter or number and contain only lowercase letters, numbers, hyphens, and underscores, with a maximum length of 32 characters.", http.StatusBadRequest)
+			return
+		}
+		if !keyAllowsChannel(w, r, req.Name) {
+			return
+		}
+
+		branch, err := queries.CreateBranch(r.Context(), database.CreateBranchParams{
+			ProjectID: projectId,
+			Name:      req.Name,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				jsonError(w, "A branch with this name already exists", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to create branch", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(BranchResponse{
+			ID:        branch.ID.String(),
+			Name:      branch.Name,
+			CreatedAt: branch.CreatedAt.Time.UnixMilli(),
+		})
+	}
+}
+
+// DeleteBranch removes an empty branch. Branches that still hold updates or
+// that a channel points at cannot be deleted.
+func DeleteBranch(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		name := chi.URLParam(r, "branch_name")
+		if !keyAllowsChannel(w, r, name) {
+			return
+		}
+
+		count, err := queries.CountBranchUpdates(r.Context(), database.CountBranchUpdatesParams{
+			ProjectID: projectId,
+			Name:      name,
+		})
+		if err != nil {
+			jsonError(w, "Failed to delete branch", http.StatusInternalServerError)
+			return
+		}
+		if count > 0 {
+			jsonError(w, "Branch still has updates; delete them first", http.StatusConflict)
+			return
+		}
+
+		err = queries.DeleteBranch(r.Context(), database.DeleteBranchParams{
+			ProjectID: projectId,
+			Name:      name,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				jsonError(w, "A channel still points at this branch", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to delete branch", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// publishBranch returns the branch a publish request targets: the explicit
+// branch if one is given, otherwise the branch behind the channel.
+func publishBranch(ctx context.Context, queries *database.Queries, projectId pgtype.UUID, branch, channel string) (string, error) {
+	if branch == "" {
+		return resolvePublishBranch(ctx, queries, projectId, channel)
+	}
+	b, err := queries.EnsureBranch(ctx, database.EnsureBranchParams{
+		ProjectID: projectId,
+		Name:      branch,
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.Name, nil
+}
+
+// resolvePublishBranch returns the branch an update published to a channel
+// lands on. A channel that does not exist yet is created together with a
+// branch of the same name, which matches how channels behaved before
+// branches existed.
+func resolvePublishBranch(ctx context.Context, queries *database.Queries, projectId pgtype.UUID, channel string) (string, error) {
+	existing, err := queries.GetChannelByName(ctx, database.GetChannelByNameParams{
+		ProjectID: projectId,
+		Name:      channel,
+	})
+	if err == nil {
+		return existing.BranchName, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
+	}
+
+	branch, err := queries.EnsureBranch(ctx, database.EnsureBranchParams{
+		ProjectID: projectId,
+		Name:      channel,
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = queries.CreateChannel(ctx, database.CreateChannelParams{
+		ProjectID: projectId,
+		Name:      channel,
+		BranchID:  branch.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return branch.Name, nil
+}
+
+// resolveChannelBranch picks the branch a device on a channel is served
+// from. Channels that split traffic bucket devices on a hash salted with the
+// channel ID, so the split is independent of per-update rollout buckets.
+// Unknown channels fall back to the branch of the same name.
+func resolveChannelBranch(ctx context.Context, queries *database.Queries, projectId pgtype.UUID, channel, deviceHash string) (string, error) {
+	c, err := queries.GetChannelByName(ctx, database.GetChannelByNameParams{
+		ProjectID: projectId,
+		Name:      channel,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return channel, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return channelBranchFor(c, deviceHash), nil
+}
+
+func channelBranchFor(c database.GetChannelByNameRow, deviceHash string) string {
+	if c.RolloutBranchName.Valid {
+		bucket := utils.CalculateSHA256([]byte(c.ID.String() + ":" + deviceHash))
+		if shouldReceiveUpdate(int(c.RolloutPercentage), bucket) {
+			return c.RolloutBranchName.String
+		}
+	}
+	return c.BranchName
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+func TestChannelBranchFor(t *testing.T) {
+	channelID, _ := utils.ParseUUID("6f1c2a8e-0b7d-4c55-9a3e-2d4b8f1e7c90")
+	channel := database.GetChannelByNameRow{
+		ID:                channelID,
+		Name:              "production",
+		BranchName:        "release-1",
+		RolloutBranchName: pgtype.Text{String: "release-2", Valid: true},
+	}
+
+	tests := []struct {
+		percentage int32
+		wantMin    int
+		wantMax    int
+	}{
+		{0, 0, 0},
+		{25, 150, 350},
+		{100, 1000, 1000},
+	}
+
+	for _, tt := range tests {
+		channel.RolloutPercentage = tt.percentage
+		rollout := 0
+		for i := 0; i < 1000; i++ {
+			device := utils.CalculateSHA256([]byte(fmt.Sprintf("device-%d", i)))
+			got := channelBranchFor(channel, device)
+			if got != channelBranchFor(channel, device) {
+				t.Fatalf("Branch for %s is not stable", device)
+			}
+			if got == "release-2" {
+				rollout++
+			}
+		}
+		if rollout < tt.wantMin || rollout > tt.wantMax {
+			t.Errorf("%d%% split sent %d of 1000 devices to the rollout branch", tt.percentage, rollout)
+		}
+	}
+
+	channel.RolloutBranchName = pgtype.Text{}
+	channel.RolloutPercentage = 50
+	if got := channelBranchFor(channel, "abcdef0123"); got != "release-1" {
+		t.Errorf("Channel without rollout branch served %q", got)
+	}
+}
+
+func TestValidPublishTarget(t *testing.T) {
+	long := strings.Repeat("a", 20)
+	tests := []struct {
+		branch  string
+		channel string
+		want    bool
+	}{
+		{"", "production", true},
+		{"release-1", "", true},
+		{long, long, true},
+		{"a", "-x", false},
+		{"-x", "a", false},
+		{"Release", "", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if got := validPublishTarget(w, tt.branch, tt.channel); got != tt.want {
+			t.Errorf("validPublishTarget(%q, %q) = %v, want %v", tt.branch, tt.channel, got, tt.want)
+		}
+	}
+}
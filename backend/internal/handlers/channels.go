@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+)
+
+// ChannelRequest points a channel at a branch. With RolloutBranch set,
+// RolloutPercentage percent of devices are served from it instead.
+type ChannelRequest struct {
+	Name              string `json:"name"`
+	Branch            string `json:"branch"`
+	RolloutBranch     string `json:"rollout_branch"`
+	RolloutPercentage int32  `json:"rollout_percentage"`
+}
+
+type ChannelResponse struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Branch            string `json:"branch"`
+	RolloutBranch     string `json:"rollout_branch,omitempty"`
+	RolloutPercentage int32  `json:"rollout_percentage"`
+	CreatedAt         int64  `json:"created_at"`
+	UpdatedAt         int64  `json:"updated_at"`
+}
+
+func toChannelResponse(c database.GetChannelByNameRow) ChannelResponse {
+	return ChannelResponse{
+		ID:                c.ID.String(),
+		Name:              c.Name,
+		Branch:            c.BranchName,
+		RolloutBranch:     c.RolloutBranchName.String,
+		RolloutPercentage: c.RolloutPercentage,
+		CreatedAt:         c.CreatedAt.Time.UnixMilli(),
+		UpdatedAt:         c.UpdatedAt.Time.UnixMilli(),
+	}
+}
+
+func ListChannels(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		channels, err := queries.ListChannels(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch channels", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]ChannelResponse, len(channels))
+		for i, c := range channels {
+			res[i] = toChannelResponse(database.GetChannelByNameRow(c))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func CreateChannel(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ChannelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !channelNameRegex.MatchString(req.Name) {
+			jsonError(w, "Invalid channel name. Must start with a letter or number and contain only lowercase letters, numbers, hyphens, and underscores, with a maximum length of 32 characters.", http.StatusBadRequest)
+			return
+		}
+		if !keyAllowsChannel(w, r, req.Name) {
+			return
+		}
+		if req.Branch == "" {
+			// Like publishing to a new channel, a bare channel gets a branch
+			// of the same name.
+			branch, err := queries.EnsureBranch(r.Context(), database.EnsureBranchParams{
+				ProjectID: projectId,
+				Name:      req.Name,
+			})
+			if err != nil {
+				jsonError(w, "Failed to create branch", http.StatusInternalServerError)
+				return
+			}
+			req.Branch = branch.Name
+		}
+
+		branchID, rolloutBranchID, ok := channelBranches(w, r, queries, projectId, req)
+		if !ok {
+			return
+		}
+
+		_, err = queries.CreateChannel(r.Context(), database.CreateChannelParams{
+			ProjectID:         projectId,
+			Name:              req.Name,
+			BranchID:          branchID,
+			RolloutBranchID:   rolloutBranchID,
+			RolloutPercentage: req.RolloutPercentage,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				jsonError(w, "A channel with this name already exists", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to create channel", http.StatusInternalServerError)
+			return
+		}
+
+		writeChannel(w, r, queries, projectId, req.Name, http.StatusCreated)
+	}
+}
+
+// UpdateChannel repoints a channel. Promoting staging to production is an
+// update of the production channel to the staging branch; nothing is
+// re-uploaded.
+func UpdateChannel(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ChannelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Name = chi.URLParam(r, "channel_name")
+		if !keyAllowsChannel(w, r, req.Name) {
+			return
+		}
+		if req.Branch == "" {
+			jsonError(w, "Branch is required", http.StatusBadRequest)
+			return
+		}
+
+		branchID, rolloutBranchID, ok := channelBranches(w, r, queries, projectId, req)
+		if !ok {
+			return
+		}
+
+		before, beforeErr := queries.GetChannelByName(r.Context(), database.GetChannelByNameParams{
+			ProjectID: projectId,
+			Name:      req.Name,
+		})
+
+		_, err = queries.UpdateChannel(r.Context(), database.UpdateChannelParams{
+			BranchID:          branchID,
+			RolloutBranchID:   rolloutBranchID,
+			RolloutPercentage: req.RolloutPercentage,
+			ProjectID:         projectId,
+			Name:              req.Name,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Channel not found", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to update channel", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Channel updated",
+			slog.String("project_id", projectId.String()),
+			slog.String("channel", req.Name),
+			slog.String("branch", req.Branch),
+			slog.String("rollout_branch", req.RolloutBranch),
+			slog.Int("rollout_percentage", int(req.RolloutPercentage)),
+		)
+
+		go InvalidateManifestCache(projectId.String())
+
+		if after := writeChannel(w, r, queries, projectId, req.Name, http.StatusOK); after != nil && beforeErr == nil {
+			audit.SetChange(r.Context(), toChannelResponse(before), after)
+		}
+	}
+}
+
+func DeleteChannel(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		name := chi.URLParam(r, "channel_name")
+		if !keyAllowsChannel(w, r, name) {
+			return
+		}
+
+		err = queries.DeleteChannel(r.Context(), database.DeleteChannelParams{
+			ProjectID: projectId,
+			Name:      name,
+		})
+		if err != nil {
+			jsonError(w, "Failed to delete channel", http.StatusInternalServerError)
+			return
+		}
+
+		go InvalidateManifestCache(projectId.String())
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// channelBranches validates the branch targets of a channel request and
+// looks up their IDs, writing the error response when they are invalid.
+func channelBranches(w http.ResponseWriter, r *http.Request, queries *database.Queries, projectId pgtype.UUID, req ChannelRequest) (pgtype.UUID, pgtype.UUID, bool) {
+	var none pgtype.UUID
+
+	if req.RolloutPercentage < 0 || req.RolloutPercentage > 100 {
+		jsonError(w, "Rollout percentage must be between 0 and 100", http.StatusBadRequest)
+		return none, none, false
+	}
+	if req.RolloutBranch == "" && req.RolloutPercentage != 0 {
+		jsonError(w, "A rollout percentage needs a rollout_branch", http.StatusBadRequest)
+		return none, none, false
+	}
+	if req.RolloutBranch != "" && req.RolloutBranch == req.Branch {
+		jsonError(w, "rollout_branch must differ from branch", http.StatusBadRequest)
+		return none, none, false
+	}
+
+	branch, err := lookupBranch(r.Context(), queries, projectId, req.Branch)
+	if err != nil {
+		branchLookupError(w, req.Branch, err)
+		return none, none, false
+	}
+	if req.RolloutBranch == "" {
+		return branch.ID, none, true
+	}
+
+	rolloutBranch, err := lookupBranch(r.Context(), queries, projectId, req.RolloutBranch)
+	if err != nil {
+		branchLookupError(w, req.RolloutBranch, err)
+		return none, none, false
+	}
+	return branch.ID, rolloutBranch.ID, true
+}
+
+func lookupBranch(ctx context.Context, queries *database.Queries, projectId pgtype.UUID, name string) (database.Branch, error) {
+	return queries.GetBranchByName(ctx, database.GetBranchByNameParams{
+		ProjectID: projectId,
+		Name:      name,
+	})
+}
+
+func branchLookupError(w http.ResponseWriter, name string, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		jsonError(w, "Branch not found: "+name, http.StatusNotFound)
+		return
+	}
+	jsonError(w, "Failed to fetch branch", http.StatusInternalServerError)
+}
+
+// writeChannel writes the named channel and returns what it wrote, or nil
+// if the channel could not be fetched.
+func writeChannel(w http.ResponseWriter, r *http.Request, queries *database.Queries, projectId pgtype.UUID, name string, status int) *ChannelResponse {
+	channel, err := queries.GetChannelByName(r.Context(), database.GetChannelByNameParams{
+		ProjectID: projectId,
+		Name:      name,
+	})
+	if err != nil {
+		jsonError(w, "Failed to fetch channel", http.StatusInternalServerError)
+		return nil
+	}
+	res := toChannelResponse(channel)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+	return &res
+}
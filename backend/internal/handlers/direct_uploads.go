@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+// DirectUploadTTL is how long a direct upload and its presigned URLs stay
+// valid. It must stay below the storage GC grace period: blobs are queued
+// for collection when the upload is created, so abandoned ones need no
+// other cleanup.
+const DirectUploadTTL = time.Hour
+
+// DirectUploadAsset is one file of an exported bundle, described by the
+// client instead of sent in a zip.
+type DirectUploadAsset struct {
+	Path string `json:"path"`
+	// Hash is the unpadded base64url SHA-256 of the file, as in manifests.
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+	// ContentType is used for files metadata.json gives no content type.
+	ContentType string `json:"content_type"`
+}
+
+type CreateDirectUploadRequest struct {
+	Platform string `json:"platform"`
+	// Activate defaults to true, as with bundle uploads.
+	Activate   *bool               `json:"activate"`
+	Metadata   ExpoMetadata        `json:"metadata"`
+	ExpoConfig json.RawMessage     `json:"expo_config"`
+	Assets     []DirectUploadAsset `json:"assets"`
+}
+
+// BlobUpload tells the client how to store a blob the project does not
+// have yet.
+type BlobUpload struct {
+	Hash string `json:"hash"`
+	storage.PresignedRequest
+	// Proxied is set when the blob goes through this server, which needs
+	// the client's API key; presigned requests must be sent without it.
+	Proxied bool `json:"proxied"`
+}
+
+type DirectUploadResponse struct {
+	ID        string       `json:"id"`
+	ExpiresAt int64        `json:"expires_at"`
+	Assets    int          `json:"assets"`
+	Reused    int          `json:"reused"`
+	Uploads   []BlobUpload `json:"uploads"`
+}
+
+// planDirectUploadAssets checks that assets describe exactly the launch
+// bundle and assets of platformMetadata and settles their paths and content
+// types the way bundle uploads do.
+func planDirectUploadAssets(platformMetadata PlatformFileMetadata, assets []DirectUploadAsset) ([]DirectUploadAsset, error) {
+	bundlePath := normalizeAssetPath(platformMetadata.Bundle)
+	contentTypes := map[string]string{bundlePath: inferBundleContentType(bundlePath)}
+	for _, asset := range platformMetadata.Assets {
+		path := normalizeAssetPath(asset.Path)
+		if _, ok := contentTypes[path]; !ok {
+			contentTypes[path] = asset.ContentType
+		}
+	}
+
+	planned := make([]DirectUploadAsset, 0, len(assets))
+	seen := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		asset.Path = normalizeAssetPath(asset.Path)
+		contentType, ok := contentTypes[asset.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s is not part of the bundle", asset.Path)
+		}
+		if seen[asset.Path] {
+			return nil, fmt.Errorf("%s is listed twice", asset.Path)
+		}
+		seen[asset.Path] = true
+
+		sum, err := base64.RawURLEncoding.DecodeString(asset.Hash)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%s has an invalid hash", asset.Path)
+		}
+		if asset.Size <= 0 {
+			return nil, fmt.Errorf("%s has an invalid size", asset.Path)
+		}
+		if contentType != "" {
+			asset.ContentType = contentType
+		} else if asset.ContentType == "" {
+			asset.ContentType = "application/octet-stream"
+		}
+		planned = append(planned, asset)
+	}
+
+	for path := range contentTypes {
+		if !seen[path] {
+			return nil, fmt.Errorf("%s is missing", path)
+		}
+	}
+	return planned, nil
+}
+
+// CreateDirectUpload starts a publish whose assets the client uploads
+// itself. Blobs the project already stores are reused; for the rest the
+// response has presigned storage requests, or requests to this server when
+// the storage provider cannot presign.
+func CreateDirectUpload(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project, update, ok := uploadTarget(w, r, queries)
+		if !ok {
+			return
+		}
+
+		var req CreateDirectUploadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Platform != "ios" && req.Platform != "android" {
+			jsonError(w, "Invalid platform", http.StatusBadRequest)
+			return
+		}
+		platformMetadata, exists := req.Metadata.FileMetadata[req.Platform]
+		if !exists {
+			jsonError(w, "Platform metadata not found", http.StatusBadRequest)
+			return
+		}
+		assets, err := planDirectUploadAssets(platformMetadata, req.Assets)
+		if err != nil {
+			jsonError(w, "Invalid assets: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		assetsJSON, err := json.Marshal(assets)
+		if err != nil {
+			jsonError(w, "Invalid assets", http.StatusBadRequest)
+			return
+		}
+
+		provider, err := publishProvider(r.Context(), queries, providers, project.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load project storage", slog.Any("error", err))
+			jsonError(w, "Project storage is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Find the blobs the project does not store yet.
+		var blobs []DirectUploadAsset
+		stored := make(map[string]bool)
+		var newSize int64
+		for _, asset := range assets {
+			if _, ok := stored[asset.Hash]; ok {
+				continue
+			}
+			_, err := queries.GetAssetByProjectAndHash(r.Context(), database.GetAssetByProjectAndHashParams{
+				ProjectID:       project.ID,
+				Hash:            asset.Hash,
+				StorageProvider: provider.Name(),
+			})
+			stored[asset.Hash] = err == nil
+			if err != nil {
+				blobs = append(blobs, asset)
+				newSize += asset.Size
+			}
+		}
+		if limit := uploads.maxSize(project); newSize > limit {
+			uploadTooLarge(w, limit)
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		expoConfig := []byte(req.ExpoConfig)
+		if len(expoConfig) == 0 || string(expoConfig) == "null" {
+			expoConfig = nil
+		}
+		activate := req.Activate == nil || *req.Activate
+		upload, err := qtx.CreateDirectUpload(r.Context(), database.CreateDirectUploadParams{
+			UpdateID:        update.ID,
+			Platform:        req.Platform,
+			Activate:        activate,
+			StorageProvider: provider.Name(),
+			ExpoConfig:      expoConfig,
+			Assets:          assetsJSON,
+			ExpiresAt:       pgtype.Timestamptz{Time: time.Now().Add(DirectUploadTTL), Valid: true},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create direct upload", slog.Any("error", err))
+			jsonError(w, "Failed to create direct upload", http.StatusInternalServerError)
+			return
+		}
+
+		for _, blob := range blobs {
+			key := buildStorageKey(project.Slug, blob.Hash)
+			err = qtx.CreateDirectUploadBlob(r.Context(), database.CreateDirectUploadBlobParams{
+				DirectUploadID: upload.ID,
+				Hash:           blob.Hash,
+				Key:            key,
+				MimeType:       blob.ContentType,
+				Size:           blob.Size,
+			})
+			if err == nil {
+				// Until finalize references it, the blob is garbage.
+				err = qtx.QueueStorageObjectForGC(r.Context(), database.QueueStorageObjectForGCParams{
+					Key:             key,
+					StorageProvider: provider.Name(),
+					MimeType:        blob.ContentType,
+				})
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to record direct upload blob", slog.Any("error", err))
+				jsonError(w, "Failed to create direct upload", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		res := DirectUploadResponse{
+			ID:        upload.ID.String(),
+			ExpiresAt: upload.ExpiresAt.Time.UnixMilli(),
+			Assets:    len(assets),
+			Reused:    len(assets) - len(blobs),
+			Uploads:   make([]BlobUpload, 0, len(blobs)),
+		}
+		presigner, canPresign := provider.(storage.Presigner)
+		for _, blob := range blobs {
+			if !canPresign {
+				res.Uploads = append(res.Uploads, BlobUpload{
+					Hash: blob.Hash,
+					PresignedRequest: storage.PresignedRequest{
+						Method:  http.MethodPut,
+						URL:     strings.TrimSuffix(r.URL.Path, "/") + "/" + upload.ID.String() + "/blobs/" + blob.Hash,
+						Headers: map[string]string{"Content-Type": blob.ContentType},
+					},
+					Proxied: true,
+				})
+				continue
+			}
+			sum, _ := base64.RawURLEncoding.DecodeString(blob.Hash)
+			presigned, err := presigner.PresignUpload(r.Context(), buildStorageKey(project.Slug, blob.Hash), blob.ContentType, blob.Size, sum, DirectUploadTTL)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to presign upload", slog.Any("error", err))
+				jsonError(w, "Failed to presign upload", http.StatusInternalServerError)
+				return
+			}
+			res.Uploads = append(res.Uploads, BlobUpload{Hash: blob.Hash, PresignedRequest: presigned})
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+		audit.SetTarget(r.Context(), "direct_upload_id", upload.ID.String())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// directUploadFromRequest loads the {direct_upload_id} upload of the update
+// a request is for.
+func directUploadFromRequest(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.Project, database.Update, database.DirectUpload, bool) {
+	project, update, ok := uploadTarget(w, r, queries)
+	if !ok {
+		return database.Project{}, database.Update{}, database.DirectUpload{}, false
+	}
+
+	id, err := utils.ParseUUID(chi.URLParam(r, "direct_upload_id"))
+	if err != nil {
+		jsonError(w, "Invalid direct upload ID", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, database.DirectUpload{}, false
+	}
+
+	upload, err := queries.GetDirectUpload(r.Context(), database.GetDirectUploadParams{
+		ID:       id,
+		UpdateID: update.ID,
+	})
+	if err != nil {
+		jsonError(w, "Direct upload not found", http.StatusNotFound)
+		return database.Project{}, database.Update{}, database.DirectUpload{}, false
+	}
+	return project, update, upload, true
+}
+
+// PutDirectUploadBlob stores a blob for storage providers that cannot
+// presign uploads. The body is checked against the blob's hash before it
+// reaches storage.
+func PutDirectUploadBlob(queries *database.Queries, providers *storage.Registry, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _, upload, ok := directUploadFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		blob, err := queries.GetDirectUploadBlob(r.Context(), database.GetDirectUploadBlobParams{
+			DirectUploadID: upload.ID,
+			Hash:           chi.URLParam(r, "hash"),
+		})
+		if err != nil {
+			jsonError(w, "Blob is not part of this upload", http.StatusNotFound)
+			return
+		}
+		if blob.Url != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		provider, err := providers.Get(r.Context(), upload.StorageProvider)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load storage provider", slog.String("provider", upload.StorageProvider), slog.Any("error", err))
+			jsonError(w, "Storage provider is not available", http.StatusInternalServerError)
+			return
+		}
+
+		os.MkdirAll(uploads.Dir, 0755)
+		tempFile, err := os.CreateTemp(uploads.Dir, "otaship-blob-*")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create temp file", slog.Any("error", err))
+			jsonError(w, "Failed to create temp file", http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(tempFile.Name())
+		defer tempFile.Close()
+
+		hasher := sha256.New()
+		body := http.MaxBytesReader(w, r.Body, blob.Size)
+		written, err := io.Copy(io.MultiWriter(tempFile, hasher), body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				jsonError(w, "Blob is larger than announced", http.StatusRequestEntityTooLarge)
+				return
+			}
+			jsonError(w, "Failed to read blob", http.StatusBadRequest)
+			return
+		}
+		if written != blob.Size || base64.RawURLEncoding.EncodeToString(hasher.Sum(nil)) != blob.Hash {
+			jsonError(w, "Blob does not match its hash", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+			jsonError(w, "Failed to read blob", http.StatusInternalServerError)
+			return
+		}
+		// Restart the grace period of the blob's queue entry, which an
+		// older copy under the same key may have let run out.
+		err = queries.QueueStorageObjectForGC(r.Context(), database.QueueStorageObjectForGCParams{
+			Key:             blob.Key,
+			StorageProvider: upload.StorageProvider,
+			MimeType:        blob.MimeType,
+		})
+		if err != nil {
+			jsonError(w, "Failed to record blob", http.StatusInternalServerError)
+			return
+		}
+		url, err := provider.Upload(r.Context(), blob.Key, tempFile, blob.MimeType, blob.Size)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to upload blob to storage",
+				slog.String("key", blob.Key),
+				slog.Any("error", err),
+			)
+			jsonError(w, "Failed to upload blob to storage", http.StatusBadGateway)
+			return
+		}
+
+		err = queries.SetDirectUploadBlobURL(r.Context(), database.SetDirectUploadBlobURLParams{
+			DirectUploadID: upload.ID,
+			Hash:           blob.Hash,
+			Url:            url,
+		})
+		if err != nil {
+			jsonError(w, "Failed to record blob", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verifyBlob checks that storage holds blob. Blobs sent through the server
+// were hashed on the way; presigned ones are checked against the checksum
+// storage recorded. It returns the URL of the blob.
+func verifyBlob(ctx context.Context, provider storage.Provider, blob database.DirectUploadBlob) (string, error) {
+	exists, err := provider.Exists(ctx, blob.Key)
+	if err != nil || !exists {
+		return "", errors.New("not uploaded")
+	}
+	if blob.Url != "" {
+		return blob.Url, nil
+	}
+
+	presigner, ok := provider.(storage.Presigner)
+	if !ok {
+		return "", errors.New("not uploaded")
+	}
+	sum, size, err := presigner.Checksum(ctx, blob.Key)
+	if err != nil {
+		return "", fmt.Errorf("checksum unavailable: %w", err)
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(blob.Hash)
+	if !bytes.Equal(sum, want) || size != blob.Size {
+		return "", errors.New("stored object does not match its hash")
+	}
+	return presigner.ObjectURL(blob.Key), nil
+}
+
+// FinalizeDirectUpload checks that every new blob reached storage intact
+// and then publishes the update like a bundle upload.
+func FinalizeDirectUpload(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project, update, upload, ok := directUploadFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		provider, err := providers.Get(r.Context(), upload.StorageProvider)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to load storage provider", slog.String("provider", upload.StorageProvider), slog.Any("error", err))
+			jsonError(w, "Storage provider is not available", http.StatusInternalServerError)
+			return
+		}
+
+		var assets []DirectUploadAsset
+		if err := json.Unmarshal(upload.Assets, &assets); err != nil {
+			jsonError(w, "Failed to read direct upload", http.StatusInternalServerError)
+			return
+		}
+		blobs, err := queries.ListDirectUploadBlobs(r.Context(), upload.ID)
+		if err != nil {
+			jsonError(w, "Failed to read direct upload", http.StatusInternalServerError)
+			return
+		}
+
+		urls := make(map[string]string, len(blobs))
+		var problems []string
+		for _, blob := range blobs {
+			url, err := verifyBlob(r.Context(), provider, blob)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", blob.Hash, err))
+				continue
+			}
+			urls[blob.Hash] = url
+		}
+		if len(problems) > 0 {
+			jsonError(w, "Assets are not ready: "+strings.Join(problems, "; "), http.StatusConflict)
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		// Look up reused blobs before replaceUpdateAssets drops the rows of
+		// a previous upload to this update, which may be the ones reused.
+		var uploadedAssets, newAssets []UploadedAsset
+		added := make(map[string]bool, len(blobs))
+		for _, asset := range assets {
+			uploaded := UploadedAsset{
+				FileName:    asset.Path,
+				Hash:        asset.Hash,
+				ContentType: asset.ContentType,
+				Size:        asset.Size,
+			}
+			if url, ok := urls[asset.Hash]; ok {
+				uploaded.StorageKey = buildStorageKey(project.Slug, asset.Hash)
+				uploaded.StorageURL = url
+				if !added[asset.Hash] {
+					added[asset.Hash] = true
+					newAssets = append(newAssets, uploaded)
+				}
+			} else {
+				existing, err := qtx.GetAssetByProjectAndHash(r.Context(), database.GetAssetByProjectAndHashParams{
+					ProjectID:       project.ID,
+					Hash:            asset.Hash,
+					StorageProvider: upload.StorageProvider,
+				})
+				if errors.Is(err, pgx.ErrNoRows) {
+					jsonError(w, "A reused asset was deleted; start a new upload", http.StatusConflict)
+					return
+				}
+				if err != nil {
+					jsonError(w, "Failed to look up asset", http.StatusInternalServerError)
+					return
+				}
+				uploaded.StorageKey = existing.Key
+				uploaded.StorageURL = existing.Url
+				uploaded.Reused = true
+			}
+			uploadedAssets = append(uploadedAssets, uploaded)
+		}
+
+		replaceUpdateAssets(r.Context(), qtx, update, upload.ExpoConfig)
+
+		if err := qtx.DeleteDirectUpload(r.Context(), upload.ID); err != nil {
+			jsonError(w, "Failed to finish direct upload", http.StatusInternalServerError)
+			return
+		}
+
+		// New blobs were queued for garbage collection when the upload was
+		// created, so there is nothing more to clean up on failure.
+		if commitBundle(w, r, tx, qtx, project, update, upload.Platform, upload.Activate, upload.StorageProvider, uploadedAssets, newAssets, func() {}) {
+			go storeAssetVariants(queries, provider, newAssets)
+		}
+	}
+}
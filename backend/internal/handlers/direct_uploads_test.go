@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPlanDirectUploadAssets(t *testing.T) {
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	metadata := PlatformFileMetadata{
+		Bundle: "_expo/static/js/ios/index-abc.hbc",
+		Assets: []AssetMetadata{
+			{Path: "assets/font1", Ext: "ttf", ContentType: "font/ttf"},
+			{Path: "assets/icon", Ext: "png"},
+		},
+	}
+	valid := []DirectUploadAsset{
+		{Path: "./_expo/static/js/ios/index-abc.hbc", Hash: hash("bundle"), Size: 6},
+		{Path: "assets/font1", Hash: hash("font"), Size: 4, ContentType: "text/plain"},
+		{Path: "assets/icon", Hash: hash("icon"), Size: 4, ContentType: "image/png"},
+	}
+
+	planned, err := planDirectUploadAssets(metadata, valid)
+	if err != nil {
+		t.Fatalf("Valid assets rejected: %v", err)
+	}
+	want := map[string]string{
+		"_expo/static/js/ios/index-abc.hbc": "application/octet-stream",
+		"assets/font1":                      "font/ttf",
+		"assets/icon":                       "image/png",
+	}
+	for _, asset := range planned {
+		if want[asset.Path] != asset.ContentType {
+			t.Errorf("%s got content type %q, want %q", asset.Path, asset.ContentType, want[asset.Path])
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func([]DirectUploadAsset) []DirectUploadAsset
+		wantErr string
+	}{
+		{"missing file", func(a []DirectUploadAsset) []DirectUploadAsset { return a[:2] }, "missing"},
+		{"extra file", func(a []DirectUploadAsset) []DirectUploadAsset {
+			return append(a, DirectUploadAsset{Path: "secrets.txt", Hash: hash("x"), Size: 1})
+		}, "not part of the bundle"},
+		{"duplicate", func(a []DirectUploadAsset) []DirectUploadAsset { return append(a, a[1]) }, "listed twice"},
+		{"bad hash", func(a []DirectUploadAsset) []DirectUploadAsset { a[0].Hash = "abc"; return a }, "invalid hash"},
+		{"bad size", func(a []DirectUploadAsset) []DirectUploadAsset { a[1].Size = 0; return a }, "invalid size"},
+	}
+	for _, tt := range tests {
+		assets := tt.modify(append([]DirectUploadAsset(nil), valid...))
+		_, err := planDirectUploadAssets(metadata, assets)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
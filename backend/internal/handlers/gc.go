@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/vknow360/otaship/backend/internal/gc"
+)
+
+// RunStorageGC runs the storage garbage collector on demand. It is a dry run
+// unless dry_run=false is passed, so the default output is a preview.
+func RunStorageGC(collector *gc.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		opts := gc.Options{DryRun: true}
+		if v := query.Get("dry_run"); v != "" {
+			dryRun, err := strconv.ParseBool(v)
+			if err != nil {
+				jsonError(w, "Invalid dry_run value", http.StatusBadRequest)
+				return
+			}
+			opts.DryRun = dryRun
+		}
+		if v := query.Get("orphans"); v != "" {
+			orphans, err := strconv.ParseBool(v)
+			if err != nil {
+				jsonError(w, "Invalid orphans value", http.StatusBadRequest)
+				return
+			}
+			opts.ScanOrphans = orphans
+		}
+
+		report, err := collector.Run(r.Context(), opts)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Storage garbage collection failed", slog.Any("error", err))
+			jsonError(w, "Failed to run garbage collection", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vknow360/otaship/backend/internal/storage"
+)
+
+// ServeLocalAsset streams objects written by the local storage provider.
+// http.ServeContent takes care of Range, If-Range and conditional requests
+// once the ETag and Content-Type headers are set. Behind the asset proxy
+// only signed URLs are served, since devices no longer need plain ones.
+func ServeLocalAsset(local *storage.LocalProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := chi.URLParam(r, "*")
+		if key == "" {
+			jsonError(w, "Asset key is required", http.StatusBadRequest)
+			return
+		}
+		if assetURLs != nil {
+			if err := assetURLs.verify(localAssetScope, key, r.URL.Query(), time.Now()); err != nil {
+				jsonError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+
+		// Serve a compressed variant when the client takes one. Variants
+		// sit next to the asset, under the key plus a suffix.
+		w.Header().Add("Vary", "Accept-Encoding")
+		var object *storage.LocalObject
+		var err error
+		for _, encoding := range acceptedEncodings(r.Header.Get("Accept-Encoding")) {
+			if object, err = local.Open(r.Context(), key+encoding.Suffix); err == nil {
+				break
+			}
+		}
+		if object == nil {
+			object, err = local.Open(r.Context(), key)
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalidKey) {
+				jsonError(w, "Asset not found", http.StatusNotFound)
+				return
+			}
+			slog.ErrorContext(r.Context(), "Failed to open local asset", slog.String("key", key), slog.Any("error", err))
+			jsonError(w, "Failed to read asset", http.StatusInternalServerError)
+			return
+		}
+		defer object.Close()
+		serveLocalObject(w, r, object)
+	}
+}
+
+// serveLocalObject writes a local object with the headers its metadata
+// calls for.
+func serveLocalObject(w http.ResponseWriter, r *http.Request, object *storage.LocalObject) {
+	if object.Meta.ContentType != "" {
+		w.Header().Set("Content-Type", object.Meta.ContentType)
+	}
+	if object.Meta.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", object.Meta.ContentEncoding)
+	}
+	if object.Meta.SHA256 != "" {
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, object.Meta.SHA256))
+	} else {
+		w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, object.Meta.Size, object.Meta.ModTime.UnixNano()))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	http.ServeContent(w, r, "", object.Meta.ModTime, object)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vknow360/otaship/backend/internal/storage"
+)
+
+func TestServeLocalAsset(t *testing.T) {
+	t.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+	local, err := storage.NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Failed to create local provider: %v", err)
+	}
+
+	data := []byte("0123456789abcdef")
+	key := "app/update/android/_expo/static/js/android/index.hbc"
+	if _, err := local.Upload(context.Background(), key, bytes.NewReader(data), "application/javascript", int64(len(data))); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/assets/*", ServeLocalAsset(local))
+
+	req := httptest.NewRequest("GET", "/assets/"+key, nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/javascript" {
+		t.Errorf("Expected Content-Type application/javascript, got %s", ct)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected ETag header")
+	}
+
+	req = httptest.NewRequest("GET", "/assets/"+key, nil)
+	req.Header.Set("Range", "bytes=4-7")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("Expected 206, got %d", rr.Code)
+	}
+	if rr.Body.String() != "4567" {
+		t.Errorf("Expected range body 4567, got %q", rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/assets/"+key, nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/assets/../secret", nil)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for traversal, got %d", rr.Code)
+	}
+}
+
+func TestServeLocalAssetVariants(t *testing.T) {
+	t.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+	local, err := storage.NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Failed to create local provider: %v", err)
+	}
+
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("console.log('hello');\n"), 200)
+	key := "app/assets/bundle"
+	if _, err := local.Upload(ctx, key, bytes.NewReader(data), "application/javascript", int64(len(data))); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	gz, _ := compressVariant(data, assetEncodings[1])
+	if _, err := local.UploadEncoded(ctx, key+".gz", bytes.NewReader(gz), "application/javascript", "gzip", int64(len(gz))); err != nil {
+		t.Fatalf("UploadEncoded failed: %v", err)
+	}
+
+	r := chi.NewRouter()
+	r.Get("/assets/*", ServeLocalAsset(local))
+
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+		wantBody       []byte
+	}{
+		{"", "", data},
+		{"gzip, deflate, br", "gzip", gz},
+		{"br", "", data},
+		{"gzip;q=0, identity", "", data},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/assets/"+key, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("Accept-Encoding %q: Content-Encoding = %q, want %q", tt.acceptEncoding, got, tt.wantEncoding)
+		}
+		if !bytes.Equal(rr.Body.Bytes(), tt.wantBody) {
+			t.Errorf("Accept-Encoding %q: unexpected body", tt.acceptEncoding)
+		}
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: missing Vary header", tt.acceptEncoding)
+		}
+	}
+}
+
+func TestServeLocalAssetRequiresSignatureBehindProxy(t *testing.T) {
+	t.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+	local, err := storage.NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Failed to create local provider: %v", err)
+	}
+	data := []byte("patch")
+	key := "app/patches/base-target"
+	if _, err := local.Upload(context.Background(), key, bytes.NewReader(data), "application/octet-stream", int64(len(data))); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	signer := NewAssetURLSigner("https://ota.example.com", []byte("secret"), time.Hour, 24*time.Hour)
+	SetAssetURLSigner(signer)
+	t.Cleanup(func() { SetAssetURLSigner(nil) })
+
+	r := chi.NewRouter()
+	r.Get("/assets/*", ServeLocalAsset(local))
+
+	signed, err := url.Parse(signer.localURL("/assets/"+key, key, time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"unsigned", "/assets/" + key, http.StatusForbidden},
+		{"signed", signed.RequestURI(), http.StatusOK},
+		{"signature for another key", "/assets/app/other?" + signed.RawQuery, http.StatusForbidden},
+		{"expired", signer.localURL("/assets/"+key, key, time.Now().Add(-time.Minute)), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", tt.target, nil))
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
@@ -10,16 +10,16 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/cache"
+	"github.com/vknow360/otaship/backend/internal/codesign"
 	"github.com/vknow360/otaship/backend/internal/database"
 	"github.com/vknow360/otaship/backend/internal/utils"
 )
@@ -29,85 +29,148 @@ type ApiKey struct {
 	ProjectID pgtype.UUID `json:"project_id"`
 }
 
-var (
-	recentDownloads      = make(map[string]time.Time)
-	recentDownloadsMutex sync.RWMutex
+// Manifest cache: keyed by "projectID:platform:runtime:branch". An entry
+// holds the update resolved for the key together with its rollout policy, so
+// that whether a device gets it is decided per request, never cached.
+type manifestCacheEntry struct {
+	UpdateID          string `json:"update_id"` // empty = no update available
+	RolloutPercentage int32  `json:"rollout_percentage"`
+	IsRollback        bool   `json:"is_rollback"`
+	CommitTime        string `json:"commit_time"`
+	Data              []byte `json:"data"`      // pre-built manifest JSON, nil for rollbacks
+	Signature         string `json:"signature"` // publish-time expo-signature, served as-is
+	LaunchHash        string `json:"launch_hash"`
+	ExpiresAt         int64  `json:"expires_at"` // unix ms when signed asset URLs expire, 0 = never
+}
+
+// expired reports whether the asset URLs of a publish-time signed manifest
+// no longer work, so serving it would only get devices 403s.
+func (e *manifestCacheEntry) expired() bool {
+	return e.ExpiresAt != 0 && time.Now().UnixMilli() >= e.ExpiresAt
+}
+
+// manifestAction is what a device is sent for a resolved update.
+type manifestAction int
+
+const (
+	serveNoUpdate manifestAction = iota
+	serveRollbackDirective
+	serveManifest
 )
 
-// Manifest cache: keyed by "projectID:platform:runtime:channel"
-type manifestCacheEntry struct {
-	data      []byte // pre-built manifest JSON (nil = no update available)
-	updateID  string
-	createdAt time.Time
+// action decides what a device gets from the resolved update.
+func (e *manifestCacheEntry) action(deviceHash, currentUpdateID, embeddedUpdateID string, protocolVersion int) manifestAction {
+	if e.UpdateID == "" || e.expired() {
+		return serveNoUpdate
+	}
+	if e.IsRollback {
+		if currentUpdateID == embeddedUpdateID {
+			return serveNoUpdate
+		}
+		return serveRollbackDirective
+	}
+	if e.RolloutPercentage < 100 && !shouldReceiveUpdate(int(e.RolloutPercentage), deviceHash) {
+		return serveNoUpdate
+	}
+	if currentUpdateID == e.UpdateID && protocolVersion == 1 {
+		return serveNoUpdate
+	}
+	return serveManifest
 }
 
-const manifestCacheTTL = 10 * time.Minute
+const (
+	manifestCacheTTL = 10 * time.Minute
+	downloadDedupTTL = 5 * time.Minute
+
+	// ManifestInvalidationChannel is the Bus channel InvalidateManifestCache
+	// publishes project IDs on.
+	ManifestInvalidationChannel = "manifest-invalidations"
+)
 
 var (
-	manifestCache      = make(map[string]*manifestCacheEntry)
-	manifestCacheMutex sync.RWMutex
+	manifestCache         cache.Cache = cache.NewMemory()
+	manifestInvalidations cache.Bus
 )
 
-func manifestCacheKey(projectID, platform, runtime, channel string) string {
-	return projectID + ":" + platform + ":" + runtime + ":" + channel
+// SetManifestCache replaces the in-process manifest cache. With a bus,
+// invalidations are also published so other instances drop their entries;
+// they should pass HandleManifestInvalidation to bus.Subscribe. Call it
+// before serving requests.
+func SetManifestCache(c cache.Cache, bus cache.Bus) {
+	manifestCache = c
+	manifestInvalidations = bus
+}
+
+func manifestCacheKey(projectID, platform, runtime, branch string) string {
+	return projectID + ":" + platform + ":" + runtime + ":" + branch
 }
 
-func getCachedManifest(key string) (*manifestCacheEntry, bool) {
-	manifestCacheMutex.RLock()
-	defer manifestCacheMutex.RUnlock()
-	entry, ok := manifestCache[key]
-	if !ok || time.Since(entry.createdAt) > manifestCacheTTL {
+func getCachedManifest(ctx context.Context, key string) (*manifestCacheEntry, bool) {
+	data, ok, err := manifestCache.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "Manifest cache read failed", slog.Any("error", err))
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	var entry manifestCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
 		return nil, false
 	}
-	return entry, true
+	return &entry, true
 }
 
-func setCachedManifest(key string, data []byte, updateID string) {
-	manifestCacheMutex.Lock()
-	defer manifestCacheMutex.Unlock()
-	manifestCache[key] = &manifestCacheEntry{
-		data:      data,
-		updateID:  updateID,
-		createdAt: time.Now(),
+func setCachedManifest(ctx context.Context, key string, entry *manifestCacheEntry) {
+	encoded, err := json.Marshal(entry)
+	if err == nil {
+		err = manifestCache.Set(ctx, key, encoded, manifestCacheTTL)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Manifest cache write failed", slog.Any("error", err))
 	}
 }
 
-// InvalidateManifestCache clears all cached manifests for a given project.
+// InvalidateManifestCache clears all cached manifests for a given project,
+// on this instance and, through the invalidation bus, on every other one.
 // Call this when updates are created, deleted, or rolled back.
 func InvalidateManifestCache(projectID string) {
-	manifestCacheMutex.Lock()
-	defer manifestCacheMutex.Unlock()
-	for key := range manifestCache {
-		if strings.HasPrefix(key, projectID+":") {
-			delete(manifestCache, key)
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	HandleManifestInvalidation(projectID)
+	if manifestInvalidations == nil {
+		return
+	}
+	if err := manifestInvalidations.Publish(ctx, projectID); err != nil {
+		slog.Error("Failed to publish manifest cache invalidation",
+			slog.String("project_id", projectID),
+			slog.Any("error", err),
+		)
 	}
 }
 
-func init() {
-	go func() {
-		for range time.NewTicker(10 * time.Minute).C {
-			now := time.Now()
-			recentDownloadsMutex.Lock()
-			for key, lastTime := range recentDownloads {
-				if now.Sub(lastTime) > 10*time.Minute {
-					delete(recentDownloads, key)
-				}
-			}
-			recentDownloadsMutex.Unlock()
+// HandleManifestInvalidation drops the cached manifests of a project. An
+// empty project ID drops every cached manifest.
+func HandleManifestInvalidation(projectID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-			manifestCacheMutex.Lock()
-			for key, entry := range manifestCache {
-				if now.Sub(entry.createdAt) > manifestCacheTTL {
-					delete(manifestCache, key)
-				}
-			}
-			manifestCacheMutex.Unlock()
-		}
-	}()
+	prefix := ""
+	if projectID != "" {
+		prefix = projectID + ":"
+	}
+	if err := manifestCache.DeletePrefix(ctx, prefix); err != nil {
+		slog.Error("Failed to invalidate manifest cache",
+			slog.String("project_id", projectID),
+			slog.Any("error", err),
+		)
+	}
 }
 
-func CheckForUpdates(queries *database.Queries) http.HandlerFunc {
+// CheckForUpdates serves the manifest endpoint. With a patcher, clients that
+// send A-IM: bsdiff are also offered a patch for the launch asset.
+func CheckForUpdates(queries *database.Queries, deviceIDHeader string, patcher *BundlePatcher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "project_id")
 
@@ -148,6 +211,24 @@ func CheckForUpdates(queries *database.Queries) http.HandlerFunc {
 
 		currentUpdateID := r.Header.Get("expo-current-update-id")
 
+		var signer *manifestSigner
+		if header := r.Header.Get("expo-expect-signature"); header != "" {
+			expect := codesign.ParseHeader(header)
+			key, err := resolveSigningKey(r.Context(), queries, projectId, expect["keyid"])
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to load signing key", slog.Any("error", err))
+				jsonError(w, "Failed to load signing key", http.StatusInternalServerError)
+				return
+			}
+			signer = &manifestSigner{key: key, keyID: expect["keyid"]}
+			if key == nil && expect["keyid"] != "" {
+				signer.unavailable = "No active signing key with keyid " + expect["keyid"]
+			} else if alg := expect["alg"]; key != nil && alg != "" && alg != key.Algorithm {
+				signer.key = nil
+				signer.unavailable = fmt.Sprintf("Signing key %s uses %s, not %s", key.KeyID, key.Algorithm, alg)
+			}
+		}
+
 		slog.InfoContext(r.Context(), "Manifest request",
 			slog.String("project_id", id),
 			slog.String("platform", platform),
@@ -155,203 +236,180 @@ func CheckForUpdates(queries *database.Queries) http.HandlerFunc {
 			slog.String("channel", channel),
 		)
 
-		cacheKey := manifestCacheKey(id, platform, runtimeVersion, channel)
-		if cached, ok := getCachedManifest(cacheKey); ok {
-			if cached.data == nil {
-				handleNoUpdateAvailable(w, r, protocolVersion)
-				return
-			}
-			if currentUpdateID == cached.updateID && protocolVersion == 1 {
-				handleNoUpdateAvailable(w, r, protocolVersion)
-				return
-			}
+		deviceHash, deviceSource := utils.BuildDeviceIdentity(r, platform, deviceIDHeader)
 
+		branch, err := resolveChannelBranch(r.Context(), queries, projectId, channel, deviceHash)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to resolve channel", slog.Any("error", err))
+			jsonError(w, "Failed to resolve channel", http.StatusInternalServerError)
+			return
+		}
+
+		cacheKey := manifestCacheKey(id, platform, runtimeVersion, branch)
+		entry, ok := getCachedManifest(r.Context(), cacheKey)
+		if ok {
 			slog.DebugContext(r.Context(), "Manifest cache hit",
 				slog.String("key", cacheKey),
-				slog.String("update_id", cached.updateID),
+				slog.String("update_id", entry.UpdateID),
 			)
-
-			deviceHash := utils.BuildDeviceHash(r, platform)
-			updateId, _ := utils.ParseUUID(cached.updateID)
-			go logDownloadEvent(queries, database.Update{ID: updateId}, projectId, deviceHash, platform, channel)
-
-			contentType := "application/json"
-			if protocolVersion == 1 {
-				contentType = "application/expo+json"
+		} else {
+			update, err := queries.GetLatestActiveUpdate(r.Context(), database.GetLatestActiveUpdateParams{
+				ProjectID:      projectId,
+				Platform:       platform,
+				RuntimeVersion: runtimeVersion,
+				Channel:        branch,
+			})
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Failed to fetch update", http.StatusInternalServerError)
+				return
 			}
-			sendMultipartResponse(w, r, "manifest", cached.data, protocolVersion, contentType, channel)
-			return
-		}
-
-		update, err := queries.GetLatestActiveUpdate(r.Context(), database.GetLatestActiveUpdateParams{
-			ProjectID:      projectId,
-			Platform:       platform,
-			RuntimeVersion: runtimeVersion,
-			Channel:        channel,
-		})
 
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				// Cache the "no update" result too
-				setCachedManifest(cacheKey, nil, "")
-				handleNoUpdateAvailable(w, r, protocolVersion)
-				return
+			// A missing update is cached too, as an entry without an ID.
+			entry = &manifestCacheEntry{}
+			if err == nil {
+				entry, err = newManifestCacheEntry(r.Context(), queries, update)
+				if err != nil {
+					if errors.Is(err, errMissingLaunchAsset) {
+						slog.ErrorContext(r.Context(), "No launch asset found for update", slog.String("update_id", update.ID.String()))
+						jsonError(w, "Invalid update: missing launch asset", http.StatusInternalServerError)
+						return
+					}
+					slog.ErrorContext(r.Context(), "Failed to build manifest", slog.Any("error", err))
+					jsonError(w, "Failed to create manifest", http.StatusInternalServerError)
+					return
+				}
 			}
-			jsonError(w, "Failed to fetch update", http.StatusInternalServerError)
-			return
+			setCachedManifest(r.Context(), cacheKey, entry)
 		}
 
-		if update.IsRollback {
-			embeddedUpdateID := r.Header.Get("expo-embedded-update-id")
-			if currentUpdateID == embeddedUpdateID {
-				handleNoUpdateAvailable(w, r, protocolVersion)
-				return
+		switch entry.action(deviceHash, currentUpdateID, r.Header.Get("expo-embedded-update-id"), protocolVersion) {
+		case serveNoUpdate:
+			if entry.expired() {
+				warnExpiredSignedManifest(r.Context(), entry.UpdateID)
 			}
+			handleNoUpdateAvailable(w, r, protocolVersion, signer)
 
+		case serveRollbackDirective:
 			directive := map[string]interface{}{
 				"type": "rollBackToEmbedded",
 				"parameters": map[string]interface{}{
-					"commitTime": update.CreatedAt.Time.Format("2006-01-02T15:04:05.000Z"),
+					"commitTime": entry.CommitTime,
 				},
 			}
 			directiveJSON, _ := json.Marshal(directive)
-			sendMultipartResponse(w, r, "directive", directiveJSON, protocolVersion, "application/json", channel)
-			return
-		}
-
-		if update.RolloutPercentage < 100 {
-			deviceHash := utils.BuildDeviceHash(r, platform)
-			if !shouldReceiveUpdate(int(update.RolloutPercentage), deviceHash) {
-				handleNoUpdateAvailable(w, r, protocolVersion)
-				return
-			}
-		}
+			sendMultipartResponse(w, r, "directive", directiveJSON, protocolVersion, "application/json", channel, signer, "", nil)
 
-		if currentUpdateID == update.ID.String() && protocolVersion == 1 {
-			handleNoUpdateAvailable(w, r, protocolVersion)
-			return
-		}
-
-		assets, err := queries.GetAssetsByUpdateID(r.Context(), update.ID)
-		if err != nil {
-			jsonError(w, "Failed to fetch assets", http.StatusInternalServerError)
-			return
-		}
-		if assets == nil {
-			assets = []database.Asset{}
-		}
+		case serveManifest:
+			slog.InfoContext(r.Context(), "Sending manifest",
+				slog.String("update_id", entry.UpdateID),
+				slog.String("platform", platform),
+				slog.String("runtime", runtimeVersion),
+			)
 
-		var launchAsset *database.Asset
-		var regularAssets []database.Asset
+			updateId, _ := utils.ParseUUID(entry.UpdateID)
+			go logDownloadEvent(queries, database.Update{ID: updateId}, projectId, deviceHash, deviceSource, platform, channel)
 
-		for i := range assets {
-			if isLaunchAsset(assets[i].FileName) {
-				launchAsset = &assets[i]
-			} else {
-				regularAssets = append(regularAssets, assets[i])
+			contentType := "application/json"
+			if protocolVersion == 1 {
+				contentType = "application/expo+json"
 			}
-		}
-
-		if launchAsset == nil {
-			slog.ErrorContext(r.Context(), "No launch asset found for update", slog.String("update_id", update.ID.String()))
-			jsonError(w, "Invalid update: missing launch asset", http.StatusInternalServerError)
-			return
-		}
 
-		// Build expoClient from stored config
-		var expoClient interface{} = map[string]interface{}{}
-		if update.ExpoConfig != nil {
-			err = json.Unmarshal(update.ExpoConfig, &expoClient)
-			if err != nil {
-				slog.WarnContext(r.Context(), "Failed to unmarshal expo config", slog.Any("error", err))
+			// The patch goes in the extensions part, so the cached and
+			// possibly publish-time signed manifest stays the same for
+			// every device.
+			var extensions []byte
+			if patcher != nil && currentUpdateID != "" && entry.LaunchHash != "" && acceptsBundlePatch(r) {
+				if patch := patcher.find(r.Context(), projectId, currentUpdateID, entry.UpdateID, entry.LaunchHash); patch != nil {
+					extensions, _ = json.Marshal(map[string]interface{}{"launchAssetPatch": patch})
+				}
 			}
+			sendMultipartResponse(w, r, "manifest", entry.Data, protocolVersion, contentType, channel, signer, entry.Signature, extensions)
 		}
+	}
+}
 
-		manifest := map[string]interface{}{
-			"id":             update.ID.String(),
-			"createdAt":      update.CreatedAt.Time.Format("2006-01-02T15:04:05.000Z"),
-			"runtimeVersion": update.RuntimeVersion,
-			"assets":         buildAssetsArray(regularAssets),
-			"metadata":       map[string]interface{}{},
-			"extra": map[string]interface{}{
-				"expoClient": expoClient,
-			},
-		}
-
-		launchEntry := map[string]interface{}{
-			"hash":        launchAsset.Hash,
-			"key":         launchAsset.Key,
-			"contentType": launchAsset.MimeType,
-			"url":         launchAsset.Url,
-		}
-		if ext := filepath.Ext(launchAsset.FileName); ext != "" {
-			launchEntry["fileExtension"] = ext
-		}
-		manifest["launchAsset"] = launchEntry
+// newManifestCacheEntry resolves what is served for an update. Rollback
+// updates become a directive and have no manifest of their own.
+func newManifestCacheEntry(ctx context.Context, queries *database.Queries, update database.Update) (*manifestCacheEntry, error) {
+	entry := &manifestCacheEntry{
+		UpdateID:          update.ID.String(),
+		RolloutPercentage: update.RolloutPercentage,
+		IsRollback:        update.IsRollback,
+		CommitTime:        update.CreatedAt.Time.Format("2006-01-02T15:04:05.000Z"),
+	}
+	if update.IsRollback {
+		return entry, nil
+	}
 
-		manifestJSON, err := json.Marshal(manifest)
+	// Publish-time signed updates are served byte for byte as signed, until
+	// the asset URLs signed with them expire.
+	entry.Data, entry.Signature = update.SignedManifest, update.ManifestSignature.String
+	if t := signedURLsExpireAt(update); !t.IsZero() {
+		entry.ExpiresAt = t.UnixMilli()
+	}
+	if entry.Data == nil {
+		data, err := buildUpdateManifest(ctx, queries, update, manifestURLExpiry())
 		if err != nil {
-			slog.ErrorContext(r.Context(), "Failed to marshal manifest", slog.Any("error", err))
-			jsonError(w, "Failed to create manifest", http.StatusInternalServerError)
-			return
+			return nil, err
 		}
+		entry.Data = data
+	}
 
-		// Cache the built manifest
-		setCachedManifest(cacheKey, manifestJSON, update.ID.String())
-
-		slog.InfoContext(r.Context(), "Sending manifest",
-			slog.String("update_id", update.ID.String()),
-			slog.String("platform", platform),
-			slog.String("runtime", runtimeVersion),
-		)
-
-		deviceHash := utils.BuildDeviceHash(r, platform)
-		go logDownloadEvent(queries, update, projectId, deviceHash, platform, channel)
-
-		contentType := "application/json"
-		if protocolVersion == 1 {
-			contentType = "application/expo+json"
-		}
+	var manifest struct {
+		LaunchAsset struct {
+			Hash string `json:"hash"`
+		} `json:"launchAsset"`
+	}
+	if err := json.Unmarshal(entry.Data, &manifest); err == nil {
+		entry.LaunchHash = manifest.LaunchAsset.Hash
+	}
+	return entry, nil
+}
 
-		sendMultipartResponse(w, r, "manifest", manifestJSON, protocolVersion, contentType, channel)
+// warnExpiredSignedManifest logs, at most once an hour per update, that an
+// active update is withheld because its signed asset URLs expired.
+func warnExpiredSignedManifest(ctx context.Context, updateID string) {
+	first, err := manifestCache.Add(ctx, "expired-manifest:"+updateID, time.Hour)
+	if err == nil && !first {
+		return
 	}
+	slog.WarnContext(ctx, "Asset URLs of the signed manifest have expired; serving no update until it is republished",
+		slog.String("update_id", updateID),
+	)
 }
 
 func logDownloadEvent(
 	queries *database.Queries,
 	update database.Update,
 	projectId pgtype.UUID,
-	deviceHash, platform, channel string,
+	deviceHash, deviceSource, platform, channel string,
 ) {
 
-	cacheKey := fmt.Sprintf("%s:%s", deviceHash, update.ID.String())
-
-	recentDownloadsMutex.RLock()
-	lastLog, ok := recentDownloads[cacheKey]
-	recentDownloadsMutex.RUnlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	if ok && time.Since(lastLog) < 5*time.Minute {
+	// Count a device at most once per update every few minutes.
+	dedupKey := "download:" + deviceHash + ":" + update.ID.String()
+	first, err := manifestCache.Add(ctx, dedupKey, downloadDedupTTL)
+	if err != nil {
+		slog.Warn("Download dedup check failed", slog.Any("error", err))
+	} else if !first {
 		return
 	}
 
 	event := database.CreateDownloadEventParams{
-		UpdateID:   update.ID,
-		ProjectID:  projectId,
-		DeviceHash: deviceHash,
-		Platform:   platform,
-		Channel:    channel,
+		UpdateID:       update.ID,
+		ProjectID:      projectId,
+		DeviceHash:     deviceHash,
+		Platform:       platform,
+		Channel:        channel,
+		DeviceIDSource: deviceSource,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_, err := queries.CreateDownloadEvent(ctx, event)
+	_, err = queries.CreateDownloadEvent(ctx, event)
 	if err != nil {
 		slog.Error("Failed to log download event", slog.Any("error", err))
-		return
+		manifestCache.DeletePrefix(ctx, dedupKey)
 	}
-
-	recentDownloadsMutex.Lock()
-	recentDownloads[cacheKey] = time.Now()
-	recentDownloadsMutex.Unlock()
 }
 
 func shouldReceiveUpdate(percentage int, deviceHash string) bool {
@@ -371,6 +429,87 @@ func shouldReceiveUpdate(percentage int, deviceHash string) bool {
 	return int(val%100) < percentage
 }
 
+// manifestSigner carries the server key chosen from expo-expect-signature.
+type manifestSigner struct {
+	key *database.SigningKey
+	// keyID is the keyid the client asked for, if any.
+	keyID string
+	// unavailable is set when the requested keyid or alg has no server key.
+	// Parts that were signed at publish time can still be served.
+	unavailable string
+}
+
+var errMissingLaunchAsset = errors.New("missing launch asset")
+
+// buildUpdateManifest renders the manifest served for an update. The output
+// only depends on stored data (json.Marshal sorts map keys) and expires, so
+// a signature made over it at publish time stays valid. With the asset
+// proxy, asset URLs expire at expires; a zero expires never does.
+func buildUpdateManifest(ctx context.Context, queries *database.Queries, update database.Update, expires time.Time) ([]byte, error) {
+	assets, err := queries.GetAssetsByUpdateID(ctx, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	if assets == nil {
+		assets = []database.Asset{}
+	}
+
+	var launchAsset *database.Asset
+	var regularAssets []database.Asset
+
+	for i := range assets {
+		if isLaunchAsset(assets[i].FileName) {
+			launchAsset = &assets[i]
+		} else {
+			regularAssets = append(regularAssets, assets[i])
+		}
+	}
+
+	if launchAsset == nil {
+		return nil, errMissingLaunchAsset
+	}
+
+	assetURL := func(asset database.Asset) string {
+		if assetURLs == nil {
+			return asset.Url
+		}
+		return assetURLs.URL(update.ProjectID.String(), asset.Hash, expires)
+	}
+
+	// Build expoClient from stored config
+	var expoClient interface{} = map[string]interface{}{}
+	if update.ExpoConfig != nil {
+		err = json.Unmarshal(update.ExpoConfig, &expoClient)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to unmarshal expo config", slog.Any("error", err))
+		}
+	}
+
+	manifest := map[string]interface{}{
+		"id":             update.ID.String(),
+		"createdAt":      update.CreatedAt.Time.Format("2006-01-02T15:04:05.000Z"),
+		"runtimeVersion": update.RuntimeVersion,
+		"assets":         buildAssetsArray(regularAssets, assetURL),
+		"metadata":       map[string]interface{}{},
+		"extra": map[string]interface{}{
+			"expoClient": expoClient,
+		},
+	}
+
+	launchEntry := map[string]interface{}{
+		"hash":        launchAsset.Hash,
+		"key":         launchAsset.Key,
+		"contentType": launchAsset.MimeType,
+		"url":         assetURL(*launchAsset),
+	}
+	if ext := filepath.Ext(launchAsset.FileName); ext != "" {
+		launchEntry["fileExtension"] = ext
+	}
+	manifest["launchAsset"] = launchEntry
+
+	return json.Marshal(manifest)
+}
+
 func sendMultipartResponse(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -379,6 +518,9 @@ func sendMultipartResponse(
 	protocolVersion int,
 	partContentType string,
 	channel string,
+	signer *manifestSigner,
+	signature string,
+	extensions []byte,
 ) {
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
@@ -389,17 +531,28 @@ func sendMultipartResponse(
 		fmt.Sprintf(`form-data; name="%s"`, partName),
 	)
 
-	if r.Header.Get("expo-expect-signature") != "" {
-		pvtKey := os.Getenv("EXPO_PRIVATE_KEY")
-		if pvtKey != "" {
-			sig, err := utils.SignManifest(data, pvtKey)
-			if err != nil {
-				slog.Error("Code signing error", slog.Any("error", err))
-			} else if sig != "" {
-				expoSignature := fmt.Sprintf(`sig="%s", keyid="main"`, sig)
-				w.Header().Set("expo-signature", expoSignature)
-				partHeader.Set("expo-signature", expoSignature)
+	if signer != nil {
+		// A publish-time signature only helps clients that trust its key.
+		expoSignature := signature
+		if expoSignature != "" && signer.keyID != "" && codesign.ParseHeader(expoSignature)["keyid"] != signer.keyID {
+			expoSignature = ""
+		}
+		if expoSignature == "" {
+			if signer.unavailable != "" {
+				jsonError(w, signer.unavailable, http.StatusBadRequest)
+				return
 			}
+			if signer.key != nil {
+				var err error
+				expoSignature, err = signPart(data, signer.key)
+				if err != nil {
+					slog.Error("Code signing error", slog.String("key_id", signer.key.KeyID), slog.Any("error", err))
+				}
+			}
+		}
+		if expoSignature != "" {
+			w.Header().Set("expo-signature", expoSignature)
+			partHeader.Set("expo-signature", expoSignature)
 		}
 	}
 
@@ -416,6 +569,24 @@ func sendMultipartResponse(
 		return
 	}
 
+	// Extensions are left out when they cannot be signed like the part.
+	if extensions != nil && (signer == nil || signer.key != nil) {
+		extHeader := make(textproto.MIMEHeader)
+		extHeader.Set("Content-Type", "application/json")
+		extHeader.Set("Content-Disposition", `form-data; name="extensions"`)
+		if signer != nil {
+			extSignature, err := signPart(extensions, signer.key)
+			if err != nil {
+				slog.Error("Code signing error", slog.String("key_id", signer.key.KeyID), slog.Any("error", err))
+			} else {
+				extHeader.Set("expo-signature", extSignature)
+			}
+		}
+		if ext, err := writer.CreatePart(extHeader); err == nil {
+			ext.Write(extensions)
+		}
+	}
+
 	writer.Close()
 
 	w.Header().Set("expo-protocol-version", strconv.Itoa(protocolVersion))
@@ -431,10 +602,24 @@ func sendMultipartResponse(
 	w.Write(buf.Bytes())
 }
 
+// signPart returns the expo-signature header value for a multipart body.
+func signPart(data []byte, signingKey *database.SigningKey) (string, error) {
+	signer, err := codesign.NewSigner(signingKey.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	sig, err := signer.Sign(data)
+	if err != nil {
+		return "", err
+	}
+	return codesign.FormatHeader(sig, signingKey.KeyID, signer.Alg()), nil
+}
+
 func handleNoUpdateAvailable(
 	w http.ResponseWriter,
 	r *http.Request,
 	protocolVersion int,
+	signer *manifestSigner,
 ) {
 	if protocolVersion == 0 {
 		jsonError(w, "No update available", http.StatusNotFound)
@@ -451,21 +636,21 @@ func handleNoUpdateAvailable(
 		return
 	}
 
-	sendMultipartResponse(w, r, "directive", directiveJSON, protocolVersion, "application/json", "")
+	sendMultipartResponse(w, r, "directive", directiveJSON, protocolVersion, "application/json", "", signer, "", nil)
 }
 
 func isLaunchAsset(fileName string) bool {
 	return strings.HasPrefix(fileName, "_expo/static/js/")
 }
 
-func buildAssetsArray(assets []database.Asset) []map[string]interface{} {
+func buildAssetsArray(assets []database.Asset, assetURL func(database.Asset) string) []map[string]interface{} {
 	result := make([]map[string]interface{}, len(assets))
 	for i, asset := range assets {
 		entry := map[string]interface{}{
 			"hash":        asset.Hash,
 			"key":         asset.Key,
 			"contentType": asset.MimeType,
-			"url":         asset.Url,
+			"url":         assetURL(asset),
 		}
 		if ext := filepath.Ext(asset.FileName); ext != "" {
 			entry["fileExtension"] = ext
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vknow360/otaship/backend/internal/cache"
+	"github.com/vknow360/otaship/backend/internal/codesign"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+func TestSendMultipartResponseSignature(t *testing.T) {
+	for _, alg := range codesign.SupportedAlgs() {
+		t.Run(alg, func(t *testing.T) {
+			privateKeyPEM, certificatePEM, err := codesign.GenerateKey(alg, "my-app", time.Hour)
+			if err != nil {
+				t.Fatalf("GenerateKey() unexpected error: %v", err)
+			}
+			key := &database.SigningKey{KeyID: "rotated", Algorithm: alg, PrivateKey: privateKeyPEM}
+
+			manifest := []byte(`{"id":"123"}`)
+			req := httptest.NewRequest("GET", "/api/manifest/x", nil)
+			rec := httptest.NewRecorder()
+			sendMultipartResponse(rec, req, "manifest", manifest, 1, "application/expo+json", "production", &manifestSigner{key: key}, "", nil)
+
+			_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+			if err != nil {
+				t.Fatalf("Invalid content type: %v", err)
+			}
+			part, err := multipart.NewReader(rec.Body, params["boundary"]).NextPart()
+			if err != nil {
+				t.Fatalf("Failed to read part: %v", err)
+			}
+			body, _ := io.ReadAll(part)
+
+			header := part.Header.Get("expo-signature")
+			parsed := codesign.ParseHeader(header)
+			if parsed["keyid"] != "rotated" || parsed["alg"] != alg {
+				t.Errorf("Unexpected expo-signature %q", header)
+			}
+			if err := codesign.VerifyHeader(certificatePEM, header, body); err != nil {
+				t.Errorf("Served manifest does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSendMultipartResponsePresignedSignature(t *testing.T) {
+	privateKeyPEM, _, err := codesign.GenerateKey(codesign.AlgRSAPKCS1SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	serverKey := &database.SigningKey{KeyID: "main", Algorithm: codesign.AlgRSAPKCS1SHA256, PrivateKey: privateKeyPEM}
+	presigned := codesign.FormatHeader([]byte("publish-time"), "release", codesign.AlgRSAPKCS1SHA256)
+
+	tests := []struct {
+		name   string
+		signer *manifestSigner
+		keyid  string
+	}{
+		{"no keyid requested", &manifestSigner{key: serverKey}, "release"},
+		{"publish keyid requested", &manifestSigner{keyID: "release", unavailable: "no server key"}, "release"},
+		{"other keyid requested", &manifestSigner{key: serverKey, keyID: "main"}, "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/manifest/x", nil)
+			rec := httptest.NewRecorder()
+			sendMultipartResponse(rec, req, "manifest", []byte(`{"id":"123"}`), 1, "application/expo+json", "production", tt.signer, presigned, nil)
+
+			header := rec.Header().Get("expo-signature")
+			if got := codesign.ParseHeader(header)["keyid"]; got != tt.keyid {
+				t.Errorf("expo-signature keyid = %q, want %q (header %q)", got, tt.keyid, header)
+			}
+		})
+	}
+}
+
+func TestSendMultipartResponseExtensions(t *testing.T) {
+	privateKeyPEM, certificatePEM, err := codesign.GenerateKey(codesign.AlgRSAPKCS1SHA256, "my-app", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateKey() unexpected error: %v", err)
+	}
+	serverKey := &database.SigningKey{KeyID: "main", Algorithm: codesign.AlgRSAPKCS1SHA256, PrivateKey: privateKeyPEM}
+	presigned := codesign.FormatHeader([]byte("publish-time"), "release", codesign.AlgRSAPKCS1SHA256)
+	extensions := []byte(`{"launchAssetPatch":{"format":"bsdiff"}}`)
+
+	tests := []struct {
+		name   string
+		signer *manifestSigner
+		want   bool
+	}{
+		{"unsigned", nil, true},
+		{"server key", &manifestSigner{key: serverKey}, true},
+		{"publish-time signature only", &manifestSigner{keyID: "release", unavailable: "no server key"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/manifest/x", nil)
+			rec := httptest.NewRecorder()
+			sendMultipartResponse(rec, req, "manifest", []byte(`{"id":"123"}`), 1, "application/expo+json", "production", tt.signer, presigned, extensions)
+
+			_, params, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+			reader := multipart.NewReader(rec.Body, params["boundary"])
+			if _, err := reader.NextPart(); err != nil {
+				t.Fatalf("Failed to read manifest part: %v", err)
+			}
+			part, err := reader.NextPart()
+			if !tt.want {
+				if err != io.EOF {
+					t.Errorf("Got an extensions part that cannot be signed")
+				}
+				return
+			}
+			if err != nil || part.FormName() != "extensions" {
+				t.Fatalf("Missing extensions part: %v", err)
+			}
+			body, _ := io.ReadAll(part)
+			if string(body) != string(extensions) {
+				t.Errorf("Extensions = %s", body)
+			}
+			if tt.signer != nil {
+				if err := codesign.VerifyHeader(certificatePEM, part.Header.Get("expo-signature"), body); err != nil {
+					t.Errorf("Extensions do not verify: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// deviceHashes returns a device inside and one outside a rollout of
+// percentage.
+func deviceHashes(t *testing.T, percentage int) (in, out string) {
+	t.Helper()
+	for i := 0; in == "" || out == ""; i++ {
+		hash := utils.CalculateSHA256([]byte(fmt.Sprintf("device-%d", i)))
+		if shouldReceiveUpdate(percentage, hash) {
+			in = hash
+		} else {
+			out = hash
+		}
+	}
+	return in, out
+}
+
+func TestManifestCacheEntryAction(t *testing.T) {
+	in, out := deviceHashes(t, 10)
+	update := manifestCacheEntry{UpdateID: "u1", RolloutPercentage: 100, Data: []byte("{}")}
+	partial := manifestCacheEntry{UpdateID: "u1", RolloutPercentage: 10, Data: []byte("{}")}
+	rollback := manifestCacheEntry{UpdateID: "r1", RolloutPercentage: 100, IsRollback: true, CommitTime: "2026-01-02T03:04:05.000Z"}
+	signed := manifestCacheEntry{UpdateID: "s1", RolloutPercentage: 100, Data: []byte("{}"), ExpiresAt: time.Now().Add(time.Hour).UnixMilli()}
+	expired := manifestCacheEntry{UpdateID: "s1", RolloutPercentage: 100, Data: []byte("{}"), ExpiresAt: time.Now().Add(-time.Hour).UnixMilli()}
+
+	tests := []struct {
+		name            string
+		entry           manifestCacheEntry
+		device          string
+		currentUpdateID string
+		embeddedID      string
+		protocolVersion int
+		want            manifestAction
+	}{
+		{"no update", manifestCacheEntry{}, in, "", "", 1, serveNoUpdate},
+		{"full rollout", update, out, "", "", 1, serveManifest},
+		{"already on update", update, in, "u1", "", 1, serveNoUpdate},
+		{"already on update, protocol 0", update, in, "u1", "", 0, serveManifest},
+		{"partial rollout, in bucket", partial, in, "", "", 1, serveManifest},
+		{"partial rollout, out of bucket", partial, out, "", "", 1, serveNoUpdate},
+		{"rollback from an update", rollback, in, "u1", "e1", 1, serveRollbackDirective},
+		{"rollback on embedded", rollback, in, "e1", "e1", 1, serveNoUpdate},
+		{"signed, urls valid", signed, in, "", "", 1, serveManifest},
+		{"signed, urls expired", expired, in, "", "", 1, serveNoUpdate},
+		{"signed, urls expired, protocol 0", expired, in, "u1", "", 0, serveNoUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.action(tt.device, tt.currentUpdateID, tt.embeddedID, tt.protocolVersion); got != tt.want {
+				t.Errorf("action() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// A cached partial rollout must still be decided per device: an in-bucket
+// device filling the cache must not hand the update to everyone else.
+func TestCachedManifestKeepsRolloutPolicy(t *testing.T) {
+	SetManifestCache(cache.NewMemory(), nil)
+	t.Cleanup(func() { SetManifestCache(cache.NewMemory(), nil) })
+
+	ctx := context.Background()
+	in, out := deviceHashes(t, 25)
+	key := manifestCacheKey("p1", "ios", "1.0.0", "production")
+
+	setCachedManifest(ctx, key, &manifestCacheEntry{UpdateID: "u1", RolloutPercentage: 25, Data: []byte(`{"id":"u1"}`), Signature: "sig"})
+	cached, ok := getCachedManifest(ctx, key)
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+	if cached.RolloutPercentage != 25 || string(cached.Data) != `{"id":"u1"}` || cached.Signature != "sig" {
+		t.Errorf("cache round trip lost fields: %+v", cached)
+	}
+	if got := cached.action(in, "", "", 1); got != serveManifest {
+		t.Errorf("in-bucket device: action() = %v, want serveManifest", got)
+	}
+	if got := cached.action(out, "", "", 1); got != serveNoUpdate {
+		t.Errorf("out-of-bucket device: action() = %v, want serveNoUpdate", got)
+	}
+
+	setCachedManifest(ctx, key, &manifestCacheEntry{UpdateID: "r1", IsRollback: true, CommitTime: "2026-01-02T03:04:05.000Z"})
+	cached, _ = getCachedManifest(ctx, key)
+	if !cached.IsRollback || cached.CommitTime != "2026-01-02T03:04:05.000Z" {
+		t.Errorf("cache round trip lost rollback policy: %+v", cached)
+	}
+	if got := cached.action(out, "u1", "e1", 1); got != serveRollbackDirective {
+		t.Errorf("cached rollback: action() = %v, want serveRollbackDirective", got)
+	}
+
+	InvalidateManifestCache("p1")
+	if _, ok := getCachedManifest(ctx, key); ok {
+		t.Error("expected InvalidateManifestCache to drop the entry")
+	}
+}
@@ -0,0 +1,564 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/auth"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type OrganizationResponse struct {
+	ID        string `json:"id"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// MemberRequest gives a user a role in an organization or project. A user
+// that does not exist yet is created when Password is set.
+type MemberRequest struct {
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type MemberResponse struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// ListOrganizations lists every organization for the root token, and the
+// user's own organizations otherwise.
+func ListOrganizations(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := []OrganizationResponse{}
+
+		if p, _ := auth.PrincipalFrom(r.Context()); p.Root {
+			orgs, err := queries.ListOrganizations(r.Context())
+			if err != nil {
+				jsonError(w, "Failed to fetch organizations", http.StatusInternalServerError)
+				return
+			}
+			for _, o := range orgs {
+				res = append(res, OrganizationResponse{
+					ID:        o.ID.String(),
+					Slug:      o.Slug,
+					Name:      o.Name,
+					CreatedAt: o.CreatedAt.Time.UnixMilli(),
+				})
+			}
+		} else {
+			orgs, err := queries.ListUserOrganizations(r.Context(), p.UserID)
+			if err != nil {
+				jsonError(w, "Failed to fetch organizations", http.StatusInternalServerError)
+				return
+			}
+			for _, o := range orgs {
+				res = append(res, OrganizationResponse{
+					ID:        o.ID.String(),
+					Slug:      o.Slug,
+					Name:      o.Name,
+					Role:      o.Role,
+					CreatedAt: o.CreatedAt.Time.UnixMilli(),
+				})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func CreateOrganization(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateOrganizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Slug == "" || req.Name == "" {
+			jsonError(w, "Slug and name are required", http.StatusBadRequest)
+			return
+		}
+
+		org, err := queries.CreateOrganization(r.Context(), database.CreateOrganizationParams{
+			Slug: req.Slug,
+			Name: req.Name,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				jsonError(w, "An organization with this slug already exists", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to create organization", http.StatusInternalServerError)
+			return
+		}
+
+		audit.SetTarget(r.Context(), "org_id", org.ID.String())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(OrganizationResponse{
+			ID:        org.ID.String(),
+			Slug:      org.Slug,
+			Name:      org.Name,
+			CreatedAt: org.CreatedAt.Time.UnixMilli(),
+		})
+	}
+}
+
+func ListOrganizationMembers(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, err := utils.ParseUUID(chi.URLParam(r, "org_id"))
+		if err != nil {
+			jsonError(w, "Invalid organization ID", http.StatusBadRequest)
+			return
+		}
+
+		members, err := queries.ListOrganizationMembers(r.Context(), orgId)
+		if err != nil {
+			jsonError(w, "Failed to fetch members", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]MemberResponse, len(members))
+		for i, m := range members {
+			res[i] = MemberResponse{
+				UserID:    m.UserID.String(),
+				Email:     m.Email,
+				Name:      m.Name,
+				Role:      m.Role,
+				CreatedAt: m.CreatedAt.Time.UnixMilli(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// SetOrganizationMember adds a user to an organization or changes their
+// role. Nobody can grant a role above their own.
+func SetOrganizationMember(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, err := utils.ParseUUID(chi.URLParam(r, "org_id"))
+		if err != nil {
+			jsonError(w, "Invalid organization ID", http.StatusBadRequest)
+			return
+		}
+
+		var req MemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		role, ok := auth.ParseRole(req.Role)
+		if !ok {
+			jsonError(w, "Role must be one of owner, admin, publisher, viewer", http.StatusBadRequest)
+			return
+		}
+
+		user, found, ok := findMemberUser(w, r, queries, req)
+		if !ok {
+			return
+		}
+
+		held, err := principalOrganizationRole(r.Context(), queries, orgId)
+		if err != nil {
+			jsonError(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		var existing auth.Role
+		if found {
+			existing, err = auth.OrganizationRole(r.Context(), queries, orgId, user.ID)
+			if err != nil {
+				jsonError(w, "Failed to fetch member", http.StatusInternalServerError)
+				return
+			}
+		}
+		if !checkGrant(w, held, role, existing) {
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		if !found {
+			if user, ok = createMemberUser(w, r, qtx, req); !ok {
+				return
+			}
+		}
+		err = qtx.UpsertOrganizationMember(r.Context(), database.UpsertOrganizationMemberParams{
+			OrganizationID: orgId,
+			UserID:         user.ID,
+			Role:           string(role),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				jsonError(w, "Organization not found", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to save member", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to save member", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Organization member set",
+			slog.String("organization_id", orgId.String()),
+			slog.String("user_id", user.ID.String()),
+			slog.String("role", string(role)),
+		)
+
+		audit.SetTarget(r.Context(), "user_id", user.ID.String())
+		audit.SetChange(r.Context(), memberAuditRole(existing), memberAuditRole(role))
+
+		writeMember(w, user, role)
+	}
+}
+
+func RemoveOrganizationMember(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgId, err := utils.ParseUUID(chi.URLParam(r, "org_id"))
+		if err != nil {
+			jsonError(w, "Invalid organization ID", http.StatusBadRequest)
+			return
+		}
+		userId, err := utils.ParseUUID(chi.URLParam(r, "user_id"))
+		if err != nil {
+			jsonError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		held, err := principalOrganizationRole(r.Context(), queries, orgId)
+		if err != nil {
+			jsonError(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		existing, err := auth.OrganizationRole(r.Context(), queries, orgId, userId)
+		if err != nil {
+			jsonError(w, "Failed to fetch member", http.StatusInternalServerError)
+			return
+		}
+		if !checkGrant(w, held, "", existing) {
+			return
+		}
+
+		removed, err := queries.RemoveOrganizationMember(r.Context(), database.RemoveOrganizationMemberParams{
+			OrganizationID: orgId,
+			UserID:         userId,
+		})
+		if err != nil {
+			jsonError(w, "Failed to remove member", http.StatusInternalServerError)
+			return
+		}
+		if removed == 0 {
+			jsonError(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		audit.SetChange(r.Context(), memberAuditRole(existing), nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListProjectMembers(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		members, err := queries.ListProjectMembers(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch members", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]MemberResponse, len(members))
+		for i, m := range members {
+			res[i] = MemberResponse{
+				UserID:    m.UserID.String(),
+				Email:     m.Email,
+				Name:      m.Name,
+				Role:      m.Role,
+				CreatedAt: m.CreatedAt.Time.UnixMilli(),
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// SetProjectMember grants a user a role on one project. The user's
+// organization role still applies; the stronger of the two wins.
+func SetProjectMember(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req MemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		role, ok := auth.ParseRole(req.Role)
+		if !ok {
+			jsonError(w, "Role must be one of owner, admin, publisher, viewer", http.StatusBadRequest)
+			return
+		}
+
+		user, found, ok := findMemberUser(w, r, queries, req)
+		if !ok {
+			return
+		}
+
+		// A user that does not exist yet has no role to compare against;
+		// the zero ID matches no membership.
+		held, existing, err := projectGrantRoles(r.Context(), queries, projectId, user.ID)
+		if err != nil {
+			jsonError(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !checkGrant(w, held, role, existing) {
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		if !found {
+			if user, ok = createMemberUser(w, r, qtx, req); !ok {
+				return
+			}
+		}
+		err = qtx.UpsertProjectMember(r.Context(), database.UpsertProjectMemberParams{
+			ProjectID: projectId,
+			UserID:    user.ID,
+			Role:      string(role),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				jsonError(w, "Project not found", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to save member", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to save member", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Project member set",
+			slog.String("project_id", projectId.String()),
+			slog.String("user_id", user.ID.String()),
+			slog.String("role", string(role)),
+		)
+
+		audit.SetTarget(r.Context(), "user_id", user.ID.String())
+		audit.SetChange(r.Context(), memberAuditRole(existing), memberAuditRole(role))
+
+		writeMember(w, user, role)
+	}
+}
+
+func RemoveProjectMember(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		userId, err := utils.ParseUUID(chi.URLParam(r, "user_id"))
+		if err != nil {
+			jsonError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		held, existing, err := projectGrantRoles(r.Context(), queries, projectId, userId)
+		if err != nil {
+			jsonError(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !checkGrant(w, held, "", existing) {
+			return
+		}
+
+		removed, err := queries.RemoveProjectMember(r.Context(), database.RemoveProjectMemberParams{
+			ProjectID: projectId,
+			UserID:    userId,
+		})
+		if err != nil {
+			jsonError(w, "Failed to remove member", http.StatusInternalServerError)
+			return
+		}
+		if removed == 0 {
+			jsonError(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		audit.SetChange(r.Context(), memberAuditRole(existing), nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// findMemberUser finds the user a member request names. found is false
+// when the account does not exist yet but the request carries a password to
+// create it with; createMemberUser does that once the grant is checked. It
+// writes the error response when ok is false.
+func findMemberUser(w http.ResponseWriter, r *http.Request, queries *database.Queries, req MemberRequest) (user database.User, found, ok bool) {
+	email := normalizeEmail(req.Email)
+	if !strings.Contains(email, "@") {
+		jsonError(w, "A valid email is required", http.StatusBadRequest)
+		return database.User{}, false, false
+	}
+
+	user, err := queries.GetUserByEmail(r.Context(), email)
+	if err == nil {
+		return user, true, true
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		jsonError(w, "Failed to fetch user", http.StatusInternalServerError)
+		return database.User{}, false, false
+	}
+	if req.Password == "" {
+		jsonError(w, "User not found; include a password to create the account", http.StatusNotFound)
+		return database.User{}, false, false
+	}
+	return database.User{}, false, true
+}
+
+// createMemberUser creates the account a member request names. It writes
+// the error response when ok is false.
+func createMemberUser(w http.ResponseWriter, r *http.Request, queries *database.Queries, req MemberRequest) (database.User, bool) {
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooShort) {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return database.User{}, false
+		}
+		jsonError(w, "Failed to create user", http.StatusInternalServerError)
+		return database.User{}, false
+	}
+	user, err := queries.CreateUser(r.Context(), database.CreateUserParams{
+		Email:        normalizeEmail(req.Email),
+		Name:         req.Name,
+		PasswordHash: hash,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			jsonError(w, "A user with this email already exists", http.StatusConflict)
+			return database.User{}, false
+		}
+		jsonError(w, "Failed to create user", http.StatusInternalServerError)
+		return database.User{}, false
+	}
+
+	slog.InfoContext(r.Context(), "User created", slog.String("user_id", user.ID.String()))
+	return user, true
+}
+
+// principalOrganizationRole returns the role the request's principal holds
+// in an organization. The root token counts as owner everywhere.
+func principalOrganizationRole(ctx context.Context, queries *database.Queries, orgId pgtype.UUID) (auth.Role, error) {
+	p, _ := auth.PrincipalFrom(ctx)
+	if p.Root {
+		return auth.RoleOwner, nil
+	}
+	return auth.OrganizationRole(ctx, queries, orgId, p.UserID)
+}
+
+// projectGrantRoles returns the role the request's principal holds on a
+// project, and the role granted to userId on it directly.
+func projectGrantRoles(ctx context.Context, queries *database.Queries, projectId, userId pgtype.UUID) (auth.Role, auth.Role, error) {
+	held := auth.RoleOwner
+	if p, _ := auth.PrincipalFrom(ctx); !p.Root {
+		var err error
+		held, err = auth.ProjectRole(ctx, queries, projectId, p.UserID)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	roles, err := queries.GetProjectRoles(ctx, database.GetProjectRolesParams{
+		UserID:    userId,
+		ProjectID: projectId,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", "", err
+	}
+	return held, auth.Highest(roles.ProjectRole), nil
+}
+
+// checkGrant stops a member from granting, or changing a member who holds,
+// a role above their own. It writes the error response when not allowed.
+func checkGrant(w http.ResponseWriter, held, role, existing auth.Role) bool {
+	if role != "" && !held.Allows(role) {
+		jsonError(w, "Cannot grant a role above your own", http.StatusForbidden)
+		return false
+	}
+	if existing != "" && !held.Allows(existing) {
+		jsonError(w, "Cannot change a member whose role is above your own", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// memberAuditRole is a membership as recorded in the audit log, nil when
+// there is none.
+func memberAuditRole(role auth.Role) any {
+	if role == "" {
+		return nil
+	}
+	return map[string]string{"role": string(role)}
+}
+
+func writeMember(w http.ResponseWriter, user database.User, role auth.Role) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MemberResponse{
+		UserID: user.ID.String(),
+		Email:  user.Email,
+		Name:   user.Name,
+		Role:   string(role),
+	})
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/bsdiff"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+const (
+	// BundlePatchFormat is the A-IM value clients send to get patches.
+	BundlePatchFormat = "bsdiff"
+	// bundlePatchBuildTimeout bounds a build. A pending patch older than
+	// this is rebuilt by the next server that needs it.
+	bundlePatchBuildTimeout = 10 * time.Minute
+)
+
+// errPatchNotWorthwhile marks patches that will never be served, because a
+// bundle is too large to diff or the patch saves too little.
+var errPatchNotWorthwhile = errors.New("patch not worthwhile")
+
+// BundlePatcher builds bsdiff patches between the launch assets of a project
+// and finds the one that takes a device from its current update to a new
+// one.
+type BundlePatcher struct {
+	queries   *database.Queries
+	providers *storage.Registry
+	maxSize   int64
+	// builds allows one build at a time per instance: diffing holds both
+	// bundles and a suffix array of the old one in memory.
+	builds chan struct{}
+}
+
+// NewBundlePatcher returns a patcher that diffs launch assets of up to
+// maxSize bytes.
+func NewBundlePatcher(queries *database.Queries, providers *storage.Registry, maxSize int64) *BundlePatcher {
+	return &BundlePatcher{
+		queries:   queries,
+		providers: providers,
+		maxSize:   maxSize,
+		builds:    make(chan struct{}, 1),
+	}
+}
+
+// bundlePatch is how a patch is described to devices in the extensions part
+// of the manifest response.
+type bundlePatch struct {
+	Format      string `json:"format"`
+	Compression string `json:"compression"`
+	// BaseHash is the launch asset the patch applies to.
+	BaseHash string `json:"baseHash"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	URL      string `json:"url"`
+}
+
+// acceptsBundlePatch reports whether the client listed bsdiff in A-IM.
+func acceptsBundlePatch(r *http.Request) bool {
+	for _, value := range strings.Split(r.Header.Get("A-IM"), ",") {
+		name, _, _ := strings.Cut(value, ";")
+		if strings.EqualFold(strings.TrimSpace(name), BundlePatchFormat) {
+			return true
+		}
+	}
+	return false
+}
+
+func bundlePatchCacheKey(projectID, currentUpdateID, targetHash string) string {
+	return projectID + ":patch:" + currentUpdateID + ":" + targetHash
+}
+
+// find returns the patch from the launch asset of currentUpdateID to
+// targetHash, or nil. Missing patches are built in the background, so the
+// device gets one on a later check.
+func (p *BundlePatcher) find(ctx context.Context, projectID pgtype.UUID, currentUpdateID, targetUpdateID, targetHash string) *bundlePatch {
+	cacheKey := bundlePatchCacheKey(projectID.String(), currentUpdateID, targetHash)
+	if data, ok, err := manifestCache.Get(ctx, cacheKey); err == nil && ok {
+		var patch bundlePatch
+		if json.Unmarshal(data, &patch) == nil && patch.Format != "" {
+			return &patch
+		}
+		return nil
+	}
+
+	// remember caches the answer; an empty patch means there is none.
+	remember := func(patch bundlePatch) *bundlePatch {
+		encoded, _ := json.Marshal(patch)
+		if err := manifestCache.Set(ctx, cacheKey, encoded, manifestCacheTTL); err != nil {
+			slog.WarnContext(ctx, "Manifest cache write failed", slog.Any("error", err))
+		}
+		if patch.Format == "" {
+			return nil
+		}
+		return &patch
+	}
+
+	currentID, err := utils.ParseUUID(currentUpdateID)
+	if err != nil {
+		return remember(bundlePatch{})
+	}
+	base, err := p.queries.GetUpdateLaunchAsset(ctx, database.GetUpdateLaunchAssetParams{
+		ProjectID: projectID,
+		UpdateID:  currentID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && base.Hash == targetHash) {
+		return remember(bundlePatch{})
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to look up launch asset", slog.Any("error", err))
+		return nil
+	}
+
+	stored, err := p.queries.GetBundlePatch(ctx, database.GetBundlePatchParams{
+		ProjectID:  projectID,
+		BaseHash:   base.Hash,
+		TargetHash: targetHash,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.WarnContext(ctx, "Failed to look up bundle patch", slog.Any("error", err))
+		return nil
+	}
+
+	switch {
+	case err == nil && stored.Status == "ready":
+		return remember(bundlePatch{
+			Format:      BundlePatchFormat,
+			Compression: "gzip",
+			BaseHash:    stored.BaseHash,
+			Hash:        stored.Hash,
+			Size:        stored.Size,
+			URL:         p.patchURL(ctx, stored),
+		})
+	case err == nil && stored.Status == "failed":
+		return remember(bundlePatch{})
+	case err == nil && time.Since(stored.CreatedAt.Time) < bundlePatchBuildTimeout:
+		// Another build is running.
+		return nil
+	}
+
+	targetID, _ := utils.ParseUUID(targetUpdateID)
+	go p.build(projectID, base, targetID)
+	return nil
+}
+
+// patchURL is where devices download a patch from. Behind the asset proxy
+// storage may be private, so patches on providers that presign downloads
+// get a URL that lasts as long as the manifest's asset URLs. Local patches
+// get a signed URL for the same time.
+func (p *BundlePatcher) patchURL(ctx context.Context, patch database.BundlePatch) string {
+	if assetURLs == nil {
+		return patch.Url
+	}
+	provider, err := p.providers.Get(ctx, patch.StorageProvider)
+	if err != nil {
+		return patch.Url
+	}
+	if _, ok := provider.(*storage.LocalProvider); ok {
+		return assetURLs.localURL(patch.Url, patch.Key, time.Now().Add(assetURLs.ttl))
+	}
+	presigner, ok := provider.(storage.DownloadPresigner)
+	if !ok {
+		return patch.Url
+	}
+	url, err := presigner.PresignDownload(ctx, patch.Key, assetURLs.ttl)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to presign bundle patch download", slog.String("key", patch.Key), slog.Any("error", err))
+		return patch.Url
+	}
+	return url
+}
+
+// build makes the patch from base to the launch asset of target, unless
+// this instance is already building one or another server claimed it.
+func (p *BundlePatcher) build(projectID pgtype.UUID, base database.Asset, targetUpdateID pgtype.UUID) {
+	select {
+	case p.builds <- struct{}{}:
+		defer func() { <-p.builds }()
+	default:
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bundlePatchBuildTimeout)
+	defer cancel()
+
+	target, err := p.queries.GetUpdateLaunchAsset(ctx, database.GetUpdateLaunchAssetParams{
+		ProjectID: projectID,
+		UpdateID:  targetUpdateID,
+	})
+	if err != nil {
+		slog.Warn("Failed to look up launch asset", slog.String("update_id", targetUpdateID.String()), slog.Any("error", err))
+		return
+	}
+
+	_, err = p.queries.ClaimBundlePatch(ctx, database.ClaimBundlePatchParams{
+		ProjectID:   projectID,
+		BaseHash:    base.Hash,
+		TargetHash:  target.Hash,
+		StaleBefore: pgtype.Timestamptz{Time: time.Now().Add(-bundlePatchBuildTimeout), Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to claim bundle patch", slog.Any("error", err))
+		return
+	}
+
+	logger := slog.With(
+		slog.String("project_id", projectID.String()),
+		slog.String("base_hash", base.Hash),
+		slog.String("target_hash", target.Hash),
+	)
+	started := time.Now()
+	ready, err := p.buildPatch(ctx, projectID, base, target)
+	if err != nil {
+		// Failed builds are retried by a later device; patches that are
+		// not worth serving are remembered so nobody tries again.
+		if errors.Is(err, errPatchNotWorthwhile) {
+			logger.Info("Skipping bundle patch", slog.Any("reason", err))
+			err = p.queries.SetBundlePatchFailed(ctx, database.SetBundlePatchFailedParams{
+				ProjectID:  projectID,
+				BaseHash:   base.Hash,
+				TargetHash: target.Hash,
+			})
+		} else {
+			logger.Error("Failed to build bundle patch", slog.Any("error", err))
+			err = p.queries.DeleteBundlePatch(ctx, database.DeleteBundlePatchParams{
+				ProjectID:  projectID,
+				BaseHash:   base.Hash,
+				TargetHash: target.Hash,
+			})
+		}
+		if err != nil {
+			logger.Error("Failed to record bundle patch failure", slog.Any("error", err))
+		}
+		return
+	}
+
+	if err := p.queries.SetBundlePatchReady(ctx, ready); err != nil {
+		logger.Error("Failed to record bundle patch", slog.Any("error", err))
+		return
+	}
+	logger.Info("Built bundle patch",
+		slog.Int64("size", ready.Size),
+		slog.Int64("target_size", target.Size),
+		slog.Duration("duration", time.Since(started)),
+	)
+}
+
+// buildPatch diffs the two launch assets and stores the gzipped patch.
+func (p *BundlePatcher) buildPatch(ctx context.Context, projectID pgtype.UUID, base, target database.Asset) (database.SetBundlePatchReadyParams, error) {
+	var ready database.SetBundlePatchReadyParams
+
+	old, err := p.readAsset(ctx, base)
+	if err != nil {
+		return ready, err
+	}
+	new, err := p.readAsset(ctx, target)
+	if err != nil {
+		return ready, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := bsdiff.Diff(old, new, zw); err != nil {
+		return ready, err
+	}
+	if err := zw.Close(); err != nil {
+		return ready, err
+	}
+	if buf.Len() > len(new)/2 {
+		return ready, fmt.Errorf("%w: patch is %d bytes for a %d byte bundle", errPatchNotWorthwhile, buf.Len(), len(new))
+	}
+
+	project, err := p.queries.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return ready, err
+	}
+	provider, err := publishProvider(ctx, p.queries, p.providers, projectID)
+	if err != nil {
+		return ready, fmt.Errorf("failed to load storage provider: %w", err)
+	}
+
+	key := project.Slug + "/patches/" + base.Hash + "/" + target.Hash
+	url, err := provider.Upload(ctx, key, bytes.NewReader(buf.Bytes()), "application/octet-stream", int64(buf.Len()))
+	if err != nil {
+		return ready, fmt.Errorf("failed to store patch: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return database.SetBundlePatchReadyParams{
+		ProjectID:       projectID,
+		BaseHash:        base.Hash,
+		TargetHash:      target.Hash,
+		StorageProvider: provider.Name(),
+		Key:             key,
+		Url:             url,
+		Hash:            base64.RawURLEncoding.EncodeToString(sum[:]),
+		Size:            int64(buf.Len()),
+	}, nil
+}
+
+// readAsset loads a launch asset from storage and checks it against its
+// hash.
+func (p *BundlePatcher) readAsset(ctx context.Context, asset database.Asset) ([]byte, error) {
+	if asset.Size > p.maxSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes", errPatchNotWorthwhile, asset.FileName, asset.Size)
+	}
+
+	provider, err := p.providers.Get(ctx, asset.StorageProvider)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Read(ctx, provider, asset.Key, asset.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, p.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.maxSize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", errPatchNotWorthwhile, asset.FileName, p.maxSize)
+	}
+	sum := sha256.Sum256(data)
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != asset.Hash {
+		return nil, fmt.Errorf("%s does not match its hash", asset.Key)
+	}
+	return data, nil
+}
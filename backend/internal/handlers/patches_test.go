@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptsBundlePatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"bsdiff", true},
+		{"gzip, BSDIFF;q=0.5", true},
+		{"vcdiff", false},
+		{"bsdiff43", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/manifest/x", nil)
+		req.Header.Set("A-IM", tt.header)
+		if got := acceptsBundlePatch(req); got != tt.want {
+			t.Errorf("acceptsBundlePatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
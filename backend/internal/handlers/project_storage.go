@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/netguard"
+	"github.com/vknow360/otaship/backend/internal/projectstorage"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+// storagePingTimeout bounds the check of a project's storage credentials.
+const storagePingTimeout = 10 * time.Second
+
+type ProjectStorageResponse struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	// ProviderName is the storage provider blobs in this storage are
+	// recorded with, as storage migrations take it.
+	ProviderName string `json:"provider_name"`
+	// Config has the settings without secrets.
+	Config    projectstorage.Config `json:"config"`
+	CheckedAt int64                 `json:"checked_at"`
+	CreatedAt int64                 `json:"created_at"`
+	UpdatedAt int64                 `json:"updated_at"`
+}
+
+func toProjectStorageResponse(row database.ProjectStorage, config projectstorage.Config) ProjectStorageResponse {
+	return ProjectStorageResponse{
+		ID:           row.ID.String(),
+		ProjectID:    row.ProjectID.String(),
+		ProviderName: projectstorage.ProviderName(row.ID),
+		Config:       config.Redacted(),
+		CheckedAt:    row.CheckedAt.Time.UnixMilli(),
+		CreatedAt:    row.CreatedAt.Time.UnixMilli(),
+		UpdatedAt:    row.UpdatedAt.Time.UnixMilli(),
+	}
+}
+
+// projectStorageLocation is what the audit log records of a project's
+// storage; never its credentials.
+func projectStorageLocation(row database.ProjectStorage) map[string]string {
+	return map[string]string{"provider": row.Provider, "bucket": row.Bucket, "prefix": row.Prefix}
+}
+
+// GetProjectStorage returns the storage a project keeps its blobs in, when
+// it has its own.
+func GetProjectStorage(queries *database.Queries, store *projectstorage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		row, config, ok := activeProjectStorage(w, r, queries, store)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toProjectStorageResponse(row, config))
+	}
+}
+
+// SetProjectStorage points a project's new blobs at its own bucket. The
+// settings are pinged before they are stored. New credentials for the
+// current bucket and prefix replace the old ones; another bucket or prefix
+// becomes a new storage, and blobs already stored stay where they are
+// until a storage migration moves them.
+func SetProjectStorage(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, store *projectstorage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !projectStorageEnabled(w, store) {
+			return
+		}
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var config projectstorage.Config
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		config.Normalize()
+		if err := config.Validate(); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		provider, err := config.NewProvider("")
+		if err != nil {
+			jsonError(w, "Invalid storage settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := pingStorage(r.Context(), provider); err != nil {
+			jsonError(w, storageCheckError(r.Context(), projectId, err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		sealed, err := store.Seal(projectId, config)
+		if err != nil {
+			jsonError(w, "Failed to encrypt storage settings", http.StatusInternalServerError)
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		if _, err := qtx.GetProjectByID(r.Context(), projectId); err != nil {
+			jsonError(w, "Project not found", http.StatusNotFound)
+			return
+		}
+
+		current, err := qtx.GetActiveProjectStorage(r.Context(), projectId)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Failed to fetch project storage", http.StatusInternalServerError)
+			return
+		}
+		var before any
+		sameLocation := false
+		if err == nil {
+			before = projectStorageLocation(current)
+			currentConfig, err := store.Open(current)
+			sameLocation = err == nil && currentConfig.SameLocation(config)
+		}
+
+		var row database.ProjectStorage
+		if sameLocation {
+			row, err = qtx.UpdateProjectStorageConfig(r.Context(), database.UpdateProjectStorageConfigParams{
+				ID:     current.ID,
+				Config: sealed,
+			})
+		} else {
+			// The replaced storage stays for the blobs stored with it.
+			_, err = qtx.DeactivateProjectStorage(r.Context(), projectId)
+			if err == nil {
+				row, err = qtx.CreateProjectStorage(r.Context(), database.CreateProjectStorageParams{
+					ProjectID: projectId,
+					Provider:  config.Provider,
+					Bucket:    config.Bucket(),
+					Prefix:    config.Prefix(),
+					Config:    sealed,
+				})
+			}
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to save project storage", slog.Any("error", err))
+			jsonError(w, "Failed to save project storage", http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to save project storage", http.StatusInternalServerError)
+			return
+		}
+
+		providers.Forget(projectstorage.ProviderName(row.ID))
+		go InvalidateManifestCache(projectId.String())
+
+		audit.SetTarget(r.Context(), "project_storage_id", row.ID.String())
+		audit.SetChange(r.Context(), before, projectStorageLocation(row))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toProjectStorageResponse(row, config))
+	}
+}
+
+// DeleteProjectStorage returns a project to the server's storage for new
+// blobs. Blobs already in its own storage are still served from there.
+func DeleteProjectStorage(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		current, err := queries.GetActiveProjectStorage(r.Context(), projectId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Project uses the server's storage", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			jsonError(w, "Failed to fetch project storage", http.StatusInternalServerError)
+			return
+		}
+		if _, err := queries.DeactivateProjectStorage(r.Context(), projectId); err != nil {
+			jsonError(w, "Failed to remove project storage", http.StatusInternalServerError)
+			return
+		}
+		go InvalidateManifestCache(projectId.String())
+
+		audit.SetTarget(r.Context(), "project_storage_id", current.ID.String())
+		audit.SetChange(r.Context(), projectStorageLocation(current), nil)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CheckProjectStorage pings a project's storage with its stored
+// credentials.
+func CheckProjectStorage(queries *database.Queries, providers *storage.Registry, store *projectstorage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		row, config, ok := activeProjectStorage(w, r, queries, store)
+		if !ok {
+			return
+		}
+
+		name := projectstorage.ProviderName(row.ID)
+		providers.Forget(name)
+		provider, err := providers.Get(r.Context(), name)
+		if err == nil {
+			err = pingStorage(r.Context(), provider)
+		}
+		if err != nil {
+			jsonError(w, storageCheckError(r.Context(), row.ProjectID, err), http.StatusBadGateway)
+			return
+		}
+
+		if err := queries.MarkProjectStorageChecked(r.Context(), row.ID); err != nil {
+			slog.WarnContext(r.Context(), "Failed to record project storage check", slog.Any("error", err))
+		}
+		row.CheckedAt.Time = time.Now()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toProjectStorageResponse(row, config))
+	}
+}
+
+// storageCheckError logs why a storage check failed and returns the message
+// for the client. The endpoint is the project's choice, so transport errors
+// are not echoed: they would tell callers what the server can reach.
+func storageCheckError(ctx context.Context, projectId pgtype.UUID, err error) string {
+	slog.WarnContext(ctx, "Project storage check failed",
+		slog.String("project_id", projectId.String()),
+		slog.Any("error", err),
+	)
+	if errors.Is(err, netguard.ErrBlockedAddress) {
+		return "Storage check failed: s3.endpoint does not resolve to a public address"
+	}
+	return "Storage check failed; check the bucket, region, endpoint and credentials"
+}
+
+func pingStorage(ctx context.Context, provider storage.Provider) error {
+	ctx, cancel := context.WithTimeout(ctx, storagePingTimeout)
+	defer cancel()
+	return provider.Ping(ctx)
+}
+
+func projectStorageEnabled(w http.ResponseWriter, store *projectstorage.Store) bool {
+	if store == nil {
+		jsonError(w, "Project storage is disabled; set STORAGE_CREDENTIALS_KEY on the server", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+func activeProjectStorage(w http.ResponseWriter, r *http.Request, queries *database.Queries, store *projectstorage.Store) (database.ProjectStorage, projectstorage.Config, bool) {
+	if !projectStorageEnabled(w, store) {
+		return database.ProjectStorage{}, projectstorage.Config{}, false
+	}
+	projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+	if err != nil {
+		jsonError(w, "Invalid project ID", http.StatusBadRequest)
+		return database.ProjectStorage{}, projectstorage.Config{}, false
+	}
+	row, err := queries.GetActiveProjectStorage(r.Context(), projectId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		jsonError(w, "Project uses the server's storage", http.StatusNotFound)
+		return row, projectstorage.Config{}, false
+	}
+	if err != nil {
+		jsonError(w, "Failed to fetch project storage", http.StatusInternalServerError)
+		return row, projectstorage.Config{}, false
+	}
+	config, err := store.Open(row)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to open project storage settings", slog.Any("error", err))
+		jsonError(w, "Failed to read project storage settings", http.StatusInternalServerError)
+		return row, config, false
+	}
+	return row, config, true
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/netguard"
+)
+
+func TestStorageCheckErrorHidesTransportErrors(t *testing.T) {
+	transport := errors.New(`Get "http://10.0.0.5:9000/bucket": dial tcp 10.0.0.5:9000: connect: connection refused`)
+	blocked := fmt.Errorf("dial tcp: %w: 10.0.0.5", netguard.ErrBlockedAddress)
+
+	for _, err := range []error{transport, blocked} {
+		msg := storageCheckError(context.Background(), pgtype.UUID{}, err)
+		if strings.Contains(msg, "10.0.0.5") || strings.Contains(msg, "refused") {
+			t.Errorf("storageCheckError(%v) = %q, leaks the transport error", err, msg)
+		}
+	}
+}
@@ -5,10 +5,16 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/auth"
 	"github.com/vknow360/otaship/backend/internal/database"
 	"github.com/vknow360/otaship/backend/internal/utils"
 )
@@ -17,34 +23,60 @@ type CreateProjectRequest struct {
 	Slug        string `json:"slug"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// OrganizationID is required for users, who need the admin role in
+	// the organization. Only the root token can create projects outside
+	// any organization.
+	OrganizationID string `json:"organization_id"`
 }
 
 type UpdateProjectRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// MaxUploadSize is the largest bundle in bytes the project may upload.
+	// It is left unchanged when omitted; 0 restores the server default.
+	MaxUploadSize *int64 `json:"max_upload_size"`
 }
 
 type ProjectResponse struct {
-	ID          string `json:"id"`
-	Slug        string `json:"slug"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CreatedAt   int64  `json:"created_at"`
+	ID             string `json:"id"`
+	Slug           string `json:"slug"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	OrganizationID string `json:"organization_id,omitempty"`
+	// MaxUploadSize is only set when the project overrides the server's
+	// upload limit.
+	MaxUploadSize int64 `json:"max_upload_size,omitempty"`
+	CreatedAt     int64 `json:"created_at"`
 }
 
 func toProjectResponse(p database.Project) ProjectResponse {
-	return ProjectResponse{
+	res := ProjectResponse{
 		ID:          p.ID.String(),
 		Slug:        p.Slug,
 		Name:        p.Name,
 		Description: p.Description,
 		CreatedAt:   p.CreatedAt.Time.UnixMilli(),
 	}
+	if p.OrganizationID.Valid {
+		res.OrganizationID = p.OrganizationID.String()
+	}
+	if p.MaxUploadSize.Valid {
+		res.MaxUploadSize = p.MaxUploadSize.Int64
+	}
+	return res
 }
 
+// GetProjects lists every project for the root token, and the projects a
+// user can access otherwise.
 func GetProjects(queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		projects, err := queries.ListProjects(r.Context())
+		var projects []database.Project
+		var err error
+		if p, _ := auth.PrincipalFrom(r.Context()); p.Root {
+			projects, err = queries.ListProjects(r.Context())
+		} else {
+			projects, err = queries.ListProjectsForUser(r.Context(), p.UserID)
+		}
 		if err != nil {
 			jsonError(w, "Failed to fetch projects", http.StatusInternalServerError)
 			return
@@ -77,16 +109,8 @@ func GetProjectByID(queries *database.Queries) http.HandlerFunc {
 			return
 		}
 
-		res := ProjectResponse{
-			ID:          project.ID.String(),
-			Slug:        project.Slug,
-			Name:        project.Name,
-			Description: project.Description,
-			CreatedAt:   project.CreatedAt.Time.UnixMilli(),
-		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(res)
+		json.NewEncoder(w).Encode(toProjectResponse(project))
 	}
 }
 
@@ -103,29 +127,54 @@ func CreateProject(queries *database.Queries) http.HandlerFunc {
 			return
 		}
 
+		var organizationId pgtype.UUID
+		if req.OrganizationID != "" {
+			organizationId, err = utils.ParseUUID(req.OrganizationID)
+			if err != nil {
+				jsonError(w, "Invalid organization ID", http.StatusBadRequest)
+				return
+			}
+		}
+		if p, _ := auth.PrincipalFrom(r.Context()); !p.Root {
+			if !organizationId.Valid {
+				jsonError(w, "organization_id is required", http.StatusBadRequest)
+				return
+			}
+			role, err := auth.OrganizationRole(r.Context(), queries, organizationId, p.UserID)
+			if err != nil {
+				jsonError(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+			if !role.Allows(auth.RoleAdmin) {
+				jsonError(w, "Creating projects requires the admin role in the organization", http.StatusForbidden)
+				return
+			}
+		}
+
 		project, err := queries.CreateProject(r.Context(), database.CreateProjectParams{
-			Slug:        req.Slug,
-			Name:        req.Name,
-			Description: req.Description,
+			Slug:           req.Slug,
+			Name:           req.Name,
+			Description:    req.Description,
+			OrganizationID: organizationId,
 		})
 		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				jsonError(w, "Organization not found", http.StatusNotFound)
+				return
+			}
 			jsonError(w, "Failed to create project", http.StatusInternalServerError)
 			return
 		}
+		audit.SetProject(r.Context(), project.ID)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(ProjectResponse{
-			ID:          project.ID.String(),
-			Slug:        project.Slug,
-			Name:        project.Name,
-			Description: project.Description,
-			CreatedAt:   project.CreatedAt.Time.UnixMilli(),
-		})
+		json.NewEncoder(w).Encode(toProjectResponse(project))
 	}
 }
 
-func DeleteProject(queries *database.Queries) http.HandlerFunc {
+func DeleteProject(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "project_id")
 		projectId, err := utils.ParseUUID(id)
@@ -134,12 +183,36 @@ func DeleteProject(queries *database.Queries) http.HandlerFunc {
 			return
 		}
 
-		_, err = queries.DeleteProject(r.Context(), projectId)
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		qtx := queries.WithTx(tx)
+
+		// Assets cascade with the project, so queue their objects first.
+		err = qtx.QueueProjectAssetsForGC(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to delete project", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = qtx.DeleteProject(r.Context(), projectId)
 		if err != nil {
 			jsonError(w, "Failed to delete project", http.StatusInternalServerError)
 			return
 		}
 
+		err = tx.Commit(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		InvalidateManifestCache(id)
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -159,27 +232,44 @@ func UpdateProject(queries *database.Queries) http.HandlerFunc {
 			jsonError(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		if req.MaxUploadSize != nil && *req.MaxUploadSize < 0 {
+			jsonError(w, "max_upload_size must not be negative", http.StatusBadRequest)
+			return
+		}
 
-		project, err := queries.UpdateProject(r.Context(), database.UpdateProjectParams{
-			ID:          projectId,
-			Name:        req.Name,
-			Description: req.Description,
-		})
+		before, err := queries.GetProjectByID(r.Context(), projectId)
 		if err != nil {
-			jsonError(w, "Failed to update project", http.StatusInternalServerError)
+			jsonError(w, "Project not found", http.StatusNotFound)
 			return
 		}
 
-		res := ProjectResponse{
-			ID:          project.ID.String(),
-			Slug:        project.Slug,
-			Name:        project.Name,
-			Description: project.Description,
-			CreatedAt:   project.CreatedAt.Time.UnixMilli(),
+		// A body with only max_upload_size leaves the name and description.
+		project := before
+		if req.Name != "" || req.MaxUploadSize == nil {
+			project, err = queries.UpdateProject(r.Context(), database.UpdateProjectParams{
+				ID:          projectId,
+				Name:        req.Name,
+				Description: req.Description,
+			})
+			if err != nil {
+				jsonError(w, "Failed to update project", http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.MaxUploadSize != nil {
+			project, err = queries.SetProjectMaxUploadSize(r.Context(), database.SetProjectMaxUploadSizeParams{
+				MaxUploadSize: pgtype.Int8{Int64: *req.MaxUploadSize, Valid: *req.MaxUploadSize > 0},
+				ID:            projectId,
+			})
+			if err != nil {
+				jsonError(w, "Failed to update project", http.StatusInternalServerError)
+				return
+			}
 		}
+		audit.SetChange(r.Context(), toProjectResponse(before), toProjectResponse(project))
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(res)
+		json.NewEncoder(w).Encode(toProjectResponse(project))
 	}
 }
 
@@ -205,6 +295,10 @@ func ValidateAPIKey(queries *database.Queries) http.HandlerFunc {
 			jsonError(w, "Invalid API key", http.StatusUnauthorized)
 			return
 		}
+		if key.ExpiresAt.Valid && !key.ExpiresAt.Time.After(time.Now()) {
+			jsonError(w, "API key expired", http.StatusUnauthorized)
+			return
+		}
 
 		project, err := queries.GetProjectByID(r.Context(), key.ProjectID)
 		if err != nil {
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+type PromoteUpdateRequest struct {
+	Channel string `json:"channel"`
+	// RolloutPercentage defaults to 100.
+	RolloutPercentage *int32 `json:"rollout_percentage"`
+}
+
+// PromoteUpdate republishes an update on the branch behind another channel.
+// The new update points at the same stored assets, so nothing is exported
+// or uploaded again.
+func PromoteUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updateIdStr := chi.URLParam(r, "update_id")
+		updateId, err := utils.ParseUUID(updateIdStr)
+		if err != nil {
+			jsonError(w, "Invalid update ID", http.StatusBadRequest)
+			return
+		}
+
+		var req PromoteUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !channelNameRegex.MatchString(req.Channel) {
+			jsonError(w, "Invalid target channel", http.StatusBadRequest)
+			return
+		}
+		rollout := int32(100)
+		if req.RolloutPercentage != nil {
+			rollout = *req.RolloutPercentage
+		}
+		if rollout < 0 || rollout > 100 {
+			jsonError(w, "Rollout percentage must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+
+		original, err := queries.GetUpdateByID(r.Context(), updateId)
+		if err != nil {
+			jsonError(w, "Update not found", http.StatusNotFound)
+			return
+		}
+
+		ctxProjectId := utils.GetProjectId(r.Context())
+		if ctxProjectId.Valid && original.ProjectID != ctxProjectId {
+			jsonError(w, "Update does not belong to this project", http.StatusForbidden)
+			return
+		}
+		if !keyAllowsChannel(w, r, req.Channel) {
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		qtx := queries.WithTx(tx)
+
+		branch, err := resolvePublishBranch(r.Context(), qtx, original.ProjectID, req.Channel)
+		if err != nil {
+			jsonError(w, "Failed to resolve channel", http.StatusInternalServerError)
+			return
+		}
+		if !keyAllowsTargets(w, r, branch) {
+			return
+		}
+		if branch == original.Channel {
+			jsonError(w, fmt.Sprintf("Update is already on branch %s, which channel %s serves", branch, req.Channel), http.StatusConflict)
+			return
+		}
+
+		err = qtx.DeactivateUpdates(r.Context(), database.DeactivateUpdatesParams{
+			ProjectID:      original.ProjectID,
+			Channel:        branch,
+			RuntimeVersion: original.RuntimeVersion,
+			Platform:       original.Platform,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to deactivate updates during promote",
+				slog.String("update_id", updateIdStr),
+				slog.Any("error", err),
+			)
+			jsonError(w, "Failed to deactivate updates", http.StatusInternalServerError)
+			return
+		}
+
+		message := "Promoted " + updateIdStr + " from " + original.Channel
+		if original.Message.Valid && original.Message.String != "" {
+			message = original.Message.String + " (" + message + ")"
+		}
+
+		promoted, err := qtx.CreateUpdate(r.Context(), database.CreateUpdateParams{
+			ProjectID:         original.ProjectID,
+			RuntimeVersion:    original.RuntimeVersion,
+			Channel:           branch,
+			RolloutPercentage: rollout,
+			Platform:          original.Platform,
+			IsActive:          true,
+			IsRollback:        original.IsRollback,
+			Message:           pgtype.Text{String: message, Valid: true},
+		})
+		if err != nil {
+			jsonError(w, "Failed to create promoted update", http.StatusInternalServerError)
+			return
+		}
+
+		if original.ExpoConfig != nil {
+			err = qtx.UpdateExpoConfig(r.Context(), database.UpdateExpoConfigParams{
+				ExpoConfig: original.ExpoConfig,
+				ID:         promoted.ID,
+			})
+			if err != nil {
+				jsonError(w, "Failed to clone expo config", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		err = qtx.CloneAssets(r.Context(), database.CloneAssetsParams{
+			SourceUpdateID: updateId,
+			TargetUpdateID: promoted.ID,
+		})
+		if err != nil {
+			jsonError(w, "Failed to clone assets", http.StatusInternalServerError)
+			return
+		}
+
+		promotion := webhook.Promotion{PromotedFrom: updateIdStr}
+		for _, event := range []string{webhook.EventUpdatePublished, webhook.EventUpdateActivated} {
+			if err := webhook.Enqueue(r.Context(), qtx, event, promoted, promotion); err != nil {
+				jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		err = tx.Commit(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		go InvalidateManifestCache(original.ProjectID.String())
+
+		slog.InfoContext(r.Context(), "Update promoted",
+			slog.String("source_update_id", updateIdStr),
+			slog.String("update_id", promoted.ID.String()),
+			slog.String("channel", req.Channel),
+			slog.String("branch", branch),
+		)
+
+		audit.SetProject(r.Context(), original.ProjectID)
+		audit.SetTarget(r.Context(), "promoted_update_id", promoted.ID.String())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toUpdateResponse(promoted, 0))
+	}
+}
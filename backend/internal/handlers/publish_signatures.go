@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/codesign"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+type CreateSigningCertificateRequest struct {
+	KeyID string `json:"key_id"`
+	// Certificate is a PEM chain, leaf first.
+	Certificate string `json:"certificate"`
+}
+
+type SigningCertificateResponse struct {
+	ID          string `json:"id"`
+	KeyID       string `json:"key_id"`
+	Algorithm   string `json:"algorithm"`
+	Subject     string `json:"subject"`
+	NotAfter    int64  `json:"not_after"`
+	Certificate string `json:"certificate"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type SubmitSignatureRequest struct {
+	KeyID string `json:"key_id"`
+	// Signature is the base64 signature over the exact bytes returned by the
+	// manifest endpoint.
+	Signature string `json:"signature"`
+}
+
+func toSigningCertificateResponse(c database.SigningCertificate) SigningCertificateResponse {
+	res := SigningCertificateResponse{
+		ID:          c.ID.String(),
+		KeyID:       c.KeyID,
+		Certificate: c.Certificate,
+		CreatedAt:   c.CreatedAt.Time.UnixMilli(),
+	}
+	// Stored chains were validated on upload; expiry is the only thing that
+	// can make them fail to parse later.
+	if chain, err := codesign.ParseCertificateChain(c.Certificate); err == nil {
+		res.Algorithm, _ = codesign.AlgForPublicKey(chain[0].PublicKey)
+		res.Subject = chain[0].Subject.String()
+		res.NotAfter = chain[0].NotAfter.UnixMilli()
+	}
+	return res
+}
+
+func ListSigningCertificates(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		certs, err := queries.ListSigningCertificates(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch certificates", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]SigningCertificateResponse, len(certs))
+		for i, c := range certs {
+			res[i] = toSigningCertificateResponse(c)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// CreateSigningCertificate registers the certificate (chain) for a key that
+// signs manifests at publish time.
+func CreateSigningCertificate(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req CreateSigningCertificateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !signingKeyIDPattern.MatchString(req.KeyID) {
+			jsonError(w, "Invalid key_id: use up to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+			return
+		}
+
+		chain, err := codesign.ParseCertificateChain(req.Certificate)
+		if err != nil {
+			jsonError(w, "Invalid certificate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := codesign.AlgForPublicKey(chain[0].PublicKey); err != nil {
+			jsonError(w, "Unsupported certificate key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cert, err := queries.CreateSigningCertificate(r.Context(), database.CreateSigningCertificateParams{
+			ProjectID:   projectId,
+			KeyID:       req.KeyID,
+			Certificate: req.Certificate,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				jsonError(w, "A certificate with this key_id already exists", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to save certificate", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toSigningCertificateResponse(cert))
+	}
+}
+
+// DeleteSigningCertificate stops accepting new publish-time signatures for a
+// key. Updates that were already signed keep serving their signature.
+func DeleteSigningCertificate(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		err = queries.DeleteSigningCertificate(r.Context(), database.DeleteSigningCertificateParams{
+			ProjectID: projectId,
+			KeyID:     chi.URLParam(r, "key_id"),
+		})
+		if err != nil {
+			jsonError(w, "Failed to delete certificate", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetUpdateManifest returns the exact manifest body devices will receive for
+// an update, so the CLI can sign it. Asset proxy URLs in it expire
+// at signedManifestURLExpiry, since the signed bytes are served unchanged.
+func GetUpdateManifest(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := projectUpdateFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+
+		manifestJSON := update.SignedManifest
+		if manifestJSON == nil {
+			var err error
+			manifestJSON, err = buildUpdateManifest(r.Context(), queries, update, signedManifestURLExpiry(update))
+			if err != nil {
+				if errors.Is(err, errMissingLaunchAsset) {
+					jsonError(w, "Update has no launch asset; upload the bundle first", http.StatusConflict)
+					return
+				}
+				slog.ErrorContext(r.Context(), "Failed to build manifest", slog.Any("error", err))
+				jsonError(w, "Failed to create manifest", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(manifestJSON)
+	}
+}
+
+// SubmitUpdateSignature checks a publish-time signature against the
+// project's registered certificate, stores it with the manifest bytes it
+// covers and activates the update.
+func SubmitUpdateSignature(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := projectUpdateFromRequest(w, r, queries)
+		if !ok || !keyAllowsChannel(w, r, update.Channel) {
+			return
+		}
+		if update.SignedManifest != nil {
+			jsonError(w, "Update is already signed", http.StatusConflict)
+			return
+		}
+
+		var req SubmitSignatureRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.KeyID == "" || req.Signature == "" {
+			jsonError(w, "key_id and signature are required", http.StatusBadRequest)
+			return
+		}
+		signature, err := base64.StdEncoding.DecodeString(req.Signature)
+		if err != nil {
+			jsonError(w, "Signature must be base64 encoded", http.StatusBadRequest)
+			return
+		}
+
+		cert, err := queries.GetSigningCertificate(r.Context(), database.GetSigningCertificateParams{
+			ProjectID: update.ProjectID,
+			KeyID:     req.KeyID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "No certificate registered for keyid "+req.KeyID, http.StatusBadRequest)
+				return
+			}
+			jsonError(w, "Failed to fetch certificate", http.StatusInternalServerError)
+			return
+		}
+		chain, err := codesign.ParseCertificateChain(cert.Certificate)
+		if err != nil {
+			jsonError(w, "Registered certificate is no longer valid: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		alg, err := codesign.AlgForPublicKey(chain[0].PublicKey)
+		if err != nil {
+			jsonError(w, "Unsupported certificate key", http.StatusBadRequest)
+			return
+		}
+		if expires := signedManifestURLExpiry(update); !expires.IsZero() && time.Now().After(expires) {
+			jsonError(w, "Asset URLs for this update have expired; republish it", http.StatusConflict)
+			return
+		}
+
+		manifestJSON, err := buildUpdateManifest(r.Context(), queries, update, signedManifestURLExpiry(update))
+		if err != nil {
+			if errors.Is(err, errMissingLaunchAsset) {
+				jsonError(w, "Update has no launch asset; upload the bundle first", http.StatusConflict)
+				return
+			}
+			jsonError(w, "Failed to create manifest", http.StatusInternalServerError)
+			return
+		}
+
+		if err := codesign.Verify(alg, chain[0].PublicKey, manifestJSON, signature); err != nil {
+			slog.WarnContext(r.Context(), "Rejected publish signature",
+				slog.String("update_id", update.ID.String()),
+				slog.String("key_id", req.KeyID),
+				slog.Any("error", err),
+			)
+			jsonError(w, "Signature does not match the manifest for keyid "+req.KeyID, http.StatusUnprocessableEntity)
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+		qtx := queries.WithTx(tx)
+
+		err = qtx.SetUpdateManifestSignature(r.Context(), database.SetUpdateManifestSignatureParams{
+			SignedManifest:    manifestJSON,
+			ManifestSignature: pgtype.Text{String: codesign.FormatHeader(signature, req.KeyID, alg), Valid: true},
+			ID:                update.ID,
+		})
+		if err != nil {
+			jsonError(w, "Failed to store signature", http.StatusInternalServerError)
+			return
+		}
+
+		err = qtx.DeactivateUpdates(r.Context(), database.DeactivateUpdatesParams{
+			ProjectID:      update.ProjectID,
+			Channel:        update.Channel,
+			Platform:       update.Platform,
+			RuntimeVersion: update.RuntimeVersion,
+		})
+		if err != nil {
+			jsonError(w, "Failed to deactivate updates", http.StatusInternalServerError)
+			return
+		}
+		if err := qtx.ActivateUpdate(r.Context(), update.ID); err != nil {
+			jsonError(w, "Failed to activate update", http.StatusInternalServerError)
+			return
+		}
+		update.IsActive = true
+		if err := webhook.Enqueue(r.Context(), qtx, webhook.EventUpdateActivated, update, nil); err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		InvalidateManifestCache(update.ProjectID.String())
+
+		slog.InfoContext(r.Context(), "Publish signature accepted",
+			slog.String("update_id", update.ID.String()),
+			slog.String("key_id", req.KeyID),
+			slog.String("alg", alg),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toUpdateResponse(update, 0))
+	}
+}
+
+// projectUpdateFromRequest loads the {update_id} update and checks it belongs
+// to the authenticated project, writing the error response when it does not.
+func projectUpdateFromRequest(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.Update, bool) {
+	updateId, err := utils.ParseUUID(chi.URLParam(r, "update_id"))
+	if err != nil {
+		jsonError(w, "Invalid update ID", http.StatusBadRequest)
+		return database.Update{}, false
+	}
+
+	update, err := queries.GetUpdateByID(r.Context(), updateId)
+	if err != nil {
+		jsonError(w, "Update not found", http.StatusNotFound)
+		return database.Update{}, false
+	}
+
+	if update.ProjectID != utils.GetProjectId(r.Context()) {
+		jsonError(w, "Update does not belong to this project", http.StatusForbidden)
+		return database.Update{}, false
+	}
+	return update, true
+}
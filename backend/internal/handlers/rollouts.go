@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/rollout"
+	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+// RolloutScheduleRequest sets a schedule on an update. Interval is a Go
+// duration such as "6h".
+type RolloutScheduleRequest struct {
+	Steps    []int32 `json:"steps"`
+	Interval string  `json:"interval"`
+}
+
+type RolloutScheduleResponse struct {
+	Steps       []int32 `json:"steps"`
+	Interval    string  `json:"interval"`
+	CurrentStep int32   `json:"current_step"`
+	Status      string  `json:"status"`
+	NextStepAt  int64   `json:"next_step_at,omitempty"`
+	UpdatedAt   int64   `json:"updated_at"`
+}
+
+type RolloutEventResponse struct {
+	Action         string `json:"action"`
+	FromPercentage int32  `json:"from_percentage"`
+	ToPercentage   int32  `json:"to_percentage"`
+	Actor          string `json:"actor"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type RolloutResponse struct {
+	UpdateID          string                   `json:"update_id"`
+	RolloutPercentage int32                    `json:"rollout_percentage"`
+	IsActive          bool                     `json:"is_active"`
+	Schedule          *RolloutScheduleResponse `json:"schedule"`
+	Events            []RolloutEventResponse   `json:"events"`
+}
+
+// GetRollout returns an update's rollout percentage, its schedule if it has
+// one, and the history of rollout changes.
+func GetRollout(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := scopedUpdateFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		writeRollout(w, r, queries, update.ID, http.StatusOK)
+	}
+}
+
+// SetRolloutSchedule replaces an update's rollout schedule. The first step
+// applies immediately; the scheduler job takes the following ones.
+func SetRolloutSchedule(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := scopedUpdateFromRequest(w, r, queries)
+		if !ok || !keyAllowsChannel(w, r, update.Channel) {
+			return
+		}
+
+		var req RolloutScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := rollout.ValidateSteps(req.Steps); err != nil {
+			jsonError(w, "Invalid steps: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		interval, err := time.ParseDuration(req.Interval)
+		if err != nil || interval < time.Minute {
+			jsonError(w, "Interval must be a duration of at least 1m, such as \"6h\"", http.StatusBadRequest)
+			return
+		}
+		if !update.IsActive {
+			jsonError(w, "Only the active update can be rolled out", http.StatusConflict)
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		qtx := queries.WithTx(tx)
+
+		err = qtx.UpdateRolloutPercentage(r.Context(), database.UpdateRolloutPercentageParams{
+			ID:                update.ID,
+			RolloutPercentage: req.Steps[0],
+		})
+		if err != nil {
+			jsonError(w, "Failed to update rollout percentage", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = qtx.UpsertRolloutSchedule(r.Context(), database.UpsertRolloutScheduleParams{
+			UpdateID:            update.ID,
+			Steps:               req.Steps,
+			StepIntervalSeconds: int64(interval / time.Second),
+			Status:              rollout.Status(req.Steps, 0),
+			NextStepAt:          rollout.NextStepAt(req.Steps, 0, time.Now(), interval),
+		})
+		if err != nil {
+			jsonError(w, "Failed to save rollout schedule", http.StatusInternalServerError)
+			return
+		}
+
+		err = qtx.CreateRolloutEvent(r.Context(), database.CreateRolloutEventParams{
+			UpdateID:       update.ID,
+			Action:         rollout.ActionScheduled,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   req.Steps[0],
+			Actor:          rolloutActor(r),
+		})
+		if err != nil {
+			jsonError(w, "Failed to record rollout event", http.StatusInternalServerError)
+			return
+		}
+
+		changed := update
+		changed.RolloutPercentage = req.Steps[0]
+		err = webhook.Enqueue(r.Context(), qtx, webhook.EventRolloutChanged, changed, webhook.RolloutChange{
+			Action:         rollout.ActionScheduled,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   req.Steps[0],
+		})
+		if err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		go InvalidateManifestCache(update.ProjectID.String())
+
+		slog.InfoContext(r.Context(), "Rollout scheduled",
+			slog.String("update_id", update.ID.String()),
+			slog.Any("steps", req.Steps),
+			slog.Duration("interval", interval),
+		)
+
+		writeRollout(w, r, queries, update.ID, http.StatusOK)
+	}
+}
+
+// PauseRollout stops a running schedule at its current step.
+func PauseRollout(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return setRolloutScheduleStatus(pool, queries, rollout.StatusRunning, rollout.StatusPaused, rollout.ActionPaused)
+}
+
+// ResumeRollout restarts a paused schedule. The next step is due one full
+// interval after resuming.
+func ResumeRollout(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return setRolloutScheduleStatus(pool, queries, rollout.StatusPaused, rollout.StatusRunning, rollout.ActionResumed)
+}
+
+func setRolloutScheduleStatus(pool *pgxpool.Pool, queries *database.Queries, from, to, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := scopedUpdateFromRequest(w, r, queries)
+		if !ok || !keyAllowsChannel(w, r, update.Channel) {
+			return
+		}
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		qtx := queries.WithTx(tx)
+
+		schedule, err := qtx.GetRolloutSchedule(r.Context(), update.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Update has no rollout schedule", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to fetch rollout schedule", http.StatusInternalServerError)
+			return
+		}
+		if schedule.Status != from {
+			jsonError(w, "Rollout schedule is "+schedule.Status+", not "+from, http.StatusConflict)
+			return
+		}
+		if to == rollout.StatusRunning && !update.IsActive {
+			jsonError(w, "Only the active update can be rolled out", http.StatusConflict)
+			return
+		}
+
+		var nextStepAt pgtype.Timestamptz
+		if to == rollout.StatusRunning {
+			interval := time.Duration(schedule.StepIntervalSeconds) * time.Second
+			nextStepAt = rollout.NextStepAt(schedule.Steps, schedule.CurrentStep, time.Now(), interval)
+		}
+
+		_, err = qtx.UpdateRolloutSchedule(r.Context(), database.UpdateRolloutScheduleParams{
+			CurrentStep: schedule.CurrentStep,
+			Status:      to,
+			NextStepAt:  nextStepAt,
+			UpdateID:    update.ID,
+		})
+		if err != nil {
+			jsonError(w, "Failed to update rollout schedule", http.StatusInternalServerError)
+			return
+		}
+
+		err = qtx.CreateRolloutEvent(r.Context(), database.CreateRolloutEventParams{
+			UpdateID:       update.ID,
+			Action:         action,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   update.RolloutPercentage,
+			Actor:          rolloutActor(r),
+		})
+		if err != nil {
+			jsonError(w, "Failed to record rollout event", http.StatusInternalServerError)
+			return
+		}
+
+		err = webhook.Enqueue(r.Context(), qtx, webhook.EventRolloutChanged, update, webhook.RolloutChange{
+			Action:         action,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   update.RolloutPercentage,
+		})
+		if err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(r.Context()); err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Rollout schedule status changed",
+			slog.String("update_id", update.ID.String()),
+			slog.String("status", to),
+			slog.Int("rollout_percentage", int(update.RolloutPercentage)),
+		)
+
+		writeRollout(w, r, queries, update.ID, http.StatusOK)
+	}
+}
+
+// scopedUpdateFromRequest loads the {update_id} update. On project routes
+// the update must belong to the API key's project.
+func scopedUpdateFromRequest(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.Update, bool) {
+	updateId, err := utils.ParseUUID(chi.URLParam(r, "update_id"))
+	if err != nil {
+		jsonError(w, "Invalid update ID", http.StatusBadRequest)
+		return database.Update{}, false
+	}
+
+	update, err := queries.GetUpdateByID(r.Context(), updateId)
+	if err != nil {
+		jsonError(w, "Update not found", http.StatusNotFound)
+		return database.Update{}, false
+	}
+
+	ctxProjectId := utils.GetProjectId(r.Context())
+	if ctxProjectId.Valid && update.ProjectID != ctxProjectId {
+		jsonError(w, "Update does not belong to this project", http.StatusForbidden)
+		return database.Update{}, false
+	}
+	audit.SetProject(r.Context(), update.ProjectID)
+	return update, true
+}
+
+// rolloutActor names who made a rollout change for the event log.
+func rolloutActor(r *http.Request) string {
+	if utils.GetProjectId(r.Context()).Valid {
+		return "api_key"
+	}
+	return "admin"
+}
+
+func writeRollout(w http.ResponseWriter, r *http.Request, queries *database.Queries, updateId pgtype.UUID, status int) {
+	update, err := queries.GetUpdateByID(r.Context(), updateId)
+	if err != nil {
+		jsonError(w, "Failed to fetch update", http.StatusInternalServerError)
+		return
+	}
+
+	res := RolloutResponse{
+		UpdateID:          update.ID.String(),
+		RolloutPercentage: update.RolloutPercentage,
+		IsActive:          update.IsActive,
+		Events:            []RolloutEventResponse{},
+	}
+
+	schedule, err := queries.GetRolloutSchedule(r.Context(), updateId)
+	if err == nil {
+		res.Schedule = &RolloutScheduleResponse{
+			Steps:       schedule.Steps,
+			Interval:    (time.Duration(schedule.StepIntervalSeconds) * time.Second).String(),
+			CurrentStep: schedule.CurrentStep,
+			Status:      schedule.Status,
+			UpdatedAt:   schedule.UpdatedAt.Time.UnixMilli(),
+		}
+		if schedule.NextStepAt.Valid {
+			res.Schedule.NextStepAt = schedule.NextStepAt.Time.UnixMilli()
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		jsonError(w, "Failed to fetch rollout schedule", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := queries.ListRolloutEvents(r.Context(), updateId)
+	if err != nil {
+		jsonError(w, "Failed to fetch rollout events", http.StatusInternalServerError)
+		return
+	}
+	for _, e := range events {
+		res.Events = append(res.Events, RolloutEventResponse{
+			Action:         e.Action,
+			FromPercentage: e.FromPercentage,
+			ToPercentage:   e.ToPercentage,
+			Actor:          e.Actor,
+			CreatedAt:      e.CreatedAt.Time.UnixMilli(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/vknow360/otaship/backend/internal/audit"
 	"github.com/vknow360/otaship/backend/internal/database"
 	"github.com/vknow360/otaship/backend/internal/storage"
 )
@@ -14,7 +15,7 @@ var allowedSettings = map[string]bool{
 	"storage_provider": true,
 }
 
-func GetSettings(queries *database.Queries, providers map[string]storage.Provider) http.HandlerFunc {
+func GetSettings(queries *database.Queries, providers *storage.Registry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		settings, err := queries.GetSettings(r.Context())
 		if err != nil {
@@ -29,7 +30,7 @@ func GetSettings(queries *database.Queries, providers map[string]storage.Provide
 		}
 
 		var providersList []string
-		for provider := range providers {
+		for provider := range providers.Server() {
 			providersList = append(providersList, provider)
 		}
 		resp["providers"] = providersList
@@ -68,19 +69,22 @@ func UpdateSetting(queries *database.Queries) http.HandlerFunc {
 			return
 		}
 
+		before, _ := queries.GetSetting(r.Context(), setting.Key)
 		if err := queries.UpdateSetting(r.Context(), setting); err != nil {
 			slog.Error("Failed to update setting", "error", err)
 			json.NewEncoder(w).Encode(map[string]string{"error": "internal server error"})
 			return
 		}
+		audit.SetTarget(r.Context(), "setting", setting.Key)
+		audit.SetChange(r.Context(), map[string]string{"value": before.Value}, map[string]string{"value": setting.Value})
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func GetStorageUsage(providers map[string]storage.Provider) http.HandlerFunc {
+func GetStorageUsage(providers *storage.Registry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		stats := make(map[string]any)
-		for provider, storage := range providers {
+		for provider, storage := range providers.Server() {
 			usage, err := storage.Usage(r.Context())
 			if err != nil {
 				slog.Error("Failed to get storage usage", "provider", provider, "error", err)
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/codesign"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/projectstorage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+const certificateValidity = 10 * 365 * 24 * time.Hour
+
+// Key IDs end up inside a quoted structured-field string in expo-signature,
+// so they are kept to a conservative character set.
+var signingKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// signingKeys seals private keys before they are stored. It is nil unless
+// STORAGE_CREDENTIALS_KEY is set, and then keys cannot be added.
+var signingKeys *projectstorage.Store
+
+// SetSigningKeyStore sets the store that seals and opens projects' private
+// keys. Call it before serving requests.
+func SetSigningKeyStore(store *projectstorage.Store) {
+	signingKeys = store
+}
+
+type CreateSigningKeyRequest struct {
+	KeyID string `json:"key_id"`
+	// Algorithm selects the key type to generate. Defaults to
+	// rsa-v1_5-sha256, the only algorithm current expo-updates clients accept.
+	Algorithm string `json:"algorithm"`
+	// PrivateKey and Certificate import an existing key pair, for example the
+	// one previously configured through EXPO_PRIVATE_KEY. The certificate
+	// must hold the key's public key. When both are empty a new key and
+	// self-signed certificate are generated.
+	PrivateKey  string `json:"private_key"`
+	Certificate string `json:"certificate"`
+	Primary     bool   `json:"primary"`
+}
+
+type SigningKeyResponse struct {
+	ID          string `json:"id"`
+	KeyID       string `json:"key_id"`
+	Algorithm   string `json:"algorithm"`
+	Certificate string `json:"certificate"`
+	IsPrimary   bool   `json:"is_primary"`
+	CreatedAt   int64  `json:"created_at"`
+	RetiredAt   int64  `json:"retired_at,omitempty"`
+}
+
+func toSigningKeyResponse(k database.ListSigningKeysRow) SigningKeyResponse {
+	var retiredAt int64
+	if k.RetiredAt.Valid {
+		retiredAt = k.RetiredAt.Time.UnixMilli()
+	}
+	return SigningKeyResponse{
+		ID:          k.ID.String(),
+		KeyID:       k.KeyID,
+		Algorithm:   k.Algorithm,
+		Certificate: k.Certificate,
+		IsPrimary:   k.IsPrimary,
+		CreatedAt:   k.CreatedAt.Time.UnixMilli(),
+		RetiredAt:   retiredAt,
+	}
+}
+
+func ListSigningKeys(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		keys, err := queries.ListSigningKeys(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch signing keys", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]SigningKeyResponse, len(keys))
+		for i, k := range keys {
+			res[i] = toSigningKeyResponse(k)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// CreateSigningKey generates (or imports) a signing key for a project. The
+// first key of a project always becomes its primary key.
+func CreateSigningKey(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateSigningKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		addSigningKey(w, r, pool, queries, req)
+	}
+}
+
+// RotateSigningKey generates a new primary key. The previous keys stay active
+// so binaries that embed their certificates keep verifying manifests until
+// they are retired.
+func RotateSigningKey(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateSigningKeyRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		req.Primary = true
+		addSigningKey(w, r, pool, queries, req)
+	}
+}
+
+// RetireSigningKey stops a key from signing. The primary key cannot be
+// retired; rotate first so there is always a key for clients that do not
+// ask for a specific keyid.
+func RetireSigningKey(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		keyID := chi.URLParam(r, "key_id")
+
+		existing, err := queries.GetActiveSigningKey(r.Context(), database.GetActiveSigningKeyParams{
+			ProjectID: projectId,
+			KeyID:     keyID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Signing key not found", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to fetch signing key", http.StatusInternalServerError)
+			return
+		}
+		if existing.IsPrimary {
+			jsonError(w, "Cannot retire the primary signing key; rotate it first", http.StatusConflict)
+			return
+		}
+
+		key, err := queries.RetireSigningKey(r.Context(), database.RetireSigningKeyParams{
+			ProjectID: projectId,
+			KeyID:     keyID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Signing key not found", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to retire signing key", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toSigningKeyResponse(database.ListSigningKeysRow(key)))
+	}
+}
+
+func addSigningKey(w http.ResponseWriter, r *http.Request, pool *pgxpool.Pool, queries *database.Queries, req CreateSigningKeyRequest) {
+	projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+	if err != nil {
+		jsonError(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, err := queries.GetProjectByID(r.Context(), projectId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		jsonError(w, "Failed to fetch project", http.StatusInternalServerError)
+		return
+	}
+
+	if signingKeys == nil {
+		jsonError(w, "Signing keys cannot be stored; set STORAGE_CREDENTIALS_KEY on the server", http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.KeyID == "" {
+		req.KeyID = newSigningKeyID()
+	}
+	if !signingKeyIDPattern.MatchString(req.KeyID) {
+		jsonError(w, "Invalid key_id: use up to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+		return
+	}
+
+	if req.Algorithm != "" && !codesign.IsSupported(req.Algorithm) {
+		jsonError(w, "Unsupported algorithm: "+req.Algorithm, http.StatusBadRequest)
+		return
+	}
+
+	privateKey, certificate := req.PrivateKey, req.Certificate
+	algorithm := req.Algorithm
+	if privateKey == "" {
+		if certificate != "" {
+			jsonError(w, "A certificate can only be imported together with its private key", http.StatusBadRequest)
+			return
+		}
+		if algorithm == "" {
+			algorithm = codesign.AlgRSAPKCS1SHA256
+		}
+		privateKey, certificate, err = codesign.GenerateKey(algorithm, project.Slug, certificateValidity)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to generate signing key", slog.Any("error", err))
+			jsonError(w, "Failed to generate signing key", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		signer, err := codesign.NewSigner(privateKey)
+		if err != nil {
+			jsonError(w, "Invalid private key: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if algorithm != "" && algorithm != signer.Alg() {
+			jsonError(w, "Private key does not match algorithm "+algorithm, http.StatusBadRequest)
+			return
+		}
+		// Devices verify against the certificate, so a key without its own
+		// certificate would sign manifests no client accepts.
+		if certificate == "" {
+			jsonError(w, "An imported private key needs its certificate", http.StatusBadRequest)
+			return
+		}
+		chain, err := codesign.ParseCertificateChain(certificate)
+		if err != nil {
+			jsonError(w, "Invalid certificate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !codesign.MatchesCertificate(signer, chain[0]) {
+			jsonError(w, "Certificate does not match the private key", http.StatusBadRequest)
+			return
+		}
+		algorithm = signer.Alg()
+	}
+
+	sealedKey, err := signingKeys.SealSigningKey(projectId, privateKey)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to seal signing key", slog.Any("error", err))
+		jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := pool.Begin(r.Context())
+	if err != nil {
+		jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(context.Background())
+	qtx := queries.WithTx(tx)
+
+	primary := req.Primary
+	if !primary {
+		_, err := qtx.GetPrimarySigningKey(r.Context(), projectId)
+		if errors.Is(err, pgx.ErrNoRows) {
+			primary = true
+		} else if err != nil {
+			jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+			return
+		}
+	}
+	if primary {
+		if err := qtx.ClearPrimarySigningKey(r.Context(), projectId); err != nil {
+			jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	key, err := qtx.CreateSigningKey(r.Context(), database.CreateSigningKeyParams{
+		ProjectID:   projectId,
+		KeyID:       req.KeyID,
+		Algorithm:   algorithm,
+		PrivateKey:  sealedKey,
+		Certificate: certificate,
+		IsPrimary:   primary,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			jsonError(w, "A signing key with this key_id already exists", http.StatusConflict)
+			return
+		}
+		jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		jsonError(w, "Failed to create signing key", http.StatusInternalServerError)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Signing key created",
+		slog.String("project_id", project.ID.String()),
+		slog.String("key_id", key.KeyID),
+		slog.Bool("primary", key.IsPrimary),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SigningKeyResponse{
+		ID:          key.ID.String(),
+		KeyID:       key.KeyID,
+		Algorithm:   key.Algorithm,
+		Certificate: key.Certificate,
+		IsPrimary:   key.IsPrimary,
+		CreatedAt:   key.CreatedAt.Time.UnixMilli(),
+	})
+}
+
+func newSigningKeyID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix)
+}
+
+// resolveSigningKey picks the key that signs a manifest response. A keyid in
+// expo-expect-signature selects one of the project's active keys; otherwise
+// the primary key is used. Projects without keys in the database fall back
+// to EXPO_PRIVATE_KEY under keyid "main". A nil key with a nil error means
+// no signature is available. Sealed private keys are returned opened.
+func resolveSigningKey(ctx context.Context, queries *database.Queries, projectId pgtype.UUID, keyID string) (*database.SigningKey, error) {
+	var (
+		key database.SigningKey
+		err error
+	)
+	if keyID != "" {
+		key, err = queries.GetActiveSigningKey(ctx, database.GetActiveSigningKeyParams{
+			ProjectID: projectId,
+			KeyID:     keyID,
+		})
+	} else {
+		key, err = queries.GetPrimarySigningKey(ctx, projectId)
+	}
+	if err == nil {
+		if projectstorage.IsSealedSigningKey(key.PrivateKey) {
+			if signingKeys == nil {
+				return nil, fmt.Errorf("signing key %s is sealed, but STORAGE_CREDENTIALS_KEY is not set", key.KeyID)
+			}
+			key.PrivateKey, err = signingKeys.OpenSigningKey(projectId, key.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &key, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if keyID == "" || keyID == "main" {
+		if pvtKey := os.Getenv("EXPO_PRIVATE_KEY"); pvtKey != "" {
+			signer, err := codesign.NewSigner(pvtKey)
+			if err != nil {
+				return nil, fmt.Errorf("EXPO_PRIVATE_KEY: %w", err)
+			}
+			return &database.SigningKey{
+				KeyID:      "main",
+				Algorithm:  signer.Alg(),
+				PrivateKey: pvtKey,
+			}, nil
+		}
+	}
+	return nil, nil
+}
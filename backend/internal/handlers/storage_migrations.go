@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/migrator"
+	"github.com/vknow360/otaship/backend/internal/projectstorage"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+const storageMigrationListLimit = 50
+
+type CreateStorageMigrationRequest struct {
+	// ProjectID limits the migration to one project. Every project is
+	// migrated when it is empty.
+	ProjectID    string `json:"project_id"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	DeleteSource bool   `json:"delete_source"`
+}
+
+type StorageMigrationResponse struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id,omitempty"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	DeleteSource  bool   `json:"delete_source"`
+	Status        string `json:"status"`
+	TotalObjects  int64  `json:"total_objects"`
+	CopiedObjects int64  `json:"copied_objects"`
+	CopiedBytes   int64  `json:"copied_bytes"`
+	FailedObjects int64  `json:"failed_objects"`
+	LastKey       string `json:"last_key,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+	CompletedAt   int64  `json:"completed_at,omitempty"`
+}
+
+func toStorageMigrationResponse(m database.StorageMigration) StorageMigrationResponse {
+	res := StorageMigrationResponse{
+		ID:            m.ID.String(),
+		From:          m.SourceProvider,
+		To:            m.TargetProvider,
+		DeleteSource:  m.DeleteSource,
+		Status:        m.Status,
+		TotalObjects:  m.TotalObjects,
+		CopiedObjects: m.CopiedObjects,
+		CopiedBytes:   m.CopiedBytes,
+		FailedObjects: m.FailedObjects,
+		LastKey:       m.LastKey,
+		LastError:     m.LastError,
+		CreatedAt:     m.CreatedAt.Time.UnixMilli(),
+		UpdatedAt:     m.UpdatedAt.Time.UnixMilli(),
+	}
+	if m.ProjectID.Valid {
+		res.ProjectID = m.ProjectID.String()
+	}
+	if m.CompletedAt.Valid {
+		res.CompletedAt = m.CompletedAt.Time.UnixMilli()
+	}
+	return res
+}
+
+// CreateStorageMigration queues a copy of every blob on one provider, for a
+// project or the whole server, to another. The migrator job does the work;
+// the response carries the number of objects to copy.
+func CreateStorageMigration(queries *database.Queries, providers *storage.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateStorageMigrationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.From == "" || req.To == "" {
+			jsonError(w, "from and to are required", http.StatusBadRequest)
+			return
+		}
+		if req.From == req.To {
+			jsonError(w, "from and to must be different providers", http.StatusBadRequest)
+			return
+		}
+
+		var projectId pgtype.UUID
+		if req.ProjectID != "" {
+			var err error
+			projectId, err = utils.ParseUUID(req.ProjectID)
+			if err != nil {
+				jsonError(w, "Invalid project ID", http.StatusBadRequest)
+				return
+			}
+			if _, err := queries.GetProjectByID(r.Context(), projectId); err != nil {
+				jsonError(w, "Project not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		for _, name := range []string{req.From, req.To} {
+			if _, err := providers.Get(r.Context(), name); err != nil {
+				jsonError(w, "Storage provider "+name+" is not configured", http.StatusBadRequest)
+				return
+			}
+			// A project's own storage only holds that project's blobs.
+			if id, ok := projectstorage.ParseProviderName(name); ok {
+				own, err := queries.GetProjectStorage(r.Context(), id)
+				if err != nil || own.ProjectID != projectId {
+					jsonError(w, "Storage provider "+name+" belongs to a project; set project_id to that project", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		open, err := queries.CountOpenStorageMigrations(r.Context(), req.From)
+		if err != nil {
+			jsonError(w, "Failed to check storage migrations", http.StatusInternalServerError)
+			return
+		}
+		if open > 0 {
+			jsonError(w, "A migration from "+req.From+" is already running or paused", http.StatusConflict)
+			return
+		}
+
+		// Publish-time signed manifests are served byte for byte, so without
+		// the asset proxy they keep pointing at the source after the move.
+		if req.DeleteSource && assetURLs == nil {
+			signed, err := queries.CountSignedUpdatesOnProvider(r.Context(), database.CountSignedUpdatesOnProviderParams{
+				ProjectID:      projectId,
+				SourceProvider: req.From,
+			})
+			if err != nil {
+				jsonError(w, "Failed to check signed updates", http.StatusInternalServerError)
+				return
+			}
+			if signed > 0 {
+				jsonError(w, fmt.Sprintf("%d signed updates have assets on %s and their manifests embed its URLs; republish them or set ASSET_URL_SECRET before using delete_source", signed, req.From), http.StatusConflict)
+				return
+			}
+		}
+
+		total, err := queries.CountStorageMigrationObjects(r.Context(), database.CountStorageMigrationObjectsParams{
+			SourceProvider: req.From,
+			ProjectID:      projectId,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to count storage objects", slog.Any("error", err))
+			jsonError(w, "Failed to count storage objects", http.StatusInternalServerError)
+			return
+		}
+
+		migration, err := queries.CreateStorageMigration(r.Context(), database.CreateStorageMigrationParams{
+			ProjectID:      projectId,
+			SourceProvider: req.From,
+			TargetProvider: req.To,
+			DeleteSource:   req.DeleteSource,
+			TotalObjects:   total,
+		})
+		if err != nil {
+			jsonError(w, "Failed to create storage migration", http.StatusInternalServerError)
+			return
+		}
+
+		audit.SetTarget(r.Context(), "migration_id", migration.ID.String())
+		audit.SetChange(r.Context(), nil, map[string]any{"from": req.From, "to": req.To, "delete_source": req.DeleteSource})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(toStorageMigrationResponse(migration))
+	}
+}
+
+func ListStorageMigrations(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		migrations, err := queries.ListStorageMigrations(r.Context(), storageMigrationListLimit)
+		if err != nil {
+			jsonError(w, "Failed to fetch storage migrations", http.StatusInternalServerError)
+			return
+		}
+		res := make([]StorageMigrationResponse, len(migrations))
+		for i, m := range migrations {
+			res[i] = toStorageMigrationResponse(m)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func GetStorageMigration(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		migration, ok := storageMigrationFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toStorageMigrationResponse(migration))
+	}
+}
+
+// PauseStorageMigration stops a running migration after its current batch.
+func PauseStorageMigration(queries *database.Queries) http.HandlerFunc {
+	return setStorageMigrationStatus(queries, migrator.StatusPaused, []string{migrator.StatusRunning},
+		"Only running migrations can be paused")
+}
+
+// ResumeStorageMigration continues a paused or failed migration from the
+// last key it recorded.
+func ResumeStorageMigration(queries *database.Queries) http.HandlerFunc {
+	return setStorageMigrationStatus(queries, migrator.StatusRunning, []string{migrator.StatusPaused, migrator.StatusFailed},
+		"Only paused or failed migrations can be resumed")
+}
+
+func setStorageMigrationStatus(queries *database.Queries, status string, from []string, conflict string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		migration, ok := storageMigrationFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		updated, err := queries.SetStorageMigrationStatus(r.Context(), database.SetStorageMigrationStatusParams{
+			Status:       status,
+			ID:           migration.ID,
+			FromStatuses: from,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, conflict, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			jsonError(w, "Failed to update storage migration", http.StatusInternalServerError)
+			return
+		}
+
+		audit.SetTarget(r.Context(), "migration_id", migration.ID.String())
+		audit.SetChange(r.Context(), map[string]string{"status": migration.Status}, map[string]string{"status": updated.Status})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toStorageMigrationResponse(updated))
+	}
+}
+
+func storageMigrationFromRequest(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.StorageMigration, bool) {
+	id, err := utils.ParseUUID(chi.URLParam(r, "migration_id"))
+	if err != nil {
+		jsonError(w, "Invalid migration ID", http.StatusBadRequest)
+		return database.StorageMigration{}, false
+	}
+	migration, err := queries.GetStorageMigration(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Storage migration not found", http.StatusNotFound)
+			return migration, false
+		}
+		jsonError(w, "Failed to fetch storage migration", http.StatusInternalServerError)
+		return migration, false
+	}
+	return migration, true
+}
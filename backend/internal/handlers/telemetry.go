@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+// Telemetry event types apps report.
+const (
+	TelemetryLaunchSuccess = "launch_success"
+	TelemetryJSError       = "js_error"
+	TelemetryCrash         = "crash"
+)
+
+// telemetryDedupTTL is how long a device's report of one event type for an
+// update keeps further reports of it from being counted.
+const telemetryDedupTTL = 24 * time.Hour
+
+// TelemetryRequest reports one event for an update. Reports are not
+// authenticated, so each device counts at most once per event type and
+// update towards a policy's min_launches and error rate.
+type TelemetryRequest struct {
+	UpdateID string `json:"update_id"`
+	Type     string `json:"type"`
+	// Count may only be 1; it is accepted for clients that send it.
+	Count int64 `json:"count,omitempty"`
+}
+
+type UpdateTelemetryResponse struct {
+	UpdateID       string  `json:"update_id"`
+	Launches       int64   `json:"launches"`
+	JSErrors       int64   `json:"js_errors"`
+	Crashes        int64   `json:"crashes"`
+	ErrorRate      float64 `json:"error_rate"`
+	UpdatedAt      int64   `json:"updated_at,omitempty"`
+	RolledBackAt   int64   `json:"rolled_back_at,omitempty"`
+	RollbackReason string  `json:"rollback_reason,omitempty"`
+}
+
+type AutoRollbackPolicyRequest struct {
+	Enabled      bool    `json:"enabled"`
+	MaxErrorRate float64 `json:"max_error_rate"`
+	MinLaunches  int32   `json:"min_launches"`
+}
+
+type AutoRollbackPolicyResponse struct {
+	Enabled      bool    `json:"enabled"`
+	MaxErrorRate float64 `json:"max_error_rate"`
+	MinLaunches  int32   `json:"min_launches"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+// RecordTelemetry takes launch outcomes from apps and adds them to the
+// update's totals. Devices are told apart like manifest requests are, and
+// repeated reports from one device are accepted but not counted. Once an
+// active update's error rate passes the project's auto-rollback policy, it
+// is rolled back in the background.
+func RecordTelemetry(pool *pgxpool.Pool, queries *database.Queries, deviceIDHeader string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req TelemetryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Count != 0 && req.Count != 1 {
+			jsonError(w, "Report one event per request", http.StatusBadRequest)
+			return
+		}
+
+		params := database.RecordUpdateTelemetryParams{ProjectID: projectId}
+		switch req.Type {
+		case TelemetryLaunchSuccess:
+			params.Launches = 1
+		case TelemetryJSError:
+			params.JsErrors = 1
+		case TelemetryCrash:
+			params.Crashes = 1
+		default:
+			jsonError(w, "Type must be one of launch_success, js_error, crash", http.StatusBadRequest)
+			return
+		}
+
+		updateId, err := utils.ParseUUID(req.UpdateID)
+		if err != nil {
+			jsonError(w, "Invalid update ID", http.StatusBadRequest)
+			return
+		}
+		update, err := queries.GetUpdateByID(r.Context(), updateId)
+		if err != nil || update.ProjectID != projectId {
+			jsonError(w, "Update not found", http.StatusNotFound)
+			return
+		}
+		params.UpdateID = update.ID
+
+		deviceHash, _ := utils.BuildDeviceIdentity(r, update.Platform, deviceIDHeader)
+		dedupKey := telemetryDedupKey(update.ID.String(), req.Type, deviceHash)
+		first, err := manifestCache.Add(r.Context(), dedupKey, telemetryDedupTTL)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Telemetry dedup check failed", slog.Any("error", err))
+			jsonError(w, "Failed to record telemetry", http.StatusServiceUnavailable)
+			return
+		}
+		if !first {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		stats, err := queries.RecordUpdateTelemetry(r.Context(), params)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to record telemetry", slog.Any("error", err))
+			manifestCache.DeletePrefix(r.Context(), dedupKey)
+			jsonError(w, "Failed to record telemetry", http.StatusInternalServerError)
+			return
+		}
+
+		if update.IsActive && !update.IsRollback && !stats.RolledBackAt.Valid && telemetryErrorRate(stats) > 0 {
+			go checkAutoRollback(pool, queries, update, stats)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// telemetryDedupKey is the cache key that marks a device's report of
+// eventType for an update as counted.
+func telemetryDedupKey(updateID, eventType, deviceHash string) string {
+	return "telemetry:" + updateID + ":" + eventType + ":" + deviceHash
+}
+
+func GetUpdateTelemetry(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		update, ok := scopedUpdateFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+
+		stats, err := queries.GetUpdateTelemetry(r.Context(), update.ID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			jsonError(w, "Failed to fetch telemetry", http.StatusInternalServerError)
+			return
+		}
+
+		res := UpdateTelemetryResponse{
+			UpdateID:       update.ID.String(),
+			Launches:       stats.Launches,
+			JSErrors:       stats.JsErrors,
+			Crashes:        stats.Crashes,
+			ErrorRate:      telemetryErrorRate(stats),
+			RollbackReason: stats.RollbackReason.String,
+		}
+		if stats.UpdatedAt.Valid {
+			res.UpdatedAt = stats.UpdatedAt.Time.UnixMilli()
+		}
+		if stats.RolledBackAt.Valid {
+			res.RolledBackAt = stats.RolledBackAt.Time.UnixMilli()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func GetAutoRollbackPolicy(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		policy, err := queries.GetAutoRollbackPolicy(r.Context(), projectId)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				jsonError(w, "Project has no auto-rollback policy", http.StatusNotFound)
+				return
+			}
+			jsonError(w, "Failed to fetch auto-rollback policy", http.StatusInternalServerError)
+			return
+		}
+		writeAutoRollbackPolicy(w, policy)
+	}
+}
+
+func SetAutoRollbackPolicy(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := routeProjectID(r)
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		if !keyAllowsChannel(w, r, "") {
+			return
+		}
+
+		var req AutoRollbackPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.MaxErrorRate <= 0 || req.MaxErrorRate > 1 {
+			jsonError(w, "max_error_rate must be a fraction greater than 0 and at most 1", http.StatusBadRequest)
+			return
+		}
+		if req.MinLaunches == 0 {
+			req.MinLaunches = 100
+		}
+		if req.MinLaunches < 0 {
+			jsonError(w, "min_launches must be positive", http.StatusBadRequest)
+			return
+		}
+
+		before, beforeErr := queries.GetAutoRollbackPolicy(r.Context(), projectId)
+
+		policy, err := queries.UpsertAutoRollbackPolicy(r.Context(), database.UpsertAutoRollbackPolicyParams{
+			ProjectID:    projectId,
+			Enabled:      req.Enabled,
+			MaxErrorRate: req.MaxErrorRate,
+			MinLaunches:  req.MinLaunches,
+		})
+		if err != nil {
+			jsonError(w, "Failed to save auto-rollback policy", http.StatusInternalServerError)
+			return
+		}
+		if beforeErr == nil {
+			audit.SetChange(r.Context(), toAutoRollbackPolicyResponse(before), toAutoRollbackPolicyResponse(policy))
+		} else {
+			audit.SetChange(r.Context(), nil, toAutoRollbackPolicyResponse(policy))
+		}
+		writeAutoRollbackPolicy(w, policy)
+	}
+}
+
+func toAutoRollbackPolicyResponse(policy database.AutoRollbackPolicy) AutoRollbackPolicyResponse {
+	return AutoRollbackPolicyResponse{
+		Enabled:      policy.Enabled,
+		MaxErrorRate: policy.MaxErrorRate,
+		MinLaunches:  policy.MinLaunches,
+		UpdatedAt:    policy.UpdatedAt.Time.UnixMilli(),
+	}
+}
+
+func writeAutoRollbackPolicy(w http.ResponseWriter, policy database.AutoRollbackPolicy) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toAutoRollbackPolicyResponse(policy))
+}
+
+// telemetryErrorRate is the share of launches that failed. A crash counts as
+// a launch that did not succeed, so updates that crash on every start still
+// produce samples.
+func telemetryErrorRate(stats database.UpdateTelemetry) float64 {
+	samples := stats.Launches + stats.Crashes
+	if samples == 0 {
+		return 0
+	}
+	return float64(stats.JsErrors+stats.Crashes) / float64(samples)
+}
+
+// exceedsPolicy reports whether an update should be rolled back under policy.
+func exceedsPolicy(stats database.UpdateTelemetry, policy database.AutoRollbackPolicy) bool {
+	if !policy.Enabled || stats.Launches+stats.Crashes < int64(policy.MinLaunches) {
+		return false
+	}
+	return telemetryErrorRate(stats) > policy.MaxErrorRate
+}
+
+// checkAutoRollback rolls update back, using the same logic as
+// CreateRollback or CreateRollbackToEmbedded, if its telemetry exceeds the
+// project's policy. It rolls back to the newest earlier update on the branch
+// that was not rolled back itself, or to the embedded update if there is none.
+func checkAutoRollback(pool *pgxpool.Pool, queries *database.Queries, update database.Update, stats database.UpdateTelemetry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	policy, err := queries.GetAutoRollbackPolicy(ctx, update.ProjectID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.Error("Failed to load auto-rollback policy", slog.Any("error", err))
+		}
+		return
+	}
+	if !exceedsPolicy(stats, policy) {
+		return
+	}
+
+	rate := telemetryErrorRate(stats)
+	reason := fmt.Sprintf("error rate %.1f%% exceeded %.1f%% after %d launches (%d JS errors, %d crashes)",
+		rate*100, policy.MaxErrorRate*100, stats.Launches+stats.Crashes, stats.JsErrors, stats.Crashes)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		slog.Error("Failed to begin auto-rollback transaction", slog.Any("error", err))
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := queries.WithTx(tx)
+
+	// Only the first report over the threshold rolls back; concurrent ones
+	// block on the row and then see it marked.
+	marked, err := qtx.MarkUpdateRolledBack(ctx, database.MarkUpdateRolledBackParams{
+		UpdateID:       update.ID,
+		RollbackReason: pgtype.Text{String: reason, Valid: true},
+	})
+	if err != nil || marked == 0 {
+		return
+	}
+
+	current, err := qtx.GetUpdateByID(ctx, update.ID)
+	if err != nil || !current.IsActive {
+		return
+	}
+
+	var rollback database.Update
+	details := webhook.Rollback{Automatic: true, Reason: reason}
+	target, err := qtx.GetAutoRollbackTarget(ctx, database.GetAutoRollbackTargetParams{
+		ProjectID:      current.ProjectID,
+		Channel:        current.Channel,
+		RuntimeVersion: current.RuntimeVersion,
+		Platform:       current.Platform,
+		CreatedAt:      current.CreatedAt,
+	})
+	switch {
+	case err == nil:
+		rollback, err = rollbackToUpdate(ctx, qtx, target, "Automatic rollback to "+target.ID.String()+": "+reason)
+		details.RolledBackTo = target.ID.String()
+	case errors.Is(err, pgx.ErrNoRows):
+		rollback, err = rollbackToEmbedded(ctx, qtx, current.ProjectID, current.Channel, current.RuntimeVersion, current.Platform, "Automatic rollback to embedded: "+reason)
+	}
+	if err == nil {
+		err = webhook.Enqueue(ctx, qtx, webhook.EventUpdateRolledBack, rollback, details)
+	}
+	if err != nil {
+		slog.Error("Automatic rollback failed",
+			slog.String("update_id", update.ID.String()),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		slog.Error("Failed to commit automatic rollback", slog.Any("error", err))
+		return
+	}
+
+	go InvalidateManifestCache(update.ProjectID.String())
+
+	slog.Warn("Update automatically rolled back",
+		slog.String("project_id", update.ProjectID.String()),
+		slog.String("update_id", update.ID.String()),
+		slog.String("rollback_update_id", rollback.ID.String()),
+		slog.Bool("to_embedded", rollback.IsRollback),
+		slog.String("reason", reason),
+	)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/cache"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+func TestExceedsPolicy(t *testing.T) {
+	policy := database.AutoRollbackPolicy{Enabled: true, MaxErrorRate: 0.05, MinLaunches: 100}
+
+	tests := []struct {
+		name  string
+		stats database.UpdateTelemetry
+		want  bool
+	}{
+		{"healthy", database.UpdateTelemetry{Launches: 1000, JsErrors: 10}, false},
+		{"too few samples", database.UpdateTelemetry{Launches: 10, Crashes: 10}, false},
+		{"error rate over threshold", database.UpdateTelemetry{Launches: 190, JsErrors: 5, Crashes: 10}, true},
+		{"crashes on every launch", database.UpdateTelemetry{Crashes: 100}, true},
+		{"exactly at threshold", database.UpdateTelemetry{Launches: 95, Crashes: 5}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exceedsPolicy(tt.stats, policy); got != tt.want {
+				t.Errorf("exceedsPolicy() = %v, want %v (error rate %.3f)", got, tt.want, telemetryErrorRate(tt.stats))
+			}
+		})
+	}
+
+	disabled := policy
+	disabled.Enabled = false
+	if exceedsPolicy(database.UpdateTelemetry{Crashes: 1000}, disabled) {
+		t.Errorf("disabled policy should never trigger a rollback")
+	}
+}
+
+func TestRecordTelemetryOneEventPerRequest(t *testing.T) {
+	// The handler must reject these before touching the database.
+	r := chi.NewRouter()
+	r.Post("/telemetry/{project_id}", RecordTelemetry(nil, nil, ""))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"batched crashes", `{"update_id":"5d9b7c1a-2e4f-4b6a-8c3d-1f0e9a8b7c6d","type":"crash","count":1000}`},
+		{"two launches", `{"update_id":"5d9b7c1a-2e4f-4b6a-8c3d-1f0e9a8b7c6d","type":"launch_success","count":2}`},
+		{"negative count", `{"update_id":"5d9b7c1a-2e4f-4b6a-8c3d-1f0e9a8b7c6d","type":"js_error","count":-1}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/telemetry/0b8f8a4e-3f3e-4a43-9d4a-6f1f0c4c2d11", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tt.name, rr.Code)
+		}
+	}
+}
+
+// telemetryDB serves one update and counts the telemetry writes made for it.
+type telemetryDB struct {
+	update   database.Update
+	recorded int
+}
+
+type scanFunc func(dest ...any) error
+
+func (f scanFunc) Scan(dest ...any) error { return f(dest...) }
+
+func (db *telemetryDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.Contains(sql, "name: GetUpdateByID "):
+		return scanFunc(func(dest ...any) error {
+			*dest[0].(*pgtype.UUID) = db.update.ID
+			*dest[1].(*pgtype.UUID) = db.update.ProjectID
+			*dest[5].(*string) = db.update.Platform
+			return nil
+		})
+	case strings.Contains(sql, "name: RecordUpdateTelemetry "):
+		db.recorded++
+		return scanFunc(func(dest ...any) error { return nil })
+	}
+	return scanFunc(func(dest ...any) error { return errors.New("unexpected query") })
+}
+
+func (db *telemetryDB) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("unexpected exec")
+}
+
+func (db *telemetryDB) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errors.New("unexpected query")
+}
+
+func TestRecordTelemetryCountsDevicesOnce(t *testing.T) {
+	SetManifestCache(cache.NewMemory(), nil)
+	t.Cleanup(func() { SetManifestCache(cache.NewMemory(), nil) })
+
+	projectID, _ := utils.ParseUUID("0b8f8a4e-3f3e-4a43-9d4a-6f1f0c4c2d11")
+	updateID, _ := utils.ParseUUID("5d9b7c1a-2e4f-4b6a-8c3d-1f0e9a8b7c6d")
+	db := &telemetryDB{update: database.Update{ID: updateID, ProjectID: projectID, Platform: "ios"}}
+
+	r := chi.NewRouter()
+	r.Post("/telemetry/{project_id}", RecordTelemetry(nil, database.New(db), ""))
+	report := func(eventType, clientID string) {
+		body := `{"update_id":"` + updateID.String() + `","type":"` + eventType + `"}`
+		req := httptest.NewRequest("POST", "/telemetry/"+projectID.String(), strings.NewReader(body))
+		if clientID != "" {
+			req.Header.Set(utils.EASClientIDHeader, clientID)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("%s from %q: expected 202, got %d", eventType, clientID, rr.Code)
+		}
+	}
+
+	// One client repeating crash reports adds a single crash.
+	for i := 0; i < 200; i++ {
+		report("crash", "device-1")
+	}
+	if db.recorded != 1 {
+		t.Fatalf("200 crash reports from one device recorded %d events, want 1", db.recorded)
+	}
+
+	report("launch_success", "device-1")
+	for i := 0; i < 5; i++ {
+		report("crash", "")
+	}
+	report("crash", "device-2")
+	if db.recorded != 4 {
+		t.Errorf("recorded %d events, want 4: one per device and event type", db.recorded)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"math"
 	"net/http"
@@ -11,15 +12,20 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
 	"github.com/vknow360/otaship/backend/internal/database"
-	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/rollout"
 	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
 )
 
 type CreateUpdateParams struct {
-	ProjectID         string `json:"project_id"`
-	RuntimeVersion    string `json:"runtime_version"`
+	ProjectID      string `json:"project_id"`
+	RuntimeVersion string `json:"runtime_version"`
+	// Channel publishes to the branch the channel points at. Branch, when
+	// set, publishes to that branch directly.
 	Channel           string `json:"channel"`
+	Branch            string `json:"branch"`
 	RolloutPercentage int32  `json:"rollout_percentage"`
 	Platform          string `json:"platform"`
 	IsRollback        bool   `json:"is_rollback"`
@@ -31,6 +37,7 @@ type UpdateResponse struct {
 	ProjectID         string `json:"project_id"`
 	RuntimeVersion    string `json:"runtime_version"`
 	Channel           string `json:"channel"`
+	Branch            string `json:"branch"`
 	RolloutPercentage int32  `json:"rollout_percentage"`
 	Platform          string `json:"platform"`
 	IsActive          bool   `json:"is_active"`
@@ -38,14 +45,22 @@ type UpdateResponse struct {
 	Message           string `json:"message"`
 	CreatedAt         int64  `json:"created_at"`
 	DownloadCount     int64  `json:"download_count"`
+	// AssetURLsExpireAt is when the asset URLs of a publish-time signed
+	// manifest expire. Devices are told there is no update after that.
+	AssetURLsExpireAt int64 `json:"asset_urls_expire_at,omitempty"`
 }
 
 func toUpdateResponse(u database.Update, count int64) UpdateResponse {
+	var expiresAt int64
+	if t := signedURLsExpireAt(u); !t.IsZero() {
+		expiresAt = t.UnixMilli()
+	}
 	return UpdateResponse{
 		ID:                u.ID.String(),
 		ProjectID:         u.ProjectID.String(),
 		RuntimeVersion:    u.RuntimeVersion,
 		Channel:           u.Channel,
+		Branch:            u.Channel,
 		RolloutPercentage: u.RolloutPercentage,
 		Platform:          u.Platform,
 		IsActive:          u.IsActive,
@@ -53,6 +68,7 @@ func toUpdateResponse(u database.Update, count int64) UpdateResponse {
 		Message:           u.Message.String,
 		CreatedAt:         u.CreatedAt.Time.UnixMilli(),
 		DownloadCount:     count,
+		AssetURLsExpireAt: expiresAt,
 	}
 }
 
@@ -195,7 +211,7 @@ func ListProjectUpdates(queries *database.Queries) http.HandlerFunc {
 }
 
 // Admin-scoped: delete any update
-func DeleteUpdate(queries *database.Queries, providers map[string]storage.Provider) http.HandlerFunc {
+func DeleteUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "update_id")
 		if id == "" {
@@ -214,29 +230,10 @@ func DeleteUpdate(queries *database.Queries, providers map[string]storage.Provid
 			jsonError(w, "Update not found", http.StatusNotFound)
 			return
 		}
+		audit.SetProject(r.Context(), updateRow.ProjectID)
 
-		updateAssets, _ := queries.GetAssetsByUpdateID(r.Context(), updateId)
-
-		for _, asset := range updateAssets {
-			count, err := queries.CountOtherAssetReferences(r.Context(), database.CountOtherAssetReferencesParams{
-				Key:      asset.Key,
-				UpdateID: updateId,
-			})
-
-			if err == nil && count == 0 {
-				targetProvider, exists := providers[asset.StorageProvider]
-				if !exists {
-					slog.WarnContext(r.Context(), "Storage provider not found", slog.String("key", asset.Key))
-					continue
-				}
-				go func(provider storage.Provider, k, mime string) {
-					_ = provider.Delete(context.Background(), k, mime)
-				}(targetProvider, asset.Key, asset.MimeType)
-			}
-		}
-
-		err = queries.DeleteUpdate(r.Context(), updateId)
-		if err != nil {
+		if err := deleteUpdateAndQueueAssets(r.Context(), pool, queries, updateRow); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete update", slog.String("update_id", id), slog.Any("error", err))
 			jsonError(w, "Failed to delete update", http.StatusInternalServerError)
 			return
 		}
@@ -247,7 +244,7 @@ func DeleteUpdate(queries *database.Queries, providers map[string]storage.Provid
 }
 
 // Project-scoped: delete update owned by the authenticated project
-func DeleteProjectUpdate(queries *database.Queries, providers map[string]storage.Provider) http.HandlerFunc {
+func DeleteProjectUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "update_id")
 		if id == "" {
@@ -270,29 +267,12 @@ func DeleteProjectUpdate(queries *database.Queries, providers map[string]storage
 			jsonError(w, "Update does not belong to this project", http.StatusForbidden)
 			return
 		}
-
-		updateAssets, _ := queries.GetAssetsByUpdateID(r.Context(), updateId)
-
-		for _, asset := range updateAssets {
-			count, err := queries.CountOtherAssetReferences(r.Context(), database.CountOtherAssetReferencesParams{
-				Key:      asset.Key,
-				UpdateID: updateId,
-			})
-
-			if err == nil && count == 0 {
-				targetProvider, exists := providers[asset.StorageProvider]
-				if !exists {
-					slog.WarnContext(r.Context(), "Storage provider not found", slog.String("key", asset.Key))
-					continue
-				}
-				go func(provider storage.Provider, k, mime string) {
-					_ = provider.Delete(context.Background(), k, mime)
-				}(targetProvider, asset.Key, asset.MimeType)
-			}
+		if !keyAllowsChannel(w, r, update.Channel) {
+			return
 		}
 
-		err = queries.DeleteUpdate(r.Context(), updateId)
-		if err != nil {
+		if err := deleteUpdateAndQueueAssets(r.Context(), pool, queries, update); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete update", slog.String("update_id", id), slog.Any("error", err))
 			jsonError(w, "Failed to delete update", http.StatusInternalServerError)
 			return
 		}
@@ -302,6 +282,44 @@ func DeleteProjectUpdate(queries *database.Queries, providers map[string]storage
 	}
 }
 
+// deleteUpdateAndQueueAssets removes the update and hands its storage
+// objects to the garbage collector, which deletes them only once no other
+// asset row (for example a rollback clone) still points at the same key.
+func deleteUpdateAndQueueAssets(ctx context.Context, pool *pgxpool.Pool, queries *database.Queries, update database.Update) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.QueueUpdateAssetsForGC(ctx, update.ID); err != nil {
+		return err
+	}
+	if err := qtx.DeleteUpdate(ctx, update.ID); err != nil {
+		return err
+	}
+	if err := webhook.Enqueue(ctx, qtx, webhook.EventUpdateDeleted, update, nil); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// validPublishTarget checks the branch and channel a publish names. Either
+// may be empty.
+func validPublishTarget(w http.ResponseWriter, branch, channel string) bool {
+	if branch != "" && !channelNameRegex.MatchString(branch) {
+		jsonError(w, "Invalid branch name. Must start with a letter or number and contain only letters, numbers, hyphens, and underscores, with a maximum length of 32 characters.", http.StatusBadRequest)
+		return false
+	}
+	if channel != "" && !channelNameRegex.MatchString(channel) {
+		jsonError(w, "Invalid channel name. Must start with a letter or number and contain only letters, numbers, hyphens, and underscores, with a maximum length of 32 characters.", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var update CreateUpdateParams
@@ -312,7 +330,7 @@ func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFun
 			return
 		}
 
-		if update.ProjectID == "" || update.Channel == "" || update.Platform == "" || update.RuntimeVersion == "" {
+		if update.ProjectID == "" || (update.Channel == "" && update.Branch == "") || update.Platform == "" || update.RuntimeVersion == "" {
 			jsonError(w, "Missing required fields", http.StatusBadRequest)
 			return
 		}
@@ -326,8 +344,10 @@ func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFun
 			return
 		}
 
-		if matched := channelNameRegex.Match([]byte(update.Channel)); !matched {
-			jsonError(w, "Invalid channel name. Must start with a letter or number and contain only letters, numbers, hyphens, and underscores, with a maximum length of 32 characters.", http.StatusBadRequest)
+		if !validPublishTarget(w, update.Branch, update.Channel) {
+			return
+		}
+		if !keyAllowsTargets(w, r, update.Channel, update.Branch) {
 			return
 		}
 		if matched := runtimeVersionRegex.Match([]byte(update.RuntimeVersion)); !matched {
@@ -358,10 +378,20 @@ func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFun
 			messageText = pgtype.Text{String: update.Message, Valid: true}
 		}
 
+		branch, err := publishBranch(r.Context(), qtx, projectId, update.Branch, update.Channel)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to resolve branch", slog.Any("error", err))
+			jsonError(w, "Failed to resolve branch", http.StatusInternalServerError)
+			return
+		}
+		if !keyAllowsTargets(w, r, branch) {
+			return
+		}
+
 		createUpdate, err := qtx.CreateUpdate(r.Context(), database.CreateUpdateParams{
 			ProjectID:         projectId,
 			RuntimeVersion:    update.RuntimeVersion,
-			Channel:           update.Channel,
+			Channel:           branch,
 			RolloutPercentage: update.RolloutPercentage,
 			Platform:          update.Platform,
 			IsActive:          false,
@@ -372,6 +402,10 @@ func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFun
 			jsonError(w, "Failed to create update", http.StatusInternalServerError)
 			return
 		}
+		if err := webhook.Enqueue(r.Context(), qtx, webhook.EventUpdatePublished, createUpdate, nil); err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
 
 		err = tx.Commit(r.Context())
 		if err != nil {
@@ -379,6 +413,8 @@ func CreateUpdate(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFun
 			return
 		}
 
+		audit.SetTarget(r.Context(), "update_id", createUpdate.ID.String())
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(toUpdateResponse(createUpdate, 0))
@@ -389,7 +425,9 @@ type UpdateRolloutRequest struct {
 	RolloutPercentage int32 `json:"rollout_percentage"`
 }
 
-func UpdateRolloutPercentage(queries *database.Queries) http.HandlerFunc {
+// UpdateRolloutPercentage sets an update's rollout by hand. A running
+// schedule on the update is paused so the scheduler does not override it.
+func UpdateRolloutPercentage(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "update_id")
 		if id == "" {
@@ -413,7 +451,23 @@ func UpdateRolloutPercentage(queries *database.Queries) http.HandlerFunc {
 			return
 		}
 
-		err = queries.UpdateRolloutPercentage(r.Context(), database.UpdateRolloutPercentageParams{
+		update, err := queries.GetUpdateByID(r.Context(), updateId)
+		if err != nil {
+			jsonError(w, "Update not found", http.StatusNotFound)
+			return
+		}
+		audit.SetProject(r.Context(), update.ProjectID)
+
+		tx, err := pool.Begin(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to start transaction", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback(r.Context())
+
+		qtx := queries.WithTx(tx)
+
+		err = qtx.UpdateRolloutPercentage(r.Context(), database.UpdateRolloutPercentageParams{
 			ID:                updateId,
 			RolloutPercentage: updateRollout.RolloutPercentage,
 		})
@@ -421,6 +475,54 @@ func UpdateRolloutPercentage(queries *database.Queries) http.HandlerFunc {
 			jsonError(w, "Failed to update rollout percentage", http.StatusInternalServerError)
 			return
 		}
+
+		paused, err := qtx.PauseRunningRolloutSchedule(r.Context(), updateId)
+		if err != nil {
+			jsonError(w, "Failed to pause rollout schedule", http.StatusInternalServerError)
+			return
+		}
+
+		err = qtx.CreateRolloutEvent(r.Context(), database.CreateRolloutEventParams{
+			UpdateID:       updateId,
+			Action:         rollout.ActionManual,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   updateRollout.RolloutPercentage,
+			Actor:          rolloutActor(r),
+		})
+		if err != nil {
+			jsonError(w, "Failed to record rollout event", http.StatusInternalServerError)
+			return
+		}
+
+		changed := update
+		changed.RolloutPercentage = updateRollout.RolloutPercentage
+		err = webhook.Enqueue(r.Context(), qtx, webhook.EventRolloutChanged, changed, webhook.RolloutChange{
+			Action:         rollout.ActionManual,
+			FromPercentage: update.RolloutPercentage,
+			ToPercentage:   updateRollout.RolloutPercentage,
+		})
+		if err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
+
+		err = tx.Commit(r.Context())
+		if err != nil {
+			jsonError(w, "Failed to commit transaction", http.StatusInternalServerError)
+			return
+		}
+		audit.SetChange(r.Context(),
+			UpdateRolloutRequest{RolloutPercentage: update.RolloutPercentage},
+			updateRollout,
+		)
+
+		go InvalidateManifestCache(update.ProjectID.String())
+
+		if paused > 0 {
+			slog.InfoContext(r.Context(), "Rollout schedule paused by manual change",
+				slog.String("update_id", id),
+			)
+		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -450,6 +552,9 @@ func CreateRollback(pool *pgxpool.Pool, queries *database.Queries) http.HandlerF
 			jsonError(w, "Update does not belong to this project", http.StatusForbidden)
 			return
 		}
+		if !keyAllowsChannel(w, r, original.Channel) {
+			return
+		}
 
 		tx, err := pool.Begin(r.Context())
 		if err != nil {
@@ -460,53 +565,20 @@ func CreateRollback(pool *pgxpool.Pool, queries *database.Queries) http.HandlerF
 
 		qtx := queries.WithTx(tx)
 
-		err = qtx.DeactivateUpdates(r.Context(), database.DeactivateUpdatesParams{
-			ProjectID:      original.ProjectID,
-			Channel:        original.Channel,
-			RuntimeVersion: original.RuntimeVersion,
-			Platform:       original.Platform,
-		})
+		rollback, err := rollbackToUpdate(r.Context(), qtx, original, "Rollback to "+updateIdStr)
 		if err != nil {
-			slog.ErrorContext(r.Context(), "Failed to deactivate updates during rollback",
+			slog.ErrorContext(r.Context(), "Failed to create rollback",
 				slog.String("update_id", updateIdStr),
 				slog.Any("error", err),
 			)
-			jsonError(w, "Failed to deactivate updates", http.StatusInternalServerError)
-			return
-		}
-
-		rollback, err := qtx.CreateUpdate(r.Context(), database.CreateUpdateParams{
-			ProjectID:         original.ProjectID,
-			RuntimeVersion:    original.RuntimeVersion,
-			Channel:           original.Channel,
-			RolloutPercentage: 100,
-			Platform:          original.Platform,
-			IsActive:          true,
-			IsRollback:        false,
-			Message:           pgtype.Text{String: "Rollback to " + updateIdStr, Valid: true},
-		})
-		if err != nil {
 			jsonError(w, "Failed to create rollback", http.StatusInternalServerError)
 			return
 		}
-
-		if original.ExpoConfig != nil {
-			err = qtx.UpdateExpoConfig(r.Context(), database.UpdateExpoConfigParams{
-				ExpoConfig: original.ExpoConfig,
-				ID:         rollback.ID,
-			})
-			if err != nil {
-				jsonError(w, "Failed to clone expo config", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		err = qtx.CloneAssets(r.Context(), database.CloneAssetsParams{
-			SourceUpdateID: updateId,
-			TargetUpdateID: rollback.ID,
+		err = webhook.Enqueue(r.Context(), qtx, webhook.EventUpdateRolledBack, rollback, webhook.Rollback{
+			RolledBackTo: original.ID.String(),
 		})
 		if err != nil {
-			jsonError(w, "Failed to clone assets", http.StatusInternalServerError)
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
 			return
 		}
 
@@ -517,6 +589,8 @@ func CreateRollback(pool *pgxpool.Pool, queries *database.Queries) http.HandlerF
 		}
 
 		go InvalidateManifestCache(original.ProjectID.String())
+		audit.SetProject(r.Context(), original.ProjectID)
+		audit.SetTarget(r.Context(), "rollback_update_id", rollback.ID.String())
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
@@ -553,6 +627,7 @@ type RollbackToEmbeddedRequest struct {
 	Platform       string `json:"platform"`
 	RuntimeVersion string `json:"runtime_version"`
 	Channel        string `json:"channel"`
+	Branch         string `json:"branch"`
 }
 
 func CreateRollbackToEmbedded(pool *pgxpool.Pool, queries *database.Queries) http.HandlerFunc {
@@ -575,8 +650,14 @@ func CreateRollbackToEmbedded(pool *pgxpool.Pool, queries *database.Queries) htt
 			return
 		}
 
-		if req.Platform == "" || req.RuntimeVersion == "" || req.Channel == "" {
-			jsonError(w, "Platform, runtime version, and channel are required", http.StatusBadRequest)
+		if req.Platform == "" || req.RuntimeVersion == "" || (req.Channel == "" && req.Branch == "") {
+			jsonError(w, "Platform, runtime version, and channel or branch are required", http.StatusBadRequest)
+			return
+		}
+		if !validPublishTarget(w, req.Branch, req.Channel) {
+			return
+		}
+		if !keyAllowsTargets(w, r, req.Channel, req.Branch) {
 			return
 		}
 
@@ -589,31 +670,24 @@ func CreateRollbackToEmbedded(pool *pgxpool.Pool, queries *database.Queries) htt
 
 		qtx := queries.WithTx(tx)
 
-		err = qtx.DeactivateUpdates(r.Context(), database.DeactivateUpdatesParams{
-			ProjectID:      projectId,
-			Channel:        req.Channel,
-			RuntimeVersion: req.RuntimeVersion,
-			Platform:       req.Platform,
-		})
+		branch, err := publishBranch(r.Context(), qtx, projectId, req.Branch, req.Channel)
 		if err != nil {
-			jsonError(w, "Failed to deactivate updates", http.StatusInternalServerError)
+			jsonError(w, "Failed to resolve branch", http.StatusInternalServerError)
+			return
+		}
+		if !keyAllowsTargets(w, r, branch) {
 			return
 		}
 
-		rollback, err := qtx.CreateUpdate(r.Context(), database.CreateUpdateParams{
-			ProjectID:         projectId,
-			RuntimeVersion:    req.RuntimeVersion,
-			Channel:           req.Channel,
-			RolloutPercentage: 100,
-			Platform:          req.Platform,
-			IsActive:          true,
-			IsRollback:        true,
-			Message:           pgtype.Text{String: "Rollback to embedded", Valid: true},
-		})
+		rollback, err := rollbackToEmbedded(r.Context(), qtx, projectId, branch, req.RuntimeVersion, req.Platform, "Rollback to embedded")
 		if err != nil {
 			jsonError(w, "Failed to create rollback", http.StatusInternalServerError)
 			return
 		}
+		if err := webhook.Enqueue(r.Context(), qtx, webhook.EventUpdateRolledBack, rollback, webhook.Rollback{}); err != nil {
+			jsonError(w, "Failed to queue webhooks", http.StatusInternalServerError)
+			return
+		}
 
 		err = tx.Commit(r.Context())
 		if err != nil {
@@ -622,9 +696,86 @@ func CreateRollbackToEmbedded(pool *pgxpool.Pool, queries *database.Queries) htt
 		}
 
 		go InvalidateManifestCache(projectId.String())
+		audit.SetTarget(r.Context(), "rollback_update_id", rollback.ID.String())
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(toUpdateResponse(rollback, 0))
 	}
 }
+
+// rollbackToUpdate makes a copy of original the active update on its branch,
+// reusing its stored assets. It runs inside the caller's transaction.
+func rollbackToUpdate(ctx context.Context, qtx *database.Queries, original database.Update, message string) (database.Update, error) {
+	err := qtx.DeactivateUpdates(ctx, database.DeactivateUpdatesParams{
+		ProjectID:      original.ProjectID,
+		Channel:        original.Channel,
+		RuntimeVersion: original.RuntimeVersion,
+		Platform:       original.Platform,
+	})
+	if err != nil {
+		return database.Update{}, fmt.Errorf("failed to deactivate updates: %w", err)
+	}
+
+	rollback, err := qtx.CreateUpdate(ctx, database.CreateUpdateParams{
+		ProjectID:         original.ProjectID,
+		RuntimeVersion:    original.RuntimeVersion,
+		Channel:           original.Channel,
+		RolloutPercentage: 100,
+		Platform:          original.Platform,
+		IsActive:          true,
+		IsRollback:        false,
+		Message:           pgtype.Text{String: message, Valid: true},
+	})
+	if err != nil {
+		return database.Update{}, fmt.Errorf("failed to create rollback: %w", err)
+	}
+
+	if original.ExpoConfig != nil {
+		err = qtx.UpdateExpoConfig(ctx, database.UpdateExpoConfigParams{
+			ExpoConfig: original.ExpoConfig,
+			ID:         rollback.ID,
+		})
+		if err != nil {
+			return database.Update{}, fmt.Errorf("failed to clone expo config: %w", err)
+		}
+	}
+
+	err = qtx.CloneAssets(ctx, database.CloneAssetsParams{
+		SourceUpdateID: original.ID,
+		TargetUpdateID: rollback.ID,
+	})
+	if err != nil {
+		return database.Update{}, fmt.Errorf("failed to clone assets: %w", err)
+	}
+	return rollback, nil
+}
+
+// rollbackToEmbedded makes a rollBackToEmbedded directive the active update
+// on a branch. It runs inside the caller's transaction.
+func rollbackToEmbedded(ctx context.Context, qtx *database.Queries, projectId pgtype.UUID, branch, runtimeVersion, platform, message string) (database.Update, error) {
+	err := qtx.DeactivateUpdates(ctx, database.DeactivateUpdatesParams{
+		ProjectID:      projectId,
+		Channel:        branch,
+		RuntimeVersion: runtimeVersion,
+		Platform:       platform,
+	})
+	if err != nil {
+		return database.Update{}, fmt.Errorf("failed to deactivate updates: %w", err)
+	}
+
+	rollback, err := qtx.CreateUpdate(ctx, database.CreateUpdateParams{
+		ProjectID:         projectId,
+		RuntimeVersion:    runtimeVersion,
+		Channel:           branch,
+		RolloutPercentage: 100,
+		Platform:          platform,
+		IsActive:          true,
+		IsRollback:        true,
+		Message:           pgtype.Text{String: message, Valid: true},
+	})
+	if err != nil {
+		return database.Update{}, fmt.Errorf("failed to create rollback: %w", err)
+	}
+	return rollback, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vknow360/otaship/backend/internal/auth"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+func TestPublishChecksEveryTargetName(t *testing.T) {
+	projectId := "0b8f8a4e-3f3e-4a43-9d4a-6f1f0c4c2d11"
+	project, _ := utils.ParseUUID(projectId)
+	key := auth.APIKey{Scopes: auth.AllScopes, Channels: []string{"staging"}}
+
+	// A key limited to staging must not reach production by naming staging
+	// as the channel. Both requests are refused before the database is used.
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := auth.WithAPIKey(utils.SetProjectId(r.Context(), project), key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Post("/updates", CreateUpdate(nil, nil))
+	r.Post("/projects/{project_id}/rollback-to-embedded", CreateRollbackToEmbedded(nil, nil))
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/updates", `{"project_id":"` + projectId + `","channel":"staging","branch":"production","platform":"ios","runtime_version":"1.0.0"}`},
+		{"/projects/" + projectId + "/rollback-to-embedded", `{"channel":"staging","branch":"production","platform":"ios","runtime_version":"1.0.0"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("POST %s: expected 403, got %d: %s", tt.path, rr.Code, rr.Body.String())
+		}
+	}
+}
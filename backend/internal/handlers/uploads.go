@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+// Resumable uploads follow the core tus protocol (https://tus.io): a POST
+// creates a session for a known number of bytes, PATCH requests append
+// chunks at the offset the server reports, and HEAD returns that offset
+// after an interruption. Once every byte has arrived, a POST to finalize
+// publishes the bundle exactly like UploadAsset.
+const (
+	TusVersion = "1.0.0"
+	// UploadSessionTTL is how long an unfinished upload can be resumed.
+	UploadSessionTTL = 24 * time.Hour
+	// chunkContentType is the only content type tus accepts for PATCH.
+	chunkContentType = "application/offset+octet-stream"
+)
+
+type UploadSessionResponse struct {
+	ID           string `json:"id"`
+	UpdateID     string `json:"update_id"`
+	Platform     string `json:"platform"`
+	Activate     bool   `json:"activate"`
+	UploadLength int64  `json:"upload_length"`
+	UploadOffset int64  `json:"upload_offset"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+func toUploadSessionResponse(s database.UploadSession) UploadSessionResponse {
+	return UploadSessionResponse{
+		ID:           s.ID.String(),
+		UpdateID:     s.UpdateID.String(),
+		Platform:     s.Platform,
+		Activate:     s.Activate,
+		UploadLength: s.UploadLength,
+		UploadOffset: s.UploadOffset,
+		ExpiresAt:    s.ExpiresAt.Time.UnixMilli(),
+	}
+}
+
+// parseUploadMetadata decodes a tus Upload-Metadata header: comma-separated
+// pairs of a key and an optional base64 value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+		}
+		meta[key] = string(value)
+	}
+	return meta, nil
+}
+
+// uploadSessionFile is where the bytes of an upload session are written.
+func uploadSessionFile(dir string, id pgtype.UUID) string {
+	return filepath.Join(dir, id.String()+".part")
+}
+
+func setUploadHeaders(w http.ResponseWriter, s database.UploadSession) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(s.UploadOffset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(s.UploadLength, 10))
+	w.Header().Set("Upload-Expires", s.ExpiresAt.Time.UTC().Format(http.TimeFormat))
+}
+
+// uploadSessionFromRequest loads the {upload_id} session of the update an
+// upload request is for.
+func uploadSessionFromRequest(w http.ResponseWriter, r *http.Request, queries *database.Queries) (database.Project, database.Update, database.UploadSession, bool) {
+	project, update, ok := uploadTarget(w, r, queries)
+	if !ok {
+		return database.Project{}, database.Update{}, database.UploadSession{}, false
+	}
+
+	uploadId, err := utils.ParseUUID(chi.URLParam(r, "upload_id"))
+	if err != nil {
+		jsonError(w, "Invalid upload ID", http.StatusBadRequest)
+		return database.Project{}, database.Update{}, database.UploadSession{}, false
+	}
+
+	session, err := queries.GetUploadSession(r.Context(), database.GetUploadSessionParams{
+		ID:       uploadId,
+		UpdateID: update.ID,
+	})
+	if err != nil {
+		jsonError(w, "Upload session not found", http.StatusNotFound)
+		return database.Project{}, database.Update{}, database.UploadSession{}, false
+	}
+	return project, update, session, true
+}
+
+// CreateUploadSession starts a resumable upload. The bundle size comes from
+// the Upload-Length header and the platform and activate form values of
+// UploadAsset from Upload-Metadata.
+func CreateUploadSession(queries *database.Queries, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project, update, ok := uploadTarget(w, r, queries)
+		if !ok {
+			return
+		}
+
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length <= 0 {
+			jsonError(w, "Upload-Length header is required", http.StatusBadRequest)
+			return
+		}
+		if limit := uploads.maxSize(project); length > limit {
+			uploadTooLarge(w, limit)
+			return
+		}
+
+		meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+		if err != nil {
+			jsonError(w, "Invalid Upload-Metadata header", http.StatusBadRequest)
+			return
+		}
+		platform := meta["platform"]
+		if platform != "ios" && platform != "android" {
+			jsonError(w, "Upload-Metadata must include platform ios or android", http.StatusBadRequest)
+			return
+		}
+
+		session, err := queries.CreateUploadSession(r.Context(), database.CreateUploadSessionParams{
+			UpdateID:     update.ID,
+			Platform:     platform,
+			Activate:     meta["activate"] != "false",
+			UploadLength: length,
+			ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(UploadSessionTTL), Valid: true},
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create upload session", slog.Any("error", err))
+			jsonError(w, "Failed to create upload session", http.StatusInternalServerError)
+			return
+		}
+
+		os.MkdirAll(uploads.Dir, 0755)
+		f, err := os.Create(uploadSessionFile(uploads.Dir, session.ID))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create upload file", slog.Any("error", err))
+			queries.DeleteUploadSession(r.Context(), session.ID)
+			jsonError(w, "Failed to create upload file", http.StatusInternalServerError)
+			return
+		}
+		f.Close()
+
+		audit.SetTarget(r.Context(), "upload_id", session.ID.String())
+
+		setUploadHeaders(w, session)
+		w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+session.ID.String())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(toUploadSessionResponse(session))
+	}
+}
+
+// GetUploadSession answers the HEAD request a client makes to learn where
+// to resume an upload.
+func GetUploadSession(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _, session, ok := uploadSessionFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		setUploadHeaders(w, session)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PatchUploadSession appends a chunk at the Upload-Offset the client sends,
+// which must match the offset of the session. Whatever part of the chunk
+// arrives is kept, so a dropped connection only loses the unsent rest.
+func PatchUploadSession(queries *database.Queries, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _, session, ok := uploadSessionFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("Content-Type") != chunkContentType {
+			jsonError(w, "Content-Type must be "+chunkContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil {
+			jsonError(w, "Upload-Offset header is required", http.StatusBadRequest)
+			return
+		}
+		if offset != session.UploadOffset {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+			jsonError(w, "Upload-Offset does not match the upload", http.StatusConflict)
+			return
+		}
+
+		f, err := os.OpenFile(uploadSessionFile(uploads.Dir, session.ID), os.O_WRONLY, 0)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to open upload file", slog.Any("error", err))
+			jsonError(w, "Failed to open upload file", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		body := http.MaxBytesReader(w, r.Body, session.UploadLength-offset)
+		written, copyErr := io.Copy(io.NewOffsetWriter(f, offset), body)
+
+		if written > 0 {
+			rows, err := queries.AdvanceUploadSession(r.Context(), database.AdvanceUploadSessionParams{
+				NewOffset:    offset + written,
+				ID:           session.ID,
+				UploadOffset: offset,
+			})
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to record upload offset", slog.Any("error", err))
+				jsonError(w, "Failed to record upload offset", http.StatusInternalServerError)
+				return
+			}
+			if rows == 0 {
+				jsonError(w, "The upload was changed by another request", http.StatusConflict)
+				return
+			}
+		}
+
+		if copyErr != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(copyErr, &tooLarge) {
+				jsonError(w, "Chunk exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+				return
+			}
+			slog.WarnContext(r.Context(), "Upload chunk interrupted",
+				slog.String("upload_id", session.ID.String()),
+				slog.Int64("written", written),
+				slog.Any("error", copyErr),
+			)
+			jsonError(w, "Failed to read chunk", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Tus-Resumable", TusVersion)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset+written, 10))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteUploadSession abandons an upload and removes what was sent.
+func DeleteUploadSession(queries *database.Queries, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, _, session, ok := uploadSessionFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		if err := queries.DeleteUploadSession(r.Context(), session.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete upload session", slog.Any("error", err))
+			jsonError(w, "Failed to delete upload session", http.StatusInternalServerError)
+			return
+		}
+		os.Remove(uploadSessionFile(uploads.Dir, session.ID))
+
+		w.Header().Set("Tus-Resumable", TusVersion)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// FinalizeUploadSession publishes a completely uploaded bundle. The session
+// is kept when publishing fails so that finalize can be retried.
+func FinalizeUploadSession(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, uploads UploadConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project, update, session, ok := uploadSessionFromRequest(w, r, queries)
+		if !ok {
+			return
+		}
+		if session.UploadOffset != session.UploadLength {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+			jsonError(w, fmt.Sprintf("Upload is incomplete: %d of %d bytes received", session.UploadOffset, session.UploadLength), http.StatusConflict)
+			return
+		}
+
+		path := uploadSessionFile(uploads.Dir, session.ID)
+		f, err := os.Open(path)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to open upload file", slog.Any("error", err))
+			jsonError(w, "Failed to open upload file", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		if !publishBundle(w, r, pool, queries, providers, project, update, f, session.UploadLength, session.Platform, session.Activate) {
+			return
+		}
+
+		if err := queries.DeleteUploadSession(r.Context(), session.ID); err != nil {
+			slog.WarnContext(r.Context(), "Failed to delete finished upload session", slog.Any("error", err))
+		}
+		os.Remove(path)
+	}
+}
+
+// CleanupUploadSessions deletes expired upload sessions along with their
+// files, and any upload file left behind without a session. It returns how
+// many sessions were removed.
+func CleanupUploadSessions(ctx context.Context, queries *database.Queries, dir string) (int, error) {
+	now := time.Now()
+	ids, err := queries.DeleteExpiredUploadSessions(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		os.Remove(uploadSessionFile(dir, id))
+	}
+
+	// Live sessions are never older than the TTL, so older files are
+	// leftovers from sessions deleted while their file was still open.
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return len(ids), nil
+		}
+		return len(ids), err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".part" {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && now.Sub(info.ModTime()) > UploadSessionTTL {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"platform aW9z,activate ZmFsc2U=", map[string]string{"platform": "ios", "activate": "false"}, false},
+		{" platform YW5kcm9pZA== , is_final", map[string]string{"platform": "android", "is_final": ""}, false},
+		{"platform not-base64!", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseUploadMetadata(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseUploadMetadata(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseUploadMetadata(%q) = %v, want %v", tt.header, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseUploadMetadata(%q)[%q] = %q, want %q", tt.header, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestUploadConfigMaxSize(t *testing.T) {
+	cfg := UploadConfig{MaxSize: 50 << 20}
+
+	if got := cfg.maxSize(database.Project{}); got != 50<<20 {
+		t.Errorf("Project without a limit got %d", got)
+	}
+	project := database.Project{MaxUploadSize: pgtype.Int8{Int64: 200 << 20, Valid: true}}
+	if got := cfg.maxSize(project); got != 200<<20 {
+		t.Errorf("Project with a 200 MB limit got %d", got)
+	}
+}
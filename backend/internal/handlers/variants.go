@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+)
+
+// assetEncoding is a Content-Encoding compressed variants are stored in.
+type assetEncoding struct {
+	Name string
+	// Suffix is appended to the asset's key to get the variant's key.
+	Suffix   string
+	compress func(io.Writer) io.WriteCloser
+}
+
+// assetEncodings are listed in order of preference when a client accepts
+// several equally.
+var assetEncodings = []assetEncoding{
+	{"br", ".br", func(w io.Writer) io.WriteCloser { return brotli.NewWriterLevel(w, 9) }},
+	{"gzip", ".gz", func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	}},
+}
+
+// minVariantSavings is the fraction of the original size a variant must
+// save to be kept.
+const minVariantSavings = 0.1
+
+// compressibleAsset reports whether an asset is text-like, or the launch
+// bundle, and worth storing compressed variants of.
+func compressibleAsset(asset UploadedAsset) bool {
+	if isLaunchAsset(asset.FileName) {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(asset.ContentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/json", "application/xml", "application/wasm":
+		return true
+	}
+	return false
+}
+
+// storeAssetVariants stores gzip and brotli variants of the newly uploaded
+// text-like assets. It reads each blob back from storage, so it can run
+// after the publish has responded. Providers that cannot serve a
+// Content-Encoding are skipped.
+func storeAssetVariants(queries *database.Queries, provider storage.Provider, assets []UploadedAsset) {
+	uploader, canEncode := provider.(storage.EncodedUploader)
+	downloader, canRead := provider.(storage.Downloader)
+	if !canEncode || !canRead {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	for _, asset := range assets {
+		if !compressibleAsset(asset) {
+			continue
+		}
+		if err := storeVariantsOf(ctx, queries, provider.Name(), uploader, downloader, asset); err != nil {
+			slog.WarnContext(ctx, "Failed to store compressed asset variants",
+				slog.String("key", asset.StorageKey),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+func storeVariantsOf(ctx context.Context, queries *database.Queries, providerName string, uploader storage.EncodedUploader, downloader storage.Downloader, asset UploadedAsset) error {
+	body, err := downloader.Download(ctx, asset.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, encoding := range assetEncodings {
+		compressed, err := compressVariant(data, encoding)
+		if err != nil {
+			return err
+		}
+		if float64(len(compressed)) > float64(len(data))*(1-minVariantSavings) {
+			continue
+		}
+
+		variantKey := asset.StorageKey + encoding.Suffix
+		url, err := uploader.UploadEncoded(ctx, variantKey, bytes.NewReader(compressed), asset.ContentType, encoding.Name, int64(len(compressed)))
+		if err != nil {
+			return err
+		}
+		err = queries.CreateAssetVariant(ctx, database.CreateAssetVariantParams{
+			StorageProvider: providerName,
+			Key:             asset.StorageKey,
+			Encoding:        encoding.Name,
+			VariantKey:      variantKey,
+			Url:             url,
+			Size:            int64(len(compressed)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compressVariant(data []byte, encoding assetEncoding) ([]byte, error) {
+	var buf bytes.Buffer
+	w := encoding.compress(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// acceptedEncodings returns the variant encodings an Accept-Encoding header
+// allows, most preferred first.
+func acceptedEncodings(header string) []assetEncoding {
+	quality := make(map[string]float64)
+	for _, value := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(value, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var accepted []assetEncoding
+	for _, encoding := range assetEncodings {
+		q, ok := quality[encoding.Name]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > 0 {
+			accepted = append(accepted, encoding)
+		}
+	}
+	// Equal qualities keep the order of assetEncodings.
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return qualityOf(quality, accepted[i]) > qualityOf(quality, accepted[j])
+	})
+	return accepted
+}
+
+func qualityOf(quality map[string]float64, encoding assetEncoding) float64 {
+	if q, ok := quality[encoding.Name]; ok {
+		return q
+	}
+	return quality["*"]
+}
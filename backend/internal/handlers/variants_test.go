@@ -0,0 +1,51 @@
+package handlers
+
+import "testing"
+
+func TestAcceptedEncodings(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"gzip, deflate, br", []string{"br", "gzip"}},
+		{"gzip;q=1.0, br;q=0.5", []string{"gzip", "br"}},
+		{"br;q=0, *", []string{"gzip"}},
+		{"identity", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, encoding := range acceptedEncodings(tt.header) {
+			got = append(got, encoding.Name)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("acceptedEncodings(%q) = %v, want %v", tt.header, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("acceptedEncodings(%q) = %v, want %v", tt.header, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCompressibleAsset(t *testing.T) {
+	tests := []struct {
+		asset UploadedAsset
+		want  bool
+	}{
+		{UploadedAsset{FileName: "_expo/static/js/ios/index.hbc", ContentType: "application/octet-stream"}, true},
+		{UploadedAsset{FileName: "assets/data", ContentType: "application/json"}, true},
+		{UploadedAsset{FileName: "assets/icon", ContentType: "image/svg+xml"}, true},
+		{UploadedAsset{FileName: "assets/readme", ContentType: "text/plain; charset=utf-8"}, true},
+		{UploadedAsset{FileName: "assets/logo", ContentType: "image/png"}, false},
+		{UploadedAsset{FileName: "assets/font", ContentType: "font/ttf"}, false},
+	}
+	for _, tt := range tests {
+		if got := compressibleAsset(tt.asset); got != tt.want {
+			t.Errorf("compressibleAsset(%s, %s) = %v, want %v", tt.asset.FileName, tt.asset.ContentType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/netguard"
+	"github.com/vknow360/otaship/backend/internal/utils"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+const (
+	minWebhookSecretLength = 16
+	defaultDeliveryLimit   = 50
+	maxDeliveryLimit       = 200
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Secret keys the HMAC signature of every delivery. One is generated
+	// when it is empty.
+	Secret string `json:"secret"`
+	// Events defaults to every event.
+	Events []string `json:"events"`
+}
+
+type WebhookResponse struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only returned when the webhook is created.
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func toWebhookResponse(h database.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        h.ID.String(),
+		URL:       h.Url,
+		Events:    h.Events,
+		CreatedAt: h.CreatedAt.Time.UnixMilli(),
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int64           `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus int32           `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  int64           `json:"next_attempt_at,omitempty"`
+	DeliveredAt    int64           `json:"delivered_at,omitempty"`
+	CreatedAt      int64           `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func toWebhookDeliveryResponse(d database.WebhookDelivery) WebhookDeliveryResponse {
+	res := WebhookDeliveryResponse{
+		ID:             d.ID,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus.Int32,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.Time.UnixMilli(),
+		Payload:        d.Payload,
+	}
+	if d.Status == webhook.StatusPending {
+		res.NextAttemptAt = d.NextAttemptAt.Time.UnixMilli()
+	}
+	if d.DeliveredAt.Valid {
+		res.DeliveredAt = d.DeliveredAt.Time.UnixMilli()
+	}
+	return res
+}
+
+// validWebhookURL reports whether raw is an absolute http or https URL whose
+// host is not localhost or a literal non-public address. Hostnames are
+// checked again when deliveries are dialed.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && netguard.AllowedHost(u.Hostname())
+}
+
+func CreateWebhook(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		var req CreateWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !validWebhookURL(req.URL) {
+			jsonError(w, "url must be an absolute http or https URL on a public host", http.StatusBadRequest)
+			return
+		}
+		events, err := webhook.ParseEvents(req.Events)
+		if err != nil {
+			jsonError(w, "Invalid events: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		secret := req.Secret
+		if secret == "" {
+			secret = "whsec_" + utils.GenerateAPIKey()
+		} else if len(secret) < minWebhookSecretLength {
+			jsonError(w, "secret must be at least 16 characters", http.StatusBadRequest)
+			return
+		}
+
+		hook, err := queries.CreateWebhook(r.Context(), database.CreateWebhookParams{
+			ProjectID: projectId,
+			Url:       req.URL,
+			Secret:    secret,
+			Events:    events,
+		})
+		if err != nil {
+			jsonError(w, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+
+		audit.SetTarget(r.Context(), "webhook_id", hook.ID.String())
+		audit.SetChange(r.Context(), nil, map[string]any{"url": hook.Url, "events": hook.Events})
+
+		res := toWebhookResponse(hook)
+		res.Secret = secret
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func ListWebhooks(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+		if err != nil {
+			jsonError(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		hooks, err := queries.ListWebhooks(r.Context(), projectId)
+		if err != nil {
+			jsonError(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+			return
+		}
+		res := make([]WebhookResponse, len(hooks))
+		for i, h := range hooks {
+			res[i] = toWebhookResponse(h)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func DeleteWebhook(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, hookId, ok := webhookParams(w, r)
+		if !ok {
+			return
+		}
+		deleted, err := queries.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+			ID:        hookId,
+			ProjectID: projectId,
+		})
+		if err != nil {
+			jsonError(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			jsonError(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first, with
+// the outcome of the latest attempt.
+func ListWebhookDeliveries(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		projectId, hookId, ok := webhookParams(w, r)
+		if !ok {
+			return
+		}
+		_, err := queries.GetWebhook(r.Context(), database.GetWebhookParams{
+			ID:        hookId,
+			ProjectID: projectId,
+		})
+		if err != nil {
+			jsonError(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+
+		limit := int32(defaultDeliveryLimit)
+		if l, err := utils.ParseInt32(r.URL.Query().Get("limit")); err == nil && l > 0 {
+			limit = min(l, maxDeliveryLimit)
+		}
+		offset := int32(0)
+		if o, err := utils.ParseInt32(r.URL.Query().Get("offset")); err == nil && o > 0 {
+			offset = o
+		}
+
+		deliveries, err := queries.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+			WebhookID: hookId,
+			Limit:     limit,
+			Offset:    offset,
+		})
+		if err != nil {
+			jsonError(w, "Failed to fetch webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+		total, err := queries.CountWebhookDeliveries(r.Context(), hookId)
+		if err != nil {
+			jsonError(w, "Failed to count webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+
+		res := make([]WebhookDeliveryResponse, len(deliveries))
+		for i, d := range deliveries {
+			res[i] = toWebhookDeliveryResponse(d)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"deliveries": res,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+		})
+	}
+}
+
+func webhookParams(w http.ResponseWriter, r *http.Request) (projectId, hookId pgtype.UUID, ok bool) {
+	projectId, err := utils.ParseUUID(chi.URLParam(r, "project_id"))
+	if err != nil {
+		jsonError(w, "Invalid project ID", http.StatusBadRequest)
+		return projectId, hookId, false
+	}
+	hookId, err = utils.ParseUUID(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		jsonError(w, "Invalid webhook ID", http.StatusBadRequest)
+		return projectId, hookId, false
+	}
+	return projectId, hookId, true
+}
@@ -7,32 +7,20 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"log/slog"
+	"maps"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/auth"
 	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/logger"
 	"github.com/vknow360/otaship/backend/internal/utils"
 )
 
-func AdminOnly(token string) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if after, ok := strings.CutPrefix(authHeader, "Bearer "); ok {
-				bearerToken := after
-				computed := utils.CalculateSHA256([]byte(bearerToken))
-				if subtle.ConstantTimeCompare([]byte(computed), []byte(token)) == 1 {
-					next.ServeHTTP(w, r)
-					return
-				}
-			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
-		})
-	}
-}
-
 func ProjectKeyOnly(queries *database.Queries) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,11 +60,267 @@ func ProjectKeyOnly(queries *database.Queries) func(next http.Handler) http.Hand
 				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid API key"})
 				return
 			}
+			if key.ExpiresAt.Valid && !key.ExpiresAt.Time.After(time.Now()) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "API key expired"})
+				return
+			}
 			go func() {
 				queries.UpdateAPIKeyLastUsed(context.Background(), key.ID)
 
 			}()
-			next.ServeHTTP(w, r.WithContext(utils.SetProjectId(r.Context(), key.ProjectID)))
+			ctx := utils.SetProjectId(r.Context(), key.ProjectID)
+			ctx = auth.WithAPIKey(ctx, auth.APIKey{
+				ID:       key.ID,
+				Name:     key.Name,
+				Scopes:   key.Scopes,
+				Channels: key.Channels,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope lets a request through if its API key holds scope. It runs
+// after ProjectKeyOnly.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.APIKeyFrom(r.Context())
+			if !ok {
+				deny(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !key.HasScope(scope) {
+				deny(w, http.StatusForbidden, "API key lacks the "+scope+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AdminAuth authenticates admin requests with either the root admin token
+// or a user session token from /api/auth/login, and stores the principal in
+// the request context for RequireRole and RootOnly.
+func AdminAuth(tokenHash string, queries *database.Queries) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bearerToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || bearerToken == "" {
+				deny(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			computed := utils.CalculateSHA256([]byte(bearerToken))
+
+			var principal auth.Principal
+			switch {
+			case subtle.ConstantTimeCompare([]byte(computed), []byte(tokenHash)) == 1:
+				principal.Root = true
+			case auth.IsSessionToken(bearerToken):
+				user, err := queries.GetUserBySessionToken(r.Context(), computed)
+				if err != nil {
+					deny(w, http.StatusUnauthorized, "Unauthorized")
+					return
+				}
+				principal.UserID = user.ID
+				principal.Email = user.Email
+				principal.Name = user.Name
+			default:
+				deny(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RootOnly limits a route to the root admin token. It runs after AdminAuth.
+func RootOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.PrincipalFrom(r.Context()); !ok || !p.Root {
+			deny(w, http.StatusForbidden, "This endpoint requires the root admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole lets a request through if its principal holds at least role
+// on the organization or project it targets: the {org_id} URL parameter,
+// else {project_id}, else the project of {update_id}, else the project_id
+// query parameter. The root admin token passes every check. It runs after
+// AdminAuth, on routes whose URL parameters are already matched.
+func RequireRole(queries *database.Queries, role auth.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFrom(r.Context())
+			if !ok {
+				deny(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if p.Root {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			held, err := requestRole(r, queries, p.UserID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to resolve role", slog.Any("error", err))
+				deny(w, http.StatusInternalServerError, "Failed to check permissions")
+				return
+			}
+			if !held.Allows(role) {
+				deny(w, http.StatusForbidden, "Requires the "+string(role)+" role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func requestRole(r *http.Request, queries *database.Queries, userID pgtype.UUID) (auth.Role, error) {
+	if id := chi.URLParam(r, "org_id"); id != "" {
+		orgID, err := utils.ParseUUID(id)
+		if err != nil {
+			return "", nil
+		}
+		return auth.OrganizationRole(r.Context(), queries, orgID, userID)
+	}
+
+	id := chi.URLParam(r, "project_id")
+	if id == "" && chi.URLParam(r, "update_id") != "" {
+		updateID, err := utils.ParseUUID(chi.URLParam(r, "update_id"))
+		if err != nil {
+			return "", nil
+		}
+		update, err := queries.GetUpdateByID(r.Context(), updateID)
+		if err != nil {
+			return "", nil
+		}
+		id = update.ProjectID.String()
+	}
+	if id == "" {
+		id = r.URL.Query().Get("project_id")
+	}
+	projectID, err := utils.ParseUUID(id)
+	if err != nil {
+		return "", nil
+	}
+	return auth.ProjectRole(r.Context(), queries, projectID, userID)
+}
+
+// Audit records every POST, PUT, PATCH and DELETE request in audit_events
+// once it has been handled, whatever the outcome. It runs after AdminAuth or
+// ProjectKeyOnly so that the actor is known.
+func Audit(queries *database.Queries) func(next http.Handler) http.Handler {
+	return auditWith(queries.CreateAuditEvent)
+}
+
+func auditWith(record func(context.Context, database.CreateAuditEventParams) error) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, event := audit.NewContext(r.Context())
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			rctx := chi.RouteContext(ctx)
+			pattern := rctx.RoutePattern()
+			if pattern == "" || strings.HasSuffix(pattern, "*") {
+				// No route matched; there is nothing to record.
+				return
+			}
+
+			params, err := auditParams(ctx, r, rctx, event, pattern, sw.status)
+			if err == nil {
+				err = record(context.WithoutCancel(ctx), params)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to record audit event",
+					slog.String("action", r.Method+" "+pattern),
+					slog.Any("error", err),
+				)
+			}
 		})
 	}
 }
+
+func auditParams(ctx context.Context, r *http.Request, rctx *chi.Context, event *audit.Event, pattern string, status int) (database.CreateAuditEventParams, error) {
+	params := database.CreateAuditEventParams{
+		Action:    r.Method + " " + pattern,
+		Path:      r.URL.Path,
+		ProjectID: event.ProjectID,
+		Status:    int32(status),
+		Ip:        utils.ClientIP(r),
+	}
+	params.RequestID, _ = ctx.Value(logger.RequestIDKey).(string)
+
+	if key, ok := auth.APIKeyFrom(ctx); ok {
+		params.ActorType = "api_key"
+		params.ActorID = key.ID.String()
+		params.ActorName = key.Name
+	} else if p, _ := auth.PrincipalFrom(ctx); p.Root {
+		params.ActorType = "admin"
+		params.ActorName = "admin token"
+	} else {
+		params.ActorType = "user"
+		params.ActorID = p.UserID.String()
+		params.ActorName = p.Email
+	}
+
+	targets := map[string]string{}
+	for i, key := range rctx.URLParams.Keys {
+		if key != "*" && rctx.URLParams.Values[i] != "" {
+			targets[key] = rctx.URLParams.Values[i]
+		}
+	}
+	maps.Copy(targets, event.Targets)
+
+	if !params.ProjectID.Valid {
+		if id, err := utils.ParseUUID(targets["project_id"]); err == nil {
+			params.ProjectID = id
+		} else {
+			params.ProjectID = utils.GetProjectId(ctx)
+		}
+	}
+
+	var err error
+	if params.Targets, err = json.Marshal(targets); err != nil {
+		return params, err
+	}
+	if event.Before != nil {
+		if params.Before, err = json.Marshal(event.Before); err != nil {
+			return params, err
+		}
+	}
+	if event.After != nil {
+		if params.After, err = json.Marshal(event.After); err != nil {
+			return params, err
+		}
+	}
+	return params, nil
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func deny(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
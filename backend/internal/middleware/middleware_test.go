@@ -1,94 +1,140 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/vknow360/otaship/backend/internal/audit"
+	"github.com/vknow360/otaship/backend/internal/auth"
+	"github.com/vknow360/otaship/backend/internal/database"
 	"github.com/vknow360/otaship/backend/internal/utils"
 )
 
-func TestAdminOnly(t *testing.T) {
-	// The raw token
-	rawToken := "my-secret-admin-token"
-	// The token the server expects (the SHA256 hex hash of the raw token)
-	validTokenHash := utils.CalculateSHA256([]byte(rawToken))
+func TestProjectKeyOnly_SkipWithoutDB(t *testing.T) {
+	// ProjectKeyOnly requires a concrete *database.Queries struct.
+	// Since we are not using a mock interface (emit_interface: false in sqlc),
+	// testing it requires a real Postgres database connection.
+	t.Skip("Skipping ProjectKeyOnly test. Requires integration test with real DB.")
+}
 
-	// Create the middleware
-	mw := AdminOnly(validTokenHash)
+func TestAdminAuthRoles(t *testing.T) {
+	rawToken := "my-secret-admin-token"
+	tokenHash := utils.CalculateSHA256([]byte(rawToken))
 
-	// A dummy handler that runs if auth passes
-	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
 	})
-
-	// Wrap the dummy handler
-	handler := mw(nextHandler)
+	// Routes behind RequireRole need no database lookup for the root token.
+	authenticated := AdminAuth(tokenHash, nil)
 
 	tests := []struct {
 		name         string
+		handler      http.Handler
 		authHeader   string
 		expectedCode int
 	}{
-		{
-			name:         "Valid Token",
-			authHeader:   "Bearer my-secret-admin-token",
-			expectedCode: http.StatusOK,
-		},
-		{
-			name:         "Invalid Token",
-			authHeader:   "Bearer wrong-token",
-			expectedCode: http.StatusUnauthorized,
-		},
-		{
-			name:         "Missing Bearer Prefix",
-			authHeader:   "my-secret-admin-token",
-			expectedCode: http.StatusUnauthorized,
-		},
-		{
-			name:         "Empty Header",
-			authHeader:   "",
-			expectedCode: http.StatusUnauthorized,
-		},
+		{"root token passes RootOnly", authenticated(RootOnly(ok)), "Bearer " + rawToken, http.StatusOK},
+		{"root token passes RequireRole", authenticated(RequireRole(nil, auth.RoleOwner)(ok)), "Bearer " + rawToken, http.StatusOK},
+		{"wrong token", authenticated(RootOnly(ok)), "Bearer wrong-token", http.StatusUnauthorized},
+		{"no token", authenticated(RootOnly(ok)), "", http.StatusUnauthorized},
+		{"RootOnly without AdminAuth", RootOnly(ok), "Bearer " + rawToken, http.StatusForbidden},
+		{"RequireRole without AdminAuth", RequireRole(nil, auth.RoleViewer)(ok), "Bearer " + rawToken, http.StatusUnauthorized},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req, err := http.NewRequest("GET", "/api/admin/projects", nil)
-			if err != nil {
-				t.Fatalf("Failed to create request: %v", err)
-			}
-
+			req := httptest.NewRequest("GET", "/api/admin/settings", nil)
 			if tt.authHeader != "" {
 				req.Header.Set("Authorization", tt.authHeader)
 			}
-
 			rr := httptest.NewRecorder()
-			handler.ServeHTTP(rr, req)
-
-			if status := rr.Code; status != tt.expectedCode {
-				t.Errorf("Handler returned wrong status code: got %v want %v",
-					status, tt.expectedCode)
+			tt.handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, tt.expectedCode)
 			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := RequireScope(auth.ScopeDelete)(ok)
 
-			if rr.Code == http.StatusUnauthorized {
-				var resp map[string]string
-				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
-					t.Errorf("Failed to decode JSON response: %v", err)
-				}
-				if resp["error"] != "Unauthorized" {
-					t.Errorf("Expected error 'Unauthorized', got %v", resp["error"])
-				}
+	tests := []struct {
+		name         string
+		key          *auth.APIKey
+		expectedCode int
+	}{
+		{"key with scope", &auth.APIKey{Scopes: auth.AllScopes}, http.StatusOK},
+		{"key without scope", &auth.APIKey{Scopes: []string{auth.ScopeRead, auth.ScopePublish}}, http.StatusForbidden},
+		{"no key", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/api/project/updates/1", nil)
+			if tt.key != nil {
+				req = req.WithContext(auth.WithAPIKey(req.Context(), *tt.key))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, tt.expectedCode)
 			}
 		})
 	}
 }
 
-func TestProjectKeyOnly_SkipWithoutDB(t *testing.T) {
-	// ProjectKeyOnly requires a concrete *database.Queries struct.
-	// Since we are not using a mock interface (emit_interface: false in sqlc),
-	// testing it requires a real Postgres database connection.
-	t.Skip("Skipping ProjectKeyOnly test. Requires integration test with real DB.")
+func TestAudit(t *testing.T) {
+	var recorded []database.CreateAuditEventParams
+	record := func(_ context.Context, p database.CreateAuditEventParams) error {
+		recorded = append(recorded, p)
+		return nil
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := auth.WithPrincipal(r.Context(), auth.Principal{Root: true})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Use(auditWith(record))
+	r.Get("/updates/{update_id}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Post("/updates/{update_id}/rollback", func(w http.ResponseWriter, r *http.Request) {
+		audit.SetTarget(r.Context(), "rollback_update_id", "rb-1")
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/updates/u-1", nil),
+		httptest.NewRequest("POST", "/missing", nil),
+		httptest.NewRequest("POST", "/updates/u-1/rollback", nil),
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(recorded) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(recorded))
+	}
+	got := recorded[0]
+	if got.Action != "POST /updates/{update_id}/rollback" {
+		t.Errorf("Action = %q", got.Action)
+	}
+	if got.ActorType != "admin" || got.Status != http.StatusCreated {
+		t.Errorf("ActorType = %q, Status = %d", got.ActorType, got.Status)
+	}
+	var targets map[string]string
+	if err := json.Unmarshal(got.Targets, &targets); err != nil {
+		t.Fatal(err)
+	}
+	if targets["update_id"] != "u-1" || targets["rollback_update_id"] != "rb-1" {
+		t.Errorf("Targets = %v", targets)
+	}
 }
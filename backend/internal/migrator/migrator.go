@@ -0,0 +1,401 @@
+package migrator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/storage"
+)
+
+// Migration statuses.
+const (
+	StatusRunning   = "running"
+	StatusPaused    = "paused"
+	StatusFailed    = "failed"
+	StatusCompleted = "completed"
+)
+
+const (
+	batchSize = 50
+	// lease keeps a claimed migration from other instances for longer than
+	// a batch can take to copy.
+	lease = 10 * time.Minute
+	// maxErrorLength bounds the error kept with a migration.
+	maxErrorLength = 500
+)
+
+// Migrator copies the blobs of storage migrations from their source provider
+// to their target and points the rows that reference them at the copies.
+type Migrator struct {
+	pool      *pgxpool.Pool
+	queries   *database.Queries
+	providers *storage.Registry
+	onChange  func(projectId string)
+}
+
+// New returns a migrator that calls onChange with the project ID of every
+// moved batch, or an empty ID for migrations of every project.
+func New(pool *pgxpool.Pool, queries *database.Queries, providers *storage.Registry, onChange func(projectId string)) *Migrator {
+	return &Migrator{pool: pool, queries: queries, providers: providers, onChange: onChange}
+}
+
+// Run claims a running migration and moves batches of it until it is done,
+// paused or ctx ends, returning how many objects were copied. A migration
+// cut short is continued from its last key by a later run.
+func (m *Migrator) Run(ctx context.Context) (int, error) {
+	now := time.Now()
+	migration, err := m.queries.ClaimStorageMigration(ctx, database.ClaimStorageMigrationParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(lease), Valid: true},
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim storage migration: %w", err)
+	}
+
+	logger := slog.With(
+		slog.String("migration_id", migration.ID.String()),
+		slog.String("source", migration.SourceProvider),
+		slog.String("target", migration.TargetProvider),
+	)
+	copied := 0
+	for ctx.Err() == nil {
+		next, n, done, err := m.runBatch(ctx, migration)
+		copied += n
+		if err != nil && ctx.Err() != nil {
+			// Out of time; the lease runs out and a later run resumes.
+			return copied, nil
+		}
+		if err != nil {
+			logger.Error("Storage migration failed", slog.Any("error", err))
+			m.setStatus(ctx, migration, StatusFailed, err.Error())
+			return copied, err
+		}
+		if done {
+			m.setStatus(ctx, migration, StatusCompleted, "")
+			logger.Info("Storage migration complete",
+				slog.Int64("copied", migration.CopiedObjects),
+				slog.Int64("failed", migration.FailedObjects),
+			)
+			return copied, nil
+		}
+		if next == nil {
+			logger.Info("Storage migration stopped", slog.String("last_key", migration.LastKey))
+			return copied, nil
+		}
+		migration = *next
+	}
+	return copied, nil
+}
+
+func (m *Migrator) setStatus(ctx context.Context, migration database.StorageMigration, status, lastError string) {
+	params := database.SetStorageMigrationStatusParams{
+		Status:       status,
+		ID:           migration.ID,
+		FromStatuses: []string{StatusRunning},
+	}
+	if lastError != "" {
+		params.LastError = pgtype.Text{String: truncate(lastError), Valid: true}
+	}
+	// The run's context may be what ended it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if _, err := m.queries.SetStorageMigrationStatus(ctx, params); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("Failed to update storage migration", slog.String("migration_id", migration.ID.String()), slog.Any("error", err))
+	}
+}
+
+// movedObject is a blob that was copied to the target, with the variants
+// copied along with it.
+type movedObject struct {
+	database.ListStorageMigrationObjectsRow
+	TargetURL string
+	Variants  []database.CreateAssetVariantParams
+}
+
+// runBatch copies the next batch and records it. It returns the migration
+// as recorded, nil when it is no longer running, and done once nothing is
+// left to copy.
+func (m *Migrator) runBatch(ctx context.Context, migration database.StorageMigration) (*database.StorageMigration, int, bool, error) {
+	objects, err := m.queries.ListStorageMigrationObjects(ctx, database.ListStorageMigrationObjectsParams{
+		SourceProvider: migration.SourceProvider,
+		ProjectID:      migration.ProjectID,
+		AfterKey:       migration.LastKey,
+		Limit:          batchSize,
+	})
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to list objects: %w", err)
+	}
+	if len(objects) == 0 {
+		return &migration, 0, true, nil
+	}
+
+	source, err := m.providers.Get(ctx, migration.SourceProvider)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	target, err := m.providers.Get(ctx, migration.TargetProvider)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	// Objects that fail stay on the source and are counted.
+	var moved []movedObject
+	var bytesCopied int64
+	var lastError string
+	for _, object := range objects {
+		result, err := m.copyObject(ctx, source, target, object)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, 0, false, ctx.Err()
+			}
+			lastError = fmt.Sprintf("%s: %v", object.Key, err)
+			slog.WarnContext(ctx, "Failed to copy storage object",
+				slog.String("migration_id", migration.ID.String()),
+				slog.String("key", object.Key),
+				slog.Any("error", err),
+			)
+			continue
+		}
+		moved = append(moved, result)
+		bytesCopied += object.Size
+	}
+	if len(moved) == 0 {
+		// Rather than skip everything while a provider is down, stop.
+		for _, provider := range []storage.Provider{source, target} {
+			if err := provider.Ping(ctx); err != nil {
+				return nil, 0, false, fmt.Errorf("storage provider %q is unavailable: %w", provider.Name(), err)
+			}
+		}
+	}
+
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	qtx := m.queries.WithTx(tx)
+
+	for _, object := range moved {
+		if err := m.recordMove(ctx, qtx, migration, object); err != nil {
+			return nil, 0, false, err
+		}
+	}
+
+	next, err := qtx.AdvanceStorageMigration(ctx, database.AdvanceStorageMigrationParams{
+		LastKey:       objects[len(objects)-1].Key,
+		CopiedObjects: int64(len(moved)),
+		CopiedBytes:   bytesCopied,
+		FailedObjects: int64(len(objects) - len(moved)),
+		LastError:     truncate(lastError),
+		LeaseUntil:    pgtype.Timestamptz{Time: time.Now().Add(lease), Valid: true},
+		ID:            migration.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Paused while copying: the rows keep pointing at the source and
+		// the batch is copied again on resume.
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to record progress: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, false, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	if m.onChange != nil {
+		projectId := ""
+		if migration.ProjectID.Valid {
+			projectId = migration.ProjectID.String()
+		}
+		m.onChange(projectId)
+	}
+	return &next, len(moved), false, nil
+}
+
+// recordMove points the rows of a copied object at the target and, when the
+// source copy is to go, queues it for garbage collection. The collector
+// deletes it once the grace period has passed, so manifests cached with the
+// old URLs keep working until then.
+func (m *Migrator) recordMove(ctx context.Context, qtx *database.Queries, migration database.StorageMigration, object movedObject) error {
+	var err error
+	if object.IsPatch {
+		err = qtx.MoveBundlePatchesToProvider(ctx, database.MoveBundlePatchesToProviderParams{
+			TargetProvider: migration.TargetProvider,
+			Url:            object.TargetURL,
+			SourceProvider: migration.SourceProvider,
+			Key:            object.Key,
+		})
+	} else {
+		err = qtx.MoveAssetsToProvider(ctx, database.MoveAssetsToProviderParams{
+			TargetProvider: migration.TargetProvider,
+			Url:            object.TargetURL,
+			SourceProvider: migration.SourceProvider,
+			Key:            object.Key,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %s: %w", object.Key, err)
+	}
+
+	for _, variant := range object.Variants {
+		if err := qtx.CreateAssetVariant(ctx, variant); err != nil {
+			return fmt.Errorf("failed to record variant %s: %w", variant.VariantKey, err)
+		}
+	}
+
+	if migration.DeleteSource {
+		err := qtx.QueueStorageObjectForGC(ctx, database.QueueStorageObjectForGCParams{
+			Key:             object.Key,
+			StorageProvider: migration.SourceProvider,
+			MimeType:        object.MimeType,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to queue %s for deletion: %w", object.Key, err)
+		}
+	}
+	return nil
+}
+
+// copyObject reads an object from the source, checks it against its hash
+// and stores it under the same key on the target. Compressed variants are
+// copied too when the target can serve them.
+func (m *Migrator) copyObject(ctx context.Context, source, target storage.Provider, object database.ListStorageMigrationObjectsRow) (movedObject, error) {
+	result := movedObject{ListStorageMigrationObjectsRow: object}
+
+	data, err := read(ctx, source, object.Key, object.Url)
+	if err != nil {
+		return result, err
+	}
+	if err := verify(data, object.Hash); err != nil {
+		return result, err
+	}
+	// The target may hold an older copy under this key that is queued for
+	// deletion; restart its grace period before writing over it.
+	err = m.queries.QueueStorageObjectForGC(ctx, database.QueueStorageObjectForGCParams{
+		Key:             object.Key,
+		StorageProvider: target.Name(),
+		MimeType:        object.MimeType,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to queue object: %w", err)
+	}
+	result.TargetURL, err = target.Upload(ctx, object.Key, bytes.NewReader(data), object.MimeType, int64(len(data)))
+	if err != nil {
+		return result, fmt.Errorf("failed to upload: %w", err)
+	}
+	if object.IsPatch {
+		return result, nil
+	}
+
+	uploader, ok := target.(storage.EncodedUploader)
+	if !ok {
+		return result, nil
+	}
+	variants, err := m.queries.ListAssetVariants(ctx, database.ListAssetVariantsParams{
+		StorageProvider: source.Name(),
+		Key:             object.Key,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to list variants: %w", err)
+	}
+	for _, variant := range variants {
+		params, err := copyVariant(ctx, source, target.Name(), uploader, object, variant)
+		if err != nil {
+			// The asset can be served without its variants.
+			slog.WarnContext(ctx, "Failed to copy compressed variant", slog.String("key", variant.VariantKey), slog.Any("error", err))
+			continue
+		}
+		result.Variants = append(result.Variants, params)
+	}
+	return result, nil
+}
+
+// copyVariant copies a compressed variant after checking that it
+// decompresses to the asset.
+func copyVariant(ctx context.Context, source storage.Provider, targetName string, uploader storage.EncodedUploader, object database.ListStorageMigrationObjectsRow, variant database.AssetVariant) (database.CreateAssetVariantParams, error) {
+	var params database.CreateAssetVariantParams
+
+	data, err := read(ctx, source, variant.VariantKey, variant.Url)
+	if err != nil {
+		return params, err
+	}
+	decoded, err := decode(data, variant.Encoding)
+	if err != nil {
+		return params, err
+	}
+	if err := verify(decoded, object.Hash); err != nil {
+		return params, err
+	}
+
+	url, err := uploader.UploadEncoded(ctx, variant.VariantKey, bytes.NewReader(data), object.MimeType, variant.Encoding, int64(len(data)))
+	if err != nil {
+		return params, fmt.Errorf("failed to upload: %w", err)
+	}
+	return database.CreateAssetVariantParams{
+		StorageProvider: targetName,
+		Key:             object.Key,
+		Encoding:        variant.Encoding,
+		VariantKey:      variant.VariantKey,
+		Url:             url,
+		Size:            int64(len(data)),
+	}, nil
+}
+
+func read(ctx context.Context, provider storage.Provider, key, url string) ([]byte, error) {
+	body, err := storage.Read(ctx, provider, key, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// verify checks data against an unpadded base64url SHA-256.
+func verify(data []byte, hash string) error {
+	sum := sha256.Sum256(data)
+	if got := base64.RawURLEncoding.EncodeToString(sum[:]); got != hash {
+		return fmt.Errorf("content hash %s does not match %s", got, hash)
+	}
+	return nil
+}
+
+// decode undoes a variant's Content-Encoding.
+func decode(data []byte, encoding string) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(data))
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return io.ReadAll(r)
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package migrator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestVerifyVariants(t *testing.T) {
+	asset := []byte("console.log('hello');\n")
+	sum := sha256.Sum256(asset)
+	hash := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	var br, gz bytes.Buffer
+	bw := brotli.NewWriter(&br)
+	bw.Write(asset)
+	bw.Close()
+	zw := gzip.NewWriter(&gz)
+	zw.Write(asset)
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		hash     string
+		wantErr  bool
+	}{
+		{"brotli", br.Bytes(), "br", hash, false},
+		{"gzip", gz.Bytes(), "gzip", hash, false},
+		{"other asset", gz.Bytes(), "gzip", "AAAA", true},
+		{"wrong encoding", br.Bytes(), "gzip", hash, true},
+		{"unknown encoding", asset, "zstd", hash, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := decode(tt.data, tt.encoding)
+			if err == nil {
+				err = verify(decoded, tt.hash)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decode and verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
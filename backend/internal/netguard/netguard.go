@@ -0,0 +1,98 @@
+// Package netguard keeps outbound requests to user-supplied URLs off the
+// server's own network. Addresses are checked when the connection is
+// dialed, after DNS resolution, so a hostname that later resolves to a
+// private address is refused as well.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// blocked lists ranges that are not covered by the netip predicates used in
+// Allowed but are still not reachable on the public internet.
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Allowed reports whether addr is a public unicast address.
+func Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blocked {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowedHost reports whether host can be accepted before it is resolved.
+// It refuses localhost and literal addresses that Allowed refuses; other
+// names are checked again by Control when they are dialed.
+func AllowedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return Allowed(addr)
+	}
+	return true
+}
+
+// Control is a net.Dialer Control function that refuses connections to
+// addresses Allowed rejects.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if !Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// Transport returns an http.Transport that dials through Control. It
+// ignores proxy settings from the environment, which would otherwise make
+// the proxy the only address that is checked.
+func Transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Control,
+	}
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// Client returns an http.Client with the given timeout that only connects
+// to public addresses, including when following redirects.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport()}
+}
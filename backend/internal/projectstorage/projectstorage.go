@@ -0,0 +1,321 @@
+// Package projectstorage loads the storage providers projects configure to
+// keep their blobs in their own buckets. Settings and credentials are kept
+// in the project_storage table, sealed with AES-256-GCM. Projects' signing
+// keys are sealed with the same key.
+package projectstorage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/netguard"
+	"github.com/vknow360/otaship/backend/internal/storage"
+	"github.com/vknow360/otaship/backend/internal/utils"
+)
+
+// Providers projects can bring. Local storage belongs to the server.
+const (
+	ProviderS3         = "s3"
+	ProviderCloudinary = "cloudinary"
+)
+
+// providerPrefix starts the names of project providers, followed by the ID
+// of their project_storage row.
+const providerPrefix = "project:"
+
+// ProviderName is the storage_provider of blobs stored with a
+// project_storage row.
+func ProviderName(id pgtype.UUID) string {
+	return providerPrefix + id.String()
+}
+
+// ParseProviderName returns the project_storage ID in a provider name. It
+// reports false for server-wide providers.
+func ParseProviderName(name string) (pgtype.UUID, bool) {
+	value, ok := strings.CutPrefix(name, providerPrefix)
+	if !ok {
+		return pgtype.UUID{}, false
+	}
+	id, err := utils.ParseUUID(value)
+	return id, err == nil
+}
+
+// Config is a project's storage, credentials included. Exactly the section
+// matching Provider is set.
+type Config struct {
+	Provider   string                    `json:"provider"`
+	S3         *storage.S3Config         `json:"s3,omitempty"`
+	Cloudinary *storage.CloudinaryConfig `json:"cloudinary,omitempty"`
+}
+
+// Normalize trims the prefix to the form keys are joined with: no leading
+// slash and one trailing slash.
+func (c *Config) Normalize() {
+	normalize := func(prefix string) string {
+		prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+		if prefix == "" {
+			return ""
+		}
+		return prefix + "/"
+	}
+	if c.S3 != nil {
+		c.S3.Prefix = normalize(c.S3.Prefix)
+		c.S3.Endpoint = strings.TrimSuffix(c.S3.Endpoint, "/")
+		c.S3.BasePath = strings.TrimSuffix(c.S3.BasePath, "/")
+	}
+	if c.Cloudinary != nil {
+		c.Cloudinary.Prefix = normalize(c.Cloudinary.Prefix)
+	}
+}
+
+// Validate checks that the settings for Provider are complete.
+func (c Config) Validate() error {
+	switch c.Provider {
+	case ProviderS3:
+		if c.S3 == nil || c.Cloudinary != nil {
+			return errors.New("s3 settings are required for the s3 provider")
+		}
+		if c.S3.Bucket == "" || c.S3.Region == "" {
+			return errors.New("s3.bucket and s3.region are required")
+		}
+		if c.S3.AccessKey == "" || c.S3.SecretAccessKey == "" {
+			return errors.New("s3.access_key and s3.secret_access_key are required")
+		}
+		for field, value := range map[string]string{"s3.endpoint": c.S3.Endpoint, "s3.base_path": c.S3.BasePath} {
+			if value == "" {
+				continue
+			}
+			if u, err := url.Parse(value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return fmt.Errorf("%s must be an http or https URL", field)
+			}
+		}
+		// The server connects to the endpoint, so it must not reach into
+		// the server's own network. Dialing checks the resolved address.
+		if c.S3.Endpoint != "" {
+			if u, _ := url.Parse(c.S3.Endpoint); !netguard.AllowedHost(u.Hostname()) {
+				return errors.New("s3.endpoint must be on a public host")
+			}
+		}
+	case ProviderCloudinary:
+		if c.Cloudinary == nil || c.S3 != nil {
+			return errors.New("cloudinary settings are required for the cloudinary provider")
+		}
+		if c.Cloudinary.CloudName == "" || c.Cloudinary.APIKey == "" || c.Cloudinary.APISecret == "" {
+			return errors.New("cloudinary.cloud_name, cloudinary.api_key and cloudinary.api_secret are required")
+		}
+	default:
+		return fmt.Errorf("provider must be %q or %q", ProviderS3, ProviderCloudinary)
+	}
+	return nil
+}
+
+// Bucket returns the S3 bucket or Cloudinary cloud the config points at.
+func (c Config) Bucket() string {
+	switch {
+	case c.S3 != nil:
+		return c.S3.Bucket
+	case c.Cloudinary != nil:
+		return c.Cloudinary.CloudName
+	}
+	return ""
+}
+
+// Prefix returns the prefix object keys are stored under.
+func (c Config) Prefix() string {
+	switch {
+	case c.S3 != nil:
+		return c.S3.Prefix
+	case c.Cloudinary != nil:
+		return c.Cloudinary.Prefix
+	}
+	return ""
+}
+
+// SameLocation reports whether c and other store objects in the same
+// place, so blobs stored with one can be reached with the other.
+func (c Config) SameLocation(other Config) bool {
+	if c.Provider != other.Provider || c.Bucket() != other.Bucket() || c.Prefix() != other.Prefix() {
+		return false
+	}
+	if c.S3 != nil && other.S3 != nil {
+		return c.S3.Endpoint == other.S3.Endpoint
+	}
+	return true
+}
+
+// Redacted returns c without its secrets, for API responses.
+func (c Config) Redacted() Config {
+	if c.S3 != nil {
+		s3 := *c.S3
+		s3.SecretAccessKey = ""
+		c.S3 = &s3
+	}
+	if c.Cloudinary != nil {
+		cld := *c.Cloudinary
+		cld.APISecret = ""
+		c.Cloudinary = &cld
+	}
+	return c
+}
+
+// NewProvider connects to the storage c describes, under name. S3 requests
+// only go to public addresses, since projects choose the endpoint.
+func (c Config) NewProvider(name string) (storage.Provider, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Provider == ProviderS3 {
+		s3 := *c.S3
+		s3.HTTPClient = &http.Client{Transport: netguard.Transport()}
+		return storage.NewS3ProviderFromConfig(name, s3)
+	}
+	return storage.NewCloudinaryProviderFromConfig(name, *c.Cloudinary)
+}
+
+// ParseKey decodes STORAGE_CREDENTIALS_KEY: 32 random bytes, base64
+// encoded, such as the output of `openssl rand -base64 32`.
+func ParseKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage credentials key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("storage credentials key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// Store seals project storage settings and signing keys, and loads
+// providers from the settings.
+type Store struct {
+	queries *database.Queries
+	aead    cipher.AEAD
+}
+
+// NewStore returns a Store sealing settings with a 32-byte key.
+func NewStore(queries *database.Queries, key []byte) (*Store, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{queries: queries, aead: aead}, nil
+}
+
+// Seal encrypts c for a row of projectID. The project ID is authenticated
+// with it, so settings copied to another project's row do not open.
+func (s *Store) Seal(projectID pgtype.UUID, c Config) ([]byte, error) {
+	plaintext, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.seal(plaintext, projectID.Bytes[:])
+}
+
+// Open decrypts the settings of a row.
+func (s *Store) Open(row database.ProjectStorage) (Config, error) {
+	var c Config
+	plaintext, err := s.open(row.Config, row.ProjectID.Bytes[:])
+	if err != nil {
+		return c, fmt.Errorf("failed to decrypt project storage settings: %w", err)
+	}
+	if err := json.Unmarshal(plaintext, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// sealedKeyPrefix starts sealed signing keys. Keys stored before they were
+// sealed are PEM in the clear.
+const sealedKeyPrefix = "sealed:"
+
+// IsSealedSigningKey reports whether a signing_keys private_key was sealed
+// with SealSigningKey.
+func IsSealedSigningKey(value string) bool {
+	return strings.HasPrefix(value, sealedKeyPrefix)
+}
+
+// SealSigningKey encrypts a PEM private key for a signing_keys row of
+// projectID. Sealed keys only open for the same project, and never as
+// storage settings.
+func (s *Store) SealSigningKey(projectID pgtype.UUID, privateKey string) (string, error) {
+	sealed, err := s.seal([]byte(privateKey), signingKeyData(projectID))
+	if err != nil {
+		return "", err
+	}
+	return sealedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSigningKey decrypts a private key sealed with SealSigningKey.
+func (s *Store) OpenSigningKey(projectID pgtype.UUID, value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedKeyPrefix)
+	if !ok {
+		return "", errors.New("signing key is not sealed")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid sealed signing key: %w", err)
+	}
+	plaintext, err := s.open(sealed, signingKeyData(projectID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt signing key: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// signingKeyData is the additional data signing keys are sealed with.
+func signingKeyData(projectID pgtype.UUID) []byte {
+	return append([]byte("signing_key:"), projectID.Bytes[:]...)
+}
+
+func (s *Store) seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (s *Store) open(sealed, additionalData []byte) ([]byte, error) {
+	size := s.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("sealed value is truncated")
+	}
+	return s.aead.Open(nil, sealed[:size], sealed[size:], additionalData)
+}
+
+// Load resolves project provider names for a storage.Registry. Providers
+// of deleted rows return storage.ErrProviderRemoved.
+func (s *Store) Load(ctx context.Context, name string) (storage.Provider, error) {
+	id, ok := ParseProviderName(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", storage.ErrUnknownProvider, name)
+	}
+	row, err := s.queries.GetProjectStorage(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %q", storage.ErrProviderRemoved, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.Open(row)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewProvider(name)
+}
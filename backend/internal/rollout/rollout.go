@@ -0,0 +1,223 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/webhook"
+)
+
+// Schedule statuses.
+const (
+	StatusRunning    = "running"
+	StatusPaused     = "paused"
+	StatusCompleted  = "completed"
+	StatusSuperseded = "superseded"
+)
+
+// Event actions recorded in rollout_events.
+const (
+	ActionScheduled  = "scheduled"
+	ActionAdvanced   = "advanced"
+	ActionCompleted  = "completed"
+	ActionPaused     = "paused"
+	ActionResumed    = "resumed"
+	ActionManual     = "manual"
+	ActionSuperseded = "superseded"
+)
+
+// ActorScheduler is the actor recorded for steps the scheduler takes.
+const ActorScheduler = "scheduler"
+
+const batchSize = 100
+
+// ValidateSteps checks that steps is a non-empty, strictly increasing list of
+// percentages between 1 and 100.
+func ValidateSteps(steps []int32) error {
+	if len(steps) == 0 {
+		return errors.New("at least one step is required")
+	}
+	for i, step := range steps {
+		if step < 1 || step > 100 {
+			return fmt.Errorf("step %d must be between 1 and 100", step)
+		}
+		if i > 0 && step <= steps[i-1] {
+			return errors.New("steps must be strictly increasing")
+		}
+	}
+	return nil
+}
+
+// Status returns the status a schedule has once it sits on step current.
+func Status(steps []int32, current int32) string {
+	if int(current) >= len(steps)-1 {
+		return StatusCompleted
+	}
+	return StatusRunning
+}
+
+// NextStepAt returns when the step after current is due, or an invalid
+// timestamp if current is the last step.
+func NextStepAt(steps []int32, current int32, from time.Time, interval time.Duration) pgtype.Timestamptz {
+	if Status(steps, current) == StatusCompleted {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: from.Add(interval), Valid: true}
+}
+
+type Scheduler struct {
+	pool     *pgxpool.Pool
+	queries  *database.Queries
+	onChange func(projectId string)
+}
+
+// NewScheduler returns a scheduler that calls onChange with the project ID
+// of every update whose rollout percentage it changed.
+func NewScheduler(pool *pgxpool.Pool, queries *database.Queries, onChange func(projectId string)) *Scheduler {
+	return &Scheduler{pool: pool, queries: queries, onChange: onChange}
+}
+
+// Run advances every schedule whose next step is due and returns how many
+// were advanced. Schedules of updates that are no longer active are marked
+// superseded instead.
+func (s *Scheduler) Run(ctx context.Context) (int, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := s.queries.WithTx(tx)
+	now := time.Now()
+
+	due, err := qtx.ListDueRolloutSchedules(ctx, database.ListDueRolloutSchedulesParams{
+		NextStepAt: pgtype.Timestamptz{Time: now, Valid: true},
+		Limit:      batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due rollout schedules: %w", err)
+	}
+
+	changed := map[string]bool{}
+	advanced := 0
+	for _, schedule := range due {
+		if !schedule.IsActive {
+			err = s.supersede(ctx, qtx, schedule)
+		} else {
+			err = s.advance(ctx, qtx, schedule, now)
+			if err == nil {
+				advanced++
+				changed[schedule.ProjectID.String()] = true
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit rollout steps: %w", err)
+	}
+
+	if s.onChange != nil {
+		for projectId := range changed {
+			s.onChange(projectId)
+		}
+	}
+	return advanced, nil
+}
+
+func (s *Scheduler) advance(ctx context.Context, qtx *database.Queries, schedule database.ListDueRolloutSchedulesRow, now time.Time) error {
+	next := schedule.CurrentStep + 1
+	if int(next) >= len(schedule.Steps) {
+		// Nothing left to do; this only happens if the schedule was edited
+		// by hand.
+		_, err := qtx.UpdateRolloutSchedule(ctx, database.UpdateRolloutScheduleParams{
+			CurrentStep: schedule.CurrentStep,
+			Status:      StatusCompleted,
+			UpdateID:    schedule.UpdateID,
+		})
+		return err
+	}
+	percentage := schedule.Steps[next]
+
+	err := qtx.UpdateRolloutPercentage(ctx, database.UpdateRolloutPercentageParams{
+		ID:                schedule.UpdateID,
+		RolloutPercentage: percentage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rollout percentage: %w", err)
+	}
+
+	interval := time.Duration(schedule.StepIntervalSeconds) * time.Second
+	status := Status(schedule.Steps, next)
+	_, err = qtx.UpdateRolloutSchedule(ctx, database.UpdateRolloutScheduleParams{
+		CurrentStep: next,
+		Status:      status,
+		NextStepAt:  NextStepAt(schedule.Steps, next, now, interval),
+		UpdateID:    schedule.UpdateID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rollout schedule: %w", err)
+	}
+
+	action := ActionAdvanced
+	if status == StatusCompleted {
+		action = ActionCompleted
+	}
+	err = qtx.CreateRolloutEvent(ctx, database.CreateRolloutEventParams{
+		UpdateID:       schedule.UpdateID,
+		Action:         action,
+		FromPercentage: schedule.RolloutPercentage,
+		ToPercentage:   percentage,
+		Actor:          ActorScheduler,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record rollout event: %w", err)
+	}
+
+	update, err := qtx.GetUpdateByID(ctx, schedule.UpdateID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch update: %w", err)
+	}
+	err = webhook.Enqueue(ctx, qtx, webhook.EventRolloutChanged, update, webhook.RolloutChange{
+		Action:         action,
+		FromPercentage: schedule.RolloutPercentage,
+		ToPercentage:   percentage,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Rollout advanced",
+		slog.String("update_id", schedule.UpdateID.String()),
+		slog.Int("from", int(schedule.RolloutPercentage)),
+		slog.Int("to", int(percentage)),
+		slog.String("status", status),
+	)
+	return nil
+}
+
+func (s *Scheduler) supersede(ctx context.Context, qtx *database.Queries, schedule database.ListDueRolloutSchedulesRow) error {
+	_, err := qtx.UpdateRolloutSchedule(ctx, database.UpdateRolloutScheduleParams{
+		CurrentStep: schedule.CurrentStep,
+		Status:      StatusSuperseded,
+		UpdateID:    schedule.UpdateID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rollout schedule: %w", err)
+	}
+	return qtx.CreateRolloutEvent(ctx, database.CreateRolloutEventParams{
+		UpdateID:       schedule.UpdateID,
+		Action:         ActionSuperseded,
+		FromPercentage: schedule.RolloutPercentage,
+		ToPercentage:   schedule.RolloutPercentage,
+		Actor:          ActorScheduler,
+	})
+}
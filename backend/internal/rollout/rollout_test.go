@@ -0,0 +1,50 @@
+package rollout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []int32
+		wantErr bool
+	}{
+		{"typical", []int32{5, 25, 50, 100}, false},
+		{"single step", []int32{100}, false},
+		{"empty", nil, true},
+		{"zero", []int32{0, 50}, true},
+		{"above 100", []int32{50, 101}, true},
+		{"not increasing", []int32{25, 25, 100}, true},
+		{"decreasing", []int32{50, 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSteps(tt.steps)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSteps(%v) error = %v, wantErr %v", tt.steps, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextStepAt(t *testing.T) {
+	steps := []int32{5, 25, 100}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := NextStepAt(steps, 0, now, 6*time.Hour)
+	if !next.Valid || !next.Time.Equal(now.Add(6*time.Hour)) {
+		t.Errorf("expected next step at %v, got %+v", now.Add(6*time.Hour), next)
+	}
+	if Status(steps, 1) != StatusRunning {
+		t.Errorf("expected schedule on step 1 of 3 to be running")
+	}
+
+	if next := NextStepAt(steps, 2, now, 6*time.Hour); next.Valid {
+		t.Errorf("expected no next step after the last one, got %v", next.Time)
+	}
+	if Status(steps, 2) != StatusCompleted {
+		t.Errorf("expected schedule on the last step to be completed")
+	}
+}
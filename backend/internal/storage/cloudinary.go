@@ -15,7 +15,19 @@ import (
 )
 
 type CloudinaryProvider struct {
-	cld *cloudinary.Cloudinary
+	cld    *cloudinary.Cloudinary
+	name   string
+	prefix string
+}
+
+// CloudinaryConfig holds the credentials of a Cloudinary product
+// environment.
+type CloudinaryConfig struct {
+	CloudName string `json:"cloud_name"`
+	APIKey    string `json:"api_key"`
+	APISecret string `json:"api_secret"`
+	// Prefix is prepended to every public ID.
+	Prefix string `json:"prefix"`
 }
 
 // Matches the actual Cloudinary API response embedded in Response interface{}
@@ -93,25 +105,33 @@ type UsageResponse struct {
 	} `json:"bandwidth"`
 }
 
+// NewCloudinaryProvider connects with the CLOUDINARY_* environment
+// variables.
 func NewCloudinaryProvider() (*CloudinaryProvider, error) {
-	name := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	api := os.Getenv("CLOUDINARY_API_KEY")
-	secret := os.Getenv("CLOUDINARY_API_SECRET")
+	return NewCloudinaryProviderFromConfig("cloudinary", CloudinaryConfig{
+		CloudName: os.Getenv("CLOUDINARY_CLOUD_NAME"),
+		APIKey:    os.Getenv("CLOUDINARY_API_KEY"),
+		APISecret: os.Getenv("CLOUDINARY_API_SECRET"),
+	})
+}
 
-	if name == "" || api == "" || secret == "" {
+// NewCloudinaryProviderFromConfig returns a provider for the environment in
+// c that reports name as its Name.
+func NewCloudinaryProviderFromConfig(name string, c CloudinaryConfig) (*CloudinaryProvider, error) {
+	if c.CloudName == "" || c.APIKey == "" || c.APISecret == "" {
 		return nil, fmt.Errorf("missing cloudinary credentials")
 	}
 
-	cld, err := cloudinary.NewFromParams(name, api, secret)
+	cld, err := cloudinary.NewFromParams(c.CloudName, c.APIKey, c.APISecret)
 	if err != nil {
 		return nil, err
 	}
 
-	return &CloudinaryProvider{cld: cld}, nil
+	return &CloudinaryProvider{cld: cld, name: name, prefix: c.Prefix}, nil
 }
 
 func (c *CloudinaryProvider) Name() string {
-	return "cloudinary"
+	return c.name
 }
 
 func (c *CloudinaryProvider) Upload(
@@ -131,7 +151,7 @@ func (c *CloudinaryProvider) Upload(
 		ctx,
 		data,
 		uploader.UploadParams{
-			PublicID:     key,
+			PublicID:     c.prefix + key,
 			ResourceType: resourceType,
 		},
 	)
@@ -146,14 +166,14 @@ func (c *CloudinaryProvider) Upload(
 func (c *CloudinaryProvider) Delete(ctx context.Context, key, mimeType string) error {
 	if strings.HasPrefix(mimeType, "image/") {
 		_, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{
-			PublicID:     key,
+			PublicID:     c.prefix + key,
 			ResourceType: "image",
 		})
 		return err
 	}
 
 	_, err := c.cld.Upload.Destroy(ctx, uploader.DestroyParams{
-		PublicID:     key,
+		PublicID:     c.prefix + key,
 		ResourceType: "raw",
 	})
 
@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// metaDir holds the sidecar metadata for every stored object. It lives inside
+// the storage root but is never addressable through a key.
+const metaDir = ".meta"
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+type LocalProvider struct {
+	root    string
+	baseURL string
+}
+
+// LocalObjectMeta is persisted next to every object so the asset route can
+// answer with the original Content-Type and a strong ETag without re-hashing.
+// ContentEncoding is only set for compressed variants of assets.
+type LocalObjectMeta struct {
+	ContentType     string    `json:"content_type"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Size            int64     `json:"size"`
+	SHA256          string    `json:"sha256"`
+	ModTime         time.Time `json:"mod_time"`
+}
+
+type LocalObject struct {
+	*os.File
+	Meta LocalObjectMeta
+}
+
+type LocalUsageResponse struct {
+	Message     string    `json:"message"`
+	Path        string    `json:"path"`
+	UsageBytes  int64     `json:"usage_bytes"`
+	UsageMB     float64   `json:"usage_mb"`
+	Files       int64     `json:"files"`
+	DateFetched time.Time `json:"date_fetched"`
+}
+
+func NewLocalProvider() (*LocalProvider, error) {
+	root := os.Getenv("LOCAL_STORAGE_PATH")
+	baseURL := os.Getenv("LOCAL_STORAGE_BASE_URL")
+	if root == "" {
+		return nil, fmt.Errorf("missing local storage path")
+	}
+
+	if baseURL == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		baseURL = "http://localhost:" + port
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(absRoot, metaDir), 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalProvider{root: absRoot, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (l *LocalProvider) Name() string {
+	return "local"
+}
+
+func (l *LocalProvider) Upload(
+	ctx context.Context,
+	key string,
+	data io.Reader,
+	contentType string,
+	size int64,
+) (string, error) {
+	return l.UploadEncoded(ctx, key, data, contentType, "", size)
+}
+
+func (l *LocalProvider) UploadEncoded(
+	ctx context.Context,
+	key string,
+	data io.Reader,
+	contentType, contentEncoding string,
+	size int64,
+) (string, error) {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0755); err != nil {
+		return "", err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(temp, hasher), data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if size > 0 && written != size {
+		return "", fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", key, size, written)
+	}
+
+	if err := os.Rename(temp.Name(), objectPath); err != nil {
+		return "", err
+	}
+
+	meta := LocalObjectMeta{
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+		Size:            written,
+		SHA256:          hex.EncodeToString(hasher.Sum(nil)),
+		ModTime:         time.Now().UTC(),
+	}
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(metaPath, metaBytes, 0644); err != nil {
+		return "", err
+	}
+
+	return l.baseURL + "/assets/" + key, nil
+}
+
+func (l *LocalProvider) Delete(ctx context.Context, key, mimeType string) error {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(objectPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalProvider) Exists(ctx context.Context, key string) (bool, error) {
+	objectPath, _, err := l.paths(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *LocalProvider) Ping(ctx context.Context) error {
+	probe, err := os.CreateTemp(l.root, ".ping-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func (l *LocalProvider) Usage(ctx context.Context) (any, error) {
+	result := &LocalUsageResponse{
+		Path:        l.root,
+		DateFetched: time.Now().UTC(),
+	}
+
+	err := filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != l.root && d.Name() == metaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result.UsageBytes += info.Size()
+		result.Files++
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.UsageMB = float64(result.UsageBytes) / (1024 * 1024)
+	result.Message = fmt.Sprintf("%.2f MB across %d files", result.UsageMB, result.Files)
+	return result, nil
+}
+
+func (l *LocalProvider) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	return filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != l.root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+// Open returns the stored object together with its metadata. The caller must
+// close the returned object.
+func (l *LocalProvider) Open(ctx context.Context, key string) (*LocalObject, error) {
+	objectPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fs.ErrNotExist
+	}
+
+	meta := LocalObjectMeta{
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+	}
+	if metaBytes, err := os.ReadFile(metaPath); err == nil {
+		_ = json.Unmarshal(metaBytes, &meta)
+	}
+
+	return &LocalObject{File: file, Meta: meta}, nil
+}
+
+func (l *LocalProvider) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	return l.Open(ctx, key)
+}
+
+// paths resolves a storage key to the object path and its sidecar metadata
+// path, refusing anything that would escape the storage root.
+func (l *LocalProvider) paths(key string) (string, string, error) {
+	if key == "" || strings.ContainsRune(key, 0) || strings.Contains(key, "\\") {
+		return "", "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key {
+		return "", "", ErrInvalidKey
+	}
+	for _, segment := range strings.Split(cleaned, "/") {
+		if segment == ".." || strings.HasPrefix(segment, ".") {
+			return "", "", ErrInvalidKey
+		}
+	}
+
+	objectPath := filepath.Join(l.root, filepath.FromSlash(cleaned))
+	metaPath := filepath.Join(l.root, metaDir, filepath.FromSlash(cleaned)+".json")
+	return objectPath, metaPath, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider is returned for names no provider is registered or
+	// loaded under.
+	ErrUnknownProvider = errors.New("storage provider is not configured")
+	// ErrProviderRemoved is returned by loaders for providers that existed
+	// but whose configuration has been deleted. Their objects can no longer
+	// be reached.
+	ErrProviderRemoved = errors.New("storage provider has been removed")
+)
+
+// loadedProviderTTL is how long a loaded provider is reused. Other server
+// instances pick up changed credentials within this time.
+const loadedProviderTTL = time.Minute
+
+// Registry resolves the provider named in an asset row. Server-wide
+// providers are configured from the environment at startup; other names,
+// such as the storage a project brings itself, go to a loader and are
+// cached for a while.
+type Registry struct {
+	server map[string]Provider
+	load   func(ctx context.Context, name string) (Provider, error)
+
+	mu     sync.Mutex
+	loaded map[string]loadedProvider
+}
+
+type loadedProvider struct {
+	provider Provider
+	at       time.Time
+}
+
+func NewRegistry(server map[string]Provider) *Registry {
+	return &Registry{server: server, loaded: make(map[string]loadedProvider)}
+}
+
+// SetLoader sets how names of non-server providers are resolved. Call it
+// before serving requests.
+func (r *Registry) SetLoader(load func(ctx context.Context, name string) (Provider, error)) {
+	r.load = load
+}
+
+// Get returns the provider registered or loaded under name.
+func (r *Registry) Get(ctx context.Context, name string) (Provider, error) {
+	if provider, ok := r.server[name]; ok {
+		return provider, nil
+	}
+	if r.load == nil || name == "" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+
+	r.mu.Lock()
+	entry, ok := r.loaded[name]
+	r.mu.Unlock()
+	if ok && time.Since(entry.at) < loadedProviderTTL {
+		return entry.provider, nil
+	}
+
+	provider, err := r.load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.loaded[name] = loadedProvider{provider: provider, at: time.Now()}
+	r.mu.Unlock()
+	return provider, nil
+}
+
+// Server returns the server-wide providers by name. The map must not be
+// modified.
+func (r *Registry) Server() map[string]Provider {
+	return r.server
+}
+
+// Forget drops a loaded provider, so the next Get loads it again.
+func (r *Registry) Forget(name string) {
+	r.mu.Lock()
+	delete(r.loaded, name)
+	r.mu.Unlock()
+}
@@ -2,59 +2,109 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type S3Provider struct {
 	s3       *s3.Client
+	name     string
 	bucket   string
 	region   string
 	basePath string
+	prefix   string
 }
 
+// S3Config locates a bucket on S3 or an S3-compatible service.
+type S3Config struct {
+	AccessKey       string `json:"access_key"`
+	SecretAccessKey string `json:"secret_access_key"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	// Endpoint is set for S3-compatible services, which are addressed
+	// path-style.
+	Endpoint string `json:"endpoint"`
+	// BasePath replaces the AWS host in object URLs.
+	BasePath string `json:"base_path"`
+	// Prefix is prepended to every object key in the bucket.
+	Prefix string `json:"prefix"`
+	// HTTPClient replaces the SDK's client, such as to limit which
+	// addresses a user-supplied Endpoint may reach.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// NewS3Provider connects to the bucket set by the S3_* environment
+// variables.
 func NewS3Provider() (*S3Provider, error) {
-	accessKey := os.Getenv("S3_ACCESS_KEY")
-	secretKey := os.Getenv("S3_SECRET_ACCESS_KEY")
-	region := os.Getenv("S3_REGION")
-	bucket := os.Getenv("S3_BUCKET_NAME")
-	endpoint := os.Getenv("S3_ENDPOINT")
-	basePath := os.Getenv("S3_BASE_PATH")
-	if accessKey == "" || secretKey == "" || region == "" || bucket == "" {
+	return NewS3ProviderFromConfig("s3", S3Config{
+		AccessKey:       os.Getenv("S3_ACCESS_KEY"),
+		SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		Region:          os.Getenv("S3_REGION"),
+		Bucket:          os.Getenv("S3_BUCKET_NAME"),
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+		BasePath:        os.Getenv("S3_BASE_PATH"),
+	})
+}
+
+// NewS3ProviderFromConfig returns a provider for the bucket in c that
+// reports name as its Name.
+func NewS3ProviderFromConfig(name string, c S3Config) (*S3Provider, error) {
+	if c.AccessKey == "" || c.SecretAccessKey == "" || c.Region == "" || c.Bucket == "" {
 		return nil, fmt.Errorf("missing s3 credentials")
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	options := []func(*config.LoadOptions) error{
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			accessKey,
-			secretKey,
+			c.AccessKey,
+			c.SecretAccessKey,
 			"",
 		)),
-		config.WithRegion(region),
-	)
+		config.WithRegion(c.Region),
+	}
+	if c.HTTPClient != nil {
+		options = append(options, config.WithHTTPClient(c.HTTPClient))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), options...)
 
 	if err != nil {
 		return nil, err
 	}
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		if endpoint != "" {
-			o.BaseEndpoint = aws.String(endpoint)
+		if c.Endpoint != "" {
+			o.BaseEndpoint = aws.String(c.Endpoint)
 			o.UsePathStyle = true
 		}
 	})
 
-	return &S3Provider{s3: client, bucket: bucket, region: region, basePath: basePath}, nil
+	return &S3Provider{
+		s3:       client,
+		name:     name,
+		bucket:   c.Bucket,
+		region:   c.Region,
+		basePath: c.BasePath,
+		prefix:   c.Prefix,
+	}, nil
 }
 
 func (s *S3Provider) Name() string {
-	return "s3"
+	return s.name
+}
+
+// objectKey returns where key is stored in the bucket.
+func (s *S3Provider) objectKey(key string) *string {
+	return aws.String(s.prefix + key)
 }
 
 func (s *S3Provider) Upload(
@@ -64,29 +114,106 @@ func (s *S3Provider) Upload(
 	contentType string,
 	size int64,
 ) (string, error) {
-	_, err := s.s3.PutObject(ctx, &s3.PutObjectInput{
+	return s.UploadEncoded(ctx, key, data, contentType, "", size)
+}
+
+func (s *S3Provider) UploadEncoded(
+	ctx context.Context,
+	key string,
+	data io.Reader,
+	contentType, contentEncoding string,
+	size int64,
+) (string, error) {
+	input := &s3.PutObjectInput{
 		Bucket:        &s.bucket,
-		Key:           &key,
+		Key:           s.objectKey(key),
 		Body:          data,
 		ContentType:   &contentType,
 		ContentLength: &size,
-	})
+	}
+	if contentEncoding != "" {
+		input.ContentEncoding = &contentEncoding
+	}
+	_, err := s.s3.PutObject(ctx, input)
 
 	if err != nil {
 		return "", err
 	}
 
+	return s.ObjectURL(key), nil
+}
+
+func (s *S3Provider) ObjectURL(key string) string {
 	if s.basePath != "" {
-		return fmt.Sprintf("%s/%s/%s", s.basePath, s.bucket, key), nil
+		return fmt.Sprintf("%s/%s/%s", s.basePath, s.bucket, s.prefix+key)
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, s.prefix+key)
+}
+
+// PresignUpload signs a PutObject carrying the object's SHA-256, which S3
+// checks against the body before storing it.
+func (s *S3Provider) PresignUpload(ctx context.Context, key, contentType string, size int64, sum []byte, ttl time.Duration) (PresignedRequest, error) {
+	checksum := base64.StdEncoding.EncodeToString(sum)
+	req, err := s3.NewPresignClient(s.s3).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:            &s.bucket,
+		Key:               s.objectKey(key),
+		ContentType:       &contentType,
+		ContentLength:     &size,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ChecksumSHA256:    &checksum,
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return PresignedRequest{}, err
+	}
+
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name, values := range req.SignedHeader {
+		// Clients set Host and Content-Length from the URL and body.
+		if name == "Host" || name == "Content-Length" {
+			continue
+		}
+		headers[name] = strings.Join(values, ",")
 	}
+	return PresignedRequest{Method: req.Method, URL: req.URL, Headers: headers}, nil
+}
 
-	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, key), nil
+// PresignDownload signs a GetObject, so objects can be read from a private
+// bucket.
+func (s *S3Provider) PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(s.s3).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    s.objectKey(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Provider) Checksum(ctx context.Context, key string) ([]byte, int64, error) {
+	head, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       &s.bucket,
+		Key:          s.objectKey(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if head.ChecksumSHA256 == nil {
+		return nil, 0, fmt.Errorf("no SHA-256 checksum stored for %s", key)
+	}
+	sum, err := base64.StdEncoding.DecodeString(*head.ChecksumSHA256)
+	if err != nil {
+		return nil, 0, err
+	}
+	return sum, aws.ToInt64(head.ContentLength), nil
 }
 
 func (s *S3Provider) Delete(ctx context.Context, key, mimeType string) error {
 	_, err := s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &s.bucket,
-		Key:    &key,
+		Key:    s.objectKey(key),
 	})
 	if err != nil {
 		return err
@@ -95,10 +222,21 @@ func (s *S3Provider) Delete(ctx context.Context, key, mimeType string) error {
 	return nil
 }
 
+func (s *S3Provider) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    s.objectKey(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
 func (s *S3Provider) Exists(ctx context.Context, key string) (bool, error) {
 	_, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: &s.bucket,
-		Key:    &key,
+		Key:    s.objectKey(key),
 	})
 
 	if err != nil {
@@ -118,6 +256,33 @@ func (s *S3Provider) Ping(ctx context.Context) error {
 	return nil
 }
 
+func (s *S3Provider) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.s3, &s3.ListObjectsV2Input{
+		Bucket: &s.bucket,
+		Prefix: s.objectKey(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			info := ObjectInfo{
+				Key:  strings.TrimPrefix(aws.ToString(object.Key), s.prefix),
+				Size: aws.ToInt64(object.Size),
+			}
+			if object.LastModified != nil {
+				info.LastModified = *object.LastModified
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *S3Provider) Usage(ctx context.Context) (any, error) {
 	return nil, errors.New("Not implemented")
 }
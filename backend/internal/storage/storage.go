@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"time"
 )
 
 type Provider interface {
@@ -13,3 +16,82 @@ type Provider interface {
 	Ping(ctx context.Context) error
 	Usage(ctx context.Context) (any, error)
 }
+
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Lister is implemented by providers that can enumerate their objects.
+// Garbage collection uses it to find objects no asset row points at.
+type Lister interface {
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// Downloader is implemented by providers that can read objects back.
+// Bundle patches are built from the stored launch assets.
+type Downloader interface {
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Read opens the object at key through the provider when it is a
+// Downloader, and from its public url otherwise.
+func Read(ctx context.Context, provider Provider, key, url string) (io.ReadCloser, error) {
+	if downloader, ok := provider.(Downloader); ok {
+		body, err := downloader.Download(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", key, err)
+		}
+		return body, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// EncodedUploader is implemented by providers that can store an object with
+// a Content-Encoding to serve it with. Compressed variants of assets are
+// only kept by these providers.
+type EncodedUploader interface {
+	UploadEncoded(ctx context.Context, key string, data io.Reader, contentType, contentEncoding string, size int64) (url string, err error)
+}
+
+// PresignedRequest is an HTTP request a client can send to storage without
+// credentials of its own. Headers must be sent as given.
+type PresignedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Presigner is implemented by providers that let clients upload objects
+// straight to storage. Publishing sends assets through the server for the
+// other providers.
+type Presigner interface {
+	// PresignUpload returns a request that stores size bytes at key, valid
+	// for ttl. Storage rejects a body whose SHA-256 is not sum.
+	PresignUpload(ctx context.Context, key, contentType string, size int64, sum []byte, ttl time.Duration) (PresignedRequest, error)
+	// Checksum returns the SHA-256 and size storage recorded for key.
+	Checksum(ctx context.Context, key string) (sum []byte, size int64, err error)
+	// ObjectURL returns the URL of key, as Upload would.
+	ObjectURL(key string) string
+}
+
+// DownloadPresigner is implemented by providers that can hand out
+// short-lived URLs to private objects. The asset proxy redirects devices to
+// them instead of streaming the object itself.
+type DownloadPresigner interface {
+	PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
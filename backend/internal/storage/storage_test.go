@@ -1,8 +1,14 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewS3Provider_MissingEnv(t *testing.T) {
@@ -53,3 +59,216 @@ func TestNewCloudinaryProvider_WithEnv(t *testing.T) {
 		t.Errorf("Expected name 'cloudinary', got %s", p.Name())
 	}
 }
+
+func TestNewLocalProvider_MissingEnv(t *testing.T) {
+	os.Clearenv()
+	_, err := NewLocalProvider()
+	if err == nil {
+		t.Errorf("Expected error when local storage path is missing")
+	}
+}
+
+func TestLocalProvider_UploadExistsDelete(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+	os.Setenv("LOCAL_STORAGE_BASE_URL", "https://ota.example.com/")
+
+	p, err := NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Name() != "local" {
+		t.Errorf("Expected name 'local', got %s", p.Name())
+	}
+
+	ctx := context.Background()
+	key := "my-app/update-1/ios/assets/logo"
+	data := []byte("hello local storage")
+
+	url, err := p.Upload(ctx, key, bytes.NewReader(data), "image/png", int64(len(data)))
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if url != "https://ota.example.com/assets/"+key {
+		t.Errorf("Unexpected URL: %s", url)
+	}
+
+	exists, err := p.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("Expected object to exist, got %v (err: %v)", exists, err)
+	}
+
+	obj, err := p.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got, _ := io.ReadAll(obj)
+	obj.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected %q, got %q", data, got)
+	}
+	if obj.Meta.ContentType != "image/png" {
+		t.Errorf("Expected content type image/png, got %s", obj.Meta.ContentType)
+	}
+	if obj.Meta.SHA256 == "" {
+		t.Errorf("Expected sha256 to be recorded")
+	}
+
+	usage, err := p.Usage(ctx)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	u := usage.(*LocalUsageResponse)
+	if u.Files != 1 || u.UsageBytes != int64(len(data)) {
+		t.Errorf("Expected 1 file of %d bytes, got %d files of %d bytes", len(data), u.Files, u.UsageBytes)
+	}
+
+	if err := p.Delete(ctx, key, "image/png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	exists, _ = p.Exists(ctx, key)
+	if exists {
+		t.Errorf("Expected object to be deleted")
+	}
+}
+
+func TestLocalProvider_RejectsTraversal(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+
+	p, err := NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, key := range []string{"../escape", "a/../../b", "/abs", ".meta/x.json", "a\\b", ""} {
+		if _, err := p.Upload(context.Background(), key, strings.NewReader("x"), "text/plain", 1); err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
+
+func TestLocalProvider_ListPrefix(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("LOCAL_STORAGE_PATH", t.TempDir())
+
+	p, err := NewLocalProvider()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"app-a/assets/one", "app-a/assets/two", "app-b/assets/three"} {
+		if _, err := p.Upload(ctx, key, strings.NewReader(key), "text/plain", int64(len(key))); err != nil {
+			t.Fatalf("Upload %s failed: %v", key, err)
+		}
+	}
+
+	var keys []string
+	err = p.List(ctx, "app-a/", func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		if info.Size != int64(len(info.Key)) {
+			t.Errorf("Expected size %d for %s, got %d", len(info.Key), info.Key, info.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "app-a/assets/one" || keys[1] != "app-a/assets/two" {
+		t.Errorf("Expected only app-a objects, got %v", keys)
+	}
+}
+
+func TestS3PresignUpload(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("S3_ACCESS_KEY", "dummy")
+	os.Setenv("S3_SECRET_ACCESS_KEY", "dummy")
+	os.Setenv("S3_REGION", "us-east-1")
+	os.Setenv("S3_BUCKET_NAME", "mybucket")
+
+	p, err := NewS3Provider()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var _ Presigner = p
+
+	sum := bytes.Repeat([]byte{0xab}, 32)
+	req, err := p.PresignUpload(context.Background(), "app/assets/abc", "application/javascript", 42, sum, time.Hour)
+	if err != nil {
+		t.Fatalf("PresignUpload failed: %v", err)
+	}
+	if req.Method != "PUT" || !strings.Contains(req.URL, "app/assets/abc") || !strings.Contains(req.URL, "X-Amz-Signature") {
+		t.Errorf("Unexpected presigned request %s %s", req.Method, req.URL)
+	}
+	// The checksum is signed, so storage refuses any other body.
+	if !strings.Contains(req.URL, "X-Amz-Checksum-Sha256=") {
+		t.Errorf("Presigned URL does not carry the checksum: %s", req.URL)
+	}
+	if req.Headers["Content-Type"] != "application/javascript" {
+		t.Errorf("Headers = %v", req.Headers)
+	}
+}
+
+func TestS3ProviderPrefix(t *testing.T) {
+	p, err := NewS3ProviderFromConfig("project:1", S3Config{
+		AccessKey:       "dummy",
+		SecretAccessKey: "dummy",
+		Region:          "eu-west-1",
+		Bucket:          "customer-bucket",
+		Prefix:          "ota/",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Name() != "project:1" {
+		t.Errorf("Expected name 'project:1', got %s", p.Name())
+	}
+	if url := p.ObjectURL("app/assets/abc"); url != "https://customer-bucket.s3.eu-west-1.amazonaws.com/ota/app/assets/abc" {
+		t.Errorf("Unexpected URL: %s", url)
+	}
+	req, err := p.PresignUpload(context.Background(), "app/assets/abc", "text/plain", 1, bytes.Repeat([]byte{1}, 32), time.Hour)
+	if err != nil {
+		t.Fatalf("PresignUpload failed: %v", err)
+	}
+	if !strings.Contains(req.URL, "/ota/app/assets/abc") {
+		t.Errorf("Presigned URL is not under the prefix: %s", req.URL)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	local := &LocalProvider{}
+	registry := NewRegistry(map[string]Provider{"local": local})
+
+	if p, err := registry.Get(ctx, "local"); err != nil || p != local {
+		t.Fatalf("Get(local) = %v, %v", p, err)
+	}
+	if _, err := registry.Get(ctx, "project:1"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("Get without a loader = %v, want %v", err, ErrUnknownProvider)
+	}
+
+	loads := 0
+	registry.SetLoader(func(ctx context.Context, name string) (Provider, error) {
+		loads++
+		if name == "project:gone" {
+			return nil, ErrProviderRemoved
+		}
+		return &LocalProvider{}, nil
+	})
+
+	first, err := registry.Get(ctx, "project:1")
+	if err != nil {
+		t.Fatalf("Get(project:1) failed: %v", err)
+	}
+	if again, _ := registry.Get(ctx, "project:1"); again != first || loads != 1 {
+		t.Errorf("Expected the loaded provider to be reused, loaded %d times", loads)
+	}
+	registry.Forget("project:1")
+	if again, _ := registry.Get(ctx, "project:1"); again == first || loads != 2 {
+		t.Errorf("Expected Forget to load the provider again, loaded %d times", loads)
+	}
+	if _, err := registry.Get(ctx, "project:gone"); !errors.Is(err, ErrProviderRemoved) {
+		t.Errorf("Get(project:gone) = %v, want %v", err, ErrProviderRemoved)
+	}
+}
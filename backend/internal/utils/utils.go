@@ -2,16 +2,9 @@ package utils
 
 import (
 	"context"
-	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/hex"
-	"encoding/pem"
-	"errors"
-	"fmt"
 	"net"
 	"net/http"
 	"strings"
@@ -69,7 +62,8 @@ func CalculateSHA256(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func getClientIP(r *http.Request) string {
+// ClientIP returns the first X-Forwarded-For address, else the peer address.
+func ClientIP(r *http.Request) string {
 	forwarded := r.Header.Get("x-forwarded-for")
 	if forwarded != "" {
 		parts := strings.SplitN(forwarded, ",", 2)
@@ -89,42 +83,40 @@ func getClientIP(r *http.Request) string {
 }
 
 func BuildDeviceHash(r *http.Request, platform string) string {
-	fingerprint := getClientIP(r) + "|" + platform
+	fingerprint := ClientIP(r) + "|" + platform
 	return CalculateSHA256([]byte(fingerprint))
 }
 
-func GenerateAPIKey() string {
-	bytes := make([]byte, 32)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
+const (
+	// EASClientIDHeader is the per-install identifier expo-updates sends.
+	EASClientIDHeader = "eas-client-id"
+	// DeviceIDSourceIP marks hashes built by BuildDeviceHash.
+	DeviceIDSourceIP = "ip"
 
-func SignManifest(manifest []byte, privateKeyString string) (string, error) {
-	privateKey, _ := pem.Decode([]byte(privateKeyString))
-	if privateKey == nil {
-		return "", errors.New("invalid private key")
-	}
+	maxDeviceIDLength = 256
+)
 
-	var rsaKey *rsa.PrivateKey
-	key, err := x509.ParsePKCS8PrivateKey(privateKey.Bytes)
-	if err != nil {
-		rsaKey, err = x509.ParsePKCS1PrivateKey(privateKey.Bytes)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse key (PKCS8/PKCS1): %v", err)
+// BuildDeviceIdentity returns the hash a device is bucketed and counted by,
+// and the source it was built from. A stable client identifier is preferred:
+// the header named by customHeader if set, then eas-client-id. Requests
+// without one fall back to BuildDeviceHash, which merges devices behind the
+// same NAT and moves a device when it changes network.
+func BuildDeviceIdentity(r *http.Request, platform, customHeader string) (string, string) {
+	for _, header := range []string{customHeader, EASClientIDHeader} {
+		if header == "" {
+			continue
 		}
-	} else {
-		var ok bool
-		rsaKey, ok = key.(*rsa.PrivateKey)
-		if !ok {
-			return "", errors.New("parsed key is not an RSA private key")
+		value := strings.TrimSpace(r.Header.Get(header))
+		if value == "" || len(value) > maxDeviceIDLength {
+			continue
 		}
+		return CalculateSHA256([]byte(value + "|" + platform)), strings.ToLower(header)
 	}
+	return BuildDeviceHash(r, platform), DeviceIDSourceIP
+}
 
-	hashed := sha256.Sum256(manifest)
-
-	signatureBytes, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hashed[:])
-	if err != nil {
-		return "", err
-	}
-	return base64.StdEncoding.EncodeToString(signatureBytes), nil
+func GenerateAPIKey() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
 }
@@ -2,10 +2,6 @@ package utils
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"net/http"
 	"testing"
 )
@@ -108,9 +104,9 @@ func TestGetClientIPAndDeviceHash(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotIP := getClientIP(tt.req)
+			gotIP := ClientIP(tt.req)
 			if gotIP != tt.wantIP {
-				t.Errorf("getClientIP() = %v, want %v", gotIP, tt.wantIP)
+				t.Errorf("ClientIP() = %v, want %v", gotIP, tt.wantIP)
 			}
 
 			// Test BuildDeviceHash implicitly
@@ -122,46 +118,52 @@ func TestGetClientIPAndDeviceHash(t *testing.T) {
 	}
 }
 
-func TestGenerateAPIKey(t *testing.T) {
-	key1 := GenerateAPIKey()
-	key2 := GenerateAPIKey()
-
-	if len(key1) != 64 {
-		t.Errorf("GenerateAPIKey() length = %d, want 64 (hex encoding of 32 bytes)", len(key1))
+func TestBuildDeviceIdentity(t *testing.T) {
+	newReq := func(ip string, headers map[string]string) *http.Request {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req
 	}
 
-	if key1 == key2 {
-		t.Errorf("GenerateAPIKey() returned identical keys: %v", key1)
+	home := newReq("10.0.0.1", map[string]string{"eas-client-id": "install-1"})
+	office := newReq("192.168.1.1", map[string]string{"eas-client-id": "install-1"})
+	hash, source := BuildDeviceIdentity(home, "ios", "")
+	if source != EASClientIDHeader {
+		t.Errorf("source = %q, want %q", source, EASClientIDHeader)
 	}
-}
-
-func TestSignManifest(t *testing.T) {
-	// Generate a temporary RSA private key for testing
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		t.Fatalf("Failed to generate test RSA key: %v", err)
+	if other, _ := BuildDeviceIdentity(office, "ios", ""); other != hash {
+		t.Errorf("device changed hash when its IP changed")
 	}
 
-	privateKeyBytes := x509.MarshalPKCS1PrivateKey(privateKey)
-	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: privateKeyBytes,
-	})
+	neighbour := newReq("10.0.0.1", map[string]string{"eas-client-id": "install-2"})
+	if other, _ := BuildDeviceIdentity(neighbour, "ios", ""); other == hash {
+		t.Errorf("devices behind the same IP share a hash")
+	}
 
-	manifestData := []byte(`{"id": "123", "version": "1.0.0"}`)
+	custom := newReq("10.0.0.1", map[string]string{"eas-client-id": "install-1", "X-Device-Id": "device-1"})
+	if _, source := BuildDeviceIdentity(custom, "ios", "X-Device-Id"); source != "x-device-id" {
+		t.Errorf("source = %q, want the configured header", source)
+	}
 
-	signature, err := SignManifest(manifestData, string(privateKeyPEM))
-	if err != nil {
-		t.Fatalf("SignManifest() unexpected error: %v", err)
+	anonymous := newReq("10.0.0.1", nil)
+	hash, source = BuildDeviceIdentity(anonymous, "ios", "X-Device-Id")
+	if source != DeviceIDSourceIP || hash != BuildDeviceHash(anonymous, "ios") {
+		t.Errorf("expected fallback to the IP hash, got source %q", source)
 	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key1 := GenerateAPIKey()
+	key2 := GenerateAPIKey()
 
-	if len(signature) == 0 {
-		t.Errorf("SignManifest() returned empty signature")
+	if len(key1) != 64 {
+		t.Errorf("GenerateAPIKey() length = %d, want 64 (hex encoding of 32 bytes)", len(key1))
 	}
 
-	// Test with invalid key
-	_, err = SignManifest(manifestData, "invalid-key-data")
-	if err == nil {
-		t.Errorf("SignManifest() with invalid key should return error")
+	if key1 == key2 {
+		t.Errorf("GenerateAPIKey() returned identical keys: %v", key1)
 	}
 }
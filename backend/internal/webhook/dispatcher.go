@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/vknow360/otaship/backend/internal/database"
+	"github.com/vknow360/otaship/backend/internal/netguard"
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-OTAShip-Event"
+	HeaderDelivery  = "X-OTAShip-Delivery"
+	HeaderSignature = "X-OTAShip-Signature"
+)
+
+// MaxAttempts is how often a delivery is tried before it is marked failed.
+// With Backoff the last attempt is about four hours after the first.
+const MaxAttempts = 10
+
+const (
+	batchSize      = 20
+	requestTimeout = 10 * time.Second
+	// lease keeps claimed deliveries from other instances for longer than
+	// a batch can take to send.
+	lease = 5 * time.Minute
+	// maxErrorLength bounds the receiver error kept with a delivery.
+	maxErrorLength = 500
+)
+
+// Backoff returns how long to wait after the given failed attempt, counting
+// from 1: 30s, 1m, 2m and so on, doubling up to 2h.
+func Backoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < 2*time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, 2*time.Hour)
+}
+
+type Dispatcher struct {
+	queries *database.Queries
+	client  *http.Client
+}
+
+// NewDispatcher returns a Dispatcher whose client refuses to connect to
+// loopback, link-local and private addresses, whatever the webhook host
+// resolves to at delivery time.
+func NewDispatcher(queries *database.Queries) *Dispatcher {
+	return &Dispatcher{queries: queries, client: netguard.Client(requestTimeout)}
+}
+
+// Run sends every delivery that is due and returns how many were accepted.
+// Deliveries that fail are scheduled again with Backoff until MaxAttempts.
+func (d *Dispatcher) Run(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := d.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(lease), Valid: true},
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+		Limit:      batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range due {
+		status, err := Deliver(ctx, d.client, delivery)
+		params := attemptResult(delivery, status, err, time.Now())
+		if params.Status == StatusDelivered {
+			delivered++
+		} else {
+			slog.WarnContext(ctx, "Webhook delivery failed",
+				slog.Int64("delivery_id", delivery.ID),
+				slog.String("webhook_id", delivery.WebhookID.String()),
+				slog.Int("attempt", int(delivery.Attempts)+1),
+				slog.String("status", params.Status),
+				slog.Any("error", err),
+			)
+		}
+		if err := d.queries.RecordWebhookAttempt(ctx, params); err != nil {
+			return delivered, fmt.Errorf("failed to record webhook attempt: %w", err)
+		}
+	}
+	return delivered, nil
+}
+
+// attemptResult is what is stored after one attempt to send delivery.
+func attemptResult(delivery database.ClaimWebhookDeliveriesRow, status int, err error, now time.Time) database.RecordWebhookAttemptParams {
+	params := database.RecordWebhookAttemptParams{
+		Status:        StatusDelivered,
+		NextAttemptAt: pgtype.Timestamptz{Time: now, Valid: true},
+		ID:            delivery.ID,
+	}
+	if status != 0 {
+		params.ResponseStatus = pgtype.Int4{Int32: int32(status), Valid: true}
+	}
+	if err == nil {
+		params.DeliveredAt = pgtype.Timestamptz{Time: now, Valid: true}
+		return params
+	}
+
+	params.LastError = err.Error()
+	if len(params.LastError) > maxErrorLength {
+		params.LastError = params.LastError[:maxErrorLength]
+	}
+	attempt := int(delivery.Attempts) + 1
+	if attempt >= MaxAttempts {
+		params.Status = StatusFailed
+	} else {
+		params.Status = StatusPending
+		params.NextAttemptAt.Time = now.Add(Backoff(attempt))
+	}
+	return params
+}
+
+// Deliver POSTs the delivery's payload to its webhook, signed with the
+// webhook's secret, and returns the response status. Any status outside
+// 2xx is an error.
+func Deliver(ctx context.Context, client *http.Client, delivery database.ClaimWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OTAShip-Webhook")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,156 @@
+// Package webhook notifies project webhooks of update lifecycle events.
+// Handlers queue events with Enqueue inside the transaction that makes the
+// change, so an event is sent only if the change is committed. A Dispatcher
+// then delivers the queue, retrying failures with exponential backoff.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/vknow360/otaship/backend/internal/database"
+)
+
+// Events a webhook can subscribe to.
+const (
+	// EventUpdatePublished is sent when an update is created, by publishing
+	// or by promoting another update.
+	EventUpdatePublished = "update.published"
+	// EventUpdateActivated is sent when an update starts being served, once
+	// its assets are uploaded or its publish signature is accepted, or when
+	// it is promoted.
+	EventUpdateActivated = "update.activated"
+	// EventUpdateRolledBack is sent for manual and automatic rollbacks. The
+	// update is the new rollback update.
+	EventUpdateRolledBack = "update.rolled_back"
+	// EventUpdateDeleted is sent when an update is deleted.
+	EventUpdateDeleted = "update.deleted"
+	// EventRolloutChanged is sent when an update's rollout percentage or
+	// schedule changes, including steps taken by the rollout scheduler.
+	EventRolloutChanged = "rollout.changed"
+)
+
+// AllEvents lists every event, in the order they are shown.
+var AllEvents = []string{
+	EventUpdatePublished,
+	EventUpdateActivated,
+	EventUpdateRolledBack,
+	EventUpdateDeleted,
+	EventRolloutChanged,
+}
+
+// ParseEvents checks events and returns them deduplicated in AllEvents
+// order. No events at all means every event.
+func ParseEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return slices.Clone(AllEvents), nil
+	}
+	for _, e := range events {
+		if !slices.Contains(AllEvents, e) {
+			return nil, fmt.Errorf("unknown event %q", e)
+		}
+	}
+	var res []string
+	for _, e := range AllEvents {
+		if slices.Contains(events, e) {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// Update is the update an event is about.
+type Update struct {
+	ID                string `json:"id"`
+	RuntimeVersion    string `json:"runtime_version"`
+	Branch            string `json:"branch"`
+	Platform          string `json:"platform"`
+	RolloutPercentage int32  `json:"rollout_percentage"`
+	IsActive          bool   `json:"is_active"`
+	IsRollback        bool   `json:"is_rollback"`
+	Message           string `json:"message"`
+	CreatedAt         int64  `json:"created_at"`
+}
+
+// Payload is the JSON body of every delivery.
+type Payload struct {
+	Event     string `json:"event"`
+	ProjectID string `json:"project_id"`
+	// Timestamp is when the event happened, in Unix milliseconds.
+	Timestamp int64  `json:"timestamp"`
+	Update    Update `json:"update"`
+	// Details depends on the event: Promotion for promoted updates,
+	// Rollback and RolloutChange.
+	Details any `json:"details,omitempty"`
+}
+
+// Promotion details an update.published or update.activated event for a
+// promoted update.
+type Promotion struct {
+	PromotedFrom string `json:"promoted_from"`
+}
+
+// Rollback details an update.rolled_back event.
+type Rollback struct {
+	// RolledBackTo is the update that was copied, or empty for a rollback
+	// to the embedded update.
+	RolledBackTo string `json:"rolled_back_to,omitempty"`
+	Automatic    bool   `json:"automatic"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// RolloutChange details a rollout.changed event. Action is one of the
+// rollout package's Action values.
+type RolloutChange struct {
+	Action         string `json:"action"`
+	FromPercentage int32  `json:"from_percentage"`
+	ToPercentage   int32  `json:"to_percentage"`
+}
+
+// Enqueue queues event about update for every webhook of its project that
+// subscribes to it. Pass the queries of the transaction making the change.
+func Enqueue(ctx context.Context, qtx *database.Queries, event string, update database.Update, details any) error {
+	payload, err := json.Marshal(Payload{
+		Event:     event,
+		ProjectID: update.ProjectID.String(),
+		Timestamp: time.Now().UnixMilli(),
+		Update: Update{
+			ID:                update.ID.String(),
+			RuntimeVersion:    update.RuntimeVersion,
+			Branch:            update.Channel,
+			Platform:          update.Platform,
+			RolloutPercentage: update.RolloutPercentage,
+			IsActive:          update.IsActive,
+			IsRollback:        update.IsRollback,
+			Message:           update.Message.String,
+			CreatedAt:         update.CreatedAt.Time.UnixMilli(),
+		},
+		Details: details,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = qtx.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		Event:     event,
+		Payload:   payload,
+		ProjectID: update.ProjectID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue %s webhooks: %w", event, err)
+	}
+	return nil
+}
+
+// Sign returns the X-OTAShip-Signature header for body: "sha256=" and the
+// hex HMAC-SHA256 of the body keyed with the webhook's secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
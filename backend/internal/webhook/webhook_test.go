@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vknow360/otaship/backend/internal/database"
+)
+
+func TestParseEvents(t *testing.T) {
+	got, err := ParseEvents(nil)
+	if err != nil || len(got) != len(AllEvents) {
+		t.Errorf("ParseEvents(nil) = %v, %v; want every event", got, err)
+	}
+	got, err = ParseEvents([]string{EventRolloutChanged, EventUpdatePublished, EventRolloutChanged})
+	if err != nil || len(got) != 2 || got[0] != EventUpdatePublished || got[1] != EventRolloutChanged {
+		t.Errorf("ParseEvents = %v, %v", got, err)
+	}
+	if _, err := ParseEvents([]string{"update.created"}); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	payload := []byte(`{"event":"update.published"}`)
+	secret := "whsec_test"
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"accepted", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"server error", http.StatusInternalServerError, true},
+		{"gone", http.StatusGone, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if got := r.Header.Get(HeaderSignature); got != Sign(secret, body) {
+					t.Errorf("signature %q does not match the body", got)
+				}
+				if r.Header.Get(HeaderEvent) != "update.published" || r.Header.Get(HeaderDelivery) != "7" {
+					t.Errorf("unexpected headers %v", r.Header)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			status, err := Deliver(context.Background(), receiver.Client(), database.ClaimWebhookDeliveriesRow{
+				ID:      7,
+				Event:   "update.published",
+				Payload: payload,
+				Url:     receiver.URL,
+				Secret:  secret,
+			})
+			if status != tt.status || (err != nil) != tt.wantErr {
+				t.Errorf("Deliver = %d, %v; want %d, error %v", status, err, tt.status, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAttemptResult(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	failure := errors.New("receiver responded 500")
+
+	ok := attemptResult(database.ClaimWebhookDeliveriesRow{Attempts: 2}, 200, nil, now)
+	if ok.Status != StatusDelivered || !ok.DeliveredAt.Valid {
+		t.Errorf("successful attempt stored as %+v", ok)
+	}
+
+	retry := attemptResult(database.ClaimWebhookDeliveriesRow{Attempts: 2}, 500, failure, now)
+	if retry.Status != StatusPending || !retry.NextAttemptAt.Time.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("third failed attempt stored as %+v, want a retry in 2m", retry)
+	}
+
+	last := attemptResult(database.ClaimWebhookDeliveriesRow{Attempts: MaxAttempts - 1}, 0, failure, now)
+	if last.Status != StatusFailed || last.ResponseStatus.Valid {
+		t.Errorf("last failed attempt stored as %+v", last)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, w := range want {
+		if got := Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := Backoff(20); got != 2*time.Hour {
+		t.Errorf("Backoff(20) = %v, want the 2h cap", got)
+	}
+}
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_assets_hash ON assets(hash, storage_provider);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_assets_key;
+DROP TABLE IF EXISTS storage_gc_queue;
@@ -0,0 +1,12 @@
+-- Storage objects that lost a reference. The GC job deletes them once no
+-- assets row points at the same key and the grace period has passed.
+CREATE TABLE storage_gc_queue (
+    key TEXT NOT NULL,
+    storage_provider TEXT NOT NULL,
+    mime_type TEXT NOT NULL DEFAULT '',
+    queued_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (key, storage_provider)
+);
+
+CREATE INDEX idx_storage_gc_queue_queued_at ON storage_gc_queue(queued_at);
+CREATE INDEX idx_assets_key ON assets(key, storage_provider);
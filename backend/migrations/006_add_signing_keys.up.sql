@@ -0,0 +1,17 @@
+-- Code signing keys for the Expo Updates protocol. A project can hold several
+-- active keys at once; clients pick one with the keyid in
+-- expo-expect-signature, and the primary key signs when none is requested.
+CREATE TABLE signing_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    algorithm TEXT NOT NULL DEFAULT 'rsa-v1_5-sha256',
+    private_key TEXT NOT NULL,
+    certificate TEXT NOT NULL DEFAULT '',
+    is_primary BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at TIMESTAMPTZ,
+    UNIQUE (project_id, key_id)
+);
+
+CREATE UNIQUE INDEX idx_signing_keys_primary ON signing_keys(project_id) WHERE is_primary;
@@ -0,0 +1,3 @@
+ALTER TABLE updates DROP COLUMN IF EXISTS manifest_signature;
+ALTER TABLE updates DROP COLUMN IF EXISTS signed_manifest;
+DROP TABLE IF EXISTS signing_certificates;
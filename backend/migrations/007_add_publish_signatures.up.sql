@@ -0,0 +1,15 @@
+-- Certificates for keys that never reach the server. The CLI signs the
+-- manifest at publish time and the server verifies it against these.
+CREATE TABLE signing_certificates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (project_id, key_id)
+);
+
+-- A publish-time signed update is served with exactly these bytes and this
+-- expo-signature value.
+ALTER TABLE updates ADD COLUMN signed_manifest BYTEA;
+ALTER TABLE updates ADD COLUMN manifest_signature TEXT;
@@ -0,0 +1,3 @@
+COMMENT ON COLUMN updates.channel IS NULL;
+DROP TABLE IF EXISTS channels;
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,33 @@
+-- Updates are published to a branch; the existing updates.channel column
+-- holds the branch name. A channel points devices at one branch, or splits
+-- its traffic between two branches by percentage.
+CREATE TABLE branches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (name ~ '^[a-z0-9][a-z0-9_-]{0,32}$'),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (project_id, name)
+);
+
+CREATE TABLE channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (name ~ '^[a-z0-9][a-z0-9_-]{0,32}$'),
+    branch_id UUID NOT NULL REFERENCES branches(id) ON DELETE RESTRICT,
+    rollout_branch_id UUID REFERENCES branches(id) ON DELETE RESTRICT,
+    rollout_percentage INT NOT NULL DEFAULT 0 CHECK (rollout_percentage BETWEEN 0 AND 100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (project_id, name),
+    CHECK (rollout_branch_id IS NULL OR rollout_branch_id <> branch_id)
+);
+
+COMMENT ON COLUMN updates.channel IS 'Name of the branch the update was published to';
+
+-- Every existing channel becomes a branch of the same name, with a channel
+-- pointing at it, so devices keep receiving the same updates.
+INSERT INTO branches (project_id, name)
+SELECT DISTINCT project_id, channel FROM updates;
+
+INSERT INTO channels (project_id, name, branch_id)
+SELECT project_id, name, id FROM branches;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rollout_events;
+DROP TABLE IF EXISTS rollout_schedules;
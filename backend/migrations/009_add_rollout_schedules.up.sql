@@ -0,0 +1,31 @@
+-- A rollout schedule raises an update's rollout_percentage through a list of
+-- steps, one step every step_interval_seconds. The scheduler job picks up
+-- running schedules whose next_step_at has passed.
+CREATE TABLE rollout_schedules (
+    update_id UUID PRIMARY KEY REFERENCES updates(id) ON DELETE CASCADE,
+    steps INT[] NOT NULL,
+    step_interval_seconds BIGINT NOT NULL CHECK (step_interval_seconds > 0),
+    current_step INT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'paused', 'completed', 'superseded')),
+    next_step_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_rollout_schedules_due ON rollout_schedules(next_step_at)
+WHERE status = 'running';
+
+-- Every change to an update's rollout percentage, whether made by the
+-- scheduler or by hand, is recorded here.
+CREATE TABLE rollout_events (
+    id BIGSERIAL PRIMARY KEY,
+    update_id UUID NOT NULL REFERENCES updates(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    from_percentage INT NOT NULL,
+    to_percentage INT NOT NULL,
+    actor TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_rollout_events_update ON rollout_events(update_id, created_at);
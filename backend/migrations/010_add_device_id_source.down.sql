@@ -0,0 +1 @@
+ALTER TABLE download_events DROP COLUMN IF EXISTS device_id_source;
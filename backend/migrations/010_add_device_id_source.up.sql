@@ -0,0 +1,4 @@
+-- Records where device_hash came from: a client-supplied identifier header
+-- (eas-client-id or the header configured with DEVICE_ID_HEADER), or 'ip'
+-- for the IP + platform fallback.
+ALTER TABLE download_events ADD COLUMN device_id_source TEXT NOT NULL DEFAULT 'ip';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS auto_rollback_policies;
+DROP TABLE IF EXISTS update_telemetry;
@@ -0,0 +1,22 @@
+-- Client-reported launch outcomes, aggregated per update. The error rate an
+-- update is judged by is (js_errors + crashes) / (launches + crashes).
+CREATE TABLE update_telemetry (
+    update_id UUID PRIMARY KEY REFERENCES updates(id) ON DELETE CASCADE,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    launches BIGINT NOT NULL DEFAULT 0,
+    js_errors BIGINT NOT NULL DEFAULT 0,
+    crashes BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    rolled_back_at TIMESTAMPTZ,
+    rollback_reason TEXT
+);
+
+-- An update whose error rate passes max_error_rate, once it has at least
+-- min_launches samples, is rolled back automatically.
+CREATE TABLE auto_rollback_policies (
+    project_id UUID PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    max_error_rate DOUBLE PRECISION NOT NULL CHECK (max_error_rate > 0 AND max_error_rate <= 1),
+    min_launches INT NOT NULL DEFAULT 100 CHECK (min_launches > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS updates_notify_change ON updates;
+DROP FUNCTION IF EXISTS notify_update_change();
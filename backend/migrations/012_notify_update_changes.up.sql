@@ -0,0 +1,19 @@
+-- Notify listeners on the otaship_updates channel with the project ID
+-- whenever an update is created, deleted, (de)activated or has its rollout
+-- changed, so every server instance can drop its cached manifests. Postgres
+-- delivers notifications on commit and folds duplicates within a
+-- transaction.
+CREATE FUNCTION notify_update_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM pg_notify('otaship_updates', OLD.project_id::text);
+    ELSE
+        PERFORM pg_notify('otaship_updates', NEW.project_id::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER updates_notify_change
+AFTER INSERT OR DELETE OR UPDATE OF is_active, rollout_percentage, is_rollback ON updates
+FOR EACH ROW EXECUTE FUNCTION notify_update_change();
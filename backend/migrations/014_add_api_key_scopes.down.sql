@@ -0,0 +1,4 @@
+ALTER TABLE api_keys
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS channels,
+    DROP COLUMN IF EXISTS scopes;
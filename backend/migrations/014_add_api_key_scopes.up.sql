@@ -0,0 +1,7 @@
+-- API keys carry the scopes they may use, an optional allowlist of channels
+-- and branches they may act on (empty means all) and an optional expiry.
+-- Existing keys keep every scope and never expire.
+ALTER TABLE api_keys
+    ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{read,publish,rollback,delete}',
+    ADD COLUMN channels TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN expires_at TIMESTAMPTZ;
@@ -0,0 +1,21 @@
+-- One row per mutating request under /api/admin and /api/project. The
+-- project is not a foreign key so that history outlives deleted projects.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_type TEXT NOT NULL CHECK (actor_type IN ('admin', 'user', 'api_key')),
+    actor_id TEXT NOT NULL DEFAULT '',
+    actor_name TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    path TEXT NOT NULL,
+    project_id UUID,
+    targets JSONB NOT NULL DEFAULT '{}',
+    before JSONB,
+    after JSONB,
+    status INTEGER NOT NULL,
+    request_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_events_created ON audit_events(created_at DESC, id DESC);
+CREATE INDEX idx_audit_events_project ON audit_events(project_id, created_at DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS max_upload_size;
+DROP TABLE IF EXISTS upload_sessions;
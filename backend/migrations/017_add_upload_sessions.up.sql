@@ -0,0 +1,18 @@
+-- Resumable bundle uploads. Chunks are written to a file under UPLOAD_DIR;
+-- the session records how many bytes have arrived so that an interrupted
+-- client can continue from there.
+CREATE TABLE upload_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    update_id UUID NOT NULL REFERENCES updates(id) ON DELETE CASCADE,
+    platform TEXT NOT NULL,
+    activate BOOLEAN NOT NULL DEFAULT true,
+    upload_length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_upload_sessions_expires ON upload_sessions(expires_at);
+
+-- Largest bundle in bytes a project may upload; NULL uses the server default.
+ALTER TABLE projects ADD COLUMN max_upload_size BIGINT;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS direct_upload_blobs;
+DROP TABLE IF EXISTS direct_uploads;
@@ -0,0 +1,27 @@
+-- Publishes whose assets clients upload straight to storage. assets lists
+-- every file of the bundle; direct_upload_blobs holds the blobs the project
+-- did not store yet, which finalize checks before activating the update.
+CREATE TABLE direct_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    update_id UUID NOT NULL REFERENCES updates(id) ON DELETE CASCADE,
+    platform TEXT NOT NULL,
+    activate BOOLEAN NOT NULL DEFAULT true,
+    storage_provider TEXT NOT NULL,
+    expo_config JSONB,
+    assets JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_direct_uploads_expires ON direct_uploads(expires_at);
+
+CREATE TABLE direct_upload_blobs (
+    direct_upload_id UUID NOT NULL REFERENCES direct_uploads(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    key TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    -- Set once the blob is known to be stored.
+    url TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (direct_upload_id, hash)
+);
@@ -0,0 +1,16 @@
+-- Binary patches from one launch asset of a project to another, built on
+-- demand for devices that can apply them. status is pending while a server
+-- builds the patch, then ready, or failed when no worthwhile patch exists.
+CREATE TABLE bundle_patches (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    base_hash TEXT NOT NULL,
+    target_hash TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    storage_provider TEXT NOT NULL DEFAULT '',
+    key TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    hash TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (project_id, base_hash, target_hash)
+);
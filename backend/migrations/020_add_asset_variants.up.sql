@@ -0,0 +1,12 @@
+-- Compressed copies of stored assets, one per Content-Encoding. Rows belong
+-- to the storage object rather than an update, and are removed when garbage
+-- collection deletes the object.
+CREATE TABLE asset_variants (
+    storage_provider TEXT NOT NULL,
+    key TEXT NOT NULL,
+    encoding TEXT NOT NULL,
+    variant_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    PRIMARY KEY (storage_provider, key, encoding)
+);
@@ -0,0 +1,24 @@
+-- Jobs copying the blobs of one project, or of every project when
+-- project_id is NULL, from one storage provider to another. Blobs are moved
+-- in key order and last_key records how far a job got, so it resumes there.
+-- A running job is leased by one server at a time.
+CREATE TABLE storage_migrations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID REFERENCES projects(id) ON DELETE CASCADE,
+    source_provider TEXT NOT NULL,
+    target_provider TEXT NOT NULL,
+    delete_source BOOLEAN NOT NULL DEFAULT false,
+    status TEXT NOT NULL DEFAULT 'running',
+    last_key TEXT NOT NULL DEFAULT '',
+    total_objects BIGINT NOT NULL DEFAULT 0,
+    copied_objects BIGINT NOT NULL DEFAULT 0,
+    copied_bytes BIGINT NOT NULL DEFAULT 0,
+    failed_objects BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    lease_until TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_storage_migrations_running ON storage_migrations(lease_until) WHERE status = 'running';
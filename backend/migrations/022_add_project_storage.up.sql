@@ -0,0 +1,18 @@
+-- Storage a project keeps its blobs in instead of the server's provider.
+-- config holds the provider settings and credentials, sealed with
+-- AES-256-GCM under STORAGE_CREDENTIALS_KEY. A project has at most one
+-- active row; replaced rows stay for as long as assets are stored with them.
+CREATE TABLE project_storage (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    bucket TEXT NOT NULL,
+    prefix TEXT NOT NULL DEFAULT '',
+    config BYTEA NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_project_storage_active ON project_storage(project_id) WHERE active;
@@ -1,15 +1,15 @@
 -- name: CreateAPIKey :one
-INSERT INTO api_keys (project_id, name, key_hash, key_suffix)
-VALUES (sqlc.arg('project_id'), sqlc.arg('name'), sqlc.arg('key_hash'), sqlc.arg('key_suffix'))
+INSERT INTO api_keys (project_id, name, key_hash, key_suffix, scopes, channels, expires_at)
+VALUES (sqlc.arg('project_id'), sqlc.arg('name'), sqlc.arg('key_hash'), sqlc.arg('key_suffix'), sqlc.arg('scopes'), sqlc.arg('channels'), sqlc.arg('expires_at'))
 RETURNING *;
 
 -- name: GetAPIKeyBySuffix :one
-SELECT id, project_id, key_hash 
+SELECT id, project_id, name, key_hash, scopes, channels, expires_at
 FROM api_keys 
 WHERE key_suffix = $1;
 
 -- name: ListAPIKeys :many
-SELECT id, name, key_suffix, created_at, last_used_at 
+SELECT id, name, key_suffix, scopes, channels, expires_at, created_at, last_used_at
 FROM api_keys 
 WHERE project_id = $1 
 ORDER BY created_at DESC;
@@ -21,4 +21,4 @@ WHERE id = sqlc.arg('id') AND project_id = sqlc.arg('project_id');
 -- name: UpdateAPIKeyLastUsed :exec
 UPDATE api_keys 
 SET last_used_at = CURRENT_TIMESTAMP 
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
@@ -0,0 +1,14 @@
+-- name: CreateAssetVariant :exec
+INSERT INTO asset_variants (storage_provider, key, encoding, variant_key, url, size)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (storage_provider, key, encoding) DO UPDATE
+SET variant_key = EXCLUDED.variant_key, url = EXCLUDED.url, size = EXCLUDED.size;
+
+-- name: ListAssetVariants :many
+SELECT * FROM asset_variants
+WHERE storage_provider = $1 AND key = $2
+ORDER BY encoding;
+
+-- name: DeleteAssetVariants :exec
+DELETE FROM asset_variants
+WHERE storage_provider = $1 AND key = $2;
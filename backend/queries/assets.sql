@@ -23,4 +23,22 @@ WHERE a.update_id = sqlc.arg('source_update_id');
 
 -- name: CountOtherAssetReferences :one
 SELECT COUNT(*) FROM assets
-WHERE key = $1 AND update_id != $2;
\ No newline at end of file
+WHERE key = $1 AND update_id != $2;
+
+-- name: GetAssetByProjectAndHash :one
+SELECT a.* FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = sqlc.arg('project_id')
+AND a.hash = sqlc.arg('hash')
+AND a.storage_provider = sqlc.arg('storage_provider')
+LIMIT 1;
+
+-- name: GetProjectAssetByHash :one
+-- GetProjectAssetByHash finds a stored blob of a project by content hash,
+-- preferring a copy on the given provider.
+SELECT a.* FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = sqlc.arg('project_id')
+AND a.hash = sqlc.arg('hash')
+ORDER BY a.storage_provider = sqlc.arg('preferred_provider') DESC
+LIMIT 1;
@@ -0,0 +1,32 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+    actor_type, actor_id, actor_name, action, path, project_id,
+    targets, before, after, status, request_id, ip
+) VALUES (
+    sqlc.arg('actor_type'), sqlc.arg('actor_id'), sqlc.arg('actor_name'), sqlc.arg('action'), sqlc.arg('path'), sqlc.narg('project_id'),
+    sqlc.arg('targets'), sqlc.narg('before'), sqlc.narg('after'), sqlc.arg('status'), sqlc.arg('request_id'), sqlc.arg('ip')
+);
+
+-- name: ListAuditEvents :many
+-- Filters are skipped when NULL. target matches any value in targets, such
+-- as an update or key ID.
+SELECT id, actor_type, actor_id, actor_name, action, path, project_id, targets, before, after, status, request_id, ip, created_at
+FROM audit_events
+WHERE (sqlc.narg('project_id')::uuid IS NULL OR project_id = sqlc.narg('project_id'))
+  AND (sqlc.narg('actor')::text IS NULL OR actor_id = sqlc.narg('actor') OR actor_name = sqlc.narg('actor'))
+  AND (sqlc.narg('action')::text IS NULL OR action LIKE '%' || sqlc.narg('action') || '%')
+  AND (sqlc.narg('target')::text IS NULL OR EXISTS (SELECT 1 FROM jsonb_each_text(targets) t WHERE t.value = sqlc.narg('target')))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR created_at < sqlc.narg('until'))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountAuditEvents :one
+SELECT COUNT(*)
+FROM audit_events
+WHERE (sqlc.narg('project_id')::uuid IS NULL OR project_id = sqlc.narg('project_id'))
+  AND (sqlc.narg('actor')::text IS NULL OR actor_id = sqlc.narg('actor') OR actor_name = sqlc.narg('actor'))
+  AND (sqlc.narg('action')::text IS NULL OR action LIKE '%' || sqlc.narg('action') || '%')
+  AND (sqlc.narg('target')::text IS NULL OR EXISTS (SELECT 1 FROM jsonb_each_text(targets) t WHERE t.value = sqlc.narg('target')))
+  AND (sqlc.narg('since')::timestamptz IS NULL OR created_at >= sqlc.narg('since'))
+  AND (sqlc.narg('until')::timestamptz IS NULL OR created_at < sqlc.narg('until'));
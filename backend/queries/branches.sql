@@ -0,0 +1,35 @@
+-- name: CreateBranch :one
+INSERT INTO branches (project_id, name)
+VALUES (sqlc.arg('project_id'), sqlc.arg('name'))
+RETURNING *;
+
+-- name: EnsureBranch :one
+INSERT INTO branches (project_id, name)
+VALUES (sqlc.arg('project_id'), sqlc.arg('name'))
+ON CONFLICT (project_id, name) DO UPDATE SET name = EXCLUDED.name
+RETURNING *;
+
+-- name: GetBranchByName :one
+SELECT * FROM branches
+WHERE project_id = sqlc.arg('project_id') AND name = sqlc.arg('name');
+
+-- name: ListBranches :many
+SELECT
+    b.id,
+    b.project_id,
+    b.name,
+    b.created_at,
+    COUNT(u.id)::bigint AS update_count
+FROM branches b
+LEFT JOIN updates u ON u.project_id = b.project_id AND u.channel = b.name
+WHERE b.project_id = $1
+GROUP BY b.id
+ORDER BY b.name;
+
+-- name: CountBranchUpdates :one
+SELECT COUNT(*) FROM updates
+WHERE project_id = sqlc.arg('project_id') AND channel = sqlc.arg('name');
+
+-- name: DeleteBranch :exec
+DELETE FROM branches
+WHERE project_id = sqlc.arg('project_id') AND name = sqlc.arg('name');
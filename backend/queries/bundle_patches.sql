@@ -0,0 +1,54 @@
+-- name: ClaimBundlePatch :one
+-- Starts building a patch unless another server is already on it. A pending
+-- row older than stale_before belongs to a build that died and is taken over.
+INSERT INTO bundle_patches (project_id, base_hash, target_hash)
+VALUES (sqlc.arg('project_id'), sqlc.arg('base_hash'), sqlc.arg('target_hash'))
+ON CONFLICT (project_id, base_hash, target_hash) DO UPDATE
+SET status = 'pending', created_at = now()
+WHERE bundle_patches.status = 'pending'
+AND bundle_patches.created_at < sqlc.arg('stale_before')
+RETURNING *;
+
+-- name: GetBundlePatch :one
+SELECT * FROM bundle_patches
+WHERE project_id = $1 AND base_hash = $2 AND target_hash = $3;
+
+-- name: SetBundlePatchReady :exec
+UPDATE bundle_patches
+SET status = 'ready', storage_provider = $4, key = $5, url = $6, hash = $7, size = $8
+WHERE project_id = $1 AND base_hash = $2 AND target_hash = $3;
+
+-- name: SetBundlePatchFailed :exec
+UPDATE bundle_patches
+SET status = 'failed'
+WHERE project_id = $1 AND base_hash = $2 AND target_hash = $3;
+
+-- name: DeleteBundlePatch :exec
+DELETE FROM bundle_patches
+WHERE project_id = $1 AND base_hash = $2 AND target_hash = $3;
+
+-- name: GetUpdateLaunchAsset :one
+SELECT a.* FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = $1
+AND a.update_id = $2
+AND starts_with(a.file_name, '_expo/static/js/')
+LIMIT 1;
+
+-- name: ListObsoleteBundlePatches :many
+-- Patches whose base or target launch asset no update of the project uses
+-- anymore.
+SELECT p.* FROM bundle_patches p
+WHERE p.created_at < sqlc.arg('created_before')
+AND NOT (
+    EXISTS (
+        SELECT 1 FROM assets a JOIN updates u ON u.id = a.update_id
+        WHERE u.project_id = p.project_id AND a.hash = p.base_hash
+    )
+    AND EXISTS (
+        SELECT 1 FROM assets a JOIN updates u ON u.id = a.update_id
+        WHERE u.project_id = p.project_id AND a.hash = p.target_hash
+    )
+)
+ORDER BY p.created_at
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,54 @@
+-- name: CreateChannel :one
+INSERT INTO channels (project_id, name, branch_id, rollout_branch_id, rollout_percentage)
+VALUES (
+    sqlc.arg('project_id'),
+    sqlc.arg('name'),
+    sqlc.arg('branch_id'),
+    sqlc.narg('rollout_branch_id'),
+    sqlc.arg('rollout_percentage')
+)
+RETURNING *;
+
+-- name: GetChannelByName :one
+SELECT
+    c.id,
+    c.project_id,
+    c.name,
+    b.name AS branch_name,
+    rb.name AS rollout_branch_name,
+    c.rollout_percentage,
+    c.created_at,
+    c.updated_at
+FROM channels c
+JOIN branches b ON b.id = c.branch_id
+LEFT JOIN branches rb ON rb.id = c.rollout_branch_id
+WHERE c.project_id = sqlc.arg('project_id') AND c.name = sqlc.arg('name');
+
+-- name: ListChannels :many
+SELECT
+    c.id,
+    c.project_id,
+    c.name,
+    b.name AS branch_name,
+    rb.name AS rollout_branch_name,
+    c.rollout_percentage,
+    c.created_at,
+    c.updated_at
+FROM channels c
+JOIN branches b ON b.id = c.branch_id
+LEFT JOIN branches rb ON rb.id = c.rollout_branch_id
+WHERE c.project_id = $1
+ORDER BY c.name;
+
+-- name: UpdateChannel :one
+UPDATE channels
+SET branch_id = sqlc.arg('branch_id'),
+    rollout_branch_id = sqlc.narg('rollout_branch_id'),
+    rollout_percentage = sqlc.arg('rollout_percentage'),
+    updated_at = now()
+WHERE project_id = sqlc.arg('project_id') AND name = sqlc.arg('name')
+RETURNING *;
+
+-- name: DeleteChannel :exec
+DELETE FROM channels
+WHERE project_id = sqlc.arg('project_id') AND name = sqlc.arg('name');
@@ -0,0 +1,34 @@
+-- name: CreateDirectUpload :one
+INSERT INTO direct_uploads (update_id, platform, activate, storage_provider, expo_config, assets, expires_at)
+VALUES (sqlc.arg('update_id'), sqlc.arg('platform'), sqlc.arg('activate'), sqlc.arg('storage_provider'), sqlc.arg('expo_config'), sqlc.arg('assets'), sqlc.arg('expires_at'))
+RETURNING *;
+
+-- name: CreateDirectUploadBlob :exec
+INSERT INTO direct_upload_blobs (direct_upload_id, hash, key, mime_type, size)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetDirectUpload :one
+SELECT * FROM direct_uploads
+WHERE id = sqlc.arg('id') AND update_id = sqlc.arg('update_id') AND expires_at > now();
+
+-- name: GetDirectUploadBlob :one
+SELECT * FROM direct_upload_blobs
+WHERE direct_upload_id = $1 AND hash = $2;
+
+-- name: ListDirectUploadBlobs :many
+SELECT * FROM direct_upload_blobs
+WHERE direct_upload_id = $1
+ORDER BY hash;
+
+-- name: SetDirectUploadBlobURL :exec
+UPDATE direct_upload_blobs
+SET url = $3
+WHERE direct_upload_id = $1 AND hash = $2;
+
+-- name: DeleteDirectUpload :exec
+DELETE FROM direct_uploads WHERE id = $1;
+
+-- name: DeleteExpiredDirectUploads :execrows
+-- Blobs of expired uploads were queued for garbage collection when the
+-- upload was created, so only the rows are left to remove.
+DELETE FROM direct_uploads WHERE expires_at <= $1;
@@ -4,8 +4,9 @@ INSERT INTO download_events (
     project_id,
     device_hash,
     platform,
-    channel
-) VALUES ($1, $2, $3, $4, $5)
+    channel,
+    device_id_source
+) VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetRecentDownloadsByProject :many
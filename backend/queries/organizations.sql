@@ -0,0 +1,35 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (slug, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListOrganizations :many
+SELECT * FROM organizations
+ORDER BY created_at DESC;
+
+-- name: ListUserOrganizations :many
+SELECT o.id, o.slug, o.name, o.created_at, om.role
+FROM organizations o
+JOIN organization_members om ON om.organization_id = o.id
+WHERE om.user_id = $1
+ORDER BY o.created_at DESC;
+
+-- name: GetOrganizationRole :one
+SELECT role FROM organization_members
+WHERE organization_id = sqlc.arg('organization_id') AND user_id = sqlc.arg('user_id');
+
+-- name: ListOrganizationMembers :many
+SELECT u.id AS user_id, u.email, u.name, om.role, om.created_at
+FROM organization_members om
+JOIN users u ON u.id = om.user_id
+WHERE om.organization_id = $1
+ORDER BY u.email;
+
+-- name: UpsertOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id, role)
+VALUES (sqlc.arg('organization_id'), sqlc.arg('user_id'), sqlc.arg('role'))
+ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+
+-- name: RemoveOrganizationMember :execrows
+DELETE FROM organization_members
+WHERE organization_id = sqlc.arg('organization_id') AND user_id = sqlc.arg('user_id');
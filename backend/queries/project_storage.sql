@@ -0,0 +1,30 @@
+-- name: GetActiveProjectStorage :one
+SELECT * FROM project_storage
+WHERE project_id = $1 AND active;
+
+-- name: GetProjectStorage :one
+SELECT * FROM project_storage
+WHERE id = $1;
+
+-- name: CreateProjectStorage :one
+INSERT INTO project_storage (project_id, provider, bucket, prefix, config)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: UpdateProjectStorageConfig :one
+-- Replaces the settings of a row whose bucket and prefix stay the same,
+-- such as rotated credentials.
+UPDATE project_storage
+SET config = $2, checked_at = now(), updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkProjectStorageChecked :exec
+UPDATE project_storage
+SET checked_at = now()
+WHERE id = $1;
+
+-- name: DeactivateProjectStorage :execrows
+UPDATE project_storage
+SET active = false, updated_at = now()
+WHERE project_id = $1 AND active;
@@ -1,15 +1,22 @@
 -- name: GetProjectBySlug :one
-SELECT id, slug, name, description, created_at FROM projects WHERE slug = $1;
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects WHERE slug = $1;
 
 -- name: GetProjectByID :one
-SELECT id, slug, name, description, created_at FROM projects WHERE id = $1;
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects WHERE id = $1;
 
 -- name: ListProjects :many
-SELECT id, slug, name, description, created_at FROM projects ORDER BY created_at DESC;
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects ORDER BY created_at DESC;
+
+-- name: ListProjectsForUser :many
+-- Projects a user can see, through an organization or a direct grant.
+SELECT id, slug, name, description, created_at, organization_id, max_upload_size FROM projects
+WHERE organization_id IN (SELECT organization_id FROM organization_members WHERE organization_members.user_id = $1)
+   OR id IN (SELECT project_id FROM project_members WHERE project_members.user_id = $1)
+ORDER BY created_at DESC;
 
 -- name: CreateProject :one
-INSERT INTO projects (slug, name, description) 
-VALUES ($1, $2, $3) 
+INSERT INTO projects (slug, name, description, organization_id) 
+VALUES ($1, $2, $3, $4) 
 RETURNING *;
 
 -- name: DeleteProject :one
@@ -19,4 +26,40 @@ DELETE FROM projects WHERE id = $1 RETURNING *;
 UPDATE projects 
 SET name = $2, description = $3 
 WHERE id = $1 
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SetProjectMaxUploadSize :one
+-- NULL falls back to the server's MAX_UPLOAD_SIZE_MB.
+UPDATE projects
+SET max_upload_size = sqlc.narg('max_upload_size')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: GetProjectRoles :one
+-- The roles a user holds on a project through its organization and
+-- directly. Either is empty when the user has none.
+SELECT
+    COALESCE(om.role, '')::text AS organization_role,
+    COALESCE(pm.role, '')::text AS project_role
+FROM projects p
+LEFT JOIN organization_members om
+    ON om.organization_id = p.organization_id AND om.user_id = sqlc.arg('user_id')
+LEFT JOIN project_members pm
+    ON pm.project_id = p.id AND pm.user_id = sqlc.arg('user_id')
+WHERE p.id = sqlc.arg('project_id');
+
+-- name: ListProjectMembers :many
+SELECT u.id AS user_id, u.email, u.name, pm.role, pm.created_at
+FROM project_members pm
+JOIN users u ON u.id = pm.user_id
+WHERE pm.project_id = $1
+ORDER BY u.email;
+
+-- name: UpsertProjectMember :exec
+INSERT INTO project_members (project_id, user_id, role)
+VALUES (sqlc.arg('project_id'), sqlc.arg('user_id'), sqlc.arg('role'))
+ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role;
+
+-- name: RemoveProjectMember :execrows
+DELETE FROM project_members
+WHERE project_id = sqlc.arg('project_id') AND user_id = sqlc.arg('user_id');
@@ -0,0 +1,63 @@
+-- name: UpsertRolloutSchedule :one
+INSERT INTO rollout_schedules (update_id, steps, step_interval_seconds, current_step, status, next_step_at)
+VALUES (
+    sqlc.arg('update_id'),
+    sqlc.arg('steps'),
+    sqlc.arg('step_interval_seconds'),
+    0,
+    sqlc.arg('status'),
+    sqlc.narg('next_step_at')
+)
+ON CONFLICT (update_id) DO UPDATE
+SET steps = EXCLUDED.steps,
+    step_interval_seconds = EXCLUDED.step_interval_seconds,
+    current_step = 0,
+    status = EXCLUDED.status,
+    next_step_at = EXCLUDED.next_step_at,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetRolloutSchedule :one
+SELECT * FROM rollout_schedules
+WHERE update_id = $1;
+
+-- name: ListDueRolloutSchedules :many
+SELECT
+    s.update_id,
+    s.steps,
+    s.step_interval_seconds,
+    s.current_step,
+    u.project_id,
+    u.is_active,
+    u.rollout_percentage
+FROM rollout_schedules s
+JOIN updates u ON u.id = s.update_id
+WHERE s.status = 'running' AND s.next_step_at <= $1
+ORDER BY s.next_step_at
+LIMIT $2
+FOR UPDATE OF s SKIP LOCKED;
+
+-- name: UpdateRolloutSchedule :one
+UPDATE rollout_schedules
+SET current_step = sqlc.arg('current_step'),
+    status = sqlc.arg('status'),
+    next_step_at = sqlc.narg('next_step_at'),
+    updated_at = now()
+WHERE update_id = sqlc.arg('update_id')
+RETURNING *;
+
+-- name: PauseRunningRolloutSchedule :execrows
+UPDATE rollout_schedules
+SET status = 'paused',
+    next_step_at = NULL,
+    updated_at = now()
+WHERE update_id = $1 AND status = 'running';
+
+-- name: CreateRolloutEvent :exec
+INSERT INTO rollout_events (update_id, action, from_percentage, to_percentage, actor)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ListRolloutEvents :many
+SELECT * FROM rollout_events
+WHERE update_id = $1
+ORDER BY created_at, id;
@@ -0,0 +1,17 @@
+-- name: CreateSigningCertificate :one
+INSERT INTO signing_certificates (project_id, key_id, certificate)
+VALUES (sqlc.arg('project_id'), sqlc.arg('key_id'), sqlc.arg('certificate'))
+RETURNING *;
+
+-- name: ListSigningCertificates :many
+SELECT * FROM signing_certificates
+WHERE project_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetSigningCertificate :one
+SELECT * FROM signing_certificates
+WHERE project_id = sqlc.arg('project_id') AND key_id = sqlc.arg('key_id');
+
+-- name: DeleteSigningCertificate :exec
+DELETE FROM signing_certificates
+WHERE project_id = sqlc.arg('project_id') AND key_id = sqlc.arg('key_id');
@@ -0,0 +1,39 @@
+-- name: CreateSigningKey :one
+INSERT INTO signing_keys (project_id, key_id, algorithm, private_key, certificate, is_primary)
+VALUES (sqlc.arg('project_id'), sqlc.arg('key_id'), sqlc.arg('algorithm'), sqlc.arg('private_key'), sqlc.arg('certificate'), sqlc.arg('is_primary'))
+RETURNING *;
+
+-- name: ListSigningKeys :many
+SELECT id, project_id, key_id, algorithm, certificate, is_primary, created_at, retired_at
+FROM signing_keys
+WHERE project_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetActiveSigningKey :one
+SELECT * FROM signing_keys
+WHERE project_id = sqlc.arg('project_id') AND key_id = sqlc.arg('key_id') AND retired_at IS NULL;
+
+-- name: GetPrimarySigningKey :one
+SELECT * FROM signing_keys
+WHERE project_id = $1 AND is_primary AND retired_at IS NULL;
+
+-- name: ClearPrimarySigningKey :exec
+UPDATE signing_keys
+SET is_primary = false
+WHERE project_id = $1 AND is_primary;
+
+-- name: RetireSigningKey :one
+UPDATE signing_keys
+SET retired_at = now()
+WHERE project_id = sqlc.arg('project_id') AND key_id = sqlc.arg('key_id') AND retired_at IS NULL AND NOT is_primary
+RETURNING id, project_id, key_id, algorithm, certificate, is_primary, created_at, retired_at;
+
+-- name: ListUnsealedSigningKeys :many
+-- Keys stored in the clear, from before private keys were sealed.
+SELECT id, project_id, private_key FROM signing_keys
+WHERE private_key NOT LIKE 'sealed:%';
+
+-- name: UpdateSigningKeyPrivateKey :exec
+UPDATE signing_keys
+SET private_key = sqlc.arg('private_key')
+WHERE id = sqlc.arg('id');
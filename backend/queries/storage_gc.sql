@@ -0,0 +1,63 @@
+-- name: QueueUpdateAssetsForGC :exec
+INSERT INTO storage_gc_queue (key, storage_provider, mime_type)
+SELECT DISTINCT ON (a.key, a.storage_provider) a.key, a.storage_provider, a.mime_type
+FROM assets a
+WHERE a.update_id = $1
+ON CONFLICT (key, storage_provider) DO UPDATE
+SET queued_at = EXCLUDED.queued_at;
+
+-- name: QueueProjectAssetsForGC :exec
+INSERT INTO storage_gc_queue (key, storage_provider, mime_type)
+SELECT DISTINCT ON (a.key, a.storage_provider) a.key, a.storage_provider, a.mime_type
+FROM assets a
+JOIN updates u ON u.id = a.update_id
+WHERE u.project_id = $1
+ON CONFLICT (key, storage_provider) DO UPDATE
+SET queued_at = EXCLUDED.queued_at;
+
+-- name: QueueStorageObjectForGC :exec
+INSERT INTO storage_gc_queue (key, storage_provider, mime_type)
+VALUES ($1, $2, $3)
+ON CONFLICT (key, storage_provider) DO UPDATE
+SET queued_at = EXCLUDED.queued_at;
+
+-- name: ListGCCandidates :many
+SELECT
+    q.key,
+    q.storage_provider,
+    q.mime_type,
+    q.queued_at,
+    (
+        SELECT COUNT(*)
+        FROM assets a
+        WHERE a.key = q.key AND a.storage_provider = q.storage_provider
+    )::bigint AS reference_count
+FROM storage_gc_queue q
+WHERE q.queued_at < $1
+ORDER BY q.queued_at
+LIMIT $2;
+
+-- name: CountAssetReferences :one
+SELECT COUNT(*) FROM assets
+WHERE key = $1 AND storage_provider = $2;
+
+-- name: LockGCQueueEntry :one
+-- Locks a queue entry that is still older than the cutoff for the rest of
+-- the transaction. Entries an upload is holding are skipped.
+SELECT key FROM storage_gc_queue
+WHERE key = $1 AND storage_provider = $2 AND queued_at < $3
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteGCQueueEntry :exec
+DELETE FROM storage_gc_queue
+WHERE key = $1 AND storage_provider = $2;
+
+-- name: ListReferencedAssetKeys :many
+SELECT key FROM assets
+WHERE storage_provider = $1
+UNION
+SELECT key FROM bundle_patches
+WHERE storage_provider = $1 AND key <> ''
+UNION
+SELECT variant_key FROM asset_variants
+WHERE storage_provider = $1;
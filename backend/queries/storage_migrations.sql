@@ -0,0 +1,111 @@
+-- name: CreateStorageMigration :one
+INSERT INTO storage_migrations (project_id, source_provider, target_provider, delete_source, total_objects)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetStorageMigration :one
+SELECT * FROM storage_migrations
+WHERE id = $1;
+
+-- name: ListStorageMigrations :many
+SELECT * FROM storage_migrations
+ORDER BY created_at DESC
+LIMIT $1;
+
+-- name: CountOpenStorageMigrations :one
+SELECT COUNT(*) FROM storage_migrations
+WHERE source_provider = $1 AND status IN ('running', 'paused');
+
+-- name: CountSignedUpdatesOnProvider :one
+-- Counts updates with a publish-time signed manifest that has assets on the
+-- provider. Their manifests embed the provider's URLs unless assets go
+-- through the asset proxy.
+SELECT COUNT(*) FROM updates u
+WHERE u.signed_manifest IS NOT NULL
+  AND (sqlc.narg('project_id')::uuid IS NULL OR u.project_id = sqlc.narg('project_id'))
+  AND EXISTS (
+    SELECT 1 FROM assets a
+    WHERE a.update_id = u.id AND a.storage_provider = sqlc.arg('source_provider')
+  );
+
+-- name: CountStorageMigrationObjects :one
+-- Counts the blobs ListStorageMigrationObjects goes through.
+SELECT ((
+    SELECT COUNT(DISTINCT a.key) FROM assets a
+    JOIN updates u ON u.id = a.update_id
+    WHERE a.storage_provider = sqlc.arg('source_provider')
+      AND (sqlc.narg('project_id')::uuid IS NULL OR u.project_id = sqlc.narg('project_id'))
+) + (
+    SELECT COUNT(*) FROM bundle_patches p
+    WHERE p.storage_provider = sqlc.arg('source_provider') AND p.status = 'ready'
+      AND (sqlc.narg('project_id')::uuid IS NULL OR p.project_id = sqlc.narg('project_id'))
+))::bigint AS total;
+
+-- name: ListStorageMigrationObjects :many
+-- Lists the blobs on the source provider after after_key, in key order:
+-- assets once per key, and ready bundle patches.
+SELECT key, hash, mime_type, url, size, is_patch FROM (
+    SELECT DISTINCT ON (a.key) a.key, a.hash, a.mime_type, a.url, a.size, false AS is_patch
+    FROM assets a
+    JOIN updates u ON u.id = a.update_id
+    WHERE a.storage_provider = sqlc.arg('source_provider')
+      AND (sqlc.narg('project_id')::uuid IS NULL OR u.project_id = sqlc.narg('project_id'))
+      AND a.key > sqlc.arg('after_key')
+    UNION ALL
+    SELECT p.key, p.hash, 'application/octet-stream', p.url, p.size, true
+    FROM bundle_patches p
+    WHERE p.storage_provider = sqlc.arg('source_provider') AND p.status = 'ready'
+      AND (sqlc.narg('project_id')::uuid IS NULL OR p.project_id = sqlc.narg('project_id'))
+      AND p.key > sqlc.arg('after_key')
+) objects
+ORDER BY key
+LIMIT sqlc.arg('limit');
+
+-- name: ClaimStorageMigration :one
+-- Leases the oldest running migration that no other server holds.
+UPDATE storage_migrations
+SET lease_until = sqlc.arg('lease_until')
+WHERE id = (
+    SELECT id FROM storage_migrations
+    WHERE status = 'running' AND lease_until <= sqlc.arg('now')
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: AdvanceStorageMigration :one
+-- Records a finished batch and extends the lease. Nothing is returned once
+-- the migration is no longer running, for example after a pause.
+UPDATE storage_migrations
+SET last_key = sqlc.arg('last_key'),
+    copied_objects = copied_objects + sqlc.arg('copied_objects'),
+    copied_bytes = copied_bytes + sqlc.arg('copied_bytes'),
+    failed_objects = failed_objects + sqlc.arg('failed_objects'),
+    last_error = CASE WHEN sqlc.arg('last_error')::text = '' THEN last_error ELSE sqlc.arg('last_error') END,
+    lease_until = sqlc.arg('lease_until'),
+    updated_at = now()
+WHERE id = sqlc.arg('id') AND status = 'running'
+RETURNING *;
+
+-- name: SetStorageMigrationStatus :one
+-- Moves a migration in one of from_statuses to status and releases its
+-- lease, so a resumed migration is picked up by the next run.
+UPDATE storage_migrations
+SET status = sqlc.arg('status'),
+    last_error = COALESCE(sqlc.narg('last_error'), last_error),
+    lease_until = now(),
+    updated_at = now(),
+    completed_at = CASE WHEN sqlc.arg('status') = 'completed' THEN now() END
+WHERE id = sqlc.arg('id') AND status = ANY(sqlc.arg('from_statuses')::text[])
+RETURNING *;
+
+-- name: MoveAssetsToProvider :exec
+UPDATE assets
+SET storage_provider = sqlc.arg('target_provider'), url = sqlc.arg('url')
+WHERE storage_provider = sqlc.arg('source_provider') AND key = sqlc.arg('key');
+
+-- name: MoveBundlePatchesToProvider :exec
+UPDATE bundle_patches
+SET storage_provider = sqlc.arg('target_provider'), url = sqlc.arg('url')
+WHERE storage_provider = sqlc.arg('source_provider') AND key = sqlc.arg('key');
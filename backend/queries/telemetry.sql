@@ -0,0 +1,53 @@
+-- name: RecordUpdateTelemetry :one
+INSERT INTO update_telemetry (update_id, project_id, launches, js_errors, crashes)
+VALUES (
+    sqlc.arg('update_id'),
+    sqlc.arg('project_id'),
+    sqlc.arg('launches'),
+    sqlc.arg('js_errors'),
+    sqlc.arg('crashes')
+)
+ON CONFLICT (update_id) DO UPDATE
+SET launches = update_telemetry.launches + EXCLUDED.launches,
+    js_errors = update_telemetry.js_errors + EXCLUDED.js_errors,
+    crashes = update_telemetry.crashes + EXCLUDED.crashes,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetUpdateTelemetry :one
+SELECT * FROM update_telemetry
+WHERE update_id = $1;
+
+-- name: MarkUpdateRolledBack :execrows
+UPDATE update_telemetry
+SET rolled_back_at = now(), rollback_reason = $2
+WHERE update_id = $1 AND rolled_back_at IS NULL;
+
+-- name: GetAutoRollbackTarget :one
+-- The newest earlier update on the same branch, platform and runtime version
+-- that is not a rollback directive and was not itself rolled back.
+SELECT u.* FROM updates u
+LEFT JOIN update_telemetry t ON t.update_id = u.id
+WHERE u.project_id = sqlc.arg('project_id')
+AND u.channel = sqlc.arg('channel')
+AND u.runtime_version = sqlc.arg('runtime_version')
+AND u.platform = sqlc.arg('platform')
+AND u.created_at < sqlc.arg('created_at')
+AND u.is_rollback = false
+AND t.rolled_back_at IS NULL
+ORDER BY u.created_at DESC
+LIMIT 1;
+
+-- name: GetAutoRollbackPolicy :one
+SELECT * FROM auto_rollback_policies
+WHERE project_id = $1;
+
+-- name: UpsertAutoRollbackPolicy :one
+INSERT INTO auto_rollback_policies (project_id, enabled, max_error_rate, min_launches)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (project_id) DO UPDATE
+SET enabled = EXCLUDED.enabled,
+    max_error_rate = EXCLUDED.max_error_rate,
+    min_launches = EXCLUDED.min_launches,
+    updated_at = now()
+RETURNING *;
@@ -93,3 +93,8 @@ SELECT
 FROM stats
 FULL OUTER JOIN events
 ON stats.update_id = events.update_id;
+
+-- name: SetUpdateManifestSignature :exec
+UPDATE updates
+SET signed_manifest = sqlc.arg('signed_manifest'), manifest_signature = sqlc.arg('manifest_signature')
+WHERE id = sqlc.arg('id');
@@ -0,0 +1,22 @@
+-- name: CreateUploadSession :one
+INSERT INTO upload_sessions (update_id, platform, activate, upload_length, expires_at)
+VALUES (sqlc.arg('update_id'), sqlc.arg('platform'), sqlc.arg('activate'), sqlc.arg('upload_length'), sqlc.arg('expires_at'))
+RETURNING *;
+
+-- name: GetUploadSession :one
+SELECT * FROM upload_sessions
+WHERE id = sqlc.arg('id') AND update_id = sqlc.arg('update_id') AND expires_at > now();
+
+-- name: AdvanceUploadSession :execrows
+-- Only moves the offset if no other request has moved it since it was read.
+UPDATE upload_sessions
+SET upload_offset = sqlc.arg('new_offset')
+WHERE id = sqlc.arg('id') AND upload_offset = sqlc.arg('upload_offset');
+
+-- name: DeleteUploadSession :exec
+DELETE FROM upload_sessions WHERE id = $1;
+
+-- name: DeleteExpiredUploadSessions :many
+DELETE FROM upload_sessions
+WHERE expires_at <= $1
+RETURNING id;
@@ -0,0 +1,27 @@
+-- name: CreateUser :one
+INSERT INTO users (email, name, password_hash)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1;
+
+-- name: CreateUserSession :one
+INSERT INTO user_sessions (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetUserBySessionToken :one
+SELECT u.id, u.email, u.name
+FROM user_sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.token_hash = $1 AND s.expires_at > now();
+
+-- name: DeleteUserSession :exec
+DELETE FROM user_sessions
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredUserSessions :exec
+DELETE FROM user_sessions
+WHERE user_id = $1 AND expires_at <= now();
@@ -0,0 +1,60 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (project_id, url, secret, events)
+VALUES (sqlc.arg('project_id'), sqlc.arg('url'), sqlc.arg('secret'), sqlc.arg('events'))
+RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+WHERE project_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = sqlc.arg('id') AND project_id = sqlc.arg('project_id');
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = sqlc.arg('id') AND project_id = sqlc.arg('project_id');
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues the event for every webhook of the project subscribed to it.
+INSERT INTO webhook_deliveries (webhook_id, event, payload)
+SELECT id, sqlc.arg('event')::text, sqlc.arg('payload')::jsonb
+FROM webhooks
+WHERE project_id = sqlc.arg('project_id') AND sqlc.arg('event')::text = ANY(events);
+
+-- name: ClaimWebhookDeliveries :many
+-- Moves due deliveries' next attempt to lease_until, so that other
+-- instances skip them while they are being sent.
+UPDATE webhook_deliveries d
+SET next_attempt_at = sqlc.arg('lease_until')
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg('now')
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+  )
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret;
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg('status'),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    response_status = sqlc.narg('response_status'),
+    last_error = sqlc.arg('last_error'),
+    delivered_at = sqlc.narg('delivered_at')
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg('webhook_id')
+ORDER BY id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountWebhookDeliveries :one
+SELECT COUNT(*) FROM webhook_deliveries
+WHERE webhook_id = $1;
@@ -21,6 +21,7 @@ func main() {
 	rootCmd.AddCommand(commands.InstallCmd)
 	rootCmd.AddCommand(commands.UpgradeCmd)
 	rootCmd.AddCommand(commands.LoginCmd)
+	rootCmd.AddCommand(commands.LogoutCmd)
 	rootCmd.AddCommand(commands.InitCommand)
 	rootCmd.AddCommand(commands.LinkCommand)
 	rootCmd.AddCommand(commands.StatusCommand)
@@ -28,9 +29,16 @@ func main() {
 	rootCmd.AddCommand(commands.ListCmd)
 	rootCmd.AddCommand(commands.DeleteCmd)
 	rootCmd.AddCommand(commands.RollbackCmd)
+	rootCmd.AddCommand(commands.PromoteCmd)
+	rootCmd.AddCommand(commands.RolloutCmd)
 	rootCmd.AddCommand(commands.ResetCmd)
 	rootCmd.AddCommand(commands.DoctorCmd)
 	rootCmd.AddCommand(commands.WhoAmICmd)
+	rootCmd.AddCommand(commands.VerifyCmd)
+	rootCmd.AddCommand(commands.BranchCmd)
+	rootCmd.AddCommand(commands.ChannelCmd)
+	rootCmd.AddCommand(commands.AuditCmd)
+	rootCmd.AddCommand(commands.StorageCmd)
 	if err := rootCmd.Execute(); err != nil {
 		errMsg := err.Error()
 		if len(errMsg) > 0 {
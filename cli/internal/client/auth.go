@@ -0,0 +1,71 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+)
+
+type User struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+type LoginResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+	User      User   `json:"user"`
+}
+
+type Organization struct {
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type CurrentUser struct {
+	Root          bool           `json:"root"`
+	User          *User          `json:"user"`
+	Organizations []Organization `json:"organizations"`
+}
+
+type APIKey struct {
+	APIKey    string `json:"api_key"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	KeySuffix string `json:"key_suffix"`
+}
+
+func (c *Client) Login(email, password string) (*LoginResponse, error) {
+	var res LoginResponse
+	body := map[string]string{"email": email, "password": password}
+	if err := c.doSessionJSON("POST", "/api/auth/login", "", body, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) Logout(token string) error {
+	return c.doSessionJSON("POST", "/api/auth/logout", token, nil, http.StatusNoContent, nil)
+}
+
+func (c *Client) CurrentUser(token string) (*CurrentUser, error) {
+	var res CurrentUser
+	if err := c.doSessionJSON("GET", "/api/admin/me", token, nil, http.StatusOK, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateAPIKey creates a project API key as the logged-in user, who needs
+// the admin role on the project.
+func (c *Client) CreateAPIKey(token, projectID, name string) (*APIKey, error) {
+	var key APIKey
+	body := map[string]string{"name": name}
+	path := "/api/admin/projects/" + url.PathEscape(projectID) + "/keys"
+	if err := c.doSessionJSON("POST", path, token, body, http.StatusCreated, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
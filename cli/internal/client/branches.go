@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/vknow360/otaship/cli/internal/utils"
+)
+
+type Branch struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	UpdateCount int64  `json:"update_count"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type Channel struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Branch            string `json:"branch"`
+	RolloutBranch     string `json:"rollout_branch,omitempty"`
+	RolloutPercentage int    `json:"rollout_percentage"`
+	CreatedAt         int64  `json:"created_at"`
+	UpdatedAt         int64  `json:"updated_at"`
+}
+
+type ChannelRequest struct {
+	Name              string `json:"name,omitempty"`
+	Branch            string `json:"branch"`
+	RolloutBranch     string `json:"rollout_branch,omitempty"`
+	RolloutPercentage int    `json:"rollout_percentage"`
+}
+
+func (c *Client) ListBranches(apiKey string) ([]Branch, error) {
+	var branches []Branch
+	if err := c.doJSON("GET", "/api/project/branches", apiKey, nil, http.StatusOK, &branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+func (c *Client) CreateBranch(apiKey, name string) (*Branch, error) {
+	var branch Branch
+	body := map[string]string{"name": name}
+	if err := c.doJSON("POST", "/api/project/branches", apiKey, body, http.StatusCreated, &branch); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+func (c *Client) DeleteBranch(apiKey, name string) error {
+	return c.doJSON("DELETE", "/api/project/branches/"+url.PathEscape(name), apiKey, nil, http.StatusNoContent, nil)
+}
+
+func (c *Client) ListChannels(apiKey string) ([]Channel, error) {
+	var channels []Channel
+	if err := c.doJSON("GET", "/api/project/channels", apiKey, nil, http.StatusOK, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (c *Client) CreateChannel(apiKey string, req *ChannelRequest) (*Channel, error) {
+	var channel Channel
+	if err := c.doJSON("POST", "/api/project/channels", apiKey, req, http.StatusCreated, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (c *Client) UpdateChannel(apiKey, name string, req *ChannelRequest) (*Channel, error) {
+	var channel Channel
+	if err := c.doJSON("PATCH", "/api/project/channels/"+url.PathEscape(name), apiKey, req, http.StatusOK, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (c *Client) DeleteChannel(apiKey, name string) error {
+	return c.doJSON("DELETE", "/api/project/channels/"+url.PathEscape(name), apiKey, nil, http.StatusNoContent, nil)
+}
+
+// doJSON sends an API-key authenticated request with an optional JSON body
+// and decodes the response into out when the expected status comes back.
+func (c *Client) doJSON(method, path, apiKey string, in any, wantStatus int, out any) error {
+	return c.do(method, path, "X-API-Key", apiKey, in, wantStatus, out)
+}
+
+// doSessionJSON is doJSON for admin routes, authenticated with a user
+// session token.
+func (c *Client) doSessionJSON(method, path, token string, in any, wantStatus int, out any) error {
+	return c.do(method, path, "Authorization", "Bearer "+token, in, wantStatus, out)
+}
+
+func (c *Client) do(method, path, authHeader, auth string, in any, wantStatus int, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, &body)
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth != "" {
+		req.Header.Set(authHeader, auth)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		return utils.HandleHTTPError(resp)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
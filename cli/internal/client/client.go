@@ -3,8 +3,10 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -34,7 +36,8 @@ type CreateUpdateRequest struct {
 	ID                string `json:"id"`
 	ProjectID         string `json:"project_id"`
 	RuntimeVersion    string `json:"runtime_version"`
-	Channel           string `json:"channel"`
+	Channel           string `json:"channel,omitempty"`
+	Branch            string `json:"branch,omitempty"`
 	Platform          string `json:"platform"`
 	Message           string `json:"message"`
 	RolloutPercentage int    `json:"rollout_percentage"`
@@ -44,6 +47,7 @@ type CreateUpdateResponse struct {
 	ID        string `json:"id"`
 	ProjectID string `json:"project_id"`
 	Platform  string `json:"platform"`
+	Branch    string `json:"branch"`
 }
 
 func (c *Client) ValidateAPIKey(apiKey string) (*ValidateKeyResponse, error) {
@@ -121,13 +125,32 @@ func (c *Client) CreateUpdate(apiKey string, req *CreateUpdateRequest) (*CreateU
 	return &result, nil
 }
 
-func (c *Client) UploadBundle(projectID, updateID, platform, apiKey, zipPath string) error {
+type UploadBundleResponse struct {
+	UploadedAssets int  `json:"uploadedAssets"`
+	NewAssets      int  `json:"newAssets"`
+	ReusedAssets   int  `json:"reusedAssets"`
+	Activated      bool `json:"activated"`
+}
+
+// UploadBundle uploads the exported bundle for an update. With activate set
+// to false the update stays inactive until a signature is submitted. It
+// uses the server's resumable uploads, which survive dropped connections,
+// and falls back to a single multipart request on servers without them.
+func (c *Client) UploadBundle(projectID, updateID, platform, apiKey, zipPath string, activate bool) (*UploadBundleResponse, error) {
+	result, err := c.uploadBundleResumable(projectID, updateID, platform, apiKey, zipPath, activate)
+	if errors.Is(err, errResumableUnsupported) {
+		return c.uploadBundleMultipart(projectID, updateID, platform, apiKey, zipPath, activate)
+	}
+	return result, err
+}
+
+func (c *Client) uploadBundleMultipart(projectID, updateID, platform, apiKey, zipPath string, activate bool) (*UploadBundleResponse, error) {
 	url := fmt.Sprintf("%s/api/project/%s/updates/%s/upload",
 		c.BaseURL, projectID, updateID)
 
 	file, err := os.Open(zipPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
 
@@ -135,6 +158,9 @@ func (c *Client) UploadBundle(projectID, updateID, platform, apiKey, zipPath str
 	writer := multipart.NewWriter(body)
 
 	writer.WriteField("platform", platform)
+	if !activate {
+		writer.WriteField("activate", "false")
+	}
 
 	part, _ := writer.CreateFormFile("bundle", filepath.Base(zipPath))
 	io.Copy(part, file)
@@ -145,6 +171,55 @@ func (c *Client) UploadBundle(projectID, updateID, platform, apiKey, zipPath str
 	req.Header.Set("X-API-Key", apiKey)
 
 	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.HandleHTTPError(resp)
+	}
+
+	var result UploadBundleResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return &result, nil
+}
+
+// GetUpdateManifest returns the exact manifest bytes the server will serve
+// for an update.
+func (c *Client) GetUpdateManifest(apiKey, updateID string) ([]byte, error) {
+	url := fmt.Sprintf("%s/api/project/updates/%s/manifest", c.BaseURL, updateID)
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-API-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.HandleHTTPError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+type SubmitSignatureRequest struct {
+	KeyID     string `json:"key_id"`
+	Signature string `json:"signature"`
+}
+
+// SubmitSignature hands a publish-time manifest signature to the server,
+// which verifies it and activates the update.
+func (c *Client) SubmitSignature(apiKey, updateID string, req *SubmitSignatureRequest) error {
+	url := fmt.Sprintf("%s/api/project/updates/%s/signature", c.BaseURL, updateID)
+
+	body, _ := json.Marshal(req)
+	httpReq, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -161,12 +236,16 @@ type UpdateSummary struct {
 	ProjectID         string `json:"project_id"`
 	RuntimeVersion    string `json:"runtime_version"`
 	Channel           string `json:"channel"`
+	Branch            string `json:"branch"`
 	Platform          string `json:"platform"`
 	RolloutPercentage int    `json:"rollout_percentage"`
 	IsActive          bool   `json:"is_active"`
 	IsRollback        bool   `json:"is_rollback"`
 	Message           string `json:"message"`
 	CreatedAt         int64  `json:"created_at"`
+	// AssetURLsExpireAt is set for updates signed at publish time whose
+	// asset URLs expire, in Unix milliseconds.
+	AssetURLsExpireAt int64 `json:"asset_urls_expire_at"`
 }
 
 func (c *Client) ListUpdates(apiKey string) ([]UpdateSummary, error) {
@@ -225,6 +304,20 @@ func (c *Client) RollbackUpdate(apiKey, updateID string) (*CreateUpdateResponse,
 	return &result, nil
 }
 
+type PromoteRequest struct {
+	Channel           string `json:"channel"`
+	RolloutPercentage *int   `json:"rollout_percentage,omitempty"`
+}
+
+func (c *Client) PromoteUpdate(apiKey, updateID string, req *PromoteRequest) (*CreateUpdateResponse, error) {
+	var result CreateUpdateResponse
+	path := fmt.Sprintf("/api/project/updates/%s/promote", updateID)
+	if err := c.doJSON("POST", path, apiKey, req, http.StatusCreated, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 type RollbackToEmbeddedRequest struct {
 	Platform       string `json:"platform"`
 	RuntimeVersion string `json:"runtime_version"`
@@ -253,3 +346,63 @@ func (c *Client) RollbackToEmbedded(apiKey, projectID string, req *RollbackToEmb
 	json.NewDecoder(resp.Body).Decode(&result)
 	return &result, nil
 }
+
+type ManifestRequest struct {
+	ProjectID      string
+	Platform       string
+	RuntimeVersion string
+	Channel        string
+	// ExpectSignature is sent as expo-expect-signature, e.g. `sig, keyid="main"`.
+	ExpectSignature string
+}
+
+// ManifestPart is the first part of a multipart manifest response, exactly
+// as served so its signature can be checked.
+type ManifestPart struct {
+	Name      string
+	Body      []byte
+	Signature string
+}
+
+func (c *Client) GetManifest(req *ManifestRequest) (*ManifestPart, error) {
+	url := fmt.Sprintf("%s/api/manifest/%s", c.BaseURL, req.ProjectID)
+
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	httpReq.Header.Set("expo-protocol-version", "1")
+	httpReq.Header.Set("expo-platform", req.Platform)
+	httpReq.Header.Set("expo-runtime-version", req.RuntimeVersion)
+	httpReq.Header.Set("expo-channel-name", req.Channel)
+	if req.ExpectSignature != "" {
+		httpReq.Header.Set("expo-expect-signature", req.ExpectSignature)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.HandleHTTPError(resp)
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, fmt.Errorf("unexpected manifest response type %q", resp.Header.Get("Content-Type"))
+	}
+
+	part, err := multipart.NewReader(resp.Body, params["boundary"]).NextPart()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest response: %w", err)
+	}
+	body, err := io.ReadAll(part)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManifestPart{
+		Name:      part.FormName(),
+		Body:      body,
+		Signature: part.Header.Get("expo-signature"),
+	}, nil
+}
@@ -0,0 +1,277 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vknow360/otaship/cli/internal/utils"
+)
+
+// ErrDirectUploadUnsupported means the server predates direct uploads and
+// the bundle has to be uploaded as a zip.
+var ErrDirectUploadUnsupported = errors.New("server does not support direct uploads")
+
+type DirectUploadAsset struct {
+	Path        string `json:"path"`
+	Hash        string `json:"hash"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+type CreateDirectUploadRequest struct {
+	Platform   string              `json:"platform"`
+	Activate   bool                `json:"activate"`
+	Metadata   json.RawMessage     `json:"metadata"`
+	ExpoConfig json.RawMessage     `json:"expo_config,omitempty"`
+	Assets     []DirectUploadAsset `json:"assets"`
+}
+
+type BlobUpload struct {
+	Hash    string            `json:"hash"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Proxied bool              `json:"proxied"`
+}
+
+type DirectUploadResponse struct {
+	ID        string       `json:"id"`
+	ExpiresAt int64        `json:"expires_at"`
+	Assets    int          `json:"assets"`
+	Reused    int          `json:"reused"`
+	Uploads   []BlobUpload `json:"uploads"`
+}
+
+// exportedFile is a file of an expo export that belongs to the update.
+type exportedFile struct {
+	DirectUploadAsset
+	localPath string
+}
+
+// describeExport lists the launch bundle and assets of platform in an expo
+// export, with their hashes.
+func describeExport(distDir, platform string) (metadata, expoConfig json.RawMessage, files []exportedFile, err error) {
+	metadata, err = os.ReadFile(filepath.Join(distDir, "metadata.json"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read metadata.json: %w", err)
+	}
+	var parsed struct {
+		FileMetadata map[string]struct {
+			Bundle string `json:"bundle"`
+			Assets []struct {
+				Path string `json:"path"`
+			} `json:"assets"`
+		} `json:"fileMetadata"`
+	}
+	if err := json.Unmarshal(metadata, &parsed); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid metadata.json: %w", err)
+	}
+	platformFiles, ok := parsed.FileMetadata[platform]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("metadata.json has no %s bundle", platform)
+	}
+
+	if config, err := os.ReadFile(filepath.Join(distDir, "expoConfig.json")); err == nil {
+		expoConfig = config
+	}
+
+	paths := []string{platformFiles.Bundle}
+	for _, asset := range platformFiles.Assets {
+		paths = append(paths, asset.Path)
+	}
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		p = strings.ReplaceAll(strings.TrimPrefix(p, "./"), "\\", "/")
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		file, err := hashExportedFile(distDir, p)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files, file)
+	}
+	return metadata, expoConfig, files, nil
+}
+
+func hashExportedFile(distDir, name string) (exportedFile, error) {
+	localPath := filepath.Join(distDir, filepath.FromSlash(name))
+	f, err := os.Open(localPath)
+	if err != nil {
+		return exportedFile{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	hasher := sha256.New()
+	hasher.Write(head[:n])
+	rest, err := io.Copy(hasher, f)
+	if err != nil {
+		return exportedFile{}, err
+	}
+
+	return exportedFile{
+		DirectUploadAsset: DirectUploadAsset{
+			Path:        name,
+			Hash:        base64.RawURLEncoding.EncodeToString(hasher.Sum(nil)),
+			Size:        int64(n) + rest,
+			ContentType: http.DetectContentType(head[:n]),
+		},
+		localPath: localPath,
+	}, nil
+}
+
+// PublishAssets publishes the platform's update from an expo export in
+// distDir without zipping it. Only files the project does not store yet are
+// uploaded, straight to storage when the server can presign them.
+func (c *Client) PublishAssets(projectID, updateID, platform, apiKey, distDir string, activate bool) (*UploadBundleResponse, error) {
+	metadata, expoConfig, files, err := describeExport(distDir, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	req := CreateDirectUploadRequest{
+		Platform:   platform,
+		Activate:   activate,
+		Metadata:   metadata,
+		ExpoConfig: expoConfig,
+	}
+	byHash := make(map[string]exportedFile, len(files))
+	for _, f := range files {
+		req.Assets = append(req.Assets, f.DirectUploadAsset)
+		byHash[f.Hash] = f
+	}
+
+	endpoint := fmt.Sprintf("%s/api/project/%s/updates/%s/direct-uploads", c.BaseURL, projectID, updateID)
+	body, _ := json.Marshal(req)
+	httpReq, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		if resp.Header.Get("Content-Type") != "application/json" {
+			return nil, ErrDirectUploadUnsupported
+		}
+		return nil, utils.HandleHTTPError(resp)
+	case http.StatusRequestEntityTooLarge:
+		return nil, utils.NewUserError("Bundle is too large", "Ask your OTAShip admin to raise the project's upload limit")
+	default:
+		return nil, utils.HandleHTTPError(resp)
+	}
+
+	var plan DirectUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		return nil, fmt.Errorf("invalid direct upload response: %w", err)
+	}
+
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	for _, upload := range plan.Uploads {
+		file, ok := byHash[upload.Hash]
+		if !ok {
+			return nil, fmt.Errorf("server asked for unknown blob %s", upload.Hash)
+		}
+		target, err := base.Parse(upload.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload URL for %s: %w", file.Path, err)
+		}
+		if err := c.uploadBlob(target.String(), apiKey, upload, file); err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", file.Path, err)
+		}
+	}
+
+	finalize, _ := http.NewRequest("POST", endpoint+"/"+plan.ID+"/finalize", nil)
+	finalize.Header.Set("X-API-Key", apiKey)
+	resp, err = http.DefaultClient.Do(finalize)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.HandleHTTPError(resp)
+	}
+
+	var result UploadBundleResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return &result, nil
+}
+
+// uploadBlob sends one file as the server described, retrying network
+// errors, rate limiting and server errors.
+func (c *Client) uploadBlob(target, apiKey string, upload BlobUpload, file exportedFile) error {
+	for attempt := 0; ; attempt++ {
+		err := c.sendBlob(target, apiKey, upload, file)
+		var transient *transientError
+		if err == nil || !errors.As(err, &transient) || attempt >= maxUploadRetries {
+			return err
+		}
+		time.Sleep(max(uploadRetryDelay(attempt+1), transient.retryAfter))
+	}
+}
+
+func (c *Client) sendBlob(target, apiKey string, upload BlobUpload, file exportedFile) error {
+	f, err := os.Open(file.localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	req, _ := http.NewRequest(upload.Method, target, f)
+	req.ContentLength = file.Size
+	for name, value := range upload.Headers {
+		req.Header.Set(name, value)
+	}
+	// Presigned requests go to storage, which must not see the API key.
+	if upload.Proxied {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &transientError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		// Proxied blobs count against the API's rate limit.
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &transientError{
+			err:        fmt.Errorf("HTTP %d", resp.StatusCode),
+			retryAfter: time.Duration(seconds) * time.Second,
+		}
+	}
+	if upload.Proxied {
+		return utils.HandleHTTPError(resp)
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("storage rejected the upload with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishAssets(t *testing.T) {
+	dist := t.TempDir()
+	files := map[string]string{
+		"metadata.json":                 `{"fileMetadata":{"ios":{"bundle":"_expo/static/js/ios/index.hbc","assets":[{"path":"assets/a","ext":"png"},{"path":"assets/b","ext":"png"}]}}}`,
+		"_expo/static/js/ios/index.hbc": "bundle",
+		"assets/a":                      "same",
+		"assets/b":                      "same",
+	}
+	for name, content := range files {
+		path := filepath.Join(dist, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	received := map[string]string{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/direct-uploads"):
+			var req CreateDirectUploadRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if len(req.Assets) != 3 {
+				t.Errorf("Sent %d assets, want 3", len(req.Assets))
+			}
+			res := DirectUploadResponse{ID: "du1", Assets: 3}
+			for _, a := range req.Assets {
+				switch a.Path {
+				case "_expo/static/js/ios/index.hbc":
+					res.Uploads = append(res.Uploads, BlobUpload{Hash: a.Hash, Method: "PUT", URL: srv.URL + "/storage/bundle"})
+				case "assets/a":
+					res.Uploads = append(res.Uploads, BlobUpload{Hash: a.Hash, Method: "PUT", URL: "direct-uploads/du1/blobs/" + a.Hash, Proxied: true})
+				}
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(res)
+		case r.Method == "PUT":
+			body, _ := io.ReadAll(r.Body)
+			received[r.URL.Path] = string(body)
+			if strings.HasPrefix(r.URL.Path, "/storage/") != (r.Header.Get("X-API-Key") == "") {
+				t.Errorf("%s got X-API-Key %q", r.URL.Path, r.Header.Get("X-API-Key"))
+			}
+			w.WriteHeader(http.StatusOK)
+		case strings.HasSuffix(r.URL.Path, "/direct-uploads/du1/finalize"):
+			w.Write([]byte(`{"uploadedAssets":3,"newAssets":2,"reusedAssets":1,"activated":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{BaseURL: srv.URL}
+	result, err := c.PublishAssets("p1", "u1", "ios", "key", dist, true)
+	if err != nil {
+		t.Fatalf("PublishAssets failed: %v", err)
+	}
+	if result.NewAssets != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if received["/storage/bundle"] != "bundle" || len(received) != 2 {
+		t.Errorf("Storage received %v", received)
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type RolloutSchedule struct {
+	Steps       []int  `json:"steps"`
+	Interval    string `json:"interval"`
+	CurrentStep int    `json:"current_step"`
+	Status      string `json:"status"`
+	NextStepAt  int64  `json:"next_step_at,omitempty"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+type RolloutEvent struct {
+	Action         string `json:"action"`
+	FromPercentage int    `json:"from_percentage"`
+	ToPercentage   int    `json:"to_percentage"`
+	Actor          string `json:"actor"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+type Rollout struct {
+	UpdateID          string           `json:"update_id"`
+	RolloutPercentage int              `json:"rollout_percentage"`
+	IsActive          bool             `json:"is_active"`
+	Schedule          *RolloutSchedule `json:"schedule"`
+	Events            []RolloutEvent   `json:"events"`
+}
+
+type RolloutScheduleRequest struct {
+	Steps    []int  `json:"steps"`
+	Interval string `json:"interval"`
+}
+
+func (c *Client) GetRollout(apiKey, updateID string) (*Rollout, error) {
+	var result Rollout
+	path := fmt.Sprintf("/api/project/updates/%s/rollout", updateID)
+	if err := c.doJSON("GET", path, apiKey, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ScheduleRollout(apiKey, updateID string, req *RolloutScheduleRequest) (*Rollout, error) {
+	var result Rollout
+	path := fmt.Sprintf("/api/project/updates/%s/rollout/schedule", updateID)
+	if err := c.doJSON("PUT", path, apiKey, req, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) PauseRollout(apiKey, updateID string) (*Rollout, error) {
+	return c.rolloutAction(apiKey, updateID, "pause")
+}
+
+func (c *Client) ResumeRollout(apiKey, updateID string) (*Rollout, error) {
+	return c.rolloutAction(apiKey, updateID, "resume")
+}
+
+func (c *Client) rolloutAction(apiKey, updateID, action string) (*Rollout, error) {
+	var result Rollout
+	path := fmt.Sprintf("/api/project/updates/%s/rollout/%s", updateID, action)
+	if err := c.doJSON("POST", path, apiKey, nil, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,70 @@
+package client
+
+import "net/http"
+
+type StorageMigration struct {
+	ID            string `json:"id"`
+	ProjectID     string `json:"project_id"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	DeleteSource  bool   `json:"delete_source"`
+	Status        string `json:"status"`
+	TotalObjects  int64  `json:"total_objects"`
+	CopiedObjects int64  `json:"copied_objects"`
+	CopiedBytes   int64  `json:"copied_bytes"`
+	FailedObjects int64  `json:"failed_objects"`
+	LastKey       string `json:"last_key"`
+	LastError     string `json:"last_error"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+	CompletedAt   int64  `json:"completed_at"`
+}
+
+type StorageMigrationRequest struct {
+	ProjectID    string `json:"project_id,omitempty"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	DeleteSource bool   `json:"delete_source"`
+}
+
+// CreateStorageMigration starts copying blobs between storage providers.
+// The storage routes need the root admin token.
+func (c *Client) CreateStorageMigration(token string, req *StorageMigrationRequest) (*StorageMigration, error) {
+	var m StorageMigration
+	if err := c.doSessionJSON("POST", "/api/admin/storage/migrations", token, req, http.StatusAccepted, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *Client) ListStorageMigrations(token string) ([]StorageMigration, error) {
+	var migrations []StorageMigration
+	if err := c.doSessionJSON("GET", "/api/admin/storage/migrations", token, nil, http.StatusOK, &migrations); err != nil {
+		return nil, err
+	}
+	return migrations, nil
+}
+
+func (c *Client) GetStorageMigration(token, id string) (*StorageMigration, error) {
+	var m StorageMigration
+	if err := c.doSessionJSON("GET", "/api/admin/storage/migrations/"+id, token, nil, http.StatusOK, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *Client) PauseStorageMigration(token, id string) (*StorageMigration, error) {
+	var m StorageMigration
+	if err := c.doSessionJSON("POST", "/api/admin/storage/migrations/"+id+"/pause", token, nil, http.StatusOK, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *Client) ResumeStorageMigration(token, id string) (*StorageMigration, error) {
+	var m StorageMigration
+	if err := c.doSessionJSON("POST", "/api/admin/storage/migrations/"+id+"/resume", token, nil, http.StatusOK, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
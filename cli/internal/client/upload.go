@@ -0,0 +1,200 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vknow360/otaship/cli/internal/utils"
+)
+
+const (
+	// uploadChunkSize is how much of a bundle each PATCH request sends.
+	uploadChunkSize = 8 << 20
+	// maxUploadRetries is how many times in a row a chunk is retried
+	// before the upload gives up.
+	maxUploadRetries = 6
+	tusVersion       = "1.0.0"
+)
+
+// errResumableUnsupported means the server predates resumable uploads.
+var errResumableUnsupported = errors.New("server does not support resumable uploads")
+
+// uploadRetryDelay is how long to wait before retrying a chunk; tests
+// shorten it.
+var uploadRetryDelay = func(attempt int) time.Duration {
+	return time.Duration(1<<attempt) * time.Second
+}
+
+// transientError is a failure worth retrying. Chunked uploads ask the
+// server how much it has before they retry.
+type transientError struct {
+	err error
+	// retryAfter is how long the server asked to wait, if it did.
+	retryAfter time.Duration
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+func transientStatus(code int) bool {
+	return code == http.StatusConflict || code == http.StatusTooManyRequests || code >= 500
+}
+
+// uploadBundleResumable sends the bundle in chunks through a tus upload
+// session. After a network error or a server error it looks up the offset
+// the server has reached and continues from there.
+func (c *Client) uploadBundleResumable(projectID, updateID, platform, apiKey, zipPath string, activate bool) (*UploadBundleResponse, error) {
+	file, err := os.Open(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	location, err := c.createUploadSession(projectID, updateID, platform, apiKey, size, activate)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	retries := 0
+	for offset < size {
+		n := min(uploadChunkSize, size-offset)
+		next, err := c.patchUploadChunk(location, apiKey, io.NewSectionReader(file, offset, n), offset, n)
+		if err == nil {
+			offset = next
+			retries = 0
+			continue
+		}
+
+		var transient *transientError
+		if !errors.As(err, &transient) || retries >= maxUploadRetries {
+			return nil, err
+		}
+		retries++
+		time.Sleep(uploadRetryDelay(retries))
+		if current, err := c.uploadOffset(location, apiKey); err == nil {
+			offset = current
+		}
+	}
+
+	return c.finalizeUpload(location, apiKey)
+}
+
+func (c *Client) createUploadSession(projectID, updateID, platform, apiKey string, size int64, activate bool) (string, error) {
+	endpoint := fmt.Sprintf("%s/api/project/%s/updates/%s/uploads", c.BaseURL, projectID, updateID)
+
+	metadata := "platform " + base64.StdEncoding.EncodeToString([]byte(platform))
+	if !activate {
+		metadata += ",activate " + base64.StdEncoding.EncodeToString([]byte("false"))
+	}
+
+	req, _ := http.NewRequest("POST", endpoint, nil)
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", metadata)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		if resp.Header.Get("Content-Type") != "application/json" {
+			return "", errResumableUnsupported
+		}
+		return "", utils.HandleHTTPError(resp)
+	case http.StatusRequestEntityTooLarge:
+		return "", utils.NewUserError("Bundle is too large", "Ask your OTAShip admin to raise the project's upload limit")
+	default:
+		return "", utils.HandleHTTPError(resp)
+	}
+
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	location, err := base.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return "", fmt.Errorf("server returned no upload location")
+	}
+	return location.String(), nil
+}
+
+// patchUploadChunk sends n bytes of body at offset and returns the offset
+// the server reached.
+func (c *Client) patchUploadChunk(location, apiKey string, body io.Reader, offset, n int64) (int64, error) {
+	req, _ := http.NewRequest("PATCH", location, body)
+	req.ContentLength = n
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, &transientError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		if transientStatus(resp.StatusCode) {
+			return 0, &transientError{err: fmt.Errorf("chunk upload failed with HTTP %d", resp.StatusCode)}
+		}
+		return 0, utils.HandleHTTPError(resp)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// uploadOffset asks the server how many bytes of the upload it has.
+func (c *Client) uploadOffset(location, apiKey string) (int64, error) {
+	req, _ := http.NewRequest("HEAD", location, nil)
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("Tus-Resumable", tusVersion)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+func (c *Client) finalizeUpload(location, apiKey string) (*UploadBundleResponse, error) {
+	req, _ := http.NewRequest("POST", location+"/finalize", nil)
+	req.Header.Set("X-API-Key", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, utils.HandleHTTPError(resp)
+	}
+
+	var result UploadBundleResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	return &result, nil
+}
@@ -0,0 +1,156 @@
+// Package codesign signs manifests at publish time and verifies signatures
+// served in expo-signature headers. It mirrors the algorithms the server can
+// sign with.
+package codesign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgRSAPKCS1SHA256  = "rsa-v1_5-sha256"
+	AlgECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgEd25519         = "ed25519"
+)
+
+var ErrBadSignature = errors.New("signature verification failed")
+
+// ParseHeader reads the parameters of a structured-field dictionary such as
+// expo-signature. Bare members map to "".
+func ParseHeader(header string) map[string]string {
+	params := make(map[string]string)
+	for _, member := range strings.Split(header, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(member, "=")
+		params[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return params
+}
+
+// ParseCertificate decodes a PEM code signing certificate.
+func ParseCertificate(certificatePEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certificatePEM)
+	if block == nil {
+		return nil, errors.New("certificate is not PEM encoded")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Verify checks a raw signature over data with the given public key.
+func Verify(alg string, publicKey crypto.PublicKey, data, signature []byte) error {
+	hashed := sha256.Sum256(data)
+
+	switch alg {
+	case AlgRSAPKCS1SHA256, "":
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("certificate key does not match %s", AlgRSAPKCS1SHA256)
+		}
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature) != nil {
+			return ErrBadSignature
+		}
+	case AlgECDSAP256SHA256:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("certificate key does not match %s", alg)
+		}
+		if !ecdsa.VerifyASN1(key, hashed[:], signature) {
+			return ErrBadSignature
+		}
+	case AlgEd25519:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("certificate key does not match %s", alg)
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return ErrBadSignature
+		}
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return nil
+}
+
+// VerifyHeader checks an expo-signature header value against the body it was
+// sent with.
+func VerifyHeader(cert *x509.Certificate, header string, data []byte) error {
+	params := ParseHeader(header)
+	if params["sig"] == "" {
+		return errors.New("expo-signature has no sig parameter")
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["sig"])
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	return Verify(params["alg"], cert.PublicKey, data, signature)
+}
+
+// Signer signs manifests with a local private key.
+type Signer struct {
+	alg string
+	key crypto.Signer
+}
+
+// NewSigner parses a PEM private key (PKCS#1, SEC 1 or PKCS#8) and picks the
+// algorithm from its type.
+func NewSigner(privateKeyPEM []byte) (*Signer, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &Signer{alg: AlgRSAPKCS1SHA256, key: k}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().Name != "P-256" {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+		return &Signer{alg: AlgECDSAP256SHA256, key: k}, nil
+	case ed25519.PrivateKey:
+		return &Signer{alg: AlgEd25519, key: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+func (s *Signer) Alg() string {
+	return s.alg
+}
+
+// Sign returns the raw signature over data.
+func (s *Signer) Sign(data []byte) ([]byte, error) {
+	if s.alg == AlgEd25519 {
+		return s.key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	hashed := sha256.Sum256(data)
+	return s.key.Sign(rand.Reader, hashed[:], crypto.SHA256)
+}
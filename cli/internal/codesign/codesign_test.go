@@ -0,0 +1,100 @@
+package codesign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	data := []byte(`{"id":"123"}`)
+	hashed := sha256.Sum256(data)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hashed[:])
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSig, _ := ecdsa.SignASN1(rand.Reader, ecKey, hashed[:])
+
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	edSig := ed25519.Sign(edPriv, data)
+
+	tests := []struct {
+		alg string
+		pub crypto.PublicKey
+		sig []byte
+	}{
+		{AlgRSAPKCS1SHA256, &rsaKey.PublicKey, rsaSig},
+		{"", &rsaKey.PublicKey, rsaSig},
+		{AlgECDSAP256SHA256, &ecKey.PublicKey, ecSig},
+		{AlgEd25519, edPub, edSig},
+	}
+
+	for _, tt := range tests {
+		if err := Verify(tt.alg, tt.pub, data, tt.sig); err != nil {
+			t.Errorf("Verify(%q) unexpected error: %v", tt.alg, err)
+		}
+		if err := Verify(tt.alg, tt.pub, []byte("tampered"), tt.sig); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("Verify(%q) with tampered data: expected ErrBadSignature, got %v", tt.alg, err)
+		}
+	}
+
+	if err := Verify(AlgEd25519, &rsaKey.PublicKey, data, rsaSig); err == nil {
+		t.Errorf("Expected error for mismatched key type")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	header := fmt.Sprintf(`sig="%s", keyid="main", alg="ed25519"`, base64.StdEncoding.EncodeToString([]byte("x")))
+	params := ParseHeader(header)
+	if params["keyid"] != "main" || params["alg"] != "ed25519" || params["sig"] != "eA==" {
+		t.Errorf("Unexpected params: %v", params)
+	}
+}
+
+func TestSignerRoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ecDER, _ := x509.MarshalECPrivateKey(ecKey)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	tests := []struct {
+		alg string
+		pem []byte
+		pub crypto.PublicKey
+	}{
+		{AlgRSAPKCS1SHA256, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), &rsaKey.PublicKey},
+		{AlgECDSAP256SHA256, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}), &ecKey.PublicKey},
+		{AlgEd25519, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}), edKey.Public()},
+	}
+
+	data := []byte(`{"id":"123"}`)
+	for _, tt := range tests {
+		signer, err := NewSigner(tt.pem)
+		if err != nil {
+			t.Fatalf("NewSigner(%s) unexpected error: %v", tt.alg, err)
+		}
+		if signer.Alg() != tt.alg {
+			t.Errorf("Alg() = %q, want %q", signer.Alg(), tt.alg)
+		}
+		sig, err := signer.Sign(data)
+		if err != nil {
+			t.Fatalf("Sign(%s) unexpected error: %v", tt.alg, err)
+		}
+		if err := Verify(tt.alg, tt.pub, data, sig); err != nil {
+			t.Errorf("Verify(%s) unexpected error: %v", tt.alg, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/config"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var (
+	auditProjectFlag string
+	auditActorFlag   string
+	auditActionFlag  string
+	auditTargetFlag  string
+	auditSinceFlag   string
+	auditLimitFlag   int
+	auditOffsetFlag  int
+)
+
+var AuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show who changed what in the current project",
+	Long: "Lists the audit log of the current project, newest first. " +
+		"Requires 'otaship login' as a user with the admin role on the project.",
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+func init() {
+	AuditCmd.Flags().StringVar(&auditProjectFlag, "project", "", "Project ID (default from otaship.json)")
+	AuditCmd.Flags().StringVar(&auditActorFlag, "actor", "", "Only events by this user email, API key name or ID")
+	AuditCmd.Flags().StringVar(&auditActionFlag, "action", "", "Only actions containing this text, e.g. rollback")
+	AuditCmd.Flags().StringVar(&auditTargetFlag, "target", "", "Only events acting on this ID, e.g. an update ID")
+	AuditCmd.Flags().StringVar(&auditSinceFlag, "since", "", "Only events newer than this, e.g. 24h")
+	AuditCmd.Flags().IntVar(&auditLimitFlag, "limit", 50, "Number of events to show")
+	AuditCmd.Flags().IntVar(&auditOffsetFlag, "offset", 0, "Number of events to skip")
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Token == "" {
+		return fmt.Errorf("not logged in as a user. Run 'otaship login --email'")
+	}
+
+	filter := client.AuditFilter{
+		ProjectID: auditProjectFlag,
+		Actor:     auditActorFlag,
+		Action:    auditActionFlag,
+		Target:    auditTargetFlag,
+		Limit:     auditLimitFlag,
+		Offset:    auditOffsetFlag,
+	}
+	if filter.ProjectID == "" {
+		projectCfg, err := config.LoadProjectConfig()
+		if err != nil || projectCfg == nil {
+			return fmt.Errorf("not in an OTAShip project. Run 'otaship init' or pass --project")
+		}
+		filter.ProjectID = projectCfg.ProjectID
+	}
+	if auditSinceFlag != "" {
+		d, err := time.ParseDuration(auditSinceFlag)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid --since %q, expected a duration such as 24h", auditSinceFlag)
+		}
+		filter.Since = time.Now().Add(-d).UnixMilli()
+	}
+
+	c := &client.Client{BaseURL: cfg.Server}
+	page, err := c.ListAuditEvents(cfg.Token, filter)
+	if err != nil {
+		return err
+	}
+	if len(page.Events) == 0 {
+		ui.Info.Println("No audit events found")
+		return nil
+	}
+
+	tableData := [][]string{{"TIME", "ACTOR", "ACTION", "TARGETS", "STATUS"}}
+	for _, e := range page.Events {
+		tableData = append(tableData, []string{
+			time.UnixMilli(e.CreatedAt).Local().Format("2006-01-02 15:04:05"),
+			e.ActorName,
+			e.Action,
+			formatTargets(e.Targets),
+			fmt.Sprintf("%d", e.Status),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	if shown := page.Offset + len(page.Events); int64(shown) < page.Total {
+		ui.Info.Printf("Showing %d-%d of %d. Use --offset %d for more\n", page.Offset+1, shown, page.Total, shown)
+	}
+	return nil
+}
+
+func formatTargets(targets map[string]string) string {
+	parts := make([]string, 0, len(targets))
+	for name, id := range targets {
+		parts = append(parts, name+"="+id)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var BranchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "Manage update branches",
+}
+
+var branchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List branches for the current project",
+	Args:  cobra.NoArgs,
+	RunE:  runBranchList,
+}
+
+var branchCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a branch",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBranchCreate,
+}
+
+var branchDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a branch without updates",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBranchDelete,
+}
+
+func init() {
+	BranchCmd.AddCommand(branchListCmd, branchCreateCmd, branchDeleteCmd)
+}
+
+func runBranchList(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	branches, err := c.ListBranches(apiKey)
+	if err != nil {
+		return err
+	}
+	if len(branches) == 0 {
+		ui.Info.Println("No branches found")
+		return nil
+	}
+
+	tableData := [][]string{{"NAME", "UPDATES", "CREATED"}}
+	for _, b := range branches {
+		tableData = append(tableData, []string{
+			b.Name,
+			fmt.Sprintf("%d", b.UpdateCount),
+			time.UnixMilli(b.CreatedAt).Local().Format("2006-01-02 15:04"),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	return nil
+}
+
+func runBranchCreate(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	branch, err := c.CreateBranch(apiKey, args[0])
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Created branch %s\n", branch.Name)
+	return nil
+}
+
+func runBranchDelete(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	if err := c.DeleteBranch(apiKey, args[0]); err != nil {
+		return err
+	}
+	ui.Success.Printf("Deleted branch %s\n", args[0])
+	return nil
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var (
+	channelBranchFlag        string
+	channelRolloutBranchFlag string
+	channelRolloutFlag       int
+)
+
+var ChannelCmd = &cobra.Command{
+	Use:   "channel",
+	Short: "Manage channels and the branches they serve",
+}
+
+var channelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List channels for the current project",
+	Args:  cobra.NoArgs,
+	RunE:  runChannelList,
+}
+
+var channelCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a channel",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runChannelCreate,
+}
+
+var channelEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Point a channel at a branch, or split it across two branches",
+	Example: "  otaship channel edit production --branch staging\n" +
+		"  otaship channel edit production --branch release-1 --rollout-branch release-2 --rollout 10",
+	Args: cobra.ExactArgs(1),
+	RunE: runChannelEdit,
+}
+
+var channelDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a channel",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runChannelDelete,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{channelCreateCmd, channelEditCmd} {
+		cmd.Flags().StringVar(&channelBranchFlag, "branch", "", "Branch the channel serves")
+		cmd.Flags().StringVar(&channelRolloutBranchFlag, "rollout-branch", "", "Second branch that receives part of the traffic")
+		cmd.Flags().IntVar(&channelRolloutFlag, "rollout", 0, "Percentage of devices served from --rollout-branch (0-100)")
+	}
+	channelEditCmd.MarkFlagRequired("branch")
+	ChannelCmd.AddCommand(channelListCmd, channelCreateCmd, channelEditCmd, channelDeleteCmd)
+}
+
+func runChannelList(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	channels, err := c.ListChannels(apiKey)
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		ui.Info.Println("No channels found")
+		return nil
+	}
+
+	tableData := [][]string{{"NAME", "BRANCH", "ROLLOUT", "UPDATED"}}
+	for _, ch := range channels {
+		tableData = append(tableData, []string{
+			ch.Name,
+			ch.Branch,
+			formatChannelRollout(ch),
+			time.UnixMilli(ch.UpdatedAt).Local().Format("2006-01-02 15:04"),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	return nil
+}
+
+func runChannelCreate(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	channel, err := c.CreateChannel(apiKey, &client.ChannelRequest{
+		Name:              args[0],
+		Branch:            channelBranchFlag,
+		RolloutBranch:     channelRolloutBranchFlag,
+		RolloutPercentage: channelRolloutFlag,
+	})
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Created channel %s → %s\n", channel.Name, formatChannelTarget(*channel))
+	return nil
+}
+
+func runChannelEdit(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	channel, err := c.UpdateChannel(apiKey, args[0], &client.ChannelRequest{
+		Branch:            channelBranchFlag,
+		RolloutBranch:     channelRolloutBranchFlag,
+		RolloutPercentage: channelRolloutFlag,
+	})
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Channel %s → %s\n", channel.Name, formatChannelTarget(*channel))
+	return nil
+}
+
+func runChannelDelete(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	confirm, err := ui.Confirm(fmt.Sprintf("Delete channel %s? Devices on it fall back to the branch of the same name.", args[0]))
+	if err != nil {
+		return err
+	}
+	if !confirm {
+		return fmt.Errorf("channel deletion cancelled")
+	}
+
+	if err := c.DeleteChannel(apiKey, args[0]); err != nil {
+		return err
+	}
+	ui.Success.Printf("Deleted channel %s\n", args[0])
+	return nil
+}
+
+func formatChannelRollout(ch client.Channel) string {
+	if ch.RolloutBranch == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%d%% → %s", ch.RolloutPercentage, ch.RolloutBranch)
+}
+
+func formatChannelTarget(ch client.Channel) string {
+	if ch.RolloutBranch == "" {
+		return ch.Branch
+	}
+	return fmt.Sprintf("%s (%d%% %s)", ch.Branch, ch.RolloutPercentage, ch.RolloutBranch)
+}
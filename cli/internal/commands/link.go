@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -50,6 +51,21 @@ func runLink(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if apiKey == "" && cfg.Token != "" {
+		apiKey = createLinkKey(c, cfg.Token, projectCfg.ProjectID)
+		if apiKey != "" {
+			project, err = c.GetProjectByID(apiKey)
+			if err != nil {
+				return fmt.Errorf("failed to fetch project with the new API key: %w", err)
+			}
+			cfg.Projects[project.ID] = apiKey
+			if err := config.SaveGlobalConfig(cfg); err != nil {
+				return err
+			}
+			ui.Success.Println("API key saved")
+		}
+	}
+
 	if apiKey == "" {
 		inputKey, err := ui.AskSecret("API Key")
 		if err != nil {
@@ -87,3 +103,25 @@ func runLink(cmd *cobra.Command, args []string) error {
 	ui.Info.Println("Ready to publish")
 	return nil
 }
+
+// createLinkKey offers to create an API key for the project as the logged-in
+// user, which needs the admin role on the project. It returns "" when no key
+// was created.
+func createLinkKey(c *client.Client, token, projectID string) string {
+	create, err := ui.Confirm("No API key stored for this project. Create one with your account?")
+	if err != nil || !create {
+		return ""
+	}
+
+	name := "otaship-cli"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += " (" + host + ")"
+	}
+	key, err := c.CreateAPIKey(token, projectID, name)
+	if err != nil {
+		ui.Warning.Printf("Could not create an API key: %v\n", err)
+		return ""
+	}
+	ui.Success.Printf("Created API key %s\n", key.Name)
+	return key.APIKey
+}
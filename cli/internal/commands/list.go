@@ -45,7 +45,8 @@ func runList(cmd *cobra.Command, args []string) error {
 	}
 
 	var tableData [][]string
-	tableData = append(tableData, []string{"ID", "PLATFORM", "RUNTIME", "CHANNEL", "ACTIVE", "ROLLOUT", "CREATED"})
+	expired := 0
+	tableData = append(tableData, []string{"ID", "PLATFORM", "RUNTIME", "BRANCH", "ACTIVE", "ROLLOUT", "CREATED", "URLS EXPIRE"})
 
 	for _, u := range updates {
 		active := "✓"
@@ -58,13 +59,30 @@ func runList(cmd *cobra.Command, args []string) error {
 
 		created := time.UnixMilli(u.CreatedAt).Local().Format("2006-01-02 15:04")
 
+		// Signed manifests point at asset URLs that stop working; the
+		// server serves no update once they have.
+		expires := "-"
+		if u.AssetURLsExpireAt != 0 {
+			expiresAt := time.UnixMilli(u.AssetURLsExpireAt)
+			expires = expiresAt.Local().Format("2006-01-02 15:04")
+			if time.Now().After(expiresAt) {
+				expires += " (expired)"
+				if u.IsActive {
+					expired++
+				}
+			}
+		}
+
 		tableData = append(tableData, []string{
-			u.ID, u.Platform, u.RuntimeVersion, u.Channel,
-			active, fmt.Sprintf("%d", u.RolloutPercentage), created,
+			u.ID, u.Platform, u.RuntimeVersion, u.Branch,
+			active, fmt.Sprintf("%d", u.RolloutPercentage), created, expires,
 		})
 	}
 
 	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	if expired > 0 {
+		ui.Warning.Printf("%d active signed update(s) have expired asset URLs and are not served; republish them\n", expired)
+	}
 
 	return nil
 }
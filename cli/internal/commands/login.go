@@ -7,14 +7,29 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
 	"github.com/vknow360/otaship/cli/internal/config"
 	"github.com/vknow360/otaship/cli/internal/ui"
 )
 
+var loginEmailFlag string
+
 var LoginCmd = &cobra.Command{
 	Use:   "login",
-	Short: "Set OTAShip server URL",
-	RunE:  runLogin,
+	Short: "Set OTAShip server URL and log in as a user",
+	Long: "Sets the OTAShip server URL, then optionally logs in with a user account.\n" +
+		"A logged-in user can link projects without pasting an API key.",
+	RunE: runLogin,
+}
+
+var LogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Log out of the OTAShip server",
+	RunE:  runLogout,
+}
+
+func init() {
+	LoginCmd.Flags().StringVar(&loginEmailFlag, "email", "", "Log in as this user")
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
@@ -23,19 +38,79 @@ func runLogin(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	changeServer := true
 	if cfg.Server != "" {
 		ui.Info.Printf("Current server: %s\n", cfg.Server)
-		change, err := ui.Confirm("Change server?")
-		if err != nil || !change {
+		changeServer, err = ui.Confirm("Change server?")
+		if err != nil {
 			return nil
 		}
 	}
 
-	server, err := ui.Ask("Enter server URL")
+	if changeServer {
+		server, err := ui.Ask("Enter server URL")
+		if err != nil {
+			return err
+		}
+		if err := setServer(cfg, server); err != nil {
+			return err
+		}
+	}
+
+	email := loginEmailFlag
+	if email == "" {
+		email, _ = ui.AskOptional("Email (leave empty to skip logging in)", "")
+	}
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+	return loginUser(cfg, email)
+}
+
+func loginUser(cfg *config.GlobalConfig, email string) error {
+	password, err := ui.AskSecret("Password")
 	if err != nil {
 		return err
 	}
-	return setServer(cfg, server)
+
+	c := &client.Client{BaseURL: cfg.Server}
+	session, err := c.Login(email, password)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	cfg.Token = session.Token
+	cfg.User = session.User.Email
+	if err := config.SaveGlobalConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ui.Success.Printf("Logged in as %s\n", session.User.Email)
+	return nil
+}
+
+func runLogout(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Token == "" {
+		ui.Info.Println("Not logged in")
+		return nil
+	}
+
+	c := &client.Client{BaseURL: cfg.Server}
+	if err := c.Logout(cfg.Token); err != nil {
+		ui.Warning.Printf("Could not end the session on the server: %v\n", err)
+	}
+
+	cfg.Token = ""
+	cfg.User = ""
+	if err := config.SaveGlobalConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ui.Success.Println("Logged out")
+	return nil
 }
 
 func setServer(cfg *config.GlobalConfig, serverUrl string) error {
@@ -50,6 +125,11 @@ func setServer(cfg *config.GlobalConfig, serverUrl string) error {
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
 
+	if cfg.Server != serverUrl {
+		// A session only works on the server that issued it.
+		cfg.Token = ""
+		cfg.User = ""
+	}
 	cfg.Server = serverUrl
 	if err := config.SaveGlobalConfig(cfg); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
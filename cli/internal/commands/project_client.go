@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/config"
+)
+
+// projectClient returns a client for the linked project and its API key.
+func projectClient() (*client.Client, string, error) {
+	projectCfg, err := config.LoadProjectConfig()
+	if err != nil || projectCfg == nil {
+		return nil, "", fmt.Errorf("not in an OTAShip project. Run 'otaship init'")
+	}
+
+	cfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	apiKey := cfg.Projects[projectCfg.ProjectID]
+	if apiKey == "" {
+		return nil, "", fmt.Errorf("no API key found. Run 'otaship link'")
+	}
+	return &client.Client{BaseURL: cfg.Server}, apiKey, nil
+}
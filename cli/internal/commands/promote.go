@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var (
+	promoteChannelFlag string
+	promoteRolloutFlag int
+)
+
+var PromoteCmd = &cobra.Command{
+	Use:   "promote [update-id]",
+	Short: "Publish an existing update to another channel without re-uploading",
+	Example: "  otaship promote 3f2a... --channel production\n" +
+		"  otaship promote 3f2a... --channel production --rollout 10",
+	Args: cobra.ExactArgs(1),
+	RunE: runPromote,
+}
+
+func init() {
+	PromoteCmd.Flags().StringVar(&promoteChannelFlag, "channel", "", "Target channel")
+	PromoteCmd.Flags().IntVar(&promoteRolloutFlag, "rollout", 100, "Rollout percentage (0-100)")
+	PromoteCmd.MarkFlagRequired("channel")
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	updateID := args[0]
+
+	if promoteRolloutFlag < 0 || promoteRolloutFlag > 100 {
+		return fmt.Errorf("rollout percentage must be between 0 and 100")
+	}
+
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	spinner, _ := ui.StartSpinner(fmt.Sprintf("Promoting update %s to %s...", updateID, promoteChannelFlag))
+
+	rollout := promoteRolloutFlag
+	promoted, err := c.PromoteUpdate(apiKey, updateID, &client.PromoteRequest{
+		Channel:           promoteChannelFlag,
+		RolloutPercentage: &rollout,
+	})
+	if err != nil {
+		spinner.Fail("FAILED")
+		return err
+	}
+
+	spinner.Success(fmt.Sprintf("Promoted update created: %s", promoted.ID))
+	ui.Info.Printf("Now active on %s (branch %s, %d%% rollout)\n", promoteChannelFlag, promoted.Branch, rollout)
+	return nil
+}
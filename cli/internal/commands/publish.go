@@ -2,7 +2,9 @@ package commands
 
 import (
 	"archive/zip"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +15,7 @@ import (
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/codesign"
 	"github.com/vknow360/otaship/cli/internal/config"
 	"github.com/vknow360/otaship/cli/internal/ui"
 )
@@ -25,6 +28,9 @@ var (
 	messageFlag  string
 	dryRunFlag   bool
 	yesFlag      bool
+	signKeyFlag  string
+	keyIDFlag    string
+	branchFlag   string
 )
 
 var PublishCommand = &cobra.Command{
@@ -42,12 +48,15 @@ type AppJson struct {
 
 func init() {
 	PublishCommand.Flags().StringVar(&channelFlag, "channel", "", "Override channel (default from config)")
+	PublishCommand.Flags().StringVar(&branchFlag, "branch", "", "Publish to this branch instead of the branch the channel points at")
 	PublishCommand.Flags().IntVar(&rolloutFlag, "rollout", 100, "Rollout percentage (0-100)")
 	PublishCommand.Flags().BoolVar(&skipExport, "skip-export", false, "Skip expo export step")
 	PublishCommand.Flags().StringVar(&platformFlag, "platform", "all", "Platform: android, ios, or all")
 	PublishCommand.Flags().StringVar(&messageFlag, "message", "", "Description for the update")
 	PublishCommand.Flags().BoolVar(&dryRunFlag, "dry-run", false, "Dry run (no actual update)")
 	PublishCommand.Flags().BoolVarP(&yesFlag, "yes", "y", false, "Skip confirmation prompt")
+	PublishCommand.Flags().StringVar(&signKeyFlag, "sign-key", "", "Sign the manifest locally with this PEM private key")
+	PublishCommand.Flags().StringVar(&keyIDFlag, "key-id", "main", "keyid of the certificate registered for --sign-key")
 }
 
 func resolvePlatform(cmd *cobra.Command) (string, error) {
@@ -148,6 +157,18 @@ func runPublish(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	var signer *codesign.Signer
+	if signKeyFlag != "" {
+		keyPEM, err := os.ReadFile(signKeyFlag)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %w", err)
+		}
+		signer, err = codesign.NewSigner(keyPEM)
+		if err != nil {
+			return err
+		}
+	}
+
 	if ui.IsInteractive() && !yesFlag {
 		confirmed, err := showSummary(platform, channel, appJson.Expo.RuntimeVersion, updateMessage, rollout, dryRunFlag)
 		if err != nil || !confirmed {
@@ -172,7 +193,11 @@ func runPublish(cmd *cobra.Command, args []string) error {
 	}
 
 	ui.Info.Printf("Publishing to: %s\n", project.Name)
-	ui.Info.Printf("Channel: %s\n", channel)
+	if branchFlag != "" {
+		ui.Info.Printf("Branch: %s\n", branchFlag)
+	} else {
+		ui.Info.Printf("Channel: %s\n", channel)
+	}
 
 	ui.Success.Printf("Project: %s\n", project.Name)
 	ui.Success.Printf("Runtime: %s\n", appJson.Expo.RuntimeVersion)
@@ -182,6 +207,7 @@ func runPublish(cmd *cobra.Command, args []string) error {
 			ProjectID:         projectCfg.ProjectID,
 			RolloutPercentage: rollout,
 			Channel:           channel,
+			Branch:            branchFlag,
 			RuntimeVersion:    appJson.Expo.RuntimeVersion,
 			Platform:          p,
 			Message:           updateMessage,
@@ -194,11 +220,63 @@ func runPublish(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return "", fmt.Errorf("failed to create update for %s: %w", p, err)
 		}
-		ui.Success.Printf("Created update for %s: %s\n", p, update.ID)
+		ui.Success.Printf("Created update for %s: %s (branch %s)\n", p, update.ID, update.Branch)
 		return update.ID, nil
 	}
 
+	// signUpdate signs the manifest the server built for the uploaded bundle.
+	// The server checks the signature against the certificate registered for
+	// the key id before it activates the update.
+	signUpdate := func(p string, updateID string) error {
+		spinner, _ := ui.StartSpinner(fmt.Sprintf("Signing %s manifest...", p))
+		manifest, err := c.GetUpdateManifest(apiKey, updateID)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to fetch %s manifest", p))
+			return fmt.Errorf("failed to fetch %s manifest: %w", p, err)
+		}
+		signature, err := signer.Sign(manifest)
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("Failed to sign %s manifest", p))
+			return fmt.Errorf("failed to sign %s manifest: %w", p, err)
+		}
+		err = c.SubmitSignature(apiKey, updateID, &client.SubmitSignatureRequest{
+			KeyID:     keyIDFlag,
+			Signature: base64.StdEncoding.EncodeToString(signature),
+		})
+		if err != nil {
+			spinner.Fail(fmt.Sprintf("%s signature rejected", p))
+			return fmt.Errorf("%s signature rejected: %w", p, err)
+		}
+		spinner.Success(fmt.Sprintf("Signed %s manifest (keyid %s, %s)", p, keyIDFlag, signer.Alg()))
+		return nil
+	}
+
+	finishPublish := func(p string, updateID string) error {
+		if signer != nil {
+			if err := signUpdate(p, updateID); err != nil {
+				return err
+			}
+		}
+		ui.Success.Printf("Published %s successfully!\n", p)
+		return nil
+	}
+
 	uploadBundle := func(p string, updateID string) error {
+		if !dryRunFlag {
+			spinner, _ := ui.StartSpinner(fmt.Sprintf("Uploading %s assets...", p))
+			result, err := c.PublishAssets(projectCfg.ProjectID, updateID, p, apiKey, filepath.Join(projectRoot, "dist"), signer == nil)
+			if err == nil {
+				spinner.Success(fmt.Sprintf("Uploaded %s assets (%d new, %d reused)", p, result.NewAssets, result.ReusedAssets))
+				return finishPublish(p, updateID)
+			}
+			if !errors.Is(err, client.ErrDirectUploadUnsupported) {
+				spinner.Fail(fmt.Sprintf("%s upload failed", p))
+				return fmt.Errorf("%s upload failed: %w", p, err)
+			}
+			// Older servers only take the whole bundle as a zip.
+			ui.StopSpinner(spinner)
+		}
+
 		spinner, _ := ui.StartSpinner(fmt.Sprintf("Packaging %s bundle...", p))
 		bundleZip, err := zipDistFolder(projectRoot, p)
 		if err != nil {
@@ -216,13 +294,13 @@ func runPublish(cmd *cobra.Command, args []string) error {
 		}
 
 		spinner, _ = ui.StartSpinner(fmt.Sprintf("Uploading %s bundle...", p))
-		if err := c.UploadBundle(projectCfg.ProjectID, updateID, p, apiKey, bundleZip); err != nil {
+		result, err := c.UploadBundle(projectCfg.ProjectID, updateID, p, apiKey, bundleZip, signer == nil)
+		if err != nil {
 			spinner.Fail(fmt.Sprintf("%s upload failed", p))
 			return fmt.Errorf("%s upload failed: %w", p, err)
 		}
-		spinner.Success(fmt.Sprintf("Uploaded %s bundle", p))
-		ui.Success.Printf("Published %s successfully!\n", p)
-		return nil
+		spinner.Success(fmt.Sprintf("Uploaded %s bundle (%d new, %d reused assets)", p, result.NewAssets, result.ReusedAssets))
+		return finishPublish(p, updateID)
 	}
 
 	deleteUpdate := func(updateID string) {
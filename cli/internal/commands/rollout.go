@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var (
+	rolloutStepsFlag string
+	rolloutEveryFlag string
+)
+
+var RolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "Inspect and control an update's progressive rollout",
+}
+
+var rolloutStatusCmd = &cobra.Command{
+	Use:   "status [update-id]",
+	Short: "Show an update's rollout percentage, schedule and history",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRolloutStatus,
+}
+
+var rolloutScheduleCmd = &cobra.Command{
+	Use:     "schedule [update-id]",
+	Short:   "Raise an update's rollout in steps on a fixed interval",
+	Example: "  otaship rollout schedule 3f2a... --steps 5,25,50,100 --every 6h",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runRolloutSchedule,
+}
+
+var rolloutPauseCmd = &cobra.Command{
+	Use:   "pause [update-id]",
+	Short: "Pause a scheduled rollout at its current step",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRolloutPause,
+}
+
+var rolloutResumeCmd = &cobra.Command{
+	Use:   "resume [update-id]",
+	Short: "Resume a paused rollout",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRolloutResume,
+}
+
+func init() {
+	rolloutScheduleCmd.Flags().StringVar(&rolloutStepsFlag, "steps", "", "Comma-separated rollout percentages, e.g. 5,25,50,100")
+	rolloutScheduleCmd.Flags().StringVar(&rolloutEveryFlag, "every", "", "Time between steps, e.g. 6h")
+	rolloutScheduleCmd.MarkFlagRequired("steps")
+	rolloutScheduleCmd.MarkFlagRequired("every")
+	RolloutCmd.AddCommand(rolloutStatusCmd, rolloutScheduleCmd, rolloutPauseCmd, rolloutResumeCmd)
+}
+
+func runRolloutStatus(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	rollout, err := c.GetRollout(apiKey, args[0])
+	if err != nil {
+		return err
+	}
+	printRollout(rollout)
+
+	if len(rollout.Events) == 0 {
+		return nil
+	}
+	fmt.Println()
+	tableData := [][]string{{"TIME", "ACTION", "FROM", "TO", "BY"}}
+	for _, e := range rollout.Events {
+		tableData = append(tableData, []string{
+			time.UnixMilli(e.CreatedAt).Local().Format("2006-01-02 15:04"),
+			e.Action,
+			fmt.Sprintf("%d%%", e.FromPercentage),
+			fmt.Sprintf("%d%%", e.ToPercentage),
+			e.Actor,
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	return nil
+}
+
+func runRolloutSchedule(cmd *cobra.Command, args []string) error {
+	steps, err := parseRolloutSteps(rolloutStepsFlag)
+	if err != nil {
+		return err
+	}
+	if _, err := time.ParseDuration(rolloutEveryFlag); err != nil {
+		return fmt.Errorf("invalid --every %q: %w", rolloutEveryFlag, err)
+	}
+
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	rollout, err := c.ScheduleRollout(apiKey, args[0], &client.RolloutScheduleRequest{
+		Steps:    steps,
+		Interval: rolloutEveryFlag,
+	})
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Rollout scheduled for %s\n", args[0])
+	printRollout(rollout)
+	return nil
+}
+
+func runRolloutPause(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	rollout, err := c.PauseRollout(apiKey, args[0])
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Rollout paused at %d%%\n", rollout.RolloutPercentage)
+	return nil
+}
+
+func runRolloutResume(cmd *cobra.Command, args []string) error {
+	c, apiKey, err := projectClient()
+	if err != nil {
+		return err
+	}
+
+	rollout, err := c.ResumeRollout(apiKey, args[0])
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Rollout resumed at %d%%\n", rollout.RolloutPercentage)
+	printRollout(rollout)
+	return nil
+}
+
+func printRollout(rollout *client.Rollout) {
+	ui.Info.Printf("Rollout: %d%%\n", rollout.RolloutPercentage)
+	if !rollout.IsActive {
+		ui.Warning.Println("Update is no longer active")
+	}
+
+	s := rollout.Schedule
+	if s == nil {
+		ui.Info.Println("No rollout schedule")
+		return
+	}
+
+	steps := make([]string, len(s.Steps))
+	for i, step := range s.Steps {
+		steps[i] = fmt.Sprintf("%d%%", step)
+		if i == s.CurrentStep {
+			steps[i] = "[" + steps[i] + "]"
+		}
+	}
+	ui.Info.Printf("Schedule: %s every %s (%s)\n", strings.Join(steps, " → "), s.Interval, s.Status)
+	if s.NextStepAt > 0 {
+		ui.Info.Printf("Next step: %s\n", time.UnixMilli(s.NextStepAt).Local().Format("2006-01-02 15:04"))
+	}
+}
+
+func parseRolloutSteps(value string) ([]int, error) {
+	var steps []int
+	for _, part := range strings.Split(value, ",") {
+		step, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(part), "%"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid step %q in --steps", part)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/config"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+// adminTokenEnv holds the server's root admin token, which the storage
+// commands need instead of a user login.
+const adminTokenEnv = "OTASHIP_ADMIN_TOKEN"
+
+const storageMigrationPollInterval = 5 * time.Second
+
+var (
+	storageFromFlag         string
+	storageToFlag           string
+	storageProjectFlag      string
+	storageAllFlag          bool
+	storageDeleteSourceFlag bool
+	storageWaitFlag         bool
+)
+
+var StorageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Move a server's assets between storage providers",
+	Long: "Copies assets and patches from one storage provider to another. " +
+		"Requires the server's root admin token in " + adminTokenEnv + ".",
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy a project's or the whole server's assets to another provider",
+	Long: "Starts a migration the server runs in the background. Each object is " +
+		"copied, checked against its hash and switched over in batches, so a " +
+		"stopped migration resumes where it left off. Set the server's storage " +
+		"provider to the target first so new publishes go there.",
+	Example: "  otaship storage migrate --from local --to s3 --all --wait\n" +
+		"  otaship storage migrate --from s3 --to r2 --project 3f2a... --delete-source",
+	Args: cobra.NoArgs,
+	RunE: runStorageMigrate,
+}
+
+var storageMigrationsCmd = &cobra.Command{
+	Use:   "migrations",
+	Short: "List recent storage migrations",
+	Args:  cobra.NoArgs,
+	RunE:  runStorageMigrations,
+}
+
+var storageStatusCmd = &cobra.Command{
+	Use:   "status [migration-id]",
+	Short: "Show a storage migration's progress",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStorageStatus,
+}
+
+var storagePauseCmd = &cobra.Command{
+	Use:   "pause [migration-id]",
+	Short: "Pause a storage migration after its current batch",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStoragePause,
+}
+
+var storageResumeCmd = &cobra.Command{
+	Use:   "resume [migration-id]",
+	Short: "Resume a paused or failed storage migration",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStorageResume,
+}
+
+func init() {
+	storageMigrateCmd.Flags().StringVar(&storageFromFlag, "from", "", "Provider to copy from, e.g. local")
+	storageMigrateCmd.Flags().StringVar(&storageToFlag, "to", "", "Provider to copy to, e.g. s3")
+	storageMigrateCmd.Flags().StringVar(&storageProjectFlag, "project", "", "Only migrate this project")
+	storageMigrateCmd.Flags().BoolVar(&storageAllFlag, "all", false, "Migrate every project on the server")
+	storageMigrateCmd.Flags().BoolVar(&storageDeleteSourceFlag, "delete-source", false, "Delete source objects once the GC grace period has passed")
+	storageMigrateCmd.Flags().BoolVar(&storageWaitFlag, "wait", false, "Show progress until the migration stops")
+	storageMigrateCmd.MarkFlagRequired("from")
+	storageMigrateCmd.MarkFlagRequired("to")
+	storageMigrateCmd.MarkFlagsMutuallyExclusive("project", "all")
+	storageMigrateCmd.MarkFlagsOneRequired("project", "all")
+
+	storageStatusCmd.Flags().BoolVar(&storageWaitFlag, "wait", false, "Show progress until the migration stops")
+	storageResumeCmd.Flags().BoolVar(&storageWaitFlag, "wait", false, "Show progress until the migration stops")
+
+	StorageCmd.AddCommand(storageMigrateCmd, storageMigrationsCmd, storageStatusCmd, storagePauseCmd, storageResumeCmd)
+}
+
+func storageClient() (*client.Client, string, error) {
+	token := os.Getenv(adminTokenEnv)
+	if token == "" {
+		return nil, "", fmt.Errorf("%s is not set. Storage commands need the server's root admin token", adminTokenEnv)
+	}
+	cfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Server == "" {
+		return nil, "", fmt.Errorf("no server configured. Run 'otaship login' first")
+	}
+	return &client.Client{BaseURL: cfg.Server}, token, nil
+}
+
+func runStorageMigrate(cmd *cobra.Command, args []string) error {
+	c, token, err := storageClient()
+	if err != nil {
+		return err
+	}
+
+	scope := "every project"
+	if storageProjectFlag != "" {
+		scope = "project " + storageProjectFlag
+	}
+	if storageDeleteSourceFlag && ui.IsInteractive() {
+		ok, err := ui.Confirm(fmt.Sprintf("Delete %s's objects from %s once they are copied to %s?", scope, storageFromFlag, storageToFlag))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			ui.Info.Println("Cancelled")
+			return nil
+		}
+	}
+
+	m, err := c.CreateStorageMigration(token, &client.StorageMigrationRequest{
+		ProjectID:    storageProjectFlag,
+		From:         storageFromFlag,
+		To:           storageToFlag,
+		DeleteSource: storageDeleteSourceFlag,
+	})
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Migrating %d objects of %s from %s to %s\n", m.TotalObjects, scope, m.From, m.To)
+	ui.Info.Printf("Migration ID: %s\n", m.ID)
+
+	if storageWaitFlag {
+		return waitForStorageMigration(c, token, m)
+	}
+	ui.Info.Printf("Follow it with 'otaship storage status %s --wait'\n", m.ID)
+	return nil
+}
+
+func runStorageMigrations(cmd *cobra.Command, args []string) error {
+	c, token, err := storageClient()
+	if err != nil {
+		return err
+	}
+
+	migrations, err := c.ListStorageMigrations(token)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		ui.Info.Println("No storage migrations found")
+		return nil
+	}
+
+	tableData := [][]string{{"ID", "CREATED", "FROM", "TO", "PROJECT", "STATUS", "PROGRESS"}}
+	for _, m := range migrations {
+		project := m.ProjectID
+		if project == "" {
+			project = "all"
+		}
+		tableData = append(tableData, []string{
+			m.ID,
+			time.UnixMilli(m.CreatedAt).Local().Format("2006-01-02 15:04"),
+			m.From,
+			m.To,
+			project,
+			m.Status,
+			fmt.Sprintf("%d/%d", m.CopiedObjects+m.FailedObjects, m.TotalObjects),
+		})
+	}
+	pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	return nil
+}
+
+func runStorageStatus(cmd *cobra.Command, args []string) error {
+	c, token, err := storageClient()
+	if err != nil {
+		return err
+	}
+
+	m, err := c.GetStorageMigration(token, args[0])
+	if err != nil {
+		return err
+	}
+	if storageWaitFlag {
+		return waitForStorageMigration(c, token, m)
+	}
+	printStorageMigration(m)
+	return nil
+}
+
+func runStoragePause(cmd *cobra.Command, args []string) error {
+	c, token, err := storageClient()
+	if err != nil {
+		return err
+	}
+
+	m, err := c.PauseStorageMigration(token, args[0])
+	if err != nil {
+		return err
+	}
+	ui.Success.Printf("Migration paused after %d of %d objects\n", m.CopiedObjects+m.FailedObjects, m.TotalObjects)
+	return nil
+}
+
+func runStorageResume(cmd *cobra.Command, args []string) error {
+	c, token, err := storageClient()
+	if err != nil {
+		return err
+	}
+
+	m, err := c.ResumeStorageMigration(token, args[0])
+	if err != nil {
+		return err
+	}
+	ui.Success.Println("Migration resumed")
+	if storageWaitFlag {
+		return waitForStorageMigration(c, token, m)
+	}
+	printStorageMigration(m)
+	return nil
+}
+
+// waitForStorageMigration polls a migration until it is no longer running.
+// Interrupting the CLI leaves the migration running on the server.
+func waitForStorageMigration(c *client.Client, token string, m *client.StorageMigration) error {
+	spinner, _ := ui.StartSpinner(storageProgress(m))
+	for m.Status == "running" {
+		time.Sleep(storageMigrationPollInterval)
+		next, err := c.GetStorageMigration(token, m.ID)
+		if err != nil {
+			ui.StopSpinner(spinner)
+			return err
+		}
+		m = next
+		spinner.UpdateText(storageProgress(m))
+	}
+	ui.StopSpinner(spinner)
+
+	printStorageMigration(m)
+	if m.Status == "failed" {
+		return fmt.Errorf("migration failed. Fix the cause and run 'otaship storage resume %s'", m.ID)
+	}
+	return nil
+}
+
+func storageProgress(m *client.StorageMigration) string {
+	return fmt.Sprintf("Copied %d of %d objects (%s)...", m.CopiedObjects, m.TotalObjects, formatSize(m.CopiedBytes))
+}
+
+func printStorageMigration(m *client.StorageMigration) {
+	project := m.ProjectID
+	if project == "" {
+		project = "all projects"
+	}
+	ui.Info.Printf("Migration %s: %s → %s (%s)\n", m.ID, m.From, m.To, project)
+	ui.Info.Printf("Status: %s\n", m.Status)
+	ui.Info.Printf("Copied: %d of %d objects, %s\n", m.CopiedObjects, m.TotalObjects, formatSize(m.CopiedBytes))
+	if m.FailedObjects > 0 {
+		ui.Warning.Printf("%d objects could not be copied and stay on %s. Start another migration once this one stops to retry them\n", m.FailedObjects, m.From)
+	}
+	if m.LastError != "" {
+		ui.Warning.Printf("Last error: %s\n", m.LastError)
+	}
+	if m.DeleteSource {
+		ui.Info.Printf("Source objects are deleted from %s once the GC grace period has passed\n", m.From)
+	}
+	if m.CompletedAt > 0 {
+		ui.Info.Printf("Completed: %s\n", time.UnixMilli(m.CompletedAt).Local().Format("2006-01-02 15:04"))
+	}
+}
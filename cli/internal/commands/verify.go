@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vknow360/otaship/cli/internal/client"
+	"github.com/vknow360/otaship/cli/internal/codesign"
+	"github.com/vknow360/otaship/cli/internal/config"
+	"github.com/vknow360/otaship/cli/internal/ui"
+)
+
+var (
+	verifyCertFlag     string
+	verifyPlatformFlag string
+	verifyChannelFlag  string
+	verifyRuntimeFlag  string
+	verifyKeyIDFlag    string
+	verifyAlgFlag      string
+)
+
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the served manifest is signed by a certificate",
+	Long: "Requests the manifest the server currently serves to devices and verifies its\n" +
+		"expo-signature against a code signing certificate, as expo-updates would.",
+	RunE: runVerify,
+}
+
+func init() {
+	VerifyCmd.Flags().StringVar(&verifyCertFlag, "cert", "", "Path to the code signing certificate (PEM)")
+	VerifyCmd.Flags().StringVar(&verifyPlatformFlag, "platform", "android", "Platform: android or ios")
+	VerifyCmd.Flags().StringVar(&verifyChannelFlag, "channel", "", "Channel (default from config)")
+	VerifyCmd.Flags().StringVar(&verifyRuntimeFlag, "runtime-version", "", "Runtime version (default from app.json)")
+	VerifyCmd.Flags().StringVar(&verifyKeyIDFlag, "keyid", "", "Key ID to request (default: the project's primary key)")
+	VerifyCmd.Flags().StringVar(&verifyAlgFlag, "alg", "", "Signing algorithm to request")
+	VerifyCmd.MarkFlagRequired("cert")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	projectCfg, err := config.LoadProjectConfig()
+	if err != nil || projectCfg == nil {
+		return fmt.Errorf("not in an OTAShip project. Run 'otaship init'")
+	}
+
+	cfg, err := config.LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	certPEM, err := os.ReadFile(verifyCertFlag)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	cert, err := codesign.ParseCertificate(certPEM)
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	runtimeVersion := verifyRuntimeFlag
+	if runtimeVersion == "" {
+		projectRoot, err := config.FindProjectRoot()
+		if err != nil {
+			return fmt.Errorf("not in an Expo project (no app.json found)")
+		}
+		appJson, err := readAppJson(projectRoot)
+		if err != nil {
+			return err
+		}
+		runtimeVersion = appJson.Expo.RuntimeVersion
+	}
+
+	channel := verifyChannelFlag
+	if channel == "" {
+		channel = projectCfg.Channel
+	}
+
+	expect := "sig"
+	if verifyKeyIDFlag != "" {
+		expect += fmt.Sprintf(`, keyid="%s"`, verifyKeyIDFlag)
+	}
+	if verifyAlgFlag != "" {
+		expect += fmt.Sprintf(`, alg="%s"`, verifyAlgFlag)
+	}
+
+	c := &client.Client{BaseURL: cfg.Server}
+	part, err := c.GetManifest(&client.ManifestRequest{
+		ProjectID:       projectCfg.ProjectID,
+		Platform:        verifyPlatformFlag,
+		RuntimeVersion:  runtimeVersion,
+		Channel:         channel,
+		ExpectSignature: expect,
+	})
+	if err != nil {
+		return err
+	}
+
+	if part.Signature == "" {
+		return fmt.Errorf("the server did not sign the %s. Configure a signing key for this project", part.Name)
+	}
+
+	if err := codesign.VerifyHeader(cert, part.Signature, part.Body); err != nil {
+		return fmt.Errorf("%s signature is not valid for this certificate: %w", part.Name, err)
+	}
+
+	params := codesign.ParseHeader(part.Signature)
+	alg := params["alg"]
+	if alg == "" {
+		alg = codesign.AlgRSAPKCS1SHA256
+	}
+	ui.Success.Printf("Signature valid (%s, keyid %q, %s)\n", part.Name, params["keyid"], alg)
+	return nil
+}
@@ -11,22 +11,35 @@ import (
 
 var WhoAmICmd = &cobra.Command{
 	Use:   "whoami",
-	Short: "Show current project config",
+	Short: "Show the logged-in user and current project",
 	RunE:  runWhoami,
 }
 
 func runWhoami(cmd *cobra.Command, args []string) error {
-	cfg, err := config.LoadProjectConfig()
+	gCfg, err := config.LoadGlobalConfig()
 	if err != nil {
 		return err
 	}
-	if cfg == nil {
-		return fmt.Errorf("no project config found. Run 'otaship link'")
+	if gCfg.Token != "" {
+		c := client.Client{BaseURL: gCfg.Server}
+		me, err := c.CurrentUser(gCfg.Token)
+		if err != nil {
+			ui.Warning.Printf("Session for %s is no longer valid: %v\n", gCfg.User, err)
+		} else if me.User != nil {
+			ui.Info.Printf("User: %s\n", me.User.Email)
+			for _, org := range me.Organizations {
+				ui.Info.Printf("  %s (%s): %s\n", org.Name, org.Slug, org.Role)
+			}
+		}
 	}
-	gCfg, err := config.LoadGlobalConfig()
+
+	cfg, err := config.LoadProjectConfig()
 	if err != nil {
 		return err
 	}
+	if cfg == nil {
+		return fmt.Errorf("no project config found. Run 'otaship link'")
+	}
 	apiKey := gCfg.Projects[cfg.ProjectID]
 	if apiKey == "" {
 		return fmt.Errorf("no API key found. Run 'otaship link'")
@@ -12,6 +12,9 @@ type GlobalConfig struct {
 	Version  string            `json:"version"`
 	Server   string            `json:"server"`
 	Projects map[string]string `json:"projects"`
+	// Token is the session of the user logged in to Server, if any.
+	Token string `json:"token,omitempty"`
+	User  string `json:"user,omitempty"`
 }
 
 type ProjectConfig struct {